@@ -1,83 +1,377 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alexferrari88/code2context/internal/appconfig"
+	"github.com/alexferrari88/code2context/internal/archiveutils"
+	"github.com/alexferrari88/code2context/internal/collector"
+	"github.com/alexferrari88/code2context/internal/gitutils"
 	"github.com/alexferrari88/code2context/internal/processor"
 	"github.com/alexferrari88/code2context/internal/utils"
 	"github.com/spf13/cobra"
 )
 
 var (
-	outputFile      string
-	gitRef          string
-	includeTree     bool // Default true
-	noTree          bool // explicit --no-tree
-	skipAuxFiles    bool
-	excludeDirsRaw  string
-	excludeExtsRaw  string
-	excludeGlobsRaw string
-	maxFileSizeStr  string
-	verbose         bool
+	outputFile         string
+	outputDir          string
+	mkdirOutputDir     bool
+	outputWithin       string
+	force              bool
+	gitRef             string
+	diffBase           string
+	includeTree        bool // Default true
+	noTree             bool // explicit --no-tree
+	fullTree           bool
+	includeTOC         bool
+	withGitInfo        bool
+	dropOutliers       bool
+	fromStdin          bool
+	gitignoreOutput    bool
+	noEmptyOutput      bool
+	gzipOutput         bool
+	showDiff           bool
+	exportIgnore       bool
+	excludeContentRaw  string
+	headLines          int
+	maxLineLength      int
+	modifiedSinceRaw   string
+	timeoutRaw         string
+	prettyJSON         bool
+	tailLines          int
+	keepDirsRaw        []string
+	noDefaultExcludes  bool
+	includeLockfiles   bool
+	ownVendorRaw       []string
+	useCache           bool // explicit --cache
+	noCache            bool // explicit --no-cache (default)
+	skipAuxFiles       bool
+	noSkipAuxFiles     bool // explicit --no-skip-aux-files
+	presetName         string
+	excludeEmpty       bool
+	followSymlinks     bool
+	showSymlinkDirs    bool
+	headerStats        bool
+	annotate           bool
+	excludeDirsRaw     []string
+	excludeExtsRaw     []string
+	omitContentExtsRaw []string
+	includeLangRaw     []string
+	excludeLangRaw     []string
+	excludeGlobsRaw    []string
+	excludeRegexRaw    []string
+	excludeDirsRegex   []string
+	ignoreFilesRaw     []string
+	maxFileSizeStr     string
+	minFileSizeStr     string
+	verbose            bool
+	quiet              bool
+	logLevelRaw        string
+	showProgress       bool
+	sortOrderRaw       string
+	prependText        string
+	appendText         string
+	formatRaw          string
+	interactive        bool
+	watch              bool
+	clipboard          bool
+	splitSizeStr       string
+	countTokens        bool
+	maxTokens          int
+	countOnly          bool
+	dedupe             bool
+	excludeVendored    bool
+	excludeGenerated   bool
+	excludeMinified    bool
+	excludeTests       bool
+	maxDepth           int
+	languageStats      bool
+	stripComments      bool
+	renderNotebooks    bool
+	notebookMarkdown   bool
+	collapseBlankLines bool
+	skipHidden         bool
+	caseInsensitive    bool
+	filesFrom          string
+	onlyTracked        bool
+	includeGitignored  bool
+	repoRoot           bool
+	repoRootFallback   bool
+	noAncestorIgnore   bool
+	reproducible       bool
+	validUTF8          bool
+	decimalSizes       bool
+	pathStyleRaw       string
+	rootLabel          string
+	relTo              string
+	fenceLangRaw       string
+	treeFenceLang      string
+	sectionSeparator   string
+	treeSortRaw        string
+	tokenizerPath      string
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "c2c <path_or_url>",
 	Short: "c2c (code2context) aggregates codebase files into a single text file for LLM context.",
-	Long: `c2c is a CLI tool that processes a local codebase or a public GitHub repository.
+	Long: `c2c is a CLI tool that processes a local codebase, an archive, or a public GitHub repository.
 It concatenates the content of selected files into a single .txt output.
 The tool intelligently skips common non-code files, respects .gitignore (including nested ones),
 and allows for custom exclusion rules. An optional file tree can be included at the top.`,
 	Example: `  c2c . -o my_project_context.txt
   c2c ./my_module --no-tree
   c2c https://github.com/spf13/cobra --ref v1.7.0
+  c2c . --diff-base main
+  printf '=== main.go ===\npackage main\n' | c2c --from-stdin -o -
+  c2c . --split-size 100KB
+  c2c . --prepend "You are reviewing the following codebase:" --append prompts/review.txt
   c2c . --exclude-dirs "docs,examples" --exclude-exts ".log,.tmp"
   c2c . --skip-aux-files --max-file-size 500KB --exclude-patterns "internal/*_test.go"`,
-	Args: cobra.ExactArgs(1),
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		utils.InitLogger(verbose)
+	Args: func(cmd *cobra.Command, args []string) error {
+		if fromStdin {
+			if len(args) > 0 {
+				return usageErrorf("--from-stdin does not take a source argument (the files come from stdin)")
+			}
+			return nil
+		}
+		if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+			return &usageError{err: err}
+		}
+		return nil
+	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// An explicit --log-level wins over the -v/--verbose (debug) and -q/--quiet (error) shortcuts.
+		level := slog.LevelInfo
+		switch {
+		case logLevelRaw != "":
+			parsedLevel, err := utils.ParseLogLevel(logLevelRaw)
+			if err != nil {
+				return usageErrorf("invalid --log-level: %w", err)
+			}
+			level = parsedLevel
+		case verbose && quiet:
+			return usageErrorf("--verbose cannot be combined with --quiet")
+		case verbose:
+			level = slog.LevelDebug
+		case quiet:
+			level = slog.LevelError
+		}
+		utils.InitLogger(level)
+		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		source := args[0]
+		source := "-"
+		if !fromStdin {
+			source = args[0]
+		}
+
+		if presetName != "" {
+			if err := applyPreset(cmd, presetName); err != nil {
+				return usageErrorf("invalid --preset: %w", err)
+			}
+		}
+
+		parseFileSize := utils.ParseFileSize
+		if decimalSizes {
+			parseFileSize = utils.ParseFileSizeDecimal
+		}
+
+		maxFileSize, err := parseFileSize(maxFileSizeStr)
+		if err != nil {
+			return usageErrorf("invalid max file size: %w", err)
+		}
 
-		maxFileSize, err := utils.ParseFileSize(maxFileSizeStr)
+		minFileSize, err := parseFileSize(minFileSizeStr)
 		if err != nil {
-			return fmt.Errorf("invalid max file size: %w", err)
+			return usageErrorf("invalid min file size: %w", err)
+		}
+		if headLines < 0 || tailLines < 0 {
+			return usageErrorf("--head and --tail must not be negative")
+		}
+		if (headLines > 0 || tailLines > 0) && maxFileSize <= 0 {
+			return usageErrorf("--head and --tail require a --max-file-size limit (they apply to larger files)")
+		}
+		var modifiedSince time.Time
+		if modifiedSinceRaw != "" {
+			if modifiedSince, err = utils.ParseModifiedSince(modifiedSinceRaw, time.Now()); err != nil {
+				return usageErrorf("invalid --modified-since: %w", err)
+			}
+		}
+		if maxLineLength < 0 {
+			return usageErrorf("--max-line-length must not be negative")
+		}
+		var timeout time.Duration
+		if timeoutRaw != "" {
+			if timeout, err = time.ParseDuration(timeoutRaw); err != nil {
+				return usageErrorf("invalid --timeout: %w", err)
+			}
+			if timeout < 0 {
+				return usageErrorf("invalid --timeout %q: must not be negative", timeoutRaw)
+			}
+		}
+		if maxFileSize > 0 && minFileSize > maxFileSize {
+			return usageErrorf("invalid min file size: %s is larger than max file size %s", minFileSizeStr, maxFileSizeStr)
+		}
+
+		if interactive && watch {
+			return usageErrorf("--interactive cannot be combined with --watch")
+		}
+		if interactive && filesFrom == "-" {
+			return usageErrorf("--interactive cannot be combined with --files-from - (both read from stdin)")
+		}
+		if fromStdin && (interactive || filesFrom == "-") {
+			return usageErrorf("--from-stdin cannot be combined with --interactive or --files-from - (all read from stdin)")
+		}
+		if fromStdin && (watch || diffBase != "" || onlyTracked || filesFrom != "") {
+			return usageErrorf("--from-stdin cannot be combined with --watch, --diff-base, --only-tracked, or --files-from")
+		}
+		if watch && (gitutils.IsGitURL(source) || archiveutils.IsArchive(source)) {
+			return usageErrorf("--watch requires a local source directory, not a Git URL or an archive")
+		}
+
+		if notebookMarkdown && !renderNotebooks {
+			return usageErrorf("--notebook-markdown requires --render-notebooks")
+		}
+		if repoRootFallback && !repoRoot {
+			return usageErrorf("--repo-root-fallback requires --repo-root")
+		}
+		if mkdirOutputDir && outputDir == "" {
+			return usageErrorf("--mkdir requires --output-dir")
 		}
 
-		var excludeDirs []string
-		if excludeDirsRaw != "" {
-			excludeDirs = strings.Split(excludeDirsRaw, ",")
-			for i, dir := range excludeDirs {
-				excludeDirs[i] = strings.TrimSpace(dir)
+		if maxDepth < 0 {
+			return usageErrorf("invalid --max-depth: %d must not be negative", maxDepth)
+		}
+
+		var splitSize int64
+		if splitSizeStr != "" {
+			splitSize, err = parseFileSize(splitSizeStr)
+			if err != nil {
+				return usageErrorf("invalid split size: %w", err)
 			}
 		}
+		if clipboard && (watch || splitSize > 0) {
+			return usageErrorf("--clipboard cannot be combined with --watch or --split-size")
+		}
+		if !cmd.Flags().Changed("gzip") {
+			gzipOutput = strings.HasSuffix(strings.ToLower(outputFile), ".gz") // Auto-detected from the output name
+		}
+		if gzipOutput && (outputFile == "-" || clipboard) {
+			return usageErrorf("--gzip cannot be combined with \"-o -\" or --clipboard (only output files are compressed)")
+		}
+		if showDiff && (outputFile == "-" || watch) {
+			return usageErrorf("--show-diff cannot be combined with \"-o -\" or --watch (it compares with the output file once)")
+		}
+		if countOnly && (watch || clipboard) {
+			return usageErrorf("--count-only cannot be combined with --watch or --clipboard (no output is written)")
+		}
 
-		var excludeExts []string
-		if excludeExtsRaw != "" {
-			excludeExts = strings.Split(excludeExtsRaw, ",")
-			for i, ext := range excludeExts {
-				trimmedExt := strings.TrimSpace(ext)
-				if !strings.HasPrefix(trimmedExt, ".") && trimmedExt != "" {
-					excludeExts[i] = "." + trimmedExt
-				} else {
-					excludeExts[i] = trimmedExt
-				}
+		if maxTokens < 0 {
+			return usageErrorf("invalid --max-tokens: %d must not be negative", maxTokens)
+		}
+		var tokenCounter utils.TokenCounter
+		if countTokens || countOnly || maxTokens > 0 {
+			tokenCounter, err = utils.NewTokenCounter(tokenizerPath)
+			if err != nil {
+				return usageErrorf("invalid --tokenizer: %w", err)
+			}
+		} else if tokenizerPath != "" {
+			return usageErrorf("--tokenizer requires --count-tokens, --count-only, or --max-tokens")
+		}
+
+		sortOrder, err := processor.ParseSortOrder(sortOrderRaw)
+		if err != nil {
+			return usageErrorf("invalid --sort: %w", err)
+		}
+
+		outputFormat, err := processor.ParseOutputFormat(formatRaw)
+		if err != nil {
+			return usageErrorf("invalid --format: %w", err)
+		}
+
+		pathStyle, err := processor.ParsePathStyle(pathStyleRaw)
+		if err != nil {
+			return usageErrorf("invalid --path-style: %w", err)
+		}
+
+		fenceLang, err := processor.ParseFenceLang(fenceLangRaw)
+		if err != nil {
+			return usageErrorf("invalid --fence-lang: %w", err)
+		}
+		treeSort, err := processor.ParseTreeSort(treeSortRaw)
+		if err != nil {
+			return usageErrorf("invalid --tree-sort: %w", err)
+		}
+
+		if cmd.Flags().Changed("section-separator") && outputFormat != processor.OutputFormatText && outputFormat != processor.OutputFormatMarkdown {
+			return usageErrorf("--section-separator only applies to --format txt and md")
+		}
+		separator := separatorEscapes.Replace(sectionSeparator)
+		if strings.ContainsAny(treeFenceLang, "` \t\r\n") {
+			return usageErrorf("invalid --tree-fence-lang %q: must be a single word without backticks", treeFenceLang)
+		}
+
+		excludeDirs := splitListFlag(excludeDirsRaw)
+
+		excludeExts := parseExtensionList(excludeExtsRaw)
+		omitContentExts := parseExtensionList(omitContentExtsRaw)
+
+		var includeExts []string
+		if includeLangs := splitListFlag(includeLangRaw); len(includeLangs) > 0 {
+			includeExts, err = collector.ExtensionsForLanguages(includeLangs)
+			if err != nil {
+				return usageErrorf("invalid --include-lang: %w", err)
+			}
+		}
+
+		if excludeLangs := splitListFlag(excludeLangRaw); len(excludeLangs) > 0 {
+			langExts, err := collector.ExtensionsForLanguages(excludeLangs)
+			if err != nil {
+				return usageErrorf("invalid --exclude-lang: %w", err)
 			}
+			excludeExts = append(excludeExts, langExts...)
+		}
+
+		excludeGlobs := splitListFlag(excludeGlobsRaw)
+
+		if excludeTests {
+			// Preset: composes with the user's own exclusions
+			excludeGlobs = append(excludeGlobs, appconfig.GetDefaultTestFilePatterns()...)
+			excludeDirs = append(excludeDirs, appconfig.GetDefaultTestDirs()...)
+		}
+
+		excludeRegexes, err := parseRegexList(excludeRegexRaw)
+		if err != nil {
+			return usageErrorf("invalid --exclude-regex: %w", err)
+		}
+		excludeDirRegexes, err := parseRegexList(excludeDirsRegex)
+		if err != nil {
+			return usageErrorf("invalid --exclude-dirs-regex: %w", err)
 		}
 
-		var excludeGlobs []string
-		if excludeGlobsRaw != "" {
-			excludeGlobs = strings.Split(excludeGlobsRaw, ",")
-			for i, glob := range excludeGlobs {
-				excludeGlobs[i] = strings.TrimSpace(glob)
+		if excludeContentRaw != "" {
+			if _, err := regexp.Compile(excludeContentRaw); err != nil {
+				return usageErrorf("invalid --exclude-content-regex: %w", err)
 			}
 		}
 
+		extraIgnoreFiles := splitListFlag(ignoreFilesRaw)
+
+		if includeGitignored && len(extraIgnoreFiles) > 0 {
+			return usageErrorf("--ignore-files cannot be combined with --include-gitignored (no ignore files are applied)")
+		}
+
 		// Determine final includeTree value
 		finalIncludeTree := includeTree     // Default to true via flag default
 		if cmd.Flags().Changed("no-tree") { // If --no-tree was explicitly used
@@ -86,16 +380,111 @@ and allows for custom exclusion rules. An optional file tree can be included at
 			finalIncludeTree = includeTree
 		}
 
+		// Determine final clone cache value: disabled unless --cache is given, --no-cache wins.
+		finalUseCache := useCache
+		if cmd.Flags().Changed("no-cache") && noCache {
+			finalUseCache = false
+		}
+
+		// Determine final skipAuxFiles value. A preset only sets --skip-aux-files if it was not given explicitly,
+		// so Changed tells an explicit --skip-aux-files=false (or --no-skip-aux-files, which wins) from the default.
+		finalSkipAuxFiles := skipAuxFiles             // Default false, or the preset's value
+		if cmd.Flags().Changed("no-skip-aux-files") { // If --no-skip-aux-files was explicitly used
+			finalSkipAuxFiles = !noSkipAuxFiles
+		} else if cmd.Flags().Changed("skip-aux-files") { // Explicit --skip-aux-files[=true|false], or set by a preset
+			finalSkipAuxFiles = skipAuxFiles
+		}
+
+		// Progress is shown automatically when stderr is a terminal, unless --progress is set explicitly.
+		finalShowProgress := utils.IsTerminal(os.Stderr)
+		if cmd.Flags().Changed("progress") {
+			finalShowProgress = showProgress
+		}
+
+		// Names are matched case-insensitively on case-insensitive platforms, unless --case-insensitive is set explicitly.
+		finalCaseInsensitive := utils.CaseInsensitiveOS()
+		if cmd.Flags().Changed("case-insensitive") {
+			finalCaseInsensitive = caseInsensitive
+		}
+
 		cfg := processor.Config{
 			SourcePath:                     source,
+			FromStdin:                      fromStdin,
+			GitignoreOutput:                gitignoreOutput,
+			NoEmptyOutput:                  noEmptyOutput,
+			Gzip:                           gzipOutput,
+			ShowDiff:                       showDiff,
+			RespectExportIgnore:            exportIgnore,
 			GitRef:                         gitRef,
+			DiffBase:                       diffBase,
+			FilesFrom:                      filesFrom,
+			OnlyTracked:                    onlyTracked,
+			IncludeGitignored:              includeGitignored,
+			RepoRoot:                       repoRoot,
+			RepoRootFallback:               repoRootFallback,
+			Force:                          force,
+			UseAncestorGitignore:           !noAncestorIgnore,
+			UseCloneCache:                  finalUseCache,
 			OutputFile:                     outputFile,
+			OutputDir:                      outputDir,
+			CreateOutputDir:                mkdirOutputDir,
+			OutputWithin:                   outputWithin,
 			IncludeTree:                    finalIncludeTree,
-			SkipAuxFiles:                   skipAuxFiles,
+			FullTree:                       fullTree,
+			IncludeTOC:                     includeTOC,
+			WithGitInfo:                    withGitInfo,
+			DropOutliers:                   dropOutliers,
+			SkipAuxFiles:                   finalSkipAuxFiles,
+			SkipEmptyFiles:                 excludeEmpty,
+			SkipHidden:                     skipHidden,
+			CaseInsensitive:                finalCaseInsensitive,
+			FollowSymlinks:                 followSymlinks,
+			ShowSymlinkDirs:                showSymlinkDirs,
+			MaxDepth:                       maxDepth,
+			HeaderStats:                    headerStats,
+			Annotate:                       annotate,
+			ExtraIgnoreFiles:               extraIgnoreFiles,
+			ShowProgress:                   finalShowProgress,
+			SortOrder:                      sortOrder,
+			Prepend:                        prependText,
+			Append:                         appendText,
+			OutputFormat:                   outputFormat,
+			PathStyle:                      pathStyle,
+			RootLabel:                      rootLabel,
+			RelativeTo:                     relTo,
+			FenceLang:                      fenceLang,
+			TreeSort:                       treeSort,
+			TreeFenceLang:                  treeFenceLang,
+			SectionSeparator:               &separator,
+			Interactive:                    interactive,
+			Watch:                          watch,
+			Timeout:                        timeout,
+			SplitSize:                      splitSize,
+			CountTokens:                    countTokens,
+			MaxTokens:                      maxTokens,
+			CountOnly:                      countOnly,
+			TokenCounter:                   tokenCounter,
 			UserExcludeDirs:                excludeDirs,
 			UserExcludeExts:                excludeExts,
+			UserIncludeExts:                includeExts,
+			OmitContentExts:                omitContentExts,
+			Dedupe:                         dedupe,
+			StripComments:                  stripComments,
+			RenderNotebooks:                renderNotebooks,
+			NotebookMarkdown:               notebookMarkdown,
+			CollapseBlankLines:             collapseBlankLines,
+			Reproducible:                   reproducible,
+			ValidUTF8:                      validUTF8,
 			UserExcludeGlobs:               excludeGlobs,
+			UserExcludeRegexes:             excludeRegexes,
+			UserExcludeDirRegexes:          excludeDirRegexes,
+			ExcludeContentRegex:            excludeContentRaw,
 			MaxFileSize:                    maxFileSize,
+			MinFileSize:                    minFileSize,
+			ModifiedSince:                  modifiedSince,
+			HeadLines:                      headLines,
+			MaxLineLength:                  maxLineLength,
+			TailLines:                      tailLines,
 			DefaultExcludeDirs:             appconfig.GetDefaultExcludedDirs(),
 			DefaultMediaExts:               appconfig.GetDefaultMediaExtensions(),
 			DefaultArchiveExts:             appconfig.GetDefaultArchiveExtensions(),
@@ -104,6 +493,33 @@ and allows for custom exclusion rules. An optional file tree can be included at
 			DefaultMiscellaneousFileNames:  appconfig.GetDefaultMiscellaneousFileNames(),
 			DefaultMiscellaneousExtensions: appconfig.GetDefaultMiscellaneousExtensions(),
 			DefaultAuxExts:                 appconfig.GetDefaultAuxFileExtensions(),
+			ExcludeVendored:                excludeVendored,
+			ExcludeGenerated:               excludeGenerated,
+			ExcludeMinified:                excludeMinified,
+			OwnVendorPrefixes:              splitListFlag(ownVendorRaw),
+			DefaultVendoredDirs:            appconfig.GetDefaultVendoredDirs(),
+			DefaultVendoredFilePatterns:    appconfig.GetDefaultVendoredFilePatterns(),
+			Logger:                         slog.Default(), // Configured by PersistentPreRun from the log flags
+		}
+
+		if prettyJSON {
+			cfg.Transformers = append(cfg.Transformers, processor.PrettyJSON)
+		}
+
+		if noDefaultExcludes {
+			// Drop all built-in exclusions; opt-in presets (--skip-aux-files, --exclude-vendored) still apply.
+			cfg.DefaultExcludeDirs = nil
+			cfg.DefaultMediaExts = nil
+			cfg.DefaultArchiveExts = nil
+			cfg.DefaultExecExts = nil
+			cfg.DefaultLockfilePatterns = nil
+			cfg.DefaultMiscellaneousFileNames = nil
+			cfg.DefaultMiscellaneousExtensions = nil
+		} else if keepDirs := splitListFlag(keepDirsRaw); len(keepDirs) > 0 {
+			cfg.DefaultExcludeDirs = withoutNames(cfg.DefaultExcludeDirs, keepDirs)
+		}
+		if includeLockfiles {
+			cfg.DefaultLockfilePatterns = nil // For dependency context; other exclusions (e.g. --skip-aux-files) still apply
 		}
 
 		proc, err := processor.New(cfg)
@@ -112,41 +528,423 @@ and allows for custom exclusion rules. An optional file tree can be included at
 		}
 
 		slog.Info("Starting processing...", "source", source)
-		err = proc.Process()
-		if err != nil {
+		// With "-o -" the output is streamed to stdout, or only collected for the clipboard.
+		toStdout := outputFile == "-"
+		var clipboardContent bytes.Buffer
+		if toStdout {
+			var stream io.Writer = os.Stdout
+			if clipboard {
+				stream = &clipboardContent
+			}
+			err = proc.ProcessTo(stream)
+		} else {
+			err = proc.Process()
+		}
+		noFiles := errors.Is(err, processor.ErrNoFiles) // Only a warning, already logged by the processor
+		if err != nil && !noFiles {
 			// Error should be logged by the processor if it's a processing error.
 			// This return will be handled by Cobra (printed to stderr).
+			if hint := cloneErrorHint(err); hint != "" {
+				return fmt.Errorf("%w\n%s", err, hint)
+			}
+			return err
+		}
+		if countOnly {
+			stats := proc.GetCountStats()
+			if outputFormat == processor.OutputFormatJSON || outputFormat == processor.OutputFormatJSONL {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetEscapeHTML(false)
+				return encoder.Encode(stats)
+			}
+			printCountStats(os.Stdout, stats, tokenCounter.Name())
+			return nil
+		}
+		wroteOutput := !noFiles || !noEmptyOutput
+		if clipboard && wroteOutput {
+			if !toStdout {
+				content, readErr := os.ReadFile(proc.GetFinalOutputFile())
+				if readErr != nil {
+					return fmt.Errorf("failed to read output for the clipboard: %w", readErr)
+				}
+				clipboardContent.Write(content)
+			}
+			if err := utils.SetClipboardFunc(clipboardContent.String()); err != nil {
+				return fmt.Errorf("failed to copy output to the clipboard: %w", err)
+			}
+			slog.Info("Output copied to the clipboard", "bytes", clipboardContent.Len())
+		}
+		if languageStats {
+			printLanguageStats(os.Stderr, proc.GetLanguageStats())
+		}
+		if countTokens {
+			printTokenSummary(os.Stderr, proc.GetTokenCounts(), proc.GetTotalTokens(), tokenCounter.Name())
+		}
+		if diff := proc.GetOutputDiff(); diff != nil {
+			printOutputDiff(os.Stderr, diff)
+		}
+		result := proc.GetResult()
+		summary := []any{"included_files", result.IncludedFiles, "skipped", formatSkipped(result.SkippedByReason), "bytes", result.TotalBytes}
+		if toStdout || !wroteOutput {
+			slog.Info("Processing complete.", summary...)
+		} else if splitSize > 0 {
+			slog.Info("Processing complete.", append(summary, "output_files", proc.GetOutputFiles())...)
+		} else {
+			slog.Info("Processing complete.", append(summary, "output_file", result.OutputPath)...)
+		}
+		if noFiles {
+			// Not a failure: only reported through the exit code, without cobra's error and usage output
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
 			return err
 		}
-		slog.Info("Processing complete.", "output_file", proc.GetFinalOutputFile())
 		return nil
 	},
 }
 
+// presets maps the --preset names to the flag values they set. Flags given explicitly take precedence.
+var presets = map[string]map[string]string{
+	// Source code only: no auxiliary files (docs, configs, data) and no tests.
+	"minimal": {"skip-aux-files": "true", "exclude-tests": "true"},
+	// Documentation only: Markdown, reStructuredText, AsciiDoc, and plain text files.
+	"docs": {"skip-aux-files": "false", "include-lang": "markdown,restructuredtext,asciidoc,text"},
+	// Everything the default exclusions allow, including auxiliary files and tests.
+	"full": {"skip-aux-files": "false", "exclude-tests": "false"},
+	// The changes of a branch for code review: files changed since main, including their tests.
+	"review": {"diff-base": "main", "exclude-tests": "false", "header-stats": "true"},
+}
+
+// presetNames returns the sorted names of the presets.
+func presetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyPreset sets the flags of the named preset that were not given explicitly.
+func applyPreset(cmd *cobra.Command, name string) error {
+	values, ok := presets[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return fmt.Errorf("unknown preset '%s'. Supported: %s", name, strings.Join(presetNames(), ", "))
+	}
+	flags := cmd.Flags()
+	for flagName, value := range values {
+		if flags.Changed(flagName) {
+			continue // Explicit flags override the preset
+		}
+		if err := flags.Set(flagName, value); err != nil {
+			return fmt.Errorf("preset '%s' sets invalid value '%s' for --%s: %w", name, value, flagName, err)
+		}
+		slog.Debug("Preset sets flag", "preset", name, "flag", flagName, "value", value)
+	}
+	return nil
+}
+
+// cloneErrorHint returns advice for a classified clone failure, or "" for other errors.
+// Git's raw output is logged with -v.
+func cloneErrorHint(err error) string {
+	switch {
+	case errors.Is(err, gitutils.ErrGitNotInstalled):
+		return "Hint: install git, or pass a local path or an archive instead of a URL."
+	case errors.Is(err, gitutils.ErrRepoNotFound):
+		return "Hint: check the URL; private repositories also need credentials configured for git."
+	case errors.Is(err, gitutils.ErrAuthRequired):
+		return "Hint: the repository requires authentication; configure git credentials or an SSH key, or clone it yourself and pass the local path."
+	case errors.Is(err, gitutils.ErrRefNotFound):
+		return "Hint: check the --ref value; it must name an existing branch or tag."
+	default:
+		return ""
+	}
+}
+
+// withoutNames returns names without the entries listed in remove (surrounding whitespace ignored).
+func withoutNames(names, remove []string) []string {
+	removed := make(map[string]bool, len(remove))
+	for _, name := range remove {
+		removed[name] = true
+	}
+	kept := make([]string, 0, len(names))
+	for _, name := range names {
+		if !removed[name] {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// splitListFlag flattens the values of a repeatable list flag into trimmed, non-empty entries. A flag
+// given once is split on commas (e.g. --exclude-dirs "docs,build"); a flag given several times takes
+// each value as one entry, so names containing commas can be passed (e.g. --exclude-dirs "a,b" --exclude-dirs c).
+func splitListFlag(values []string) []string {
+	if len(values) == 1 {
+		values = strings.Split(values[0], ",")
+	}
+	var entries []string
+	for _, value := range values {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			entries = append(entries, trimmed)
+		}
+	}
+	return entries
+}
+
+// parseRegexList trims the values of a repeatable regular expression flag, dropping empty ones, and checks that
+// they compile. Unlike splitListFlag it never splits on commas, which regular expressions contain (e.g. ".{1,3}").
+func parseRegexList(values []string) ([]string, error) {
+	var exprs []string
+	for _, value := range values {
+		expr := strings.TrimSpace(value)
+		if expr == "" {
+			continue
+		}
+		if _, err := regexp.Compile(expr); err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+// parseExtensionList flattens the values of a list flag of extensions (see splitListFlag), adding a
+// leading dot where missing.
+func parseExtensionList(values []string) []string {
+	exts := splitListFlag(values)
+	for i, ext := range exts {
+		if !strings.HasPrefix(ext, ".") {
+			exts[i] = "." + ext
+		}
+	}
+	return exts
+}
+
+// printLanguageStats writes the number of files and bytes per language, largest first.
+func printLanguageStats(w io.Writer, stats []processor.LanguageStats) {
+	fmt.Fprintln(w, "Languages:")
+	for _, entry := range stats {
+		fileUnit := "files"
+		if entry.Files == 1 {
+			fileUnit = "file"
+		}
+		fmt.Fprintf(w, "  %-12s %5d %-5s %10s\n", entry.Language, entry.Files, fileUnit, utils.FormatBytes(uint64(entry.Bytes)))
+	}
+}
+
+// printCountStats writes the summary of --count-only: the totals, the languages, and the skipped entries.
+func printCountStats(w io.Writer, stats processor.CountStats, counterName string) {
+	fmt.Fprintf(w, "Files:   %d\n", stats.Files)
+	fmt.Fprintf(w, "Bytes:   %d (%s)\n", stats.Bytes, utils.FormatBytes(uint64(stats.Bytes)))
+	fmt.Fprintf(w, "Tokens:  %d (%s)\n", stats.Tokens, counterName)
+	fmt.Fprintf(w, "Skipped: %s\n", formatSkipped(stats.Skipped))
+	printLanguageStats(w, stats.Languages)
+}
+
+// formatSkipped renders the skipped entry counts as "reason=count" pairs, ordered by reason.
+func formatSkipped(skippedByReason map[string]int) string {
+	reasons := make([]string, 0, len(skippedByReason))
+	for reason := range skippedByReason {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	pairs := make([]string, len(reasons))
+	for i, reason := range reasons {
+		pairs[i] = fmt.Sprintf("%s=%d", reason, skippedByReason[reason])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// printTokenSummary writes the token count of each file followed by the total.
+func printTokenSummary(w io.Writer, counts []processor.FileTokenCount, total int, counterName string) {
+	width := len(strconv.Itoa(total))
+	fmt.Fprintf(w, "Token counts (%s):\n", counterName)
+	for _, count := range counts {
+		fmt.Fprintf(w, "  %*d  %s\n", width, count.Tokens, count.Path)
+	}
+	fmt.Fprintf(w, "  %*d  total (%d files)\n", width, total, len(counts))
+}
+
+// separatorEscapes turns the escape sequences accepted by --section-separator into the characters they stand for.
+var separatorEscapes = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t")
+
+// printOutputDiff writes the files added, removed, and changed since the previous output.
+func printOutputDiff(w io.Writer, diff *processor.OutputDiff) {
+	fmt.Fprintf(w, "Changes since the previous output: %d added, %d removed, %d changed, %d unchanged\n",
+		len(diff.Added), len(diff.Removed), len(diff.Changed), diff.Unchanged)
+	for _, path := range diff.Added {
+		fmt.Fprintf(w, "  + %s\n", path)
+	}
+	for _, path := range diff.Removed {
+		fmt.Fprintf(w, "  - %s\n", path)
+	}
+	for _, path := range diff.Changed {
+		fmt.Fprintf(w, "  ~ %s\n", path)
+	}
+}
+
+// Exit codes of the c2c command, so scripts can tell failures apart.
+const (
+	exitGeneric        = 1 // Any other failure
+	exitUsage          = 2 // Invalid arguments or flags
+	exitSourceNotFound = 3 // The local source path does not exist
+	exitCloneFailed    = 4 // The repository could not be cloned
+	exitOutputFailed   = 5 // The output could not be written
+	exitNoFiles        = 6 // No files matched the filters (a warning; the output is still written without --no-empty-output)
+	exitTimeout        = 7 // The --timeout expired before the output was written
+)
+
+// usageError is an error in the command line arguments or flags.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+func usageErrorf(format string, args ...any) error {
+	return &usageError{err: fmt.Errorf(format, args...)}
+}
+
+// exitCode maps an error returned by the command to the process exit code.
+func exitCode(err error) int {
+	var usageErr *usageError
+	var cloneErr *gitutils.CloneError
+	switch {
+	case errors.As(err, &usageErr):
+		return exitUsage
+	case errors.Is(err, processor.ErrSourceNotFound):
+		return exitSourceNotFound
+	case errors.As(err, &cloneErr), errors.Is(err, gitutils.ErrGitNotInstalled):
+		return exitCloneFailed
+	case errors.Is(err, processor.ErrOutputWrite):
+		return exitOutputFailed
+	case errors.Is(err, processor.ErrNoFiles):
+		return exitNoFiles
+	case errors.Is(err, processor.ErrTimeout):
+		return exitTimeout
+	default:
+		return exitGeneric
+	}
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		// Cobra already prints the error using the RunE pattern
-		os.Exit(1)
+		// Cobra already prints the error (to stderr) using the RunE pattern
+		os.Exit(exitCode(err))
 	}
 }
 
 func init() {
-	rootCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file name (default: <folder_name>.txt or <repo_name>.txt)")
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &usageError{err: err}
+	})
+	rootCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file name, or \"-\" for stdout (default: <folder_name>.<format> or <repo_name>.<format>)")
+	rootCmd.Flags().StringVar(&outputDir, "output-dir", "", "Directory for the default-named output file (ignored if -o is given)")
+	rootCmd.Flags().BoolVar(&mkdirOutputDir, "mkdir", false, "Create the --output-dir directory if it does not exist")
+	rootCmd.Flags().StringVar(&outputWithin, "output-within", "", "Refuse to write the output file anywhere but inside this directory, with symbolic links resolved (e.g., \".\" in CI)")
+	rootCmd.Flags().BoolVar(&showDiff, "show-diff", false, "Print to stderr which files were added, removed, or changed compared to the previous output at the same path")
+	rootCmd.Flags().BoolVar(&gzipOutput, "gzip", false, "Compress the output file with gzip (default: true if the -o name ends in \".gz\"; a default name gets \".gz\" appended)")
+	rootCmd.Flags().BoolVar(&noEmptyOutput, "no-empty-output", false, "Don't write the output file if no files match the filters (c2c exits with code 6 either way)")
+	rootCmd.Flags().BoolVar(&gitignoreOutput, "gitignore-output", false, "Add the output file to the nearest .gitignore (creating one if needed) when it is written inside the source")
+	rootCmd.Flags().BoolVar(&decimalSizes, "decimal-sizes", false, "Interpret KB, MB, GB, and TB in size flags as powers of 1000 (KiB, MiB, GiB, and TiB are always powers of 1024)")
+	rootCmd.Flags().StringVar(&splitSizeStr, "split-size", "", "Split the output into numbered parts of at most this size (e.g., \"100KB\" writes <name>.part1.txt, <name>.part2.txt, ...)")
+	rootCmd.Flags().StringVar(&formatRaw, "format", "txt", "Output format: txt, md (tree in a fenced block), xml (<document> elements), json (array of file objects), or jsonl (one object per line)")
 	rootCmd.Flags().StringVar(&gitRef, "ref", "", "Git reference (branch, tag, commit) for remote repositories")
+	rootCmd.Flags().BoolVar(&useCache, "cache", false, "Keep clones of remote repositories in the user cache directory and reuse them between runs")
+	rootCmd.Flags().BoolVar(&noCache, "no-cache", false, "Clone remote repositories into a temporary directory that is removed afterwards (default; overrides --cache if set)")
+	rootCmd.Flags().BoolVar(&repoRoot, "repo-root", false, "Process the root of the git repository containing the local path (e.g. the whole project when run from a subdirectory)")
+	rootCmd.Flags().BoolVar(&repoRootFallback, "repo-root-fallback", false, "With --repo-root, process the path as given if it is not inside a git repository instead of failing")
+	rootCmd.Flags().BoolVar(&force, "force", false, "Process the filesystem root or your home directory, which is refused otherwise")
+	rootCmd.Flags().BoolVar(&fromStdin, "from-stdin", false, "Read the files from stdin instead of a source, each starting with a line \"=== path ===\" followed by its content (no filters apply)")
+	rootCmd.Flags().StringVar(&filesFrom, "files-from", "", "Only include the files listed (one relative path per line) in this file, or \"-\" for stdin")
+	rootCmd.Flags().BoolVar(&exportIgnore, "respect-export-ignore", false, "Exclude paths marked export-ignore in the .gitattributes of the git work tree root (left out of git archive)")
+	rootCmd.Flags().BoolVar(&includeGitignored, "include-gitignored", false, "Include files excluded by .gitignore files (all other exclusions still apply)")
+	rootCmd.Flags().BoolVar(&onlyTracked, "only-tracked", false, "Only include files tracked by git (git ls-files), instead of applying .gitignore files")
+	rootCmd.Flags().BoolVar(&noAncestorIgnore, "no-ancestor-gitignore", false, "Don't apply .gitignore files above a local source directory (by default those up to the git repository root apply)")
+	rootCmd.Flags().StringVar(&diffBase, "diff-base", "", "Only include files changed between this Git reference and HEAD (e.g., \"main\")")
 
 	// --tree is true by default. --no-tree can explicitly disable it.
 	rootCmd.Flags().BoolVar(&includeTree, "tree", true, "Include a tree representation of the codebase (enabled by default)")
 	rootCmd.Flags().BoolVar(&noTree, "no-tree", false, "Disable the tree representation of the codebase (overrides --tree if set)")
+	rootCmd.Flags().BoolVar(&fullTree, "full-tree", false, "Show excluded files and directories in the tree too, marked \"(excluded)\" (their contents are still left out)")
+	rootCmd.Flags().BoolVar(&includeTOC, "toc", false, "Write a table of contents after the tree: the included files, numbered in output order")
+	rootCmd.Flags().BoolVar(&withGitInfo, "with-git-info", false, "Write the repository URL, commit hash, branch (or requested ref), and dirty state before the tree")
 	// If both --tree=false and --no-tree are set, --no-tree (which means don't include tree) wins.
 	// If --tree=true and --no-tree is set, --no-tree wins.
 	// This logic is handled in RunE.
 
-	rootCmd.Flags().BoolVar(&skipAuxFiles, "skip-aux-files", false, "Skip non-code, human-readable auxiliary files (json, csv, yml, md, txt, etc.)")
-	rootCmd.Flags().StringVar(&excludeDirsRaw, "exclude-dirs", "", "Comma-separated list of directory names to exclude (e.g., \"docs,tests\")")
-	rootCmd.Flags().StringVar(&excludeExtsRaw, "exclude-exts", "", "Comma-separated list of file extensions to exclude (e.g., \".log,.tmp,json\")")
-	rootCmd.Flags().StringVar(&excludeGlobsRaw, "exclude-patterns", "", "Comma-separated list of glob patterns to exclude (e.g., \"*_test.go,vendor/*\")")
+	rootCmd.Flags().BoolVar(&headerStats, "header-stats", false, "Include line count and size in each file header (e.g., \"main.go (142 lines, 3.1 KiB)\")")
+	rootCmd.Flags().BoolVar(&annotate, "annotate", false, "Write a comment line before each file with its path, language, line count, and whether it is a test or generated (e.g., \"// main_test.go | go | 142 lines | test\"); not in the JSON formats")
+	rootCmd.Flags().StringVar(&relTo, "rel-to", "", "Show paths relative to this directory, which must contain the local source (e.g., \"--rel-to .\" from a monorepo root for \"services/api/main.go\")")
+	rootCmd.Flags().StringVar(&pathStyleRaw, "path-style", "relative", "Paths in file headers: relative, absolute, or repo (prefixed with the repo/folder name, e.g. \"myrepo/cmd/root.go\")")
+	rootCmd.Flags().StringVar(&rootLabel, "root-label", "", "Name shown at the top of the tree, and as the prefix of paths with --path-style repo (default: the folder/repo name)")
+	rootCmd.Flags().StringVar(&treeSortRaw, "tree-sort", "dirs-first", "Order of the tree's entries at every level: dirs-first, files-first, or alpha (directories and files mixed)")
+	rootCmd.Flags().StringVar(&sectionSeparator, "section-separator", `\n`, "Text between file sections with --format txt or md, with \\n for a newline (e.g., \"---\", or \"\" for none); a final newline is added if missing")
+	rootCmd.Flags().StringVar(&treeFenceLang, "tree-fence-lang", "text", "Language tag of the fence around the tree with --format md (e.g., \"tree\")")
+	rootCmd.Flags().StringVar(&fenceLangRaw, "fence-lang", "never", "Name the language before the path in each file's opening fence: auto (if known from the extension), always (error for unknown languages), or never")
+	rootCmd.Flags().StringVar(&prependText, "prepend", "", "Text, or path to a text file, to write at the top of the output (before the tree)")
+	rootCmd.Flags().StringVar(&appendText, "append", "", "Text, or path to a text file, to write at the end of the output (after the last file)")
+	rootCmd.Flags().StringVar(&sortOrderRaw, "sort", "path", "Order of file sections: path, ext (grouped by extension), size (largest last), or mtime (most recent last)")
+	rootCmd.Flags().BoolVar(&skipAuxFiles, "skip-aux-files", false, "Skip non-code, human-readable auxiliary files (json, csv, yml, md, txt, etc.); --skip-aux-files=false overrides a preset")
+	rootCmd.Flags().BoolVar(&noSkipAuxFiles, "no-skip-aux-files", false, "Include auxiliary files even if a preset skips them (overrides --skip-aux-files if set)")
+	rootCmd.Flags().StringVar(&presetName, "preset", "", "Named bundle of flag defaults: minimal (code only, no aux files or tests), docs (documentation only), full (aux files and tests included), or review (--diff-base main with tests); explicit flags override it")
+	rootCmd.Flags().BoolVar(&excludeTests, "exclude-tests", false, "Skip test files (*_test.go, *.test.ts, test_*.py, *Test.java, *_spec.rb, ...) and test dirs (__tests__, spec, tests)")
+	rootCmd.Flags().BoolVar(&excludeVendored, "exclude-vendored", false, "Skip vendored code: third-party dirs (third_party, Pods, .pnpm, ...), minified bundles, and files with a generated-code header")
+	rootCmd.Flags().BoolVar(&excludeMinified, "exclude-minified", false, "Skip minified files: names containing \".min.\" and files whose first 8 KiB have lines over 500 bytes on average")
+	rootCmd.Flags().BoolVar(&excludeGenerated, "exclude-generated", false, "Skip generated files, detected by a marker comment (\"Code generated ... DO NOT EDIT\", \"@generated\", \"auto-generated\", ...) in their first 40 lines")
+	rootCmd.Flags().BoolVar(&caseInsensitive, "case-insensitive", false, "Match excluded directory names, glob patterns, and file names case-insensitively; .gitignore rules are unaffected (default: enabled on Windows and macOS)")
+	rootCmd.Flags().BoolVar(&skipHidden, "skip-hidden", false, "Skip hidden files and directories (names starting with \".\", e.g. .github/)")
+	rootCmd.Flags().BoolVar(&excludeEmpty, "exclude-empty", false, "Skip empty (zero-byte) files")
+	rootCmd.Flags().BoolVar(&stripComments, "strip-comments", false, "Remove comments from Go, JavaScript/TypeScript, Python, and C/C++ files (string literals are preserved)")
+	rootCmd.Flags().BoolVar(&renderNotebooks, "render-notebooks", false, "Write Jupyter notebooks (.ipynb) as their code cells instead of raw JSON (outputs and metadata are dropped)")
+	rootCmd.Flags().BoolVar(&notebookMarkdown, "notebook-markdown", false, "With --render-notebooks, also write markdown cells (as \"# \" comments)")
+	rootCmd.Flags().BoolVar(&prettyJSON, "pretty-json", false, "Indent the content of .json files (e.g., minified API responses); invalid JSON is written unchanged")
+	rootCmd.Flags().BoolVar(&reproducible, "reproducible", false, "Normalize output for byte-for-byte comparison: trim trailing whitespace of content lines and end with a single newline")
+	rootCmd.Flags().BoolVar(&validUTF8, "valid-utf8", false, "Replace invalid UTF-8 byte sequences in file contents with the replacement character (U+FFFD)")
+	rootCmd.Flags().BoolVar(&collapseBlankLines, "collapse-blank-lines", false, "Write runs of consecutive blank lines in file contents as a single blank line")
+	rootCmd.Flags().BoolVar(&dedupe, "dedupe", false, "Write the content of identical files only once; later copies reference the first one")
+	rootCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follow symbolic links and include their targets (each real directory only once, at its real location if inside the source)")
+	rootCmd.Flags().BoolVar(&showSymlinkDirs, "show-symlink-dirs", false, "Show symbolic links to directories in the tree, annotated with \"-> target\", without following them")
+	rootCmd.Flags().StringArrayVar(&excludeDirsRaw, "exclude-dirs", nil, "Comma-separated or repeated list of directory names, or relative paths containing a slash, to exclude; globs allowed (e.g., \"docs,internal/testdata,*-generated\")")
+	rootCmd.Flags().StringArrayVar(&keepDirsRaw, "keep-dirs", nil, "Comma-separated or repeated list of directory names to remove from the default exclusions (e.g., \"vendor,dist\")")
+	rootCmd.Flags().StringArrayVar(&ownVendorRaw, "include-own-vendor", nil, "Comma-separated or repeated list of Go module path prefixes whose packages in the excluded vendor directory are included (e.g., \"github.com/me\")")
+	rootCmd.Flags().BoolVar(&includeLockfiles, "include-lockfiles", false, "Include lock files (go.sum, package-lock.json, yarn.lock, poetry.lock, ...), which are excluded by default")
+	rootCmd.Flags().BoolVar(&noDefaultExcludes, "no-default-excludes", false, "Drop all built-in exclusions (directories such as .git and node_modules, media, archives, executables, lock files, ...)")
+	rootCmd.Flags().StringArrayVar(&excludeExtsRaw, "exclude-exts", nil, "Comma-separated or repeated list of file extensions to exclude (e.g., \".log,.tmp,json\")")
+	rootCmd.Flags().StringArrayVar(&omitContentExtsRaw, "omit-content-exts", nil, "Comma-separated or repeated list of file extensions whose files are listed with a header but without content (e.g., \".min.js,.svg\")")
+	rootCmd.Flags().StringArrayVar(&includeLangRaw, "include-lang", nil, "Comma-separated or repeated list of languages to include, excluding all other files (e.g., \"go,ts\")")
+	rootCmd.Flags().StringArrayVar(&excludeLangRaw, "exclude-lang", nil, "Comma-separated or repeated list of languages to exclude (e.g., \"python,cpp\")")
+	rootCmd.Flags().StringArrayVar(&excludeGlobsRaw, "exclude-patterns", nil, "Comma-separated or repeated list of glob patterns to exclude (e.g., \"*_test.go,vendor/*\")")
+	rootCmd.Flags().StringArrayVar(&excludeDirsRegex, "exclude-dirs-regex", nil, "Regular expression matched against relative directory paths to prune; repeat the flag for several, as commas are part of the expression (e.g., \"^(apps|libs)/[^/]+/node_modules$\")")
+	rootCmd.Flags().StringArrayVar(&excludeRegexRaw, "exclude-regex", nil, "Regular expression matched against relative paths to exclude; repeat the flag for several, as commas are part of the expression (e.g., \"_pb\\.go$\")")
+	rootCmd.Flags().StringVar(&excludeContentRaw, "exclude-content-regex", "", "Exclude files whose content (the first 64 KiB) matches this regular expression (e.g., \"Licensed under the Apache License\")")
+	rootCmd.Flags().StringArrayVar(&ignoreFilesRaw, "ignore-files", nil, "Comma-separated or repeated list of additional gitignore-syntax files to respect in every directory (e.g., \".dockerignore,.npmignore\")")
+	rootCmd.Flags().IntVar(&maxDepth, "max-depth", 0, "Maximum directory depth to include (1 = top-level files and dir names only); 0 means unlimited")
 	rootCmd.Flags().StringVar(&maxFileSizeStr, "max-file-size", "1MB", "Maximum file size to include (e.g., \"500KB\", \"2MB\", \"1024\")")
-	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
+	rootCmd.Flags().BoolVar(&dropOutliers, "drop-outliers", false, "Exclude files much larger than the others (above the upper quartile plus 3 times the interquartile range of the candidate sizes)")
+	rootCmd.Flags().IntVar(&headLines, "head", 0, "Include files larger than --max-file-size with only their first N lines, instead of skipping them")
+	rootCmd.Flags().IntVar(&tailLines, "tail", 0, "Include files larger than --max-file-size with only their last N lines (after the --head lines), instead of skipping them")
+	rootCmd.Flags().StringVar(&modifiedSinceRaw, "modified-since", "", "Only include files modified within this age (e.g., \"24h\", \"7d\", \"2w\") or since this date (e.g., \"2024-05-01\" or an RFC 3339 timestamp)")
+	rootCmd.Flags().IntVar(&maxLineLength, "max-line-length", 0, "Cut content lines longer than N characters, noting how many were cut (e.g., for minified files); 0 means unlimited")
+	rootCmd.Flags().StringVar(&minFileSizeStr, "min-file-size", "0", "Minimum file size to include (e.g., \"10B\", \"1KB\"); 0 disables the minimum")
+	rootCmd.Flags().BoolVar(&clipboard, "clipboard", false, "Also copy the output to the system clipboard (with \"-o -\", only to the clipboard)")
+	rootCmd.Flags().BoolVar(&watch, "watch", false, "Keep running and regenerate the output whenever files under the local source change (Ctrl-C to stop)")
+	rootCmd.Flags().StringVar(&timeoutRaw, "timeout", "", "Stop with exit code 7 if cloning, walking, and writing take longer than this (e.g., \"30s\", \"2m\"); no output file is left behind (with --watch, per regeneration)")
+	rootCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Review the candidate files and deselect some before writing (requires a terminal on stdin)")
+	rootCmd.Flags().BoolVar(&languageStats, "lang-stats", false, "Print the number of files and bytes per language after writing")
+	rootCmd.Flags().BoolVar(&countOnly, "count-only", false, "Only print the number of included files, their bytes and tokens, and a language breakdown (as JSON with --format json); no output is written")
+	rootCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Stop including files, in output order, once their sections would exceed this many tokens (the tree stays complete); 0 means unlimited")
+	rootCmd.Flags().BoolVar(&countTokens, "count-tokens", false, "Print the token count of each file and the total after writing")
+	rootCmd.Flags().StringVar(&tokenizerPath, "tokenizer", "", "Path to a tiktoken BPE ranks file (e.g., cl100k_base.tiktoken; not bundled, download it separately) used by --count-tokens, --count-only, and --max-tokens (default: estimate 1 token per 4 bytes)")
+	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging (same as --log-level debug)")
+	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only log errors (same as --log-level error)")
+	rootCmd.Flags().StringVar(&logLevelRaw, "log-level", "", "Minimum level of log messages: debug, info, warn, or error (default: info)")
+	rootCmd.Flags().BoolVar(&showProgress, "progress", false, "Show progress while cloning and walking (default: enabled when stderr is a terminal)")
 
 	// Set executable name for usage printout
 	rootCmd.Use = "c2c <path_or_url>"
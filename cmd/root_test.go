@@ -0,0 +1,1008 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexferrari88/code2context/internal/gitutils"
+	"github.com/alexferrari88/code2context/internal/processor"
+	"github.com/alexferrari88/code2context/internal/utils"
+	"github.com/spf13/pflag"
+)
+
+// executeCommand runs the root command with args and --quiet (see runCommand).
+func executeCommand(t *testing.T, args ...string) error {
+	t.Helper()
+	return runCommand(t, append(args, "--quiet")...)
+}
+
+// runCommand runs the root command with args after resetting its flags (see resetFlags).
+func runCommand(t *testing.T, args ...string) error {
+	t.Helper()
+	resetFlags(t)
+	rootCmd.SilenceErrors = false
+	rootCmd.SilenceUsage = false
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	rootCmd.SetArgs(args)
+	return rootCmd.Execute()
+}
+
+// resetFlags resets all flags of the root command to their defaults, as the flags are bound to
+// package-level variables that keep their values between executions.
+func resetFlags(t *testing.T) {
+	t.Helper()
+	rootCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			_ = slice.Replace(nil)
+		} else if err := f.Value.Set(f.DefValue); err != nil {
+			t.Fatalf("resetting --%s: %v", f.Name, err)
+		}
+		f.Changed = false
+	})
+}
+
+// writeTree creates the files (slash-separated path -> content) below a new temporary directory and
+// returns its path.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range files {
+		absPath := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(absPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(absPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// runToPaths runs the command on root with args and returns the paths of the file sections of the output.
+func runToPaths(t *testing.T, root string, args ...string) []string {
+	t.Helper()
+	outputPath := filepath.Join(t.TempDir(), "out.txt")
+	if err := executeCommand(t, append([]string{root, "-o", outputPath, "--no-tree"}, args...)...); err != nil {
+		t.Fatalf("execute error = %v", err)
+	}
+	output, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	inSection := false
+	for _, line := range strings.Split(string(output), "\n") {
+		switch {
+		case inSection && line == "```":
+			inSection = false
+		case !inSection && strings.HasPrefix(line, "```") && len(line) > 3:
+			paths = append(paths, line[3:])
+			inSection = true
+		}
+	}
+	return paths
+}
+
+func TestExcludeRegex(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go":             "package main\n",
+		"api/api.pb.go":       "package api\n",
+		"api/api_pb.go":       "package api\n",
+		"pkg/testdata/a.go":   "package testdata\n",
+		"pkg/x/testdata/b.go": "package testdata\n",
+		"mytestdata/c.go":     "package mytestdata\n",
+		"abc.go":              "package abc\n",
+	})
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "suffix", args: []string{"--exclude-regex", `.*_pb\.go$`}, want: []string{"abc.go", "api/api.pb.go", "main.go", "mytestdata/c.go", "pkg/testdata/a.go", "pkg/x/testdata/b.go"}},
+		{name: "path element", args: []string{"--exclude-regex", `(^|/)testdata/`}, want: []string{"abc.go", "api/api.pb.go", "api/api_pb.go", "main.go", "mytestdata/c.go"}},
+		{name: "comma is part of the expression", args: []string{"--exclude-regex", `^[a-z]{1,3}\.go$`}, want: []string{"api/api.pb.go", "api/api_pb.go", "main.go", "mytestdata/c.go", "pkg/testdata/a.go", "pkg/x/testdata/b.go"}},
+		{name: "repeated", args: []string{"--exclude-regex", `.*_pb\.go$`, "--exclude-regex", `(^|/)testdata/`}, want: []string{"abc.go", "api/api.pb.go", "main.go", "mytestdata/c.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToPaths(t, root, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludeDirsRegex(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go":                    "package main\n",
+		"apps/web/node_modules/x.js": "module.exports = {}\n",
+		"apps/web/app.js":            "export {}\n",
+		"libs/ui/node_modules/y.js":  "module.exports = {}\n",
+		"gen/v12/types.go":           "package v12\n",
+		"gen/v3/types.go":            "package v3\n",
+	})
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "nested directories", args: []string{"--exclude-dirs-regex", `^(apps|libs)/[^/]+/node_modules$`}, want: []string{"apps/web/app.js", "gen/v12/types.go", "gen/v3/types.go", "main.go"}},
+		{name: "comma is part of the expression", args: []string{"--exclude-dirs-regex", `^gen/v[0-9]{2,3}$`}, want: []string{"apps/web/app.js", "apps/web/node_modules/x.js", "gen/v3/types.go", "libs/ui/node_modules/y.js", "main.go"}},
+		{name: "repeated", args: []string{"--exclude-dirs-regex", `^gen$`, "--exclude-dirs-regex", `(^|/)node_modules$`}, want: []string{"apps/web/app.js", "main.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToPaths(t, root, append(tt.args, "--no-default-excludes")...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidFlagValueIsUsageError(t *testing.T) {
+	root := writeTree(t, map[string]string{"main.go": "package main\n"})
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "exclude regex", args: []string{"--exclude-regex", "a("}},
+		{name: "exclude content regex", args: []string{"--exclude-content-regex", "a("}},
+		{name: "exclude dirs regex", args: []string{"--exclude-dirs-regex", "a("}},
+		{name: "tree fence lang with a space", args: []string{"--format", "md", "--tree-fence-lang", "a b"}},
+		{name: "tree fence lang with backticks", args: []string{"--format", "md", "--tree-fence-lang", "`x`"}},
+		{name: "tree sort", args: []string{"--tree-sort", "size"}},
+		{name: "timeout", args: []string{"--timeout", "30"}},
+		{name: "negative timeout", args: []string{"--timeout", "-1s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "out.txt")
+			err := executeCommand(t, append([]string{root, "-o", outputPath}, tt.args...)...)
+			if code := exitCode(err); code != exitUsage {
+				t.Errorf("exit code = %d (error %v), want %d", code, err, exitUsage)
+			}
+			if _, statErr := os.Stat(outputPath); statErr == nil {
+				t.Error("output written despite the invalid flag")
+			}
+		})
+	}
+}
+
+func TestLanguageFilters(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"app.py":       "print('hi')\n",
+		"stubs.pyi":    "def f() -> None: ...\n",
+		"main.go":      "package main\n",
+		"lib/x.cpp":    "int x;\n",
+		"lib/x.hpp":    "int x();\n",
+		"lib/util.c":   "int y;\n",
+		"web/index.ts": "export {}\n",
+	})
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "include python", args: []string{"--include-lang", "python"}, want: []string{"app.py", "stubs.pyi"}},
+		{name: "include cpp", args: []string{"--include-lang", "CPP"}, want: []string{"lib/x.cpp", "lib/x.hpp"}},
+		{name: "include several", args: []string{"--include-lang", "go,ts"}, want: []string{"main.go", "web/index.ts"}},
+		{name: "exclude cpp", args: []string{"--exclude-lang", "cpp"}, want: []string{"app.py", "lib/util.c", "main.go", "stubs.pyi", "web/index.ts"}},
+		{name: "exclude wins", args: []string{"--include-lang", "python,go", "--exclude-lang", "go"}, want: []string{"app.py", "stubs.pyi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToPaths(t, root, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	for _, flag := range []string{"--include-lang", "--exclude-lang"} {
+		t.Run("unknown "+flag, func(t *testing.T) {
+			err := executeCommand(t, root, "-o", filepath.Join(t.TempDir(), "out.txt"), flag, "cobol")
+			if code := exitCode(err); code != exitUsage {
+				t.Errorf("exit code = %d (error %v), want %d", code, err, exitUsage)
+			}
+		})
+	}
+}
+
+func TestFileSizeFlags(t *testing.T) {
+	root := writeTree(t, map[string]string{"tiny.go": "x", "main.go": "package main\n\nfunc main() {}\n"})
+	tests := []struct {
+		name     string
+		args     []string
+		want     []string
+		wantCode int
+	}{
+		{name: "minimum", args: []string{"--min-file-size", "2"}, want: []string{"main.go"}},
+		{name: "minimum equal to the size", args: []string{"--min-file-size", "1"}, want: []string{"main.go", "tiny.go"}},
+		{name: "range", args: []string{"--min-file-size", "1", "--max-file-size", "10B"}, want: []string{"tiny.go"}},
+		{name: "invalid minimum", args: []string{"--min-file-size", "big"}, wantCode: exitUsage},
+		{name: "negative minimum", args: []string{"--min-file-size", "-1KB"}, wantCode: exitUsage},
+		{name: "invalid maximum", args: []string{"--max-file-size", "1XB"}, wantCode: exitUsage},
+		{name: "minimum above maximum", args: []string{"--min-file-size", "2KB", "--max-file-size", "1KB"}, wantCode: exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantCode != 0 {
+				err := executeCommand(t, append([]string{root, "-o", filepath.Join(t.TempDir(), "out.txt")}, tt.args...)...)
+				if code := exitCode(err); code != tt.wantCode {
+					t.Errorf("exit code = %d (error %v), want %d", code, err, tt.wantCode)
+				}
+				return
+			}
+			if got := runToPaths(t, root, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintLanguageStats(t *testing.T) {
+	var out strings.Builder
+	printLanguageStats(&out, []processor.LanguageStats{{Language: "go", Files: 2, Bytes: 2048}, {Language: "python", Files: 1, Bytes: 10}})
+	want := "Languages:\n" +
+		"  go               2 files    2.0 KiB\n" +
+		"  python           1 file        10 B\n"
+	if got := out.String(); got != want {
+		t.Errorf("printLanguageStats() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestExcludeTests(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go":                 "package main\n",
+		"main_test.go":            "package main\n",
+		"web/app.ts":              "export {}\n",
+		"web/app.test.ts":         "test('x', () => {})\n",
+		"web/__tests__/helper.ts": "export {}\n",
+		"py/test_app.py":          "def test(): pass\n",
+		"notes.md":                "# notes\n",
+	})
+	all := []string{"main.go", "main_test.go", "notes.md", "py/test_app.py", "web/__tests__/helper.ts", "web/app.test.ts", "web/app.ts"}
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "kept without the flag", want: all},
+		{name: "excluded", args: []string{"--exclude-tests"}, want: []string{"main.go", "notes.md", "web/app.ts"}},
+		{name: "composes with user excludes", args: []string{"--exclude-tests", "--exclude-patterns", "*.md"}, want: []string{"main.go", "web/app.ts"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToPaths(t, root, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// captureOutput runs fn with *target (os.Stdout or os.Stderr) redirected to a temporary file
+// and returns what it wrote.
+func captureOutput(t *testing.T, target **os.File, fn func()) string {
+	t.Helper()
+	file, err := os.Create(filepath.Join(t.TempDir(), "output"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	original := *target
+	*target = file
+	defer func() { *target = original }()
+	fn()
+	content, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestClipboard(t *testing.T) {
+	root := writeTree(t, map[string]string{"main.go": "package main\n"})
+	var copied []string
+	var setErr error
+	original := utils.SetClipboardFunc
+	utils.SetClipboardFunc = func(text string) error {
+		copied = append(copied, text)
+		return setErr
+	}
+	t.Cleanup(func() { utils.SetClipboardFunc = original })
+
+	t.Run("file and clipboard", func(t *testing.T) {
+		copied = nil
+		outputPath := filepath.Join(t.TempDir(), "out.txt")
+		if err := executeCommand(t, root, "-o", outputPath, "--clipboard"); err != nil {
+			t.Fatalf("execute error = %v", err)
+		}
+		written, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(copied) != 1 || copied[0] != string(written) {
+			t.Errorf("clipboard received %q, want the output file content %q", copied, written)
+		}
+	})
+
+	t.Run("clipboard only with -o -", func(t *testing.T) {
+		copied = nil
+		var err error
+		stdout := captureOutput(t, &os.Stdout, func() { err = executeCommand(t, root, "-o", "-", "--clipboard") })
+		if err != nil {
+			t.Fatalf("execute error = %v", err)
+		}
+		if stdout != "" {
+			t.Errorf("stdout = %q, want nothing", stdout)
+		}
+		if len(copied) != 1 || !strings.Contains(copied[0], "```main.go\npackage main\n```") {
+			t.Errorf("clipboard received %q, want the generated output", copied)
+		}
+	})
+
+	t.Run("clipboard failure", func(t *testing.T) {
+		setErr = errors.New("no clipboard tool found")
+		defer func() { setErr = nil }()
+		err := executeCommand(t, root, "-o", filepath.Join(t.TempDir(), "out.txt"), "--clipboard")
+		if err == nil || !strings.Contains(err.Error(), "no clipboard tool found") {
+			t.Errorf("execute error = %v, want the clipboard error", err)
+		}
+	})
+
+	for _, args := range [][]string{{"--watch"}, {"--split-size", "1000"}, {"--gzip"}, {"--count-only"}} {
+		t.Run("with "+args[0], func(t *testing.T) {
+			copied = nil
+			err := executeCommand(t, append([]string{root, "-o", filepath.Join(t.TempDir(), "out.txt"), "--clipboard"}, args...)...)
+			if code := exitCode(err); code != exitUsage {
+				t.Errorf("exit code = %d (error %v), want %d", code, err, exitUsage)
+			}
+			if len(copied) != 0 {
+				t.Errorf("clipboard received %q, want nothing", copied)
+			}
+		})
+	}
+}
+
+func TestLogLevelFlags(t *testing.T) {
+	root := writeTree(t, map[string]string{"main.go": "package main\n"})
+	tests := []struct {
+		name    string
+		args    []string
+		want    slog.Level
+		wantErr bool
+	}{
+		{name: "default", want: slog.LevelInfo},
+		{name: "verbose", args: []string{"-v"}, want: slog.LevelDebug},
+		{name: "quiet", args: []string{"-q"}, want: slog.LevelError},
+		{name: "log level", args: []string{"--log-level", "warn"}, want: slog.LevelWarn},
+		{name: "log level wins over quiet", args: []string{"--log-level", "info", "--quiet"}, want: slog.LevelInfo},
+		{name: "log level wins over verbose", args: []string{"--log-level", "ERROR", "--verbose"}, want: slog.LevelError},
+		{name: "verbose and quiet", args: []string{"-v", "-q"}, wantErr: true},
+		{name: "unknown log level", args: []string{"--log-level", "trace"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			captureOutput(t, &os.Stderr, func() {
+				err = runCommand(t, append([]string{root, "-o", filepath.Join(t.TempDir(), "out.txt")}, tt.args...)...)
+			})
+			if tt.wantErr {
+				if code := exitCode(err); code != exitUsage {
+					t.Errorf("exit code = %d (error %v), want %d", code, err, exitUsage)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("execute error = %v", err)
+			}
+			ctx := context.Background()
+			if !slog.Default().Enabled(ctx, tt.want) || slog.Default().Enabled(ctx, tt.want-1) {
+				t.Errorf("logger level is not %v", tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultExcludeFlags(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go":             "package main\n",
+		"vendor/lib/lib.go":   "package lib\n",
+		"dist/app.js":         "console.log(1)\n",
+		"node_modules/x/y.js": "module.exports = {}\n",
+		"logo.png":            "png",
+		"package-lock.json":   "{}\n",
+	})
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "defaults", want: []string{"main.go"}},
+		{name: "keep vendor", args: []string{"--keep-dirs", "vendor"}, want: []string{"main.go", "vendor/lib/lib.go"}},
+		{name: "keep several", args: []string{"--keep-dirs", "vendor,dist"}, want: []string{"dist/app.js", "main.go", "vendor/lib/lib.go"}},
+		{name: "keep repeated", args: []string{"--keep-dirs", "vendor", "--keep-dirs", "node_modules"}, want: []string{"main.go", "node_modules/x/y.js", "vendor/lib/lib.go"}},
+		{
+			name: "no default excludes",
+			args: []string{"--no-default-excludes"},
+			want: []string{"dist/app.js", "logo.png", "main.go", "node_modules/x/y.js", "package-lock.json", "vendor/lib/lib.go"},
+		},
+		{name: "user excludes still apply", args: []string{"--no-default-excludes", "--exclude-dirs", "node_modules,dist,vendor", "--exclude-exts", ".png,.json"}, want: []string{"main.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToPaths(t, root, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "usage", err: usageErrorf("invalid --format: %w", errors.New("unknown")), want: exitUsage},
+		{name: "source not found", err: processor.ErrSourceNotFound, want: exitSourceNotFound},
+		{name: "clone error", err: &gitutils.CloneError{URL: "https://example.com/r.git", Err: errors.New("exit status 128")}, want: exitCloneFailed},
+		{name: "classified clone error", err: &gitutils.CloneError{URL: "https://example.com/r.git", Reason: gitutils.ErrRepoNotFound, Err: errors.New("exit status 128")}, want: exitCloneFailed},
+		{name: "git not installed", err: gitutils.ErrGitNotInstalled, want: exitCloneFailed},
+		{name: "output write", err: processor.ErrOutputWrite, want: exitOutputFailed},
+		{name: "no files", err: processor.ErrNoFiles, want: exitNoFiles},
+		{name: "timeout", err: processor.ErrTimeout, want: exitTimeout},
+		{name: "generic", err: errors.New("something failed"), want: exitGeneric},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+			wrapped := fmt.Errorf("processor: step failed: %w", tt.err)
+			if got := exitCode(wrapped); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", wrapped, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitCodeOfCommand(t *testing.T) {
+	root := writeTree(t, map[string]string{"main.go": "package main\n"})
+	empty := t.TempDir()
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "success", args: []string{root, "-o", filepath.Join(t.TempDir(), "out.txt")}, want: 0},
+		{name: "unknown flag", args: []string{root, "--no-such-flag"}, want: exitUsage},
+		{name: "too many arguments", args: []string{root, root}, want: exitUsage},
+		{name: "missing source", args: []string{filepath.Join(root, "missing"), "-o", filepath.Join(t.TempDir(), "out.txt")}, want: exitSourceNotFound},
+		{name: "unwritable output", args: []string{root, "-o", filepath.Join(root, "main.go", "out.txt")}, want: exitOutputFailed},
+		{name: "no files", args: []string{empty, "-o", filepath.Join(t.TempDir(), "out.txt")}, want: exitNoFiles},
+		{name: "output outside --output-within", args: []string{root, "-o", filepath.Join(empty, "..", "out.txt"), "--output-within", empty}, want: exitOutputFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := executeCommand(t, tt.args...)
+			got := 0
+			if err != nil {
+				got = exitCode(err)
+			}
+			if got != tt.want {
+				t.Errorf("exit code = %d (error %v), want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// flagValue returns the value of the root command's flag, with list values joined by commas.
+func flagValue(t *testing.T, name string) string {
+	t.Helper()
+	f := rootCmd.Flags().Lookup(name)
+	if f == nil {
+		t.Fatalf("no flag --%s", name)
+	}
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		return strings.Join(slice.GetSlice(), ",")
+	}
+	return f.Value.String()
+}
+
+func TestApplyPreset(t *testing.T) {
+	tests := []struct {
+		name     string
+		preset   string
+		explicit map[string]string // Flags set before the preset is applied
+		want     map[string]string
+	}{
+		{name: "minimal", preset: "minimal", want: map[string]string{"skip-aux-files": "true", "exclude-tests": "true", "include-lang": ""}},
+		{name: "docs", preset: "docs", want: map[string]string{"skip-aux-files": "false", "include-lang": "markdown,restructuredtext,asciidoc,text"}},
+		{name: "full", preset: "full", want: map[string]string{"skip-aux-files": "false", "exclude-tests": "false"}},
+		{name: "review", preset: "review", want: map[string]string{"diff-base": "main", "exclude-tests": "false", "header-stats": "true"}},
+		{name: "name is case-insensitive", preset: " Minimal ", want: map[string]string{"skip-aux-files": "true", "exclude-tests": "true"}},
+		{
+			name:     "explicit flags override",
+			preset:   "minimal",
+			explicit: map[string]string{"exclude-tests": "false"},
+			want:     map[string]string{"skip-aux-files": "true", "exclude-tests": "false"},
+		},
+		{
+			name:     "explicit value override",
+			preset:   "review",
+			explicit: map[string]string{"diff-base": "develop"},
+			want:     map[string]string{"diff-base": "develop", "header-stats": "true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags(t)
+			for name, value := range tt.explicit {
+				if err := rootCmd.Flags().Set(name, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := applyPreset(rootCmd, tt.preset); err != nil {
+				t.Fatalf("applyPreset() error = %v", err)
+			}
+			for name, want := range tt.want {
+				if got := flagValue(t, name); got != want {
+					t.Errorf("--%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("every preset applies", func(t *testing.T) {
+		for _, name := range presetNames() {
+			resetFlags(t)
+			if err := applyPreset(rootCmd, name); err != nil {
+				t.Errorf("applyPreset(%s) error = %v", name, err)
+			}
+		}
+	})
+
+	t.Run("unknown preset", func(t *testing.T) {
+		resetFlags(t)
+		err := applyPreset(rootCmd, "huge")
+		if err == nil || !strings.Contains(err.Error(), "minimal") {
+			t.Errorf("applyPreset() error = %v, want an error listing the presets", err)
+		}
+		root := writeTree(t, map[string]string{"main.go": "package main\n"})
+		err = executeCommand(t, root, "-o", filepath.Join(t.TempDir(), "out.txt"), "--preset", "huge")
+		if code := exitCode(err); code != exitUsage {
+			t.Errorf("exit code = %d (error %v), want %d", code, err, exitUsage)
+		}
+	})
+}
+
+func TestPresetOutput(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go":      "package main\n",
+		"main_test.go": "package main\n",
+		"README.md":    "# Readme\n",
+		"config.yml":   "key: value\n",
+	})
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "no preset", want: []string{"README.md", "config.yml", "main.go", "main_test.go"}},
+		{name: "minimal", args: []string{"--preset", "minimal"}, want: []string{"main.go"}},
+		{name: "minimal with tests", args: []string{"--preset", "minimal", "--exclude-tests=false"}, want: []string{"main.go", "main_test.go"}},
+		{name: "docs", args: []string{"--preset", "docs"}, want: []string{"README.md"}},
+		{name: "full", args: []string{"--preset", "full"}, want: []string{"README.md", "config.yml", "main.go", "main_test.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToPaths(t, root, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipAuxFilesFlags(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go":      "package main\n",
+		"main_test.go": "package main\n",
+		"README.md":    "# Readme\n",
+		"config.yml":   "key: value\n",
+	})
+	withAux := []string{"README.md", "config.yml", "main.go"}
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "default", want: []string{"README.md", "config.yml", "main.go", "main_test.go"}},
+		{name: "explicit", args: []string{"--skip-aux-files"}, want: []string{"main.go", "main_test.go"}},
+		{name: "preset", args: []string{"--preset", "minimal"}, want: []string{"main.go"}},
+		{name: "explicit false wins over preset", args: []string{"--preset", "minimal", "--skip-aux-files=false"}, want: withAux},
+		{name: "negation wins over preset", args: []string{"--preset", "minimal", "--no-skip-aux-files"}, want: withAux},
+		{name: "negation wins over explicit", args: []string{"--skip-aux-files", "--no-skip-aux-files"}, want: []string{"README.md", "config.yml", "main.go", "main_test.go"}},
+		{name: "negation false keeps skipping", args: []string{"--preset", "minimal", "--no-skip-aux-files=false"}, want: []string{"main.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToPaths(t, root, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncludeGitignored(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".gitignore":        "*_local.py\n.env.example\ncache/\n",
+		"main.go":           "package main\n",
+		"settings_local.py": "DEBUG = True\n",
+		".env.example":      "KEY=value\n",
+		"cache/data.json":   "{}\n",
+	})
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "gitignore applies", want: []string{"main.go"}},
+		{name: "include gitignored", args: []string{"--include-gitignored"}, want: []string{".env.example", "cache/data.json", "main.go", "settings_local.py"}},
+		{name: "other exclusions still apply", args: []string{"--include-gitignored", "--exclude-exts", ".py"}, want: []string{".env.example", "cache/data.json", "main.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToPaths(t, root, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("with ignore files", func(t *testing.T) {
+		err := executeCommand(t, root, "-o", filepath.Join(t.TempDir(), "out.txt"), "--include-gitignored", "--ignore-files", ".dockerignore")
+		if code := exitCode(err); code != exitUsage {
+			t.Errorf("exit code = %d (error %v), want %d", code, err, exitUsage)
+		}
+	})
+}
+
+func TestFormatSkipped(t *testing.T) {
+	tests := []struct {
+		skipped map[string]int
+		want    string
+	}{
+		{skipped: nil, want: ""},
+		{skipped: map[string]int{"media": 1}, want: "media=1"},
+		{skipped: map[string]int{"too_large": 2, "gitignore": 3, "media": 1}, want: "gitignore=3 media=1 too_large=2"},
+	}
+	for _, tt := range tests {
+		if got := formatSkipped(tt.skipped); got != tt.want {
+			t.Errorf("formatSkipped(%v) = %q, want %q", tt.skipped, got, tt.want)
+		}
+	}
+}
+
+func TestNoEmptyOutput(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantOutput bool
+	}{
+		{name: "empty output written", wantOutput: true},
+		{name: "no empty output", args: []string{"--no-empty-output"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "out.txt")
+			err := executeCommand(t, append([]string{t.TempDir(), "-o", outputPath}, tt.args...)...)
+			if !errors.Is(err, processor.ErrNoFiles) || exitCode(err) != exitNoFiles {
+				t.Errorf("Execute() error = %v, want ErrNoFiles with exit code %d", err, exitNoFiles)
+			}
+			if _, statErr := os.Stat(outputPath); (statErr == nil) != tt.wantOutput {
+				t.Errorf("output written = %v, want %v", statErr == nil, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRelTo(t *testing.T) {
+	monorepo := writeTree(t, map[string]string{"services/api/main.go": "package main\n"})
+	api := filepath.Join(monorepo, "services", "api")
+	if got, want := runToPaths(t, api, "--rel-to", monorepo), []string{"services/api/main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("paths = %v, want %v", got, want)
+	}
+	if got, want := runToPaths(t, api), []string{"main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("paths without --rel-to = %v, want %v", got, want)
+	}
+}
+
+func TestCountOnly(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go":     "package main\n",
+		"lib/util.go": "package lib\n\nfunc F() {}\n",
+		"app.py":      "print('hi')\n",
+		"logo.png":    "png",
+	})
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "text",
+			want: "Files:   3\nBytes:   50 (50 B)\nTokens:  14 (estimate (bytes/4))\nSkipped: media=1\n",
+		},
+		{
+			name: "json",
+			args: []string{"--format", "json"},
+			want: `{"files":3,"bytes":50,"tokens":14,"languages":[{"language":"go","files":2,"bytes":38},{"language":"python","files":1,"bytes":12}],"skipped":{"media":1}}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "out.txt")
+			var err error
+			stdout := captureOutput(t, &os.Stdout, func() {
+				err = executeCommand(t, append([]string{root, "-o", outputPath, "--count-only"}, tt.args...)...)
+			})
+			if err != nil {
+				t.Fatalf("execute error = %v", err)
+			}
+			if !strings.HasPrefix(stdout, tt.want) {
+				t.Errorf("stdout =\n%s\nwant it to start with\n%s", stdout, tt.want)
+			}
+			if _, statErr := os.Stat(outputPath); !errors.Is(statErr, os.ErrNotExist) {
+				t.Errorf("Stat(output) error = %v, want no output file", statErr)
+			}
+		})
+	}
+}
+
+func TestExcludeMinified(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"app.min.js": "var a = 1;\n",
+		"bundle.js":  strings.Repeat("var a=1;", 200) + "\n",
+		"main.js":    "const a = 1;\n",
+	})
+	if got, want := runToPaths(t, root), []string{"app.min.js", "bundle.js", "main.js"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("paths = %v, want %v", got, want)
+	}
+	if got, want := runToPaths(t, root, "--exclude-minified"), []string{"main.js"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("paths with --exclude-minified = %v, want %v", got, want)
+	}
+}
+
+func TestSplitListFlag(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{name: "unset", values: nil, want: nil},
+		{name: "comma-separated", values: []string{"docs, build,,tmp "}, want: []string{"docs", "build", "tmp"}},
+		{name: "repeated", values: []string{"docs", " build "}, want: []string{"docs", "build"}},
+		{name: "repeated keeps commas", values: []string{"a,b", "c"}, want: []string{"a,b", "c"}},
+		{name: "empty values", values: []string{"", " "}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitListFlag(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitListFlag(%q) = %q, want %q", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepeatedListFlags(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go":        "package main\n",
+		"docs/guide.go":  "package docs\n",
+		"tools/gen.go":   "package tools\n",
+		"a,b/comma.go":   "package comma\n",
+		"notes.txt":      "notes\n",
+		"data.csv":       "a,b\n",
+		"main_test.go":   "package main\n",
+		"helper_test.go": "package main\n",
+	})
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "comma form",
+			args: []string{"--exclude-dirs", "docs,tools", "--exclude-exts", ".txt,.csv", "--exclude-patterns", "main_test.go,helper_test.go"},
+			want: []string{"a,b/comma.go", "main.go"},
+		},
+		{
+			name: "repeated form",
+			args: []string{"--exclude-dirs", "docs", "--exclude-dirs", "tools", "--exclude-exts", ".txt", "--exclude-exts", ".csv", "--exclude-patterns", "*_test.go"},
+			want: []string{"a,b/comma.go", "main.go"},
+		},
+		{
+			name: "directory name with a comma",
+			args: []string{"--exclude-dirs", "a,b", "--exclude-dirs", "docs", "--exclude-patterns", "*_test.go", "--exclude-patterns", "*.txt"},
+			want: []string{"data.csv", "main.go", "tools/gen.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToPaths(t, root, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paths = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForce(t *testing.T) {
+	home := writeTree(t, map[string]string{"main.go": "package main\n"})
+	t.Setenv("HOME", home)
+	outputPath := filepath.Join(t.TempDir(), "out.txt")
+	if err := executeCommand(t, home, "-o", outputPath); err == nil || !strings.Contains(err.Error(), "refusing to process") {
+		t.Errorf("execute error = %v, want a refusal to process the home directory", err)
+	}
+	if got, want := runToPaths(t, home, "--force"), []string{"main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("paths with --force = %v, want %v", got, want)
+	}
+}
+
+func TestIncludeLockfiles(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go":               "package main\n",
+		"requirements.txt":      "requests==2.31.0\n",
+		"go.sum":                "example.com/x v1.0.0 h1:abc=\n",
+		"package-lock.json":     "{}\n",
+		"yarn.lock":             "# yarn lockfile v1\n",
+		"build.gradle.lockfile": "empty=\n",
+	})
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "excluded by default", want: []string{"main.go", "requirements.txt"}},
+		{name: "included with the flag", args: []string{"--include-lockfiles"}, want: []string{"build.gradle.lockfile", "go.sum", "main.go", "package-lock.json", "requirements.txt", "yarn.lock"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToPaths(t, root, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paths = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncludeOwnVendor(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go":                            "package main\n",
+		"vendor/github.com/me/lib/lib.go":    "package lib\n",
+		"vendor/github.com/other/lib/lib.go": "package lib\n",
+	})
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "vendor excluded by default", want: []string{"main.go"}},
+		{name: "own prefix", args: []string{"--include-own-vendor", "github.com/me"}, want: []string{"main.go", "vendor/github.com/me/lib/lib.go"}},
+		{name: "several prefixes", args: []string{"--include-own-vendor", "github.com/me,github.com/other"}, want: []string{"main.go", "vendor/github.com/me/lib/lib.go", "vendor/github.com/other/lib/lib.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToPaths(t, root, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paths = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGzip(t *testing.T) {
+	root := writeTree(t, map[string]string{"main.go": "package main\n"})
+	tests := []struct {
+		name      string
+		output    string
+		args      []string
+		wantGzip  bool
+		wantUsage bool
+	}{
+		{name: "detected from the name", output: "out.txt.gz", wantGzip: true},
+		{name: "detected case-insensitively", output: "out.TXT.GZ", wantGzip: true},
+		{name: "explicit", output: "out.txt", args: []string{"--gzip"}, wantGzip: true},
+		{name: "explicitly off", output: "out.txt.gz", args: []string{"--gzip=false"}},
+		{name: "plain name", output: "out.txt"},
+		{name: "standard output", output: "-", args: []string{"--gzip"}, wantUsage: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := tt.output
+			if outputPath != "-" {
+				outputPath = filepath.Join(t.TempDir(), tt.output)
+			}
+			err := executeCommand(t, append([]string{root, "-o", outputPath}, tt.args...)...)
+			if tt.wantUsage {
+				if code := exitCode(err); code != exitUsage {
+					t.Errorf("exit code = %d (error %v), want %d", code, err, exitUsage)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("execute error = %v", err)
+			}
+			data, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantGzip {
+				reader, err := gzip.NewReader(bytes.NewReader(data))
+				if err != nil {
+					t.Fatalf("gzip.NewReader() error = %v, want a gzipped output", err)
+				}
+				if data, err = io.ReadAll(reader); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !strings.Contains(string(data), "```main.go\npackage main\n```") {
+				t.Errorf("output =\n%q\nwant the section of main.go", data)
+			}
+		})
+	}
+}
+
+func TestShowDiff(t *testing.T) {
+	root := writeTree(t, map[string]string{"keep.go": "package main\n", "change.go": "package main\n", "remove.go": "package main\n"})
+	outputPath := filepath.Join(t.TempDir(), "out.txt")
+	if err := executeCommand(t, root, "-o", outputPath); err != nil {
+		t.Fatalf("execute error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "change.go"), []byte("package main\n\nconst v = 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "remove.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "add.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stderr := captureOutput(t, &os.Stderr, func() {
+		if err := executeCommand(t, root, "-o", outputPath, "--show-diff"); err != nil {
+			t.Fatalf("execute error = %v", err)
+		}
+	})
+	want := "Changes since the previous output: 1 added, 1 removed, 1 changed, 1 unchanged\n  + add.go\n  - remove.go\n  ~ change.go\n"
+	if !strings.Contains(stderr, want) {
+		t.Errorf("stderr = %q, want the diff summary %q", stderr, want)
+	}
+}
+
+func TestRespectExportIgnore(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".gitattributes": "docs/ export-ignore\n",
+		"main.go":        "package main\n",
+		"docs/guide.md":  "# Guide\n",
+	})
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "default", want: []string{"docs/guide.md", "main.go"}},
+		{name: "respected", args: []string{"--respect-export-ignore"}, want: []string{"main.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToPaths(t, root, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -32,6 +32,40 @@ func GetDefaultExcludedDirs() []string {
 	}
 }
 
+func GetDefaultVendoredDirs() []string {
+	// Skipped with --exclude-vendored, in addition to the default excluded dirs
+	return []string{
+		"third_party", "third-party", "thirdparty", "external", "extern",
+		// Package manager stores
+		"Pods", "Carthage", ".pnpm", ".yarn", "bower_components", "jspm_packages", "web_modules",
+		"site-packages", ".bundle",
+	}
+}
+
+func GetDefaultVendoredFilePatterns() []string {
+	// Glob patterns matched against the base name; skipped with --exclude-vendored
+	return []string{
+		"*.min.js", "*.min.css", "*.min.mjs", "*-min.js", "*.bundle.js", "*.chunk.js",
+		"*.pb.go", "*_pb2.py", "*_pb2_grpc.py", "*.pb.cc", "*.pb.h",
+	}
+}
+
+func GetDefaultTestFilePatterns() []string {
+	// Glob patterns matched against the base name; skipped with --exclude-tests
+	return []string{
+		"*_test.go",
+		"*.test.js", "*.test.jsx", "*.test.ts", "*.test.tsx", "*.spec.js", "*.spec.ts",
+		"test_*.py", "*_test.py",
+		"*Test.java", "*Tests.java", "*Test.kt",
+		"*_spec.rb", "*_test.rb",
+	}
+}
+
+func GetDefaultTestDirs() []string {
+	// Skipped with --exclude-tests, at any depth
+	return []string{"__tests__", "spec", "tests"}
+}
+
 func GetDefaultMediaExtensions() []string { // Ensure all start with a dot
 	return []string{
 		// Images
@@ -71,12 +105,12 @@ func GetDefaultExecutableExtensions() []string { // Ensure all start with a dot
 }
 
 func GetDefaultLockfilePatterns() []string {
-	// These are exact names or glob patterns matched against the base name
+	// These are exact names or glob patterns matched against the base name.
+	// Hand-edited dependency specs such as requirements.txt and constraints.txt are not lock files.
 	return []string{
 		"go.sum", "package-lock.json", "yarn.lock", "composer.lock", "Gemfile.lock",
 		"Pipfile.lock", "poetry.lock", "Cargo.lock", "*.gradle.lockfile", "Podfile.lock",
 		"pubspec.lock", "mix.lock", "npm-shrinkwrap.json", "pnpm-lock.yaml",
-		"requirements.txt", "constraints.txt", // Common for Python deps
 		"terraform.lock.hcl",
 	}
 }
@@ -0,0 +1,247 @@
+package archiveutils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// archiveKind identifies a supported archive format.
+type archiveKind int
+
+const (
+	kindNone archiveKind = iota
+	kindZip
+	kindTar
+	kindTarGz
+)
+
+// kindByExtension returns the archive format implied by the file name.
+func kindByExtension(path string) archiveKind {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return kindZip
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return kindTarGz
+	case strings.HasSuffix(lower, ".tar"):
+		return kindTar
+	default:
+		return kindNone
+	}
+}
+
+// hasMagic checks the leading bytes of the file against the signature of kind.
+func hasMagic(path string, kind archiveKind) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, 262)
+	n, _ := io.ReadFull(f, header)
+	header = header[:n]
+	switch kind {
+	case kindZip:
+		return bytes.HasPrefix(header, []byte("PK\x03\x04")) || bytes.HasPrefix(header, []byte("PK\x05\x06")) // Regular or empty archive
+	case kindTarGz:
+		return bytes.HasPrefix(header, []byte{0x1f, 0x8b})
+	case kindTar:
+		return len(header) >= 262 && string(header[257:262]) == "ustar"
+	default:
+		return false
+	}
+}
+
+// IsArchive reports whether path is a regular file with a supported archive extension
+// (.zip, .tar, .tar.gz, or .tgz) whose content starts with that format's magic bytes.
+func IsArchive(path string) bool {
+	kind := kindByExtension(path)
+	if kind == kindNone {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	return hasMagic(path, kind)
+}
+
+// archiveName returns the file name of the archive without its archive extension(s).
+func archiveName(path string) string {
+	name := filepath.Base(path)
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// ExtractArchive extracts the archive at path into a new temporary directory.
+// It returns the directory to process, a name for it, and the temporary directory the caller must remove.
+// If the archive holds a single top-level directory (as in "project-1.0/..."), that directory and
+// its name are returned; otherwise the extraction directory, named after the archive.
+// Entries that would be written outside the extraction directory (zip-slip) are rejected,
+// and symbolic and hard links are skipped.
+func ExtractArchive(path string, logger *slog.Logger) (string, string, string, error) {
+	kind := kindByExtension(path)
+	if kind == kindNone || !hasMagic(path, kind) {
+		return "", "", "", fmt.Errorf("archiveutils: '%s' is not a supported archive (.zip, .tar, .tar.gz)", path)
+	}
+
+	parentTempDir, err := os.MkdirTemp("", "c2c_archive_parent_*")
+	if err != nil {
+		return "", "", "", fmt.Errorf("archiveutils: failed to create parent temporary directory: %w", err)
+	}
+	name := archiveName(path)
+	dest := filepath.Join(parentTempDir, name)
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		os.RemoveAll(parentTempDir)
+		return "", "", "", fmt.Errorf("archiveutils: failed to create extraction directory: %w", err)
+	}
+
+	if kind == kindZip {
+		err = extractZip(path, dest, logger)
+	} else {
+		err = extractTar(path, dest, kind == kindTarGz, logger)
+	}
+	if err != nil {
+		os.RemoveAll(parentTempDir) // Clean up on failure
+		return "", "", "", err
+	}
+
+	root := dest
+	if entries, readErr := os.ReadDir(dest); readErr == nil && len(entries) == 1 && entries[0].IsDir() {
+		name = entries[0].Name()
+		root = filepath.Join(dest, name)
+	}
+	logger.Info("Archive extracted successfully", "archive", path, "path", root)
+	return root, name, parentTempDir, nil
+}
+
+// safeJoin joins the archive entry name to dest, rejecting names that escape dest.
+func safeJoin(dest, entryName string) (string, error) {
+	entryName = filepath.FromSlash(entryName)
+	if filepath.IsAbs(entryName) || filepath.VolumeName(entryName) != "" {
+		return "", fmt.Errorf("archiveutils: refusing to extract absolute path '%s'", entryName)
+	}
+	target := filepath.Join(dest, entryName)
+	if target != dest && !strings.HasPrefix(target, dest+string(filepath.Separator)) {
+		return "", fmt.Errorf("archiveutils: refusing to extract '%s' outside the destination directory", entryName)
+	}
+	return target, nil
+}
+
+// writeFile creates target (and its parent directories) with the given permissions and content.
+func writeFile(target string, perm os.FileMode, content io.Reader, modTime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("archiveutils: failed to create directory for '%s': %w", target, err)
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("archiveutils: failed to create '%s': %w", target, err)
+	}
+	if _, err := io.Copy(out, content); err != nil {
+		out.Close()
+		return fmt.Errorf("archiveutils: failed to extract '%s': %w", target, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("archiveutils: failed to close '%s': %w", target, err)
+	}
+	if !modTime.IsZero() {
+		_ = os.Chtimes(target, modTime, modTime) // Keep mtimes for --sort mtime; not critical
+	}
+	return nil
+}
+
+func extractZip(path, dest string, logger *slog.Logger) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("archiveutils: failed to open zip archive '%s': %w", path, err)
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		target, err := safeJoin(dest, file.Name)
+		if err != nil {
+			return err
+		}
+		mode := file.Mode()
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return fmt.Errorf("archiveutils: failed to create directory '%s': %w", target, err)
+			}
+		case mode.IsRegular():
+			content, err := file.Open()
+			if err != nil {
+				return fmt.Errorf("archiveutils: failed to read '%s' from zip archive: %w", file.Name, err)
+			}
+			err = writeFile(target, mode.Perm(), content, file.Modified)
+			content.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			logger.Debug("Archive: Skipping non-regular zip entry", "name", file.Name, "mode", mode)
+		}
+	}
+	return nil
+}
+
+func extractTar(path, dest string, gzipped bool, logger *slog.Logger) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("archiveutils: failed to open tar archive '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	var stream io.Reader = f
+	if gzipped {
+		gzipReader, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("archiveutils: failed to decompress '%s': %w", path, err)
+		}
+		defer gzipReader.Close()
+		stream = gzipReader
+	}
+
+	reader := tar.NewReader(stream)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("archiveutils: failed to read tar archive '%s': %w", path, err)
+		}
+		target, err := safeJoin(dest, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return fmt.Errorf("archiveutils: failed to create directory '%s': %w", target, err)
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, header.FileInfo().Mode().Perm(), reader, header.ModTime); err != nil {
+				return err
+			}
+		default:
+			logger.Debug("Archive: Skipping non-regular tar entry", "name", header.Name, "type", string(header.Typeflag))
+		}
+	}
+}
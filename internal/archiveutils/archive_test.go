@@ -0,0 +1,184 @@
+package archiveutils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// archiveFile is an entry of a test archive; names ending in "/" are directories.
+type archiveFile struct {
+	name    string
+	content string
+}
+
+// tarGz returns a gzip-compressed tar archive of files.
+func tarGz(t *testing.T, files []archiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		header := &tar.Header{Name: file.name, Mode: 0o644, Size: int64(len(file.content)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(file.name, "/") {
+			header = &tar.Header{Name: file.name, Mode: 0o755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// zipArchive returns a zip archive of files.
+func zipArchive(t *testing.T, files []archiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeArchive writes data to a file named name in a new temporary directory and returns its path.
+func writeArchive(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestIsArchive(t *testing.T) {
+	files := []archiveFile{{name: "a.txt", content: "a"}}
+	tests := []struct {
+		name string
+		file string
+		data []byte
+		want bool
+	}{
+		{name: "tar.gz", file: "src.tar.gz", data: tarGz(t, files), want: true},
+		{name: "tgz", file: "src.tgz", data: tarGz(t, files), want: true},
+		{name: "zip", file: "src.ZIP", data: zipArchive(t, files), want: true},
+		{name: "wrong magic bytes", file: "src.zip", data: tarGz(t, files), want: false},
+		{name: "text file", file: "notes.txt", data: []byte("PK\x03\x04"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsArchive(writeArchive(t, tt.file, tt.data)); got != tt.want {
+				t.Errorf("IsArchive(%s) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+	if IsArchive(t.TempDir() + "/dir.zip") {
+		t.Error("IsArchive() = true for a missing file")
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		data      []byte
+		wantName  string
+		wantFiles map[string]string // Slash-separated path below the returned root -> content
+	}{
+		{
+			name:      "single top-level directory becomes the root",
+			file:      "project.tar.gz",
+			data:      tarGz(t, []archiveFile{{name: "project-1.0/"}, {name: "project-1.0/main.go", content: "package main\n"}, {name: "project-1.0/lib/util.go", content: "package lib\n"}}),
+			wantName:  "project-1.0",
+			wantFiles: map[string]string{"main.go": "package main\n", "lib/util.go": "package lib\n"},
+		},
+		{
+			name:      "several top-level entries are named after the archive",
+			file:      "flat.tgz",
+			data:      tarGz(t, []archiveFile{{name: "a.txt", content: "a"}, {name: "b/c.txt", content: "c"}}),
+			wantName:  "flat",
+			wantFiles: map[string]string{"a.txt": "a", "b/c.txt": "c"},
+		},
+		{
+			name:      "zip archive",
+			file:      "code.zip",
+			data:      zipArchive(t, []archiveFile{{name: "x.py", content: "print(1)\n"}, {name: "y/z.py", content: "pass\n"}}),
+			wantName:  "code",
+			wantFiles: map[string]string{"x.py": "print(1)\n", "y/z.py": "pass\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, name, tempDir, err := ExtractArchive(writeArchive(t, tt.file, tt.data), discardLogger)
+			if err != nil {
+				t.Fatalf("ExtractArchive() error = %v", err)
+			}
+			defer os.RemoveAll(tempDir)
+			if name != tt.wantName {
+				t.Errorf("name = %q, want %q", name, tt.wantName)
+			}
+			if !strings.HasPrefix(root, tempDir) {
+				t.Errorf("root %q is not below the temporary directory %q", root, tempDir)
+			}
+			for path, want := range tt.wantFiles {
+				got, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+				if err != nil {
+					t.Errorf("extracted file %s: %v", path, err)
+				} else if string(got) != want {
+					t.Errorf("extracted file %s = %q, want %q", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractArchiveRejectsZipSlip(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data []byte
+	}{
+		{name: "tar parent traversal", file: "evil.tar.gz", data: tarGz(t, []archiveFile{{name: "../escaped.txt", content: "x"}})},
+		{name: "tar nested traversal", file: "evil.tgz", data: tarGz(t, []archiveFile{{name: "a/../../escaped.txt", content: "x"}})},
+		{name: "tar absolute path", file: "abs.tar.gz", data: tarGz(t, []archiveFile{{name: "/tmp/escaped.txt", content: "x"}})},
+		{name: "zip parent traversal", file: "evil.zip", data: zipArchive(t, []archiveFile{{name: "../escaped.txt", content: "x"}})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, tempDir, err := ExtractArchive(writeArchive(t, tt.file, tt.data), discardLogger)
+			if err == nil {
+				os.RemoveAll(tempDir)
+				t.Fatal("ExtractArchive() error = nil, want a path traversal error")
+			}
+			if !strings.Contains(err.Error(), "refusing to extract") {
+				t.Errorf("ExtractArchive() error = %v, want a path traversal error", err)
+			}
+		})
+	}
+}
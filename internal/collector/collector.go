@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	ignore "github.com/sabhiram/go-gitignore"
@@ -42,28 +43,93 @@ var textualButNonCode = map[string]bool{
 	".bat":  true,
 }
 
-var codeLangByExt = map[string]string{
+// LanguageByExtension maps lower-case file extensions to the language name used as code fence hint.
+var LanguageByExtension = map[string]string{
 	".go":    "go",
 	".js":    "javascript",
+	".mjs":   "javascript",
+	".cjs":   "javascript",
+	".jsx":   "javascript",
 	".ts":    "typescript",
+	".tsx":   "typescript",
 	".py":    "python",
+	".pyi":   "python",
 	".java":  "java",
 	".rb":    "ruby",
 	".rs":    "rust",
 	".cpp":   "cpp",
+	".cc":    "cpp",
+	".cxx":   "cpp",
+	".hpp":   "cpp",
+	".hh":    "cpp",
+	".hxx":   "cpp",
 	".c":     "c",
 	".h":     "c",
 	".cs":    "csharp",
 	".php":   "php",
 	".swift": "swift",
 	".kt":    "kotlin",
+	".kts":   "kotlin",
 	".sql":   "sql",
 	".html":  "html",
+	".htm":   "html",
 	".css":   "css",
 	".xml":   "xml",
 	".sh":    "bash",
+	".bash":  "bash",
 	".bat":   "bat",
 	".ps1":   "powershell",
+	".md":    "markdown",
+	".mdx":   "markdown",
+	".rst":   "restructuredtext",
+	".adoc":  "asciidoc",
+	".txt":   "text",
+}
+
+// LanguageOf returns the language of the file at path based on its extension, or "" if it is unknown.
+func LanguageOf(path string) string {
+	return LanguageByExtension[strings.ToLower(filepath.Ext(path))]
+}
+
+// ExtensionsForLanguages resolves language names (e.g. "python") to all known extensions for them.
+// Names are matched case-insensitively; a known extension without its dot (e.g. "ts") selects its language.
+func ExtensionsForLanguages(names []string) ([]string, error) {
+	var exts []string
+	for _, name := range names {
+		lang := strings.ToLower(strings.TrimSpace(name))
+		if lang == "" {
+			continue
+		}
+		if aliased, ok := LanguageByExtension["."+strings.TrimPrefix(lang, ".")]; ok {
+			lang = aliased
+		}
+		found := false
+		for ext, extLang := range LanguageByExtension {
+			if extLang == lang {
+				exts = append(exts, ext)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown language '%s'. Supported languages: %s", name, strings.Join(SupportedLanguages(), ", "))
+		}
+	}
+	sort.Strings(exts)
+	return exts, nil
+}
+
+// SupportedLanguages returns the sorted, de-duplicated language names known to LanguageByExtension.
+func SupportedLanguages() []string {
+	seen := make(map[string]bool)
+	var langs []string
+	for _, lang := range LanguageByExtension {
+		if !seen[lang] {
+			seen[lang] = true
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+	return langs
 }
 
 func Run(ctx context.Context, w io.Writer, opts Options) error {
@@ -145,7 +211,7 @@ func Run(ctx context.Context, w io.Writer, opts Options) error {
 
 	for _, p := range paths {
 		ext := strings.ToLower(filepath.Ext(p))
-		lang := codeLangByExt[ext]
+		lang := LanguageByExtension[ext]
 		// Header
 		fmt.Fprintf(bufw, "```%s %s\n", lang, p)
 		// Content
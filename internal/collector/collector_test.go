@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtensionsForLanguages(t *testing.T) {
+	tests := []struct {
+		name    string
+		langs   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "python", langs: []string{"python"}, want: []string{".py", ".pyi"}},
+		{name: "cpp", langs: []string{"cpp"}, want: []string{".cc", ".cpp", ".cxx", ".hh", ".hpp", ".hxx"}},
+		{name: "c excludes cpp", langs: []string{"c"}, want: []string{".c", ".h"}},
+		{name: "case-insensitive", langs: []string{"  Python "}, want: []string{".py", ".pyi"}},
+		{name: "extension alias", langs: []string{"ts"}, want: []string{".ts", ".tsx"}},
+		{name: "several", langs: []string{"go", "python"}, want: []string{".go", ".py", ".pyi"}},
+		{name: "blank entries are ignored", langs: []string{"", "go"}, want: []string{".go"}},
+		{name: "unknown", langs: []string{"go", "cobol"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtensionsForLanguages(tt.langs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ExtensionsForLanguages(%q) = %v, want an error", tt.langs, got)
+				}
+				// The error lists the supported languages
+				if msg := err.Error(); !strings.Contains(msg, "cobol") || !strings.Contains(msg, "python") {
+					t.Errorf("error %q should name the unknown and the supported languages", msg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExtensionsForLanguages(%q) error = %v", tt.langs, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtensionsForLanguages(%q) = %v, want %v", tt.langs, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package filefilter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// contentScanBytes caps how much of a file is searched for FilterConfig.ExcludeContentRegex,
+// so large files cost no more than their first 64 KiB.
+const contentScanBytes = 64 * 1024
+
+// contentMatches reports whether re matches the first contentScanBytes of the file at path.
+func contentMatches(path string, re *regexp.Regexp) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("filefilter: failed to open '%s' to match its content: %w", path, err)
+	}
+	defer f.Close()
+
+	prefix, err := io.ReadAll(io.LimitReader(f, contentScanBytes))
+	if err != nil {
+		return false, fmt.Errorf("filefilter: failed to read '%s' to match its content: %w", path, err)
+	}
+	return re.Match(prefix), nil
+}
@@ -0,0 +1,101 @@
+package filefilter
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestContentMatches(t *testing.T) {
+	re := regexp.MustCompile(`Licensed under the Apache License`)
+	const marker = "// Licensed under the Apache License\n"
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "match at the start", content: marker + "package x\n", want: true},
+		{name: "match at the end of the first 64 KiB", content: strings.Repeat("x", contentScanBytes-len(marker)) + marker, want: true},
+		{name: "match across the limit", content: strings.Repeat("x", contentScanBytes-10) + marker, want: false},
+		{name: "match after 64 KiB", content: strings.Repeat("x", contentScanBytes) + marker, want: false},
+		{name: "no match", content: "package x\n", want: false},
+		{name: "empty", content: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.go")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := contentMatches(path, re)
+			if err != nil {
+				t.Fatalf("contentMatches() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("contentMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("unreadable", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, path := range []string{filepath.Join(dir, "missing.go"), dir} {
+			if _, err := contentMatches(path, re); err == nil {
+				t.Errorf("contentMatches(%s) error = nil, want an error", path)
+			}
+		}
+	})
+}
+
+func TestExcludeContentRegex(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"licensed.go": "// Licensed under the Apache License\npackage x\n",
+		"late.go":     strings.Repeat("\n", contentScanBytes) + "// Licensed under the Apache License\n",
+		"plain.go":    "package x\n",
+		"vanished.go": "// Licensed under the Apache License\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ff, err := NewFileFilter(root, FilterConfig{ExcludeContentRegex: `Licensed under the Apache License`})
+	if err != nil {
+		t.Fatalf("NewFileFilter() error = %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want Reason
+	}{
+		{path: "licensed.go", want: ReasonContent},
+		{path: "late.go", want: ""},
+		{path: "plain.go", want: ""},
+	}
+	for _, tt := range tests {
+		if got := exclusionReason(t, ff, root, tt.path); got != tt.want {
+			t.Errorf("ExclusionReason(%s) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	// A file that cannot be read (here: removed after it was listed) is not excluded by its content.
+	vanished := filepath.Join(root, "vanished.go")
+	info, err := os.Lstat(vanished)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(vanished); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ff.ExclusionReason(vanished, fs.FileInfoToDirEntry(info), nil); got != "" || err != nil {
+		t.Errorf("ExclusionReason(vanished.go) = %q, %v, want \"\", nil", got, err)
+	}
+
+	if _, err := NewFileFilter(root, FilterConfig{ExcludeContentRegex: "a("}); err == nil {
+		t.Error("NewFileFilter() with an invalid content regex: error = nil, want an error")
+	}
+}
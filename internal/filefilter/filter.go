@@ -5,21 +5,36 @@ import (
 	"io/fs"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/alexferrari88/code2context/internal/utils"
-	gitignore "github.com/sabhiram/go-gitignore"
 )
 
 type FilterConfig struct {
 	MaxFileSize                    int64
+	KeepLargeFiles                 bool      // Don't exclude files above MaxFileSize (the processor writes only part of them)
+	MinFileSize                    int64     // 0 means no minimum
+	ModifiedSince                  time.Time // If non-zero, files last modified before this time are excluded (directories are still walked)
 	UserExcludeDirs                []string
 	UserExcludeExts                []string
+	UserIncludeExts                []string // If non-empty, only files with one of these extensions are included
+	UserIncludeGlobs               []string // If non-empty, only files matching one of these glob patterns ("**" allowed) are included
 	UserExcludeGlobs               []string
+	UserExcludeRegexes             []string // Regular expressions matched against the slash-separated relative path
+	UserExcludeDirRegexes          []string // Regular expressions matched against the slash-separated relative path of directories, which are pruned
+	ExcludeContentRegex            string   // If set, files whose first 64 KiB match this regular expression are excluded
 	SkipAuxFiles                   bool
+	SkipEmptyFiles                 bool
+	SkipHidden                     bool     // Exclude files and directories whose name starts with "."
+	CaseInsensitive                bool     // Match directory names, globs, and file names case-insensitively (not .gitignore rules)
+	RestrictToPaths                []string // If non-nil, only these slash-separated relative file paths can be included
 	DefaultExcludeDirs             []string
+	OwnVendorPrefixes              []string // Go module path prefixes (e.g. "github.com/me") whose packages in a default-excluded vendor directory are included
 	DefaultMediaExts               []string
 	DefaultArchiveExts             []string
 	DefaultExecExts                []string
@@ -27,13 +42,34 @@ type FilterConfig struct {
 	DefaultMiscellaneousFileNames  []string
 	DefaultMiscellaneousExtensions []string
 	DefaultAuxExts                 []string
-	FinalOutputFilePath            string // Absolute path to the final output file
+	ExcludeVendored                bool         // Also skip VendoredDirs, VendoredFilePatterns, and files with a generated-code header
+	VendoredDirs                   []string     // Directory names treated as vendored code (e.g. "third_party")
+	VendoredFilePatterns           []string     // Glob patterns matched against the base name (e.g. "*.min.js")
+	ExcludeGenerated               bool         // Skip files with a generated-code header (also done by ExcludeVendored)
+	ExcludeMinified                bool         // Skip minified files, by name (".min.") or by their average line length (see IsMinifiedFile)
+	IgnoreGitignore                bool         // Don't apply the ignore stack passed to IsExcluded (.gitignore and extra ignore files)
+	ExportIgnore                   *IgnoreRules // If set, paths matching these export-ignore patterns (see gitutils.ExportIgnorePatterns) are excluded
+	FinalOutputFilePath            string       // Absolute path to the final output file
+	ExcludeOutputParts             bool         // Also exclude numbered parts of the output file ("name.partN.ext")
+	Logger                         *slog.Logger // Receives the filter's debug logs; nil discards them
+
+	// CustomExclude, if set, is consulted before the built-in rules. When it reports handled=true its
+	// decision is authoritative: exclude decides inclusion and skipDir prunes an excluded directory.
+	// When handled is false, the built-in rules run as usual.
+	CustomExclude func(absPath string, d fs.DirEntry) (exclude bool, skipDir bool, handled bool)
 }
 
 type FileFilter struct {
 	config                 FilterConfig
-	basePath               string // Absolute path to the root of processing
-	absFinalOutputFilePath string // Store the absolute output file path
+	basePath               string           // Absolute path to the root of processing
+	absFinalOutputFilePath string           // Store the absolute output file path
+	userExcludeRegexps     []*regexp.Regexp // Compiled from config.UserExcludeRegexes
+	userExcludeDirRegexps  []*regexp.Regexp // Compiled from config.UserExcludeDirRegexes
+	contentExcludeRegexp   *regexp.Regexp   // Compiled from config.ExcludeContentRegex; nil if unset
+	restrictedFiles        map[string]bool  // Set of config.RestrictToPaths; nil when unrestricted
+	restrictedDirs         map[string]bool  // Ancestor directories of restrictedFiles
+	excludedPaths          map[string]bool  // Absolute paths excluded explicitly via ExcludePaths
+	logger                 *slog.Logger
 }
 
 func NewFileFilter(basePath string, config FilterConfig) (*FileFilter, error) {
@@ -50,82 +86,321 @@ func NewFileFilter(basePath string, config FilterConfig) (*FileFilter, error) {
 		}
 	}
 
+	excludeRegexps, err := compileRegexes(config.UserExcludeRegexes, "exclude regex")
+	if err != nil {
+		return nil, err
+	}
+	excludeDirRegexps, err := compileRegexes(config.UserExcludeDirRegexes, "exclude dirs regex")
+	if err != nil {
+		return nil, err
+	}
+
+	var contentRegexp *regexp.Regexp
+	if config.ExcludeContentRegex != "" {
+		if contentRegexp, err = regexp.Compile(config.ExcludeContentRegex); err != nil {
+			return nil, fmt.Errorf("NewFileFilter: invalid exclude content regex '%s': %w", config.ExcludeContentRegex, err)
+		}
+	}
+
+	var restrictedFiles, restrictedDirs map[string]bool
+	if config.RestrictToPaths != nil {
+		restrictedFiles = make(map[string]bool, len(config.RestrictToPaths))
+		restrictedDirs = make(map[string]bool)
+		for _, restrictedPath := range config.RestrictToPaths {
+			restrictedPath = path.Clean(filepath.ToSlash(restrictedPath))
+			restrictedFiles[restrictedPath] = true
+			for dir := path.Dir(restrictedPath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+				restrictedDirs[dir] = true
+			}
+		}
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+
 	return &FileFilter{
 		config:                 config,
 		basePath:               absBasePath,
 		absFinalOutputFilePath: absOutputFilePath,
+		userExcludeRegexps:     excludeRegexps,
+		userExcludeDirRegexps:  excludeDirRegexps,
+		contentExcludeRegexp:   contentRegexp,
+		restrictedFiles:        restrictedFiles,
+		restrictedDirs:         restrictedDirs,
+		logger:                 logger,
 	}, nil
 }
 
+// compileRegexes compiles the non-empty expressions; kind names them in the error (e.g. "exclude regex").
+func compileRegexes(exprs []string, kind string) ([]*regexp.Regexp, error) {
+	var regexps []*regexp.Regexp
+	for _, expr := range exprs {
+		if expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("NewFileFilter: invalid %s '%s': %w", kind, expr, err)
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
+// ExcludePaths marks the given absolute paths as excluded, e.g. files deselected by the user.
+func (ff *FileFilter) ExcludePaths(absPaths ...string) {
+	if ff.excludedPaths == nil {
+		ff.excludedPaths = make(map[string]bool, len(absPaths))
+	}
+	for _, absPath := range absPaths {
+		ff.excludedPaths[absPath] = true
+	}
+}
+
+// IsOutput reports whether absPath is the output file or, when splitting, one of its parts.
+func (ff *FileFilter) IsOutput(absPath string) bool {
+	if ff.absFinalOutputFilePath != "" && absPath == ff.absFinalOutputFilePath {
+		return true
+	}
+	return ff.config.ExcludeOutputParts && ff.isOutputPart(absPath)
+}
+
+// isOutputPart reports whether absPath is a numbered part of the output file,
+// e.g. "/out/repo.part3.txt" for the output file "/out/repo.txt".
+func (ff *FileFilter) isOutputPart(absPath string) bool {
+	if ff.absFinalOutputFilePath == "" || filepath.Dir(absPath) != filepath.Dir(ff.absFinalOutputFilePath) {
+		return false
+	}
+	ext := filepath.Ext(ff.absFinalOutputFilePath)
+	stem := strings.TrimSuffix(filepath.Base(ff.absFinalOutputFilePath), ext)
+	baseName := filepath.Base(absPath)
+	if !strings.HasPrefix(baseName, stem+".part") || !strings.HasSuffix(baseName, ext) {
+		return false
+	}
+	number := strings.TrimSuffix(strings.TrimPrefix(baseName, stem+".part"), ext)
+	return number != "" && strings.Trim(number, "0123456789") == ""
+}
+
+// fold returns s lowercased if names are matched case-insensitively, and unchanged otherwise.
+func (ff *FileFilter) fold(s string) string {
+	if ff.config.CaseInsensitive {
+		return strings.ToLower(s)
+	}
+	return s
+}
+
+// matchesExcludedDir reports whether a directory matches an exclude-dirs entry. Entries containing
+// a slash (e.g. "internal/testdata") are matched against the slash-separated relative path of the
+// directory; bare names (e.g. "testdata") match a directory of that name at any depth.
+// Entries with glob metacharacters (e.g. "*-generated") are matched as patterns, otherwise exactly.
+func matchesExcludedDir(entry, baseName, relPath string) bool {
+	entry = strings.Trim(filepath.ToSlash(entry), "/")
+	target := baseName
+	if strings.Contains(entry, "/") {
+		entry = path.Clean(entry)
+		target = relPath
+	}
+	if strings.ContainsAny(entry, "*?[") {
+		matched, _ := path.Match(entry, target)
+		return matched
+	}
+	return target == entry
+}
+
 // IsExcluded checks if a file or directory should be excluded.
-// `activeGitIgnores` is a slice of gitignore.GitIgnore objects, ordered from root to most specific.
+// `activeIgnores` is a slice of compiled ignore files, ordered from root to most specific.
 // The path provided to this function should be absolute.
-func (ff *FileFilter) IsExcluded(absPath string, d fs.DirEntry, activeGitIgnores []*gitignore.GitIgnore) (bool, error) {
+func (ff *FileFilter) IsExcluded(absPath string, d fs.DirEntry, activeIgnores []*IgnoreRules) (bool, error) {
+	reason, err := ff.ExclusionReason(absPath, d, activeIgnores)
+	return reason != "", err
+}
+
+// ExclusionReason is IsExcluded reporting why a path is excluded: the Reason of the first rule
+// that matched, or "" if the path is included. The error is filepath.SkipDir for an excluded
+// directory whose contents need not be visited, as with IsExcluded.
+func (ff *FileFilter) ExclusionReason(absPath string, d fs.DirEntry, activeIgnores []*IgnoreRules) (Reason, error) {
 	// 0. Highest Priority: Never include the output file itself.
 	if ff.absFinalOutputFilePath != "" && absPath == ff.absFinalOutputFilePath {
-		slog.Debug("Filter: Skipping the output file itself", "path", absPath)
-		return true, nil // Or SkipDir if it's a directory, though unlikely for the output file.
+		ff.logger.Debug("Filter: Skipping the output file itself", "path", absPath)
+		return ReasonOutput, nil // Or SkipDir if it's a directory, though unlikely for the output file.
+	}
+
+	if ff.config.ExcludeOutputParts && ff.isOutputPart(absPath) {
+		ff.logger.Debug("Filter: Skipping a part of the split output file", "path", absPath)
+		return ReasonOutput, nil
+	}
+
+	// 0a. Paths excluded explicitly after the fact (e.g. deselected interactively)
+	if ff.excludedPaths[absPath] {
+		ff.logger.Debug("Filter: Skipping explicitly excluded path", "path", absPath)
+		return ReasonDeselected, nil
+	}
+
+	// 0b. Custom hook supplied by embedders; authoritative when it handles the path.
+	if ff.config.CustomExclude != nil {
+		if exclude, skipDir, handled := ff.config.CustomExclude(absPath, d); handled {
+			ff.logger.Debug("Filter: Decision made by custom exclude hook", "path", absPath, "exclude", exclude, "skip_dir", skipDir)
+			if exclude && skipDir {
+				return ReasonCustom, filepath.SkipDir
+			}
+			if exclude {
+				return ReasonCustom, nil
+			}
+			return "", nil
+		}
 	}
 
 	info, err := d.Info()
 	if err != nil {
 		// Handle broken symlinks gracefully
 		if d.Type()&fs.ModeSymlink != 0 && os.IsNotExist(err) {
-			slog.Debug("Filter: Skipping broken symbolic link", "path", absPath)
-			return true, nil
+			ff.logger.Debug("Filter: Skipping broken symbolic link", "path", absPath)
+			return ReasonSymlink, nil
 		}
-		slog.Warn("Filter: Failed to get file info", "path", absPath, "error", err)
-		return false, fmt.Errorf("filter: could not get file info for '%s': %w", absPath, err)
+		ff.logger.Warn("Filter: Failed to get file info", "path", absPath, "error", err)
+		return "", fmt.Errorf("filter: could not get file info for '%s': %w", absPath, err)
 	}
 
 	relPath, err := filepath.Rel(ff.basePath, absPath)
 	if err != nil {
-		slog.Warn("Filter: Could not get relative path", "base", ff.basePath, "target", absPath, "error", err)
+		ff.logger.Warn("Filter: Could not get relative path", "base", ff.basePath, "target", absPath, "error", err)
 		relPath = filepath.Base(absPath)
 	}
 	relPath = filepath.ToSlash(relPath)
 	baseName := filepath.Base(absPath)
 
-	// 0b. Symbolic links (always excluded, moved after output file check)
+	// 0d. Symbolic links (always excluded, moved after output file check)
 	if info.Mode()&os.ModeSymlink != 0 {
-		slog.Debug("Filter: Skipping symbolic link", "path", relPath)
-		return true, nil
+		ff.logger.Debug("Filter: Skipping symbolic link", "path", relPath)
+		return ReasonSymlink, nil
 	}
 
-	// 1. Default and User-defined Directory Name Exclusions
+	// 0d2. Named pipes, sockets, and devices: reading them could block forever or never end
+	if !info.IsDir() && !info.Mode().IsRegular() {
+		ff.logger.Debug("Filter: Skipping special file", "path", relPath, "mode", info.Mode().String())
+		return ReasonSpecial, nil
+	}
+
+	// 0e. Restriction to an explicit set of paths (e.g. files changed since --diff-base)
+	if ff.restrictedFiles != nil {
+		if info.IsDir() && relPath != "." && !ff.restrictedDirs[relPath] {
+			ff.logger.Debug("Filter: Skipping directory without restricted paths", "path", relPath)
+			return ReasonNotSelected, filepath.SkipDir
+		}
+		if !info.IsDir() && !ff.restrictedFiles[relPath] {
+			ff.logger.Debug("Filter: Skipping file not in restricted paths", "path", relPath)
+			return ReasonNotSelected, nil
+		}
+	}
+
+	// 0f. Hidden (dot-prefixed) files and directories, except the root itself
+	if ff.config.SkipHidden && relPath != "." && strings.HasPrefix(baseName, ".") {
+		ff.logger.Debug("Filter: Skipping hidden entry", "path", relPath)
+		if info.IsDir() {
+			return ReasonHidden, filepath.SkipDir
+		}
+		return ReasonHidden, nil
+	}
+
+	// 1. Default and User-defined Directory Name Exclusions. With OwnVendorPrefixes, the default
+	// exclusion of a Go vendor directory is narrowed to the packages of other modules.
+	importPath, inVendor := vendoredImportPath(ff.fold(relPath))
+	ownVendor := inVendor && ff.ownVendorActive()
+	if ownVendor && !ff.isOwnVendored(importPath, info.IsDir()) {
+		ff.logger.Debug("Filter: Skipping vendored package of another module", "path", relPath, "import_path", importPath)
+		if info.IsDir() {
+			return ReasonExcludedDir, filepath.SkipDir
+		}
+		return ReasonExcludedDir, nil
+	}
 	if info.IsDir() {
 		allExcludeDirs := append(ff.config.DefaultExcludeDirs, ff.config.UserExcludeDirs...)
-		for _, excludedDirName := range allExcludeDirs {
-			if baseName == excludedDirName {
-				slog.Debug("Filter: Skipping directory by name", "path", relPath, "rule", excludedDirName)
-				return true, filepath.SkipDir
+		for i, excludedDir := range allExcludeDirs {
+			if ownVendor && i < len(ff.config.DefaultExcludeDirs) && ff.fold(excludedDir) == ff.fold(goVendorDir) {
+				continue // Walked for the own packages, checked above
+			}
+			if matchesExcludedDir(ff.fold(excludedDir), ff.fold(baseName), ff.fold(relPath)) {
+				ff.logger.Debug("Filter: Skipping directory by name", "path", relPath, "rule", excludedDir)
+				return ReasonExcludedDir, filepath.SkipDir
+			}
+		}
+		for _, re := range ff.userExcludeDirRegexps {
+			if relPath != "." && re.MatchString(relPath) {
+				ff.logger.Debug("Filter: Skipping directory by user regex", "path", relPath, "regex", re.String())
+				return ReasonExcludedDir, filepath.SkipDir
+			}
+		}
+		if ff.config.ExcludeVendored {
+			for _, vendoredDir := range ff.config.VendoredDirs {
+				if matchesExcludedDir(ff.fold(vendoredDir), ff.fold(baseName), ff.fold(relPath)) {
+					ff.logger.Debug("Filter: Skipping vendored directory", "path", relPath, "rule", vendoredDir)
+					return ReasonVendored, filepath.SkipDir
+				}
+			}
+		}
+	}
+
+	// 1a. Git internals under any name (e.g. a bare repository or a worktree's git dir); not the root itself
+	if info.IsDir() && relPath != "." && IsGitDir(absPath) {
+		ff.logger.Debug("Filter: Skipping git directory", "path", relPath)
+		return ReasonGitDir, filepath.SkipDir
+	}
+
+	// 2. Gitignore check (including any extra ignore files). All levels are evaluated and the last
+	// matching rule wins (git semantics), so deeper negations can re-include paths ignored by a parent .gitignore.
+	// Skipped entirely with IgnoreGitignore, so ignored paths are only subject to the other rules.
+	if !ff.config.IgnoreGitignore {
+		if ignored, level, rule := matchIgnoreStack(activeIgnores, absPath, info.IsDir()); ignored {
+			ff.logger.Debug("Filter: Path ignored by ignore file", "path", relPath, "gitignore_at_level", level, "rule", rule)
+			if info.IsDir() {
+				return ReasonIgnored, filepath.SkipDir
 			}
+			return ReasonIgnored, nil
 		}
 	}
 
-	// 2. Gitignore check
-	for i := len(activeGitIgnores) - 1; i >= 0; i-- {
-		matcher := activeGitIgnores[i]
-		if matcher != nil && matcher.MatchesPath(absPath) {
-			slog.Debug("Filter: Path ignored by .gitignore", "path", relPath, "gitignore_at_level", i)
+	// 2a. Paths kept out of `git archive` by .gitattributes, whatever IgnoreGitignore says
+	if ff.config.ExportIgnore != nil {
+		if _, ignored, rule := ff.config.ExportIgnore.match(absPath, info.IsDir()); ignored {
+			ff.logger.Debug("Filter: Skipping export-ignore path", "path", relPath, "rule", rule)
 			if info.IsDir() {
-				return true, filepath.SkipDir
+				return ReasonExportIgnore, filepath.SkipDir
 			}
-			return true, nil
+			return ReasonExportIgnore, nil
 		}
 	}
 
 	if info.IsDir() {
-		return false, nil
+		return "", nil
 	}
 
 	// 3. Max file size
-	if ff.config.MaxFileSize > 0 && info.Size() > ff.config.MaxFileSize {
-		slog.Info("Filter: Skipping large file",
+	if ff.config.MaxFileSize > 0 && info.Size() > ff.config.MaxFileSize && !ff.config.KeepLargeFiles {
+		ff.logger.Info("Filter: Skipping large file",
 			"path", relPath,
 			"size", utils.FormatBytes(uint64(info.Size())),
 			"limit", utils.FormatBytes(uint64(ff.config.MaxFileSize)))
-		return true, nil
+		return ReasonTooLarge, nil
+	}
+
+	// 3b. Min file size (a file must fall within [min, max])
+	if ff.config.MinFileSize > 0 && info.Size() < ff.config.MinFileSize {
+		ff.logger.Debug("Filter: Skipping small file",
+			"path", relPath,
+			"size", utils.FormatBytes(uint64(info.Size())),
+			"minimum", utils.FormatBytes(uint64(ff.config.MinFileSize)))
+		return ReasonTooSmall, nil
+	}
+
+	// 3c. Modification time
+	if !ff.config.ModifiedSince.IsZero() && info.ModTime().Before(ff.config.ModifiedSince) {
+		ff.logger.Debug("Filter: Skipping file modified before the cutoff",
+			"path", relPath,
+			"modified", info.ModTime().Format(time.RFC3339),
+			"cutoff", ff.config.ModifiedSince.Format(time.RFC3339))
+		return ReasonTooOld, nil
 	}
 
 	fileExt := strings.ToLower(filepath.Ext(absPath))
@@ -133,8 +408,38 @@ func (ff *FileFilter) IsExcluded(absPath string, d fs.DirEntry, activeGitIgnores
 	// 4. User-defined excluded extensions
 	for _, excludedExt := range ff.config.UserExcludeExts {
 		if excludedExt != "" && fileExt == excludedExt {
-			slog.Debug("Filter: Skipping by user-excluded extension", "path", relPath, "ext", fileExt)
-			return true, nil
+			ff.logger.Debug("Filter: Skipping by user-excluded extension", "path", relPath, "ext", fileExt)
+			return ReasonExtension, nil
+		}
+	}
+
+	// 4b. User-defined included extensions (allowlist)
+	if len(ff.config.UserIncludeExts) > 0 {
+		allowed := false
+		for _, includedExt := range ff.config.UserIncludeExts {
+			if fileExt == includedExt {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			ff.logger.Debug("Filter: Skipping file not in included extensions", "path", relPath, "ext", fileExt)
+			return ReasonExtension, nil
+		}
+	}
+
+	// 4c. Included glob patterns (allowlist, e.g. from .contextinclude); the exclusion rules still apply
+	if len(ff.config.UserIncludeGlobs) > 0 {
+		allowed := false
+		for _, pattern := range ff.config.UserIncludeGlobs {
+			if matchGlob(ff.fold(pattern), ff.fold(relPath)) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			ff.logger.Debug("Filter: Skipping file not matching included patterns", "path", relPath)
+			return ReasonPattern, nil
 		}
 	}
 
@@ -143,72 +448,86 @@ func (ff *FileFilter) IsExcluded(absPath string, d fs.DirEntry, activeGitIgnores
 		if pattern == "" {
 			continue
 		}
-		matchedRel, _ := filepath.Match(pattern, relPath)
+		matchedRel, _ := filepath.Match(ff.fold(pattern), ff.fold(relPath))
 		if matchedRel {
-			slog.Debug("Filter: Skipping by user glob pattern (relative path)", "path", relPath, "pattern", pattern)
-			return true, nil
+			ff.logger.Debug("Filter: Skipping by user glob pattern (relative path)", "path", relPath, "pattern", pattern)
+			return ReasonPattern, nil
 		}
-		matchedBase, _ := filepath.Match(pattern, baseName)
+		matchedBase, _ := filepath.Match(ff.fold(pattern), ff.fold(baseName))
 		if matchedBase {
-			slog.Debug("Filter: Skipping by user glob pattern (basename)", "path", relPath, "pattern", pattern)
-			return true, nil
+			ff.logger.Debug("Filter: Skipping by user glob pattern (basename)", "path", relPath, "pattern", pattern)
+			return ReasonPattern, nil
 		}
 	}
 
+	// 5b. User-defined excluded regular expressions
+	for _, re := range ff.userExcludeRegexps {
+		if re.MatchString(relPath) {
+			ff.logger.Debug("Filter: Skipping by user regex", "path", relPath, "regex", re.String())
+			return ReasonPattern, nil
+		}
+	}
+
+	// 5c. Empty (zero-byte) files
+	if ff.config.SkipEmptyFiles && info.Mode().IsRegular() && info.Size() == 0 {
+		ff.logger.Debug("Filter: Skipping empty file", "path", relPath)
+		return ReasonEmpty, nil
+	}
+
 	// 6. Executable check
 	if runtime.GOOS != "windows" && (info.Mode()&0111 != 0) {
-		slog.Debug("Filter: Skipping executable by POSIX permission", "path", relPath)
-		return true, nil
+		ff.logger.Debug("Filter: Skipping executable by POSIX permission", "path", relPath)
+		return ReasonExecutable, nil
 	}
 	for _, execExt := range ff.config.DefaultExecExts {
 		if fileExt == execExt {
-			slog.Debug("Filter: Skipping executable by extension", "path", relPath, "ext", fileExt)
-			return true, nil
+			ff.logger.Debug("Filter: Skipping executable by extension", "path", relPath, "ext", fileExt)
+			return ReasonExecutable, nil
 		}
 	}
 	if fileExt == "" && runtime.GOOS != "windows" && (info.Mode()&0111 != 0) {
-		slog.Debug("Filter: Skipping executable (no extension, POSIX permission)", "path", relPath)
-		return true, nil
+		ff.logger.Debug("Filter: Skipping executable (no extension, POSIX permission)", "path", relPath)
+		return ReasonExecutable, nil
 	}
 
 	// 7. Media file extensions
 	for _, mediaExt := range ff.config.DefaultMediaExts {
 		if fileExt == mediaExt {
-			slog.Debug("Filter: Skipping media file by extension", "path", relPath, "ext", fileExt)
-			return true, nil
+			ff.logger.Debug("Filter: Skipping media file by extension", "path", relPath, "ext", fileExt)
+			return ReasonMedia, nil
 		}
 	}
 
 	// 8. Archive file extensions
 	for _, archiveExt := range ff.config.DefaultArchiveExts {
 		if fileExt == archiveExt {
-			slog.Debug("Filter: Skipping archive file by extension", "path", relPath, "ext", archiveExt)
-			return true, nil
+			ff.logger.Debug("Filter: Skipping archive file by extension", "path", relPath, "ext", archiveExt)
+			return ReasonArchive, nil
 		}
 	}
 
 	// 9. Lock file patterns
 	for _, lockPattern := range ff.config.DefaultLockfilePatterns {
-		matched, _ := filepath.Match(lockPattern, baseName)
+		matched, _ := filepath.Match(ff.fold(lockPattern), ff.fold(baseName))
 		if matched {
-			slog.Debug("Filter: Skipping lock file", "path", relPath, "pattern", lockPattern)
-			return true, nil
+			ff.logger.Debug("Filter: Skipping lock file", "path", relPath, "pattern", lockPattern)
+			return ReasonLockfile, nil
 		}
 	}
 
 	// 9b. Miscellaneous extensions
 	for _, miscExt := range ff.config.DefaultMiscellaneousExtensions {
 		if fileExt == miscExt {
-			slog.Debug("Filter: Skipping miscellaneous file by extension", "path", relPath, "ext", miscExt)
-			return true, nil
+			ff.logger.Debug("Filter: Skipping miscellaneous file by extension", "path", relPath, "ext", miscExt)
+			return ReasonMiscellaneous, nil
 		}
 	}
 
 	// 9c. Miscellaneous file names
 	for _, miscName := range ff.config.DefaultMiscellaneousFileNames {
-		if baseName == miscName {
-			slog.Debug("Filter: Skipping miscellaneous file by name", "path", relPath, "name", miscName)
-			return true, nil
+		if ff.fold(baseName) == ff.fold(miscName) {
+			ff.logger.Debug("Filter: Skipping miscellaneous file by name", "path", relPath, "name", miscName)
+			return ReasonMiscellaneous, nil
 		}
 	}
 
@@ -223,7 +542,7 @@ func (ff *FileFilter) IsExcluded(absPath string, d fs.DirEntry, activeGitIgnores
 					break
 				}
 			} else if strings.Contains(auxPattern, "*") || strings.Contains(auxPattern, "?") {
-				matched, _ := filepath.Match(auxPattern, baseName)
+				matched, _ := filepath.Match(ff.fold(auxPattern), ff.fold(baseName))
 				if matched {
 					isAux = true
 					break
@@ -240,10 +559,48 @@ func (ff *FileFilter) IsExcluded(absPath string, d fs.DirEntry, activeGitIgnores
 			}
 		}
 		if isAux {
-			slog.Debug("Filter: Skipping auxiliary file", "path", relPath, "rule_type", "aux-skip")
-			return true, nil
+			ff.logger.Debug("Filter: Skipping auxiliary file", "path", relPath, "rule_type", "aux-skip")
+			return ReasonAuxiliary, nil
+		}
+	}
+
+	// 11. Vendored code: minified bundles
+	if ff.config.ExcludeVendored {
+		for _, pattern := range ff.config.VendoredFilePatterns {
+			if matched, _ := filepath.Match(ff.fold(pattern), ff.fold(baseName)); matched {
+				ff.logger.Debug("Filter: Skipping vendored file by pattern", "path", relPath, "pattern", pattern)
+				return ReasonVendored, nil
+			}
+		}
+	}
+
+	// 12. Files with a generated-code header (read last, as it opens the file)
+	if (ff.config.ExcludeVendored || ff.config.ExcludeGenerated) && isGeneratedFile(absPath, ff.logger) {
+		ff.logger.Debug("Filter: Skipping generated file", "path", relPath)
+		return ReasonGenerated, nil
+	}
+
+	// 12b. Minified files, by name or by the length of their first lines (reads the file as well)
+	if ff.config.ExcludeMinified {
+		minified, err := IsMinifiedFile(absPath)
+		if err != nil {
+			ff.logger.Warn("Filter: Could not check for minified content", "path", relPath, "error", err)
+		} else if minified {
+			ff.logger.Debug("Filter: Skipping minified file", "path", relPath)
+			return ReasonMinified, nil
+		}
+	}
+
+	// 13. Content matching the exclude content regex (read last as well)
+	if ff.contentExcludeRegexp != nil {
+		matched, err := contentMatches(absPath, ff.contentExcludeRegexp)
+		if err != nil {
+			ff.logger.Warn("Filter: Could not match file content", "path", relPath, "error", err)
+		} else if matched {
+			ff.logger.Info("Filter: Skipping file with matching content", "path", relPath, "regex", ff.contentExcludeRegexp.String())
+			return ReasonContent, nil
 		}
 	}
 
-	return false, nil
+	return "", nil
 }
@@ -0,0 +1,751 @@
+package filefilter
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexferrari88/code2context/internal/appconfig"
+)
+
+// exclusionReason returns the reason ff excludes the path (slash-separated, relative to root) under
+// the ignore stack, or "".
+func exclusionReason(t *testing.T, ff *FileFilter, root, path string, ignores ...*IgnoreRules) Reason {
+	t.Helper()
+	absPath := filepath.Join(root, filepath.FromSlash(path))
+	info, err := os.Lstat(absPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reason, _ := ff.ExclusionReason(absPath, fs.FileInfoToDirEntry(info), ignores)
+	return reason
+}
+
+func TestModifiedSince(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "recent.go", "old.go", "olddir/recent.go", "olddir/old.go")
+	now := time.Now()
+	old := now.AddDate(0, 0, -30)
+	for _, path := range []string{"old.go", "olddir/old.go", "olddir"} {
+		if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(path)), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ff, err := NewFileFilter(root, FilterConfig{ModifiedSince: now.AddDate(0, 0, -7)})
+	if err != nil {
+		t.Fatalf("NewFileFilter() error = %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want Reason
+	}{
+		{path: "recent.go", want: ""},
+		{path: "old.go", want: ReasonTooOld},
+		{path: "olddir", want: ""}, // Directories are walked whatever their age
+		{path: "olddir/recent.go", want: ""},
+		{path: "olddir/old.go", want: ReasonTooOld},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := exclusionReason(t, ff, root, tt.path); got != tt.want {
+				t.Errorf("ExclusionReason(%s) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "BUILD/", "Docs/Guide/", "APP.LOG", "Thumbs.DB", "main.go", "CACHE.TMP", "other.tmp")
+	ignores := CompileIgnoreLines(root, "*.tmp\n")
+	config := FilterConfig{
+		UserExcludeDirs:               []string{"build", "docs/guide"},
+		UserExcludeGlobs:              []string{"*.log"},
+		DefaultMiscellaneousFileNames: []string{"thumbs.db"},
+	}
+	tests := []struct {
+		path            string
+		wantSensitive   Reason
+		wantInsensitive Reason
+	}{
+		{path: "BUILD", wantSensitive: "", wantInsensitive: ReasonExcludedDir},
+		{path: "Docs/Guide", wantSensitive: "", wantInsensitive: ReasonExcludedDir},
+		{path: "APP.LOG", wantSensitive: "", wantInsensitive: ReasonPattern},
+		{path: "Thumbs.DB", wantSensitive: "", wantInsensitive: ReasonMiscellaneous},
+		{path: "main.go", wantSensitive: "", wantInsensitive: ""},
+		{path: "CACHE.TMP", wantSensitive: "", wantInsensitive: ""}, // .gitignore rules stay case-sensitive
+		{path: "other.tmp", wantSensitive: ReasonIgnored, wantInsensitive: ReasonIgnored},
+	}
+	for _, caseInsensitive := range []bool{false, true} {
+		config.CaseInsensitive = caseInsensitive
+		ff, err := NewFileFilter(root, config)
+		if err != nil {
+			t.Fatalf("NewFileFilter() error = %v", err)
+		}
+		for _, tt := range tests {
+			want := tt.wantSensitive
+			if caseInsensitive {
+				want = tt.wantInsensitive
+			}
+			if got := exclusionReason(t, ff, root, tt.path, ignores); got != want {
+				t.Errorf("CaseInsensitive=%v: ExclusionReason(%s) = %q, want %q", caseInsensitive, tt.path, got, want)
+			}
+		}
+	}
+}
+
+func TestCustomExclude(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "go.lock", "yarn.lock", "main.go", "keep.go", "gen/", "gen/a.go", "docs/", "docs/b.go")
+	hook := func(absPath string, d fs.DirEntry) (exclude bool, skipDir bool, handled bool) {
+		switch d.Name() {
+		case "go.lock":
+			return false, false, true // Force-include a file the default rules exclude
+		case "main.go":
+			return true, false, true // Force-exclude a file the default rules include
+		case "gen":
+			return true, true, true // Prune a directory
+		case "docs":
+			return true, false, true // Exclude a directory without pruning it
+		}
+		return false, false, false
+	}
+	ff, err := NewFileFilter(root, FilterConfig{DefaultLockfilePatterns: []string{"*.lock"}, CustomExclude: hook})
+	if err != nil {
+		t.Fatalf("NewFileFilter() error = %v", err)
+	}
+
+	tests := []struct {
+		path        string
+		want        Reason
+		wantSkipDir bool
+	}{
+		{path: "go.lock", want: ""},
+		{path: "yarn.lock", want: ReasonLockfile}, // Not handled: built-in rules apply
+		{path: "main.go", want: ReasonCustom},
+		{path: "keep.go", want: ""},
+		{path: "gen", want: ReasonCustom, wantSkipDir: true},
+		{path: "docs", want: ReasonCustom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			absPath := filepath.Join(root, filepath.FromSlash(tt.path))
+			info, err := os.Lstat(absPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ff.ExclusionReason(absPath, fs.FileInfoToDirEntry(info), nil)
+			if got != tt.want {
+				t.Errorf("ExclusionReason(%s) = %q, want %q", tt.path, got, tt.want)
+			}
+			if skipDir := err == filepath.SkipDir; skipDir != tt.wantSkipDir {
+				t.Errorf("ExclusionReason(%s) error = %v, want SkipDir %v", tt.path, err, tt.wantSkipDir)
+			}
+		})
+	}
+}
+
+func TestSkipEmptyFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "one.go", "emptydir/") // One byte each
+	for _, name := range []string{"empty.go", "forced.go"} {
+		if err := os.WriteFile(filepath.Join(root, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	forceInclude := func(absPath string, d fs.DirEntry) (bool, bool, bool) {
+		return false, false, d.Name() == "forced.go"
+	}
+
+	tests := []struct {
+		name string
+		cfg  FilterConfig
+		want map[string]Reason
+	}{
+		{
+			name: "off",
+			cfg:  FilterConfig{},
+			want: map[string]Reason{"empty.go": "", "one.go": "", "emptydir": ""},
+		},
+		{
+			name: "on",
+			cfg:  FilterConfig{SkipEmptyFiles: true},
+			want: map[string]Reason{"empty.go": ReasonEmpty, "one.go": "", "emptydir": "", "forced.go": ReasonEmpty},
+		},
+		{
+			name: "custom hook wins",
+			cfg:  FilterConfig{SkipEmptyFiles: true, CustomExclude: forceInclude},
+			want: map[string]Reason{"empty.go": ReasonEmpty, "forced.go": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ff, err := NewFileFilter(root, tt.cfg)
+			if err != nil {
+				t.Fatalf("NewFileFilter() error = %v", err)
+			}
+			for path, want := range tt.want {
+				if got := exclusionReason(t, ff, root, path); got != want {
+					t.Errorf("ExclusionReason(%s) = %q, want %q", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFileSizeLimits(t *testing.T) {
+	root := t.TempDir()
+	for name, size := range map[string]int{"s0.go": 0, "s9.go": 9, "s10.go": 10, "s100.go": 100, "s101.go": 101} {
+		if err := os.WriteFile(filepath.Join(root, name), make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFiles(t, root, "dir/")
+
+	tests := []struct {
+		name     string
+		min, max int64
+		want     map[string]Reason
+	}{
+		{
+			name: "no limits",
+			want: map[string]Reason{"s0.go": "", "s9.go": "", "s10.go": "", "s100.go": "", "s101.go": "", "dir": ""},
+		},
+		{
+			name: "minimum is inclusive",
+			min:  10,
+			want: map[string]Reason{"s0.go": ReasonTooSmall, "s9.go": ReasonTooSmall, "s10.go": "", "s101.go": "", "dir": ""},
+		},
+		{
+			name: "maximum is inclusive",
+			max:  100,
+			want: map[string]Reason{"s0.go": "", "s100.go": "", "s101.go": ReasonTooLarge, "dir": ""},
+		},
+		{
+			name: "both",
+			min:  10, max: 100,
+			want: map[string]Reason{"s9.go": ReasonTooSmall, "s10.go": "", "s100.go": "", "s101.go": ReasonTooLarge, "dir": ""},
+		},
+		{
+			name: "equal limits",
+			min:  100, max: 100,
+			want: map[string]Reason{"s10.go": ReasonTooSmall, "s100.go": "", "s101.go": ReasonTooLarge},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ff, err := NewFileFilter(root, FilterConfig{MinFileSize: tt.min, MaxFileSize: tt.max})
+			if err != nil {
+				t.Fatalf("NewFileFilter() error = %v", err)
+			}
+			for path, want := range tt.want {
+				if got := exclusionReason(t, ff, root, path); got != want {
+					t.Errorf("ExclusionReason(%s) = %q, want %q", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExcludeDirPaths(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "testdata/", "internal/testdata/", "internal/pkg/", "pkg/testdata/", "pkg/internal/testdata/", "internal/testdata.go")
+	tests := []struct {
+		name    string
+		entries []string
+		want    map[string]Reason
+	}{
+		{
+			name:    "bare name matches at any depth",
+			entries: []string{"testdata"},
+			want: map[string]Reason{
+				"testdata": ReasonExcludedDir, "internal/testdata": ReasonExcludedDir, "pkg/testdata": ReasonExcludedDir,
+				"pkg/internal/testdata": ReasonExcludedDir, "internal": "", "internal/pkg": "", "internal/testdata.go": "",
+			},
+		},
+		{
+			name:    "path matches only that directory",
+			entries: []string{"internal/testdata"},
+			want: map[string]Reason{
+				"internal/testdata": ReasonExcludedDir, "testdata": "", "pkg/testdata": "", "pkg/internal/testdata": "",
+				"internal": "", "internal/pkg": "", "internal/testdata.go": "",
+			},
+		},
+		{
+			name:    "slashes around a path are ignored",
+			entries: []string{"/internal/testdata/"},
+			want:    map[string]Reason{"internal/testdata": ReasonExcludedDir, "pkg/internal/testdata": ""},
+		},
+		{
+			name:    "path is cleaned",
+			entries: []string{"internal/./pkg/../testdata"},
+			want:    map[string]Reason{"internal/testdata": ReasonExcludedDir, "internal/pkg": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ff, err := NewFileFilter(root, FilterConfig{UserExcludeDirs: tt.entries})
+			if err != nil {
+				t.Fatalf("NewFileFilter() error = %v", err)
+			}
+			for path, want := range tt.want {
+				if got := exclusionReason(t, ff, root, path); got != want {
+					t.Errorf("ExclusionReason(%s) = %q, want %q", path, got, want)
+				}
+			}
+			// Excluded directories are pruned
+			absPath := filepath.Join(root, "internal", "testdata")
+			info, err := os.Lstat(absPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ff.ExclusionReason(absPath, fs.FileInfoToDirEntry(info), nil); err != filepath.SkipDir {
+				t.Errorf("ExclusionReason(internal/testdata) error = %v, want filepath.SkipDir", err)
+			}
+		})
+	}
+}
+
+func TestExcludeDirGlobs(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "node_modules/", "node_cache/", "node/", "src/node_x/", "api-generated/", "api-generated-old/", "nodes.go", "[lit]/")
+	tests := []struct {
+		name    string
+		entries []string
+		want    map[string]Reason
+	}{
+		{
+			name:    "star",
+			entries: []string{"node_*"},
+			want:    map[string]Reason{"node_modules": ReasonExcludedDir, "node_cache": ReasonExcludedDir, "src/node_x": ReasonExcludedDir, "node": "", "nodes.go": ""},
+		},
+		{
+			name:    "suffix",
+			entries: []string{"*-generated"},
+			want:    map[string]Reason{"api-generated": ReasonExcludedDir, "api-generated-old": ""},
+		},
+		{
+			name:    "question mark and class",
+			entries: []string{"nod?_[cm]*"},
+			want:    map[string]Reason{"node_modules": ReasonExcludedDir, "node_cache": ReasonExcludedDir, "src/node_x": ""},
+		},
+		{
+			name:    "literal name matches exactly",
+			entries: []string{"node"},
+			want:    map[string]Reason{"node": ReasonExcludedDir, "node_modules": "", "node_cache": ""},
+		},
+		{
+			name:    "glob in a path",
+			entries: []string{"src/node_*"},
+			want:    map[string]Reason{"src/node_x": ReasonExcludedDir, "node_modules": ""},
+		},
+		{
+			name:    "invalid pattern matches nothing",
+			entries: []string{"[lit"},
+			want:    map[string]Reason{"[lit]": "", "node": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ff, err := NewFileFilter(root, FilterConfig{UserExcludeDirs: tt.entries})
+			if err != nil {
+				t.Fatalf("NewFileFilter() error = %v", err)
+			}
+			for path, want := range tt.want {
+				absPath := filepath.Join(root, filepath.FromSlash(path))
+				info, err := os.Lstat(absPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := ff.ExclusionReason(absPath, fs.FileInfoToDirEntry(info), nil)
+				if got != want {
+					t.Errorf("ExclusionReason(%s) = %q, want %q", path, got, want)
+				}
+				if pruned := err == filepath.SkipDir; pruned != (want == ReasonExcludedDir) {
+					t.Errorf("ExclusionReason(%s) error = %v, want SkipDir %v", path, err, want == ReasonExcludedDir)
+				}
+			}
+		})
+	}
+}
+
+func TestExcludeVendored(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "third_party/", "Pods/", "src/", "src/app.min.js", "api/api.pb.go")
+	files := map[string]string{
+		"gen.go":      "// Code generated by stringer; DO NOT EDIT.\n\npackage x\n",
+		"schema.ts":   "/* @generated */\nexport type T = {};\n",
+		"normal.go":   "package x\n\n// Generate a value (not a generated file).\nfunc Generate() {}\n",
+		"src/main.js": "console.log('DO NOT EDIT');\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(path)), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vendored := FilterConfig{VendoredDirs: []string{"third_party", "Pods"}, VendoredFilePatterns: []string{"*.min.js", "*.pb.go"}}
+
+	tests := []struct {
+		name      string
+		vendored  bool
+		generated bool
+		want      map[string]Reason
+	}{
+		{
+			name: "off",
+			want: map[string]Reason{"third_party": "", "Pods": "", "src/app.min.js": "", "api/api.pb.go": "", "gen.go": "", "schema.ts": "", "normal.go": "", "src/main.js": ""},
+		},
+		{
+			name:     "vendored",
+			vendored: true,
+			want: map[string]Reason{
+				"third_party": ReasonVendored, "Pods": ReasonVendored, "src": "", "src/app.min.js": ReasonVendored, "api/api.pb.go": ReasonVendored,
+				"gen.go": ReasonGenerated, "schema.ts": ReasonGenerated, "normal.go": "", "src/main.js": "",
+			},
+		},
+		{
+			name:      "generated only",
+			generated: true,
+			want:      map[string]Reason{"third_party": "", "src/app.min.js": "", "gen.go": ReasonGenerated, "schema.ts": ReasonGenerated, "normal.go": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := vendored
+			cfg.ExcludeVendored, cfg.ExcludeGenerated = tt.vendored, tt.generated
+			ff, err := NewFileFilter(root, cfg)
+			if err != nil {
+				t.Fatalf("NewFileFilter() error = %v", err)
+			}
+			for path, want := range tt.want {
+				if got := exclusionReason(t, ff, root, path); got != want {
+					t.Errorf("ExclusionReason(%s) = %q, want %q", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSkipHidden(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, ".github/", ".github/workflows/ci.yml", ".foo", ".gitignore", "main.go", "src/", "src/.env", "src/app.go", "not.hidden")
+	tests := []struct {
+		name       string
+		skipHidden bool
+		want       map[string]Reason
+	}{
+		{
+			name: "included by default",
+			want: map[string]Reason{".github": "", ".github/workflows/ci.yml": "", ".foo": "", ".gitignore": "", "src/.env": "", "main.go": ""},
+		},
+		{
+			name:       "skipped",
+			skipHidden: true,
+			want: map[string]Reason{
+				".github": ReasonHidden, ".foo": ReasonHidden, ".gitignore": ReasonHidden, "src/.env": ReasonHidden,
+				"main.go": "", "src": "", "src/app.go": "", "not.hidden": "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ff, err := NewFileFilter(root, FilterConfig{SkipHidden: tt.skipHidden})
+			if err != nil {
+				t.Fatalf("NewFileFilter() error = %v", err)
+			}
+			for path, want := range tt.want {
+				if got := exclusionReason(t, ff, root, path); got != want {
+					t.Errorf("ExclusionReason(%s) = %q, want %q", path, got, want)
+				}
+			}
+			if !tt.skipHidden {
+				return
+			}
+			absPath := filepath.Join(root, ".github")
+			info, err := os.Lstat(absPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ff.ExclusionReason(absPath, fs.FileInfoToDirEntry(info), nil); err != filepath.SkipDir {
+				t.Errorf("ExclusionReason(.github) error = %v, want filepath.SkipDir", err)
+			}
+		})
+	}
+
+	t.Run("hidden root is walked", func(t *testing.T) {
+		hiddenRoot := filepath.Join(t.TempDir(), ".project")
+		writeFiles(t, hiddenRoot, "main.go")
+		ff, err := NewFileFilter(hiddenRoot, FilterConfig{SkipHidden: true})
+		if err != nil {
+			t.Fatalf("NewFileFilter() error = %v", err)
+		}
+		info, err := os.Lstat(hiddenRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ff.ExclusionReason(hiddenRoot, fs.FileInfoToDirEntry(info), nil); got != "" || err != nil {
+			t.Errorf("ExclusionReason(root) = %q, %v, want included", got, err)
+		}
+		if got := exclusionReason(t, ff, hiddenRoot, "main.go"); got != "" {
+			t.Errorf("ExclusionReason(main.go) = %q, want included", got)
+		}
+	})
+}
+
+func TestGitDir(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  bool
+	}{
+		{name: "bare repository", files: []string{"HEAD", "objects/", "refs/"}, want: true},
+		{name: "git dir with packed objects", files: []string{"HEAD", "config", "objects/pack/pack-1.pack", "refs/heads/main"}, want: true},
+		{name: "no objects", files: []string{"HEAD", "refs/"}},
+		{name: "no refs", files: []string{"HEAD", "objects/"}},
+		{name: "no HEAD", files: []string{"objects/", "refs/"}},
+		{name: "HEAD is a directory", files: []string{"HEAD/", "objects/", "refs/"}},
+		{name: "objects is a file", files: []string{"HEAD", "objects", "refs/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, "main.go", "repo.bak/")
+			for _, file := range tt.files {
+				writeFiles(t, filepath.Join(root, "repo.bak"), file)
+			}
+			if got := IsGitDir(filepath.Join(root, "repo.bak")); got != tt.want {
+				t.Errorf("IsGitDir() = %v, want %v", got, tt.want)
+			}
+
+			ff, err := NewFileFilter(root, FilterConfig{})
+			if err != nil {
+				t.Fatalf("NewFileFilter() error = %v", err)
+			}
+			absPath := filepath.Join(root, "repo.bak")
+			info, err := os.Lstat(absPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reason, skipErr := ff.ExclusionReason(absPath, fs.FileInfoToDirEntry(info), nil)
+			if tt.want && (reason != ReasonGitDir || skipErr != filepath.SkipDir) {
+				t.Errorf("ExclusionReason(repo.bak) = %q, %v, want %q, filepath.SkipDir", reason, skipErr, ReasonGitDir)
+			}
+			if !tt.want && reason != "" {
+				t.Errorf("ExclusionReason(repo.bak) = %q, want it included", reason)
+			}
+		})
+	}
+
+	t.Run("source root is walked", func(t *testing.T) {
+		root := t.TempDir()
+		writeFiles(t, root, "HEAD", "objects/", "refs/", "main.go")
+		ff, err := NewFileFilter(root, FilterConfig{})
+		if err != nil {
+			t.Fatalf("NewFileFilter() error = %v", err)
+		}
+		if got := exclusionReason(t, ff, root, "."); got != "" {
+			t.Errorf("ExclusionReason(.) = %q, want the root included", got)
+		}
+	})
+}
+
+func TestExcludeGenerated(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"zz_generated.deepcopy.go": "// Code generated by controller-gen. DO NOT EDIT.\n\npackage v1\n",
+		"api/api.pb.go":            "// Code generated by protoc-gen-go. DO NOT EDIT.\n// versions:\n// \tprotoc v4.25.1\n// source: api.proto\n\npackage api\n",
+		"web/schema.ts":            "/* eslint-disable */\n// This file is auto-generated by openapi-typescript.\nexport interface Pet {}\n",
+		"main.go":                  "// Package main is hand-written.\npackage main\n\nfunc main() {}\n",
+		"gen.go":                   "package main\n\n//go:generate stringer -type=Kind\n",
+	}
+	for path, content := range files {
+		absPath := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(absPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(absPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	generated := map[string]bool{"zz_generated.deepcopy.go": true, "api/api.pb.go": true, "web/schema.ts": true}
+	for _, excludeGenerated := range []bool{false, true} {
+		ff, err := NewFileFilter(root, FilterConfig{ExcludeGenerated: excludeGenerated})
+		if err != nil {
+			t.Fatalf("NewFileFilter() error = %v", err)
+		}
+		for path := range files {
+			want := Reason("")
+			if excludeGenerated && generated[path] {
+				want = ReasonGenerated
+			}
+			if got := exclusionReason(t, ff, root, path); got != want {
+				t.Errorf("ExcludeGenerated %v: ExclusionReason(%s) = %q, want %q", excludeGenerated, path, got, want)
+			}
+		}
+	}
+}
+
+func TestExcludeMinified(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"app.min.js":  "var a = 1;\n",                                    // By name
+		"bundle.js":   strings.Repeat("var a=1;", 200) + "\n",            // Hand-minified: one 1600-byte line
+		"main.js":     strings.Repeat("const a = 1;\n", 100),             // Normal
+		"README.md":   strings.Repeat("A long paragraph. ", 20) + "\n\n", // Long lines, but not over the average
+		"admin.js":    "export {}\n",                                     // "min" without the dots
+		"dir.min.js/": "",
+	}
+	for path, content := range files {
+		absPath := filepath.Join(root, filepath.FromSlash(path))
+		if strings.HasSuffix(path, "/") {
+			if err := os.Mkdir(absPath, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(absPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	minified := map[string]bool{"app.min.js": true, "bundle.js": true}
+	for _, excludeMinified := range []bool{false, true} {
+		ff, err := NewFileFilter(root, FilterConfig{ExcludeMinified: excludeMinified})
+		if err != nil {
+			t.Fatalf("NewFileFilter() error = %v", err)
+		}
+		for path := range files {
+			path = strings.TrimSuffix(path, "/") // Directories are never minified
+			want := Reason("")
+			if excludeMinified && minified[path] {
+				want = ReasonMinified
+			}
+			if got := exclusionReason(t, ff, root, path); got != want {
+				t.Errorf("ExcludeMinified %v: ExclusionReason(%s) = %q, want %q", excludeMinified, path, got, want)
+			}
+		}
+	}
+}
+
+func TestIgnoreGitignore(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "app.log", "main.go", "build/", "build/out.go", "big.txt")
+	ignores := []*IgnoreRules{CompileIgnoreLines(root, "*.log", "build/", "big.txt")}
+	tests := []struct {
+		name            string
+		ignoreGitignore bool
+		userExcludeExts []string
+		want            map[string]Reason
+	}{
+		{
+			name: "gitignore applies",
+			want: map[string]Reason{"app.log": ReasonIgnored, "build": ReasonIgnored, "big.txt": ReasonIgnored, "main.go": ""},
+		},
+		{
+			name:            "gitignore skipped",
+			ignoreGitignore: true,
+			want:            map[string]Reason{"app.log": "", "build": "", "build/out.go": "", "big.txt": "", "main.go": ""},
+		},
+		{
+			name:            "other rules still apply",
+			ignoreGitignore: true,
+			userExcludeExts: []string{".log"},
+			want:            map[string]Reason{"app.log": ReasonExtension, "build": "", "main.go": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ff, err := NewFileFilter(root, FilterConfig{IgnoreGitignore: tt.ignoreGitignore, UserExcludeExts: tt.userExcludeExts})
+			if err != nil {
+				t.Fatalf("NewFileFilter() error = %v", err)
+			}
+			for path, want := range tt.want {
+				if got := exclusionReason(t, ff, root, path, ignores...); got != want {
+					t.Errorf("ExclusionReason(%s) = %q, want %q", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultLockfiles(t *testing.T) {
+	root := t.TempDir()
+	want := map[string]Reason{
+		"go.sum":               ReasonLockfile,
+		"package-lock.json":    ReasonLockfile,
+		"Pipfile.lock":         ReasonLockfile,
+		"poetry.lock":          ReasonLockfile,
+		"app.gradle.lockfile":  ReasonLockfile,
+		"requirements.txt":     "", // Hand-edited dependency specs are not lock files
+		"requirements-dev.txt": "",
+		"constraints.txt":      "",
+		"go.mod":               "",
+		"package.json":         "",
+		"Pipfile":              "",
+	}
+	for path := range want {
+		writeFiles(t, root, path)
+	}
+	ff, err := NewFileFilter(root, FilterConfig{DefaultLockfilePatterns: appconfig.GetDefaultLockfilePatterns()})
+	if err != nil {
+		t.Fatalf("NewFileFilter() error = %v", err)
+	}
+	for path, wantReason := range want {
+		if got := exclusionReason(t, ff, root, path); got != wantReason {
+			t.Errorf("ExclusionReason(%s) = %q, want %q", path, got, wantReason)
+		}
+	}
+}
+
+// fakeFileInfo describes a file of any mode, e.g. a named pipe that cannot be created portably.
+type fakeFileInfo struct {
+	name string
+	mode fs.FileMode
+}
+
+func (fi fakeFileInfo) Name() string       { return fi.name }
+func (fi fakeFileInfo) Size() int64        { return 0 }
+func (fi fakeFileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fakeFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi fakeFileInfo) Sys() any           { return nil }
+
+func TestSpecialFiles(t *testing.T) {
+	root := t.TempDir()
+	ff, err := NewFileFilter(root, FilterConfig{})
+	if err != nil {
+		t.Fatalf("NewFileFilter() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		mode fs.FileMode
+		want Reason
+	}{
+		{name: "regular.go", mode: 0o644, want: ""},
+		{name: "dir", mode: fs.ModeDir | 0o755, want: ""},
+		{name: "fifo", mode: fs.ModeNamedPipe | 0o644, want: ReasonSpecial},
+		{name: "socket", mode: fs.ModeSocket | 0o755, want: ReasonSpecial},
+		{name: "disk", mode: fs.ModeDevice | 0o660, want: ReasonSpecial},
+		{name: "tty", mode: fs.ModeDevice | fs.ModeCharDevice | 0o620, want: ReasonSpecial},
+		{name: "irregular", mode: fs.ModeIrregular, want: ReasonSpecial},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := fs.FileInfoToDirEntry(fakeFileInfo{name: tt.name, mode: tt.mode})
+			reason, err := ff.ExclusionReason(filepath.Join(root, tt.name), entry, nil)
+			if err != nil {
+				t.Fatalf("ExclusionReason() error = %v", err)
+			}
+			if reason != tt.want {
+				t.Errorf("ExclusionReason(%s) = %q, want %q", tt.name, reason, tt.want)
+			}
+			excluded, _ := ff.IsExcluded(filepath.Join(root, tt.name), entry, nil)
+			if excluded != (tt.want != "") {
+				t.Errorf("IsExcluded(%s) = %v, want %v", tt.name, excluded, tt.want != "")
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package filefilter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	generatedHeaderLines = 40       // Number of leading lines searched for a generated-code marker
+	generatedHeaderBytes = 8 * 1024 // Read limit, so minified single-line files are not read entirely
+)
+
+// generatedMarkers are the phrases tools put in a comment near the top of generated files,
+// e.g. Go's and protoc's "Code generated ... DO NOT EDIT." and OpenAPI's "This file is auto-generated".
+var generatedMarkers = []string{"Code generated", "@generated", "DO NOT EDIT", "auto-generated", "autogenerated", "automatically generated"}
+
+// commentPrefixes are the line starts recognized as comments when looking for markers,
+// so that code merely mentioning a marker (e.g. in a string) is not mistaken for generated code.
+var commentPrefixes = []string{"//", "/*", "*", "#", "<!--", "--", ";", "%"}
+
+// IsGeneratedFile reports whether one of the first lines of the file at path is a comment
+// carrying a generated-code marker, such as Go's "Code generated ... DO NOT EDIT." header.
+func IsGeneratedFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("filefilter: failed to open '%s' to check for a generated header: %w", path, err)
+	}
+	defer f.Close()
+	return IsGeneratedContent(f), nil
+}
+
+// IsGeneratedContent is IsGeneratedFile for content read from r.
+func IsGeneratedContent(r io.Reader) bool {
+	scanner := bufio.NewScanner(io.LimitReader(r, generatedHeaderBytes))
+	scanner.Buffer(make([]byte, 0, generatedHeaderBytes), generatedHeaderBytes)
+	for i := 0; i < generatedHeaderLines && scanner.Scan(); i++ {
+		if isGeneratedMarkerLine(scanner.Text()) {
+			return true
+		}
+	}
+	// A scan error here only means the header could not be fully inspected (e.g. a very long line).
+	return false
+}
+
+// isGeneratedFile is IsGeneratedFile for the filter: a file whose header cannot be read counts as hand-written.
+func isGeneratedFile(path string, logger *slog.Logger) bool {
+	generated, err := IsGeneratedFile(path)
+	if err != nil {
+		logger.Warn("Filter: Could not check for a generated-code header", "path", path, "error", err)
+		return false
+	}
+	return generated
+}
+
+func isGeneratedMarkerLine(line string) bool {
+	line = strings.TrimSpace(line)
+	isComment := false
+	for _, prefix := range commentPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			isComment = true
+			break
+		}
+	}
+	if !isComment {
+		return false
+	}
+	for _, marker := range generatedMarkers {
+		if strings.Contains(line, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package filefilter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsGeneratedContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "go header", content: "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage api\n", want: true},
+		{name: "go header after license", content: "// Copyright 2024 The Authors.\n\n// Code generated by stringer; DO NOT EDIT.\npackage x\n", want: true},
+		{name: "at generated", content: "/**\n * @generated\n */\nexport const x = 1;\n", want: true},
+		{name: "hash comment", content: "# This file is auto-generated by tooling\nkey: value\n", want: true},
+		{name: "marker in a string literal", content: "package lint\n\nconst header = \"// Code generated ... DO NOT EDIT.\"\n", want: false},
+		{name: "marker in code", content: "package gen\n\nvar msg = fmt.Sprintf(\"@generated %s\", name)\n", want: false},
+		{name: "marker after line 40", content: strings.Repeat("x := 1\n", generatedHeaderLines) + "// Code generated by hand. DO NOT EDIT.\n", want: false},
+		{name: "marker on line 40", content: strings.Repeat("x := 1\n", generatedHeaderLines-1) + "// Code generated by hand. DO NOT EDIT.\n", want: true},
+		{name: "hand-written", content: "package main\n\nfunc main() {}\n", want: false},
+		{name: "empty", content: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsGeneratedContent(strings.NewReader(tt.content)); got != tt.want {
+				t.Errorf("IsGeneratedContent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsGeneratedMarkerLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{line: "// Code generated by mockgen. DO NOT EDIT.", want: true},
+		{line: "  /* @generated */", want: true},
+		{line: " * This file was automatically generated.", want: true},
+		{line: "<!-- autogenerated -->", want: true},
+		{line: "-- DO NOT EDIT", want: true},
+		{line: "// Hand-written helpers", want: false},
+		{line: `s := "// Code generated"`, want: false},
+		{line: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := isGeneratedMarkerLine(tt.line); got != tt.want {
+			t.Errorf("isGeneratedMarkerLine(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package filefilter
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// IsGitDir reports whether the directory at path looks like a git repository's internal directory:
+// it holds a HEAD file next to "objects" and "refs" directories. This catches git dirs that are not
+// named ".git", such as bare repositories or the git dirs of nested worktrees and submodules.
+func IsGitDir(path string) bool {
+	head, err := os.Stat(filepath.Join(path, "HEAD"))
+	if err != nil || !head.Mode().IsRegular() {
+		return false
+	}
+	for _, name := range []string{"objects", "refs"} {
+		info, err := os.Stat(filepath.Join(path, name))
+		if err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
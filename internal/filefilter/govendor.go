@@ -0,0 +1,53 @@
+package filefilter
+
+import "strings"
+
+// goVendorDir is the name of the directory holding a Go module's vendored dependencies.
+const goVendorDir = "vendor"
+
+// vendoredImportPath splits the slash-separated relPath at its topmost "vendor" segment. It returns the
+// import path below it (e.g. "github.com/me/lib" for "vendor/github.com/me/lib"), which is empty for the
+// vendor directory itself, and false if relPath is not in a vendor directory.
+func vendoredImportPath(relPath string) (string, bool) {
+	segments := strings.Split(relPath, "/")
+	for i, segment := range segments {
+		if segment == goVendorDir {
+			return strings.Join(segments[i+1:], "/"), true
+		}
+	}
+	return "", false
+}
+
+// isOwnVendored reports whether the vendored package directory importPath is one of OwnVendorPrefixes
+// or below one, matched by whole path elements as in GOPRIVATE. With leadsToOwn, a directory above a
+// prefix (e.g. "github.com" for "github.com/me") also counts, so the walk can reach the prefix.
+func (ff *FileFilter) isOwnVendored(importPath string, leadsToOwn bool) bool {
+	importPath = ff.fold(importPath)
+	for _, prefix := range ff.config.OwnVendorPrefixes {
+		prefix = ff.fold(strings.Trim(prefix, "/"))
+		if prefix == "" {
+			continue
+		}
+		if importPath == prefix || strings.HasPrefix(importPath, prefix+"/") {
+			return true
+		}
+		if leadsToOwn && (importPath == "" || strings.HasPrefix(prefix, importPath+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownVendorActive reports whether OwnVendorPrefixes take effect: only if vendor directories are
+// excluded by default (not after --keep-dirs vendor or --no-default-excludes).
+func (ff *FileFilter) ownVendorActive() bool {
+	if len(ff.config.OwnVendorPrefixes) == 0 {
+		return false
+	}
+	for _, excludedDir := range ff.config.DefaultExcludeDirs {
+		if ff.fold(excludedDir) == ff.fold(goVendorDir) {
+			return true
+		}
+	}
+	return false
+}
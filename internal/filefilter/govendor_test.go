@@ -0,0 +1,128 @@
+package filefilter
+
+import "testing"
+
+func TestVendoredImportPath(t *testing.T) {
+	tests := []struct {
+		relPath        string
+		wantImportPath string
+		wantInVendor   bool
+	}{
+		{relPath: "vendor", wantImportPath: "", wantInVendor: true},
+		{relPath: "vendor/github.com/me/lib/a.go", wantImportPath: "github.com/me/lib/a.go", wantInVendor: true},
+		{relPath: "sub/vendor/github.com/x", wantImportPath: "github.com/x", wantInVendor: true},
+		{relPath: "vendors/github.com/x", wantInVendor: false},
+		{relPath: "main.go", wantInVendor: false},
+	}
+	for _, tt := range tests {
+		importPath, inVendor := vendoredImportPath(tt.relPath)
+		if importPath != tt.wantImportPath || inVendor != tt.wantInVendor {
+			t.Errorf("vendoredImportPath(%q) = %q, %v, want %q, %v", tt.relPath, importPath, inVendor, tt.wantImportPath, tt.wantInVendor)
+		}
+	}
+}
+
+func TestIsOwnVendored(t *testing.T) {
+	ff, err := NewFileFilter(t.TempDir(), FilterConfig{OwnVendorPrefixes: []string{"github.com/me", "/example.org/team/"}})
+	if err != nil {
+		t.Fatalf("NewFileFilter() error = %v", err)
+	}
+	tests := []struct {
+		importPath string
+		leadsToOwn bool
+		want       bool
+	}{
+		{importPath: "github.com/me", want: true},
+		{importPath: "github.com/me/lib", want: true},
+		{importPath: "github.com/me/lib/internal", want: true},
+		{importPath: "github.com/meow", want: false},      // Whole path elements only
+		{importPath: "github.com/meow/lib", want: false},  // Whole path elements only
+		{importPath: "github.com/other/me", want: false},  // Prefixes are anchored
+		{importPath: "example.org/team/svc", want: true},  // Slashes around a prefix are ignored
+		{importPath: "example.org/teamwork", want: false}, // Whole path elements only
+		{importPath: "github.com", want: false},           // A parent only counts with leadsToOwn
+		{importPath: "github.com", leadsToOwn: true, want: true},
+		{importPath: "", leadsToOwn: true, want: true}, // The vendor directory itself
+		{importPath: "github.com/m", leadsToOwn: true, want: false},
+		{importPath: "golang.org", leadsToOwn: true, want: false},
+		{importPath: "example.org", leadsToOwn: true, want: true},
+	}
+	for _, tt := range tests {
+		if got := ff.isOwnVendored(tt.importPath, tt.leadsToOwn); got != tt.want {
+			t.Errorf("isOwnVendored(%q, %v) = %v, want %v", tt.importPath, tt.leadsToOwn, got, tt.want)
+		}
+	}
+}
+
+func TestOwnVendor(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root,
+		"vendor/github.com/me/lib/lib.go",
+		"vendor/github.com/meow/cat/cat.go",
+		"vendor/golang.org/x/text/text.go",
+		"vendor/modules.txt",
+	)
+	tests := []struct {
+		name        string
+		excludeDirs []string // DefaultExcludeDirs
+		active      bool
+		want        map[string]Reason
+	}{
+		{
+			name:        "own packages are kept",
+			excludeDirs: []string{"vendor", "node_modules"},
+			active:      true,
+			want: map[string]Reason{
+				"vendor":                            "",
+				"vendor/github.com":                 "",
+				"vendor/github.com/me":              "",
+				"vendor/github.com/me/lib":          "",
+				"vendor/github.com/me/lib/lib.go":   "",
+				"vendor/github.com/meow":            ReasonExcludedDir,
+				"vendor/github.com/meow/cat/cat.go": ReasonExcludedDir,
+				"vendor/golang.org":                 ReasonExcludedDir,
+				"vendor/modules.txt":                ReasonExcludedDir,
+			},
+		},
+		{
+			name:        "off after --keep-dirs vendor",
+			excludeDirs: []string{"node_modules"},
+			want: map[string]Reason{
+				"vendor":                            "",
+				"vendor/github.com/me/lib/lib.go":   "",
+				"vendor/github.com/meow/cat/cat.go": "",
+				"vendor/golang.org/x/text/text.go":  "",
+				"vendor/modules.txt":                "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ff, err := NewFileFilter(root, FilterConfig{OwnVendorPrefixes: []string{"github.com/me"}, DefaultExcludeDirs: tt.excludeDirs})
+			if err != nil {
+				t.Fatalf("NewFileFilter() error = %v", err)
+			}
+			if active := ff.ownVendorActive(); active != tt.active {
+				t.Errorf("ownVendorActive() = %v, want %v", active, tt.active)
+			}
+			for path, want := range tt.want {
+				if got := exclusionReason(t, ff, root, path); got != want {
+					t.Errorf("ExclusionReason(%s) = %q, want %q", path, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("vendor excluded without prefixes", func(t *testing.T) {
+		ff, err := NewFileFilter(root, FilterConfig{DefaultExcludeDirs: []string{"vendor"}})
+		if err != nil {
+			t.Fatalf("NewFileFilter() error = %v", err)
+		}
+		if ff.ownVendorActive() {
+			t.Error("ownVendorActive() = true without OwnVendorPrefixes")
+		}
+		if got := exclusionReason(t, ff, root, "vendor"); got != ReasonExcludedDir {
+			t.Errorf("ExclusionReason(vendor) = %q, want %q", got, ReasonExcludedDir)
+		}
+	})
+}
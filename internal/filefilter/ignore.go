@@ -0,0 +1,107 @@
+package filefilter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gitignore "github.com/sabhiram/go-gitignore"
+)
+
+// IgnoreRules holds the compiled rules of a single ignore file (e.g. a .gitignore).
+// Patterns are evaluated relative to dir, the directory the file was found in.
+type IgnoreRules struct {
+	dir   string // Absolute path of the directory the rules are anchored at
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	matcher *gitignore.GitIgnore // Single-pattern matcher with any leading "!" stripped
+	negate  bool                 // True for "!pattern" rules that re-include paths
+	line    string               // Original line, for logging
+}
+
+// CompileIgnoreFile reads and compiles the ignore file at path, anchoring its rules at dir.
+func CompileIgnoreFile(dir, path string) (*IgnoreRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("filefilter: failed to read ignore file '%s': %w", path, err)
+	}
+	return CompileIgnoreLines(dir, strings.Split(string(data), "\n")...), nil
+}
+
+// CompileIgnoreLines compiles gitignore-syntax lines, anchoring the resulting rules at dir.
+// Each line is compiled separately so that negations can be evaluated across files.
+func CompileIgnoreLines(dir string, lines ...string) *IgnoreRules {
+	ir := &IgnoreRules{dir: dir}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(strings.TrimRight(line, "\r"))
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		negate := false
+		pattern := trimmed
+		if strings.HasPrefix(pattern, "!") {
+			negate = true
+			pattern = pattern[1:]
+		}
+		if pattern == "" {
+			continue
+		}
+		ir.rules = append(ir.rules, ignoreRule{
+			matcher: gitignore.CompileIgnoreLines(pattern),
+			negate:  negate,
+			line:    trimmed,
+		})
+	}
+	return ir
+}
+
+// Extend appends the rules of other after the rules of ir, so they win on conflicting matches.
+// Both rule sets are expected to be anchored at the same directory.
+func (ir *IgnoreRules) Extend(other *IgnoreRules) {
+	if other == nil {
+		return
+	}
+	ir.rules = append(ir.rules, other.rules...)
+}
+
+// match evaluates the rules in file order against absPath. It reports whether any rule matched,
+// whether the last matching rule ignores the path, and that rule's original line.
+func (ir *IgnoreRules) match(absPath string, isDir bool) (matched bool, ignored bool, line string) {
+	relPath, err := filepath.Rel(ir.dir, absPath)
+	if err != nil || relPath == "." || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return false, false, "" // Path is not below this ignore file
+	}
+	relPath = filepath.ToSlash(relPath)
+	if isDir {
+		relPath += "/" // Lets directory-only patterns such as "build/" match the directory itself
+	}
+	for _, rule := range ir.rules {
+		if rule.matcher.MatchesPath(relPath) {
+			matched = true
+			ignored = !rule.negate
+			line = rule.line
+		}
+	}
+	return matched, ignored, line
+}
+
+// matchIgnoreStack evaluates a stack of ignore files ordered from the root to the most specific directory.
+// Like git, all levels are considered and the last matching rule wins, so a deeper file can
+// re-include (via "!") a path ignored higher up, or ignore a path a parent file re-included.
+func matchIgnoreStack(stack []*IgnoreRules, absPath string, isDir bool) (ignored bool, level int, line string) {
+	level = -1
+	for i, rules := range stack {
+		if rules == nil {
+			continue
+		}
+		if matched, levelIgnored, matchedLine := rules.match(absPath, isDir); matched {
+			ignored = levelIgnored
+			level = i
+			line = matchedLine
+		}
+	}
+	return ignored, level, line
+}
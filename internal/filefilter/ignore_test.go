@@ -0,0 +1,128 @@
+package filefilter
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles creates the files (content "x") and directories (paths ending in "/") below root.
+func writeFiles(t *testing.T, root string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		absPath := filepath.Join(root, filepath.FromSlash(path))
+		if path[len(path)-1] == '/' {
+			if err := os.MkdirAll(absPath, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(absPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(absPath, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMatchIgnoreStack(t *testing.T) {
+	tests := []struct {
+		name    string
+		ignores map[string]string // Directory (slash-separated, "." for the root) -> .gitignore content
+		paths   map[string]bool   // Path (directories end in "/") -> expected to be ignored
+	}{
+		{
+			name:    "deeper negation re-includes a file ignored by the root",
+			ignores: map[string]string{".": "*.log\n", "sub": "!keep.log\n"},
+			paths: map[string]bool{
+				"a.log":        true,
+				"sub/keep.log": false,
+				"sub/drop.log": true,
+				"keep.log":     true,
+				"sub/main.go":  false,
+			},
+		},
+		{
+			name:    "deeper ignore overrides a negation higher up",
+			ignores: map[string]string{".": "*.log\n!keep.log\n", "sub": "keep.log\n"},
+			paths: map[string]bool{
+				"keep.log":     false,
+				"sub/keep.log": true,
+			},
+		},
+		{
+			name:    "double-star directory pattern matches at any depth",
+			ignores: map[string]string{".": "**/build/\n"},
+			paths: map[string]bool{
+				"build/":         true,
+				"a/build/":       true,
+				"a/b/c/build/":   true,
+				"a/build.go":     false,
+				"a/builder/":     false,
+				"a/b/build-file": false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for path := range tt.paths {
+				writeFiles(t, root, path)
+			}
+			for dir, content := range tt.ignores {
+				writeFiles(t, root, dir+"/")
+				if err := os.WriteFile(filepath.Join(root, dir, ".gitignore"), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for path, want := range tt.paths {
+				isDir := path[len(path)-1] == '/'
+				absPath := filepath.Join(root, filepath.FromSlash(path))
+				var stack []*IgnoreRules
+				for _, dir := range []string{".", "sub"} { // From the root down
+					if content, ok := tt.ignores[dir]; ok && (dir == "." || filepath.Dir(path) == dir) {
+						stack = append(stack, CompileIgnoreLines(filepath.Join(root, dir), content))
+					}
+				}
+				if got, _, _ := matchIgnoreStack(stack, absPath, isDir); got != want {
+					t.Errorf("matchIgnoreStack(%q) = %v, want %v", path, got, want)
+				}
+			}
+
+			checkGitParity(t, root, tt.paths)
+		})
+	}
+}
+
+// checkGitParity verifies that `git check-ignore` agrees with the expected results, if git is available.
+func checkGitParity(t *testing.T, root string, paths map[string]bool) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Log("git not available; skipping the comparison with git check-ignore")
+		return
+	}
+	if out, err := exec.Command("git", "-C", root, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, out)
+	}
+	for path, want := range paths {
+		cmd := exec.Command("git", "check-ignore", "-q", path)
+		cmd.Dir = root
+		err := cmd.Run()
+		exitErr, isExitErr := err.(*exec.ExitError)
+		switch {
+		case err == nil:
+			if !want {
+				t.Errorf("git check-ignore ignores %q, expected it not to be", path)
+			}
+		case isExitErr && exitErr.ExitCode() == 1:
+			if want {
+				t.Errorf("git check-ignore does not ignore %q, expected it to be", path)
+			}
+		default:
+			t.Fatalf("git check-ignore %q failed: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package filefilter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	minifiedScanBytes     = 8 * 1024 // Prefix of a file whose lines are measured
+	minifiedAvgLineLength = 500      // Average line length (in bytes) above which a file counts as minified
+	minifiedNameInfix     = ".min."  // As in "app.min.js" or "style.min.css"
+)
+
+// IsMinifiedFile reports whether the file at path looks minified: its name contains ".min.", or
+// the lines in its first 8 KiB are longer than 500 bytes on average (e.g. a single-line bundle).
+func IsMinifiedFile(path string) (bool, error) {
+	if strings.Contains(strings.ToLower(filepath.Base(path)), minifiedNameInfix) {
+		return true, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("filefilter: failed to open '%s' to check for minified content: %w", path, err)
+	}
+	defer f.Close()
+
+	prefix, err := io.ReadAll(io.LimitReader(f, minifiedScanBytes))
+	if err != nil {
+		return false, fmt.Errorf("filefilter: failed to read '%s' to check for minified content: %w", path, err)
+	}
+	if len(prefix) == 0 {
+		return false, nil
+	}
+	lines := bytes.Count(prefix, []byte{'\n'})
+	if prefix[len(prefix)-1] != '\n' {
+		lines++ // A final line without a newline, or one cut off by the read limit
+	}
+	return len(prefix)/lines > minifiedAvgLineLength, nil
+}
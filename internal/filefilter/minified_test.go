@@ -0,0 +1,50 @@
+package filefilter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsMinifiedFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		content  string
+		want     bool
+	}{
+		{name: "min infix", fileName: "app.min.js", content: "var a = 1;\n", want: true},
+		{name: "min infix is case-insensitive", fileName: "Style.MIN.css", content: "a{}\n", want: true},
+		{name: "min without dots", fileName: "admin.js", content: "var a = 1;\n", want: false},
+		{name: "long single line", fileName: "bundle.js", content: strings.Repeat("x", 600) + "\n", want: true},
+		{name: "average just above the limit", fileName: "bundle.js", content: strings.Repeat(strings.Repeat("x", 501)+"\n", 4), want: true},
+		{name: "short lines", fileName: "app.js", content: strings.Repeat(strings.Repeat("x", 100)+"\n", 10), want: false},
+		{name: "one long line among short ones", fileName: "app.js", content: strings.Repeat("x", 2000) + "\n" + strings.Repeat("y\n", 10), want: false},
+		{name: "no trailing newline counts the last line", fileName: "bundle.js", content: strings.Repeat("x", 501), want: true},
+		{name: "no trailing newline at the limit", fileName: "bundle.js", content: strings.Repeat("x", 499) + "\n" + strings.Repeat("x", 500), want: false},
+		{name: "only the scanned prefix counts", fileName: "app.js", content: strings.Repeat("x\n", minifiedScanBytes/2) + strings.Repeat("x", 100000), want: false},
+		{name: "empty file", fileName: "empty.js", content: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.fileName)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := IsMinifiedFile(path)
+			if err != nil {
+				t.Fatalf("IsMinifiedFile() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsMinifiedFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := IsMinifiedFile(filepath.Join(t.TempDir(), "missing.js")); err == nil {
+			t.Error("IsMinifiedFile() error = nil, want an error")
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package filefilter
+
+// Reason names the rule that excluded a path (see FileFilter.ExclusionReason).
+type Reason string
+
+const (
+	ReasonOutput        Reason = "output"        // The output file or one of its parts
+	ReasonDeselected    Reason = "deselected"    // Excluded via ExcludePaths (e.g. deselected interactively)
+	ReasonCustom        Reason = "custom"        // FilterConfig.CustomExclude
+	ReasonSymlink       Reason = "symlink"       // Symbolic links (including broken ones)
+	ReasonSpecial       Reason = "special"       // Named pipes, sockets, and devices (anything but regular files and directories)
+	ReasonNotSelected   Reason = "not_selected"  // Not in RestrictToPaths
+	ReasonHidden        Reason = "hidden"        // Dot-prefixed, with SkipHidden
+	ReasonExcludedDir   Reason = "excluded_dir"  // Default and user directory exclusions
+	ReasonGitDir        Reason = "git_dir"       // Git internals
+	ReasonIgnored       Reason = "gitignore"     // .gitignore and extra ignore files
+	ReasonExportIgnore  Reason = "export_ignore" // .gitattributes export-ignore patterns, with FilterConfig.ExportIgnore
+	ReasonTooLarge      Reason = "too_large"     // Above MaxFileSize
+	ReasonTooSmall      Reason = "too_small"     // Below MinFileSize
+	ReasonTooOld        Reason = "too_old"       // Last modified before ModifiedSince
+	ReasonExtension     Reason = "extension"     // Excluded extension, or not an included one
+	ReasonPattern       Reason = "pattern"       // Include and exclude globs, exclude regexes
+	ReasonEmpty         Reason = "empty"         // Zero-byte files, with SkipEmptyFiles
+	ReasonExecutable    Reason = "executable"    // Executables by extension or permission
+	ReasonMedia         Reason = "media"         // Media file extensions
+	ReasonArchive       Reason = "archive"       // Archive file extensions
+	ReasonLockfile      Reason = "lockfile"      // Lock file patterns
+	ReasonMiscellaneous Reason = "miscellaneous" // Miscellaneous file names and extensions
+	ReasonAuxiliary     Reason = "auxiliary"     // Auxiliary files, with SkipAuxFiles
+	ReasonVendored      Reason = "vendored"      // Vendored directories and minified bundles
+	ReasonGenerated     Reason = "generated"     // Files with a generated-code header
+	ReasonMinified      Reason = "minified"      // Minified files, with ExcludeMinified
+	ReasonContent       Reason = "content"       // Content matching ExcludeContentRegex
+)
@@ -0,0 +1,97 @@
+package gitutils
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Classified reasons for a failed clone. Check for them with errors.Is.
+var (
+	ErrRepoNotFound    = errors.New("repository not found or not public")
+	ErrAuthRequired    = errors.New("authentication required")
+	ErrRefNotFound     = errors.New("ref not found")
+	ErrGitNotInstalled = errors.New("git not installed")
+)
+
+// lookPath finds an executable in PATH; a variable so tests can simulate a missing git.
+var lookPath = exec.LookPath
+
+// EnsureGitAvailable returns an error wrapping ErrGitNotInstalled if the git executable is not in PATH.
+func EnsureGitAvailable() error {
+	if _, err := lookPath("git"); err != nil {
+		return fmt.Errorf("gitutils: git executable not found in PATH; install git or use a local path: %w", ErrGitNotInstalled)
+	}
+	return nil
+}
+
+// CloneError is returned when cloning a repository fails. Reason is one of the classified errors
+// above if git's output could be mapped to one, and Stderr holds git's raw error output.
+type CloneError struct {
+	URL    string
+	Ref    string
+	Reason error // Nil if the failure could not be classified
+	Stderr string
+	Err    error // The error of running git
+}
+
+// Error describes the failure with any credentials in the URL redacted, also where git's output repeats it.
+func (e *CloneError) Error() string {
+	redactedURL := RedactURLCredentials(e.URL)
+	if e.Reason != nil {
+		return fmt.Sprintf("gitutils: failed to clone repository '%s' (ref: '%s'): %v", redactedURL, e.Ref, e.Reason)
+	}
+	stderr := strings.TrimSpace(e.Stderr)
+	if redactedURL != e.URL {
+		stderr = strings.ReplaceAll(stderr, e.URL, redactedURL)
+	}
+	return fmt.Sprintf("gitutils: failed to clone repository '%s' (ref: '%s'): %v. Stderr: %s", redactedURL, e.Ref, e.Err, stderr)
+}
+
+// Unwrap exposes both the classified reason and the underlying error to errors.Is and errors.As.
+func (e *CloneError) Unwrap() []error {
+	if e.Reason == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Reason, e.Err}
+}
+
+// cloneErrorPatterns maps lowercase fragments of git's error output to a classified reason.
+// They are checked in order, so more specific fragments come first: ref and authentication failures
+// can mention "not found" too (e.g. in the URL), which is matched last.
+var cloneErrorPatterns = []struct {
+	fragment string
+	reason   error
+}{
+	{"remote branch", ErrRefNotFound}, // "Remote branch v9 not found in upstream origin"
+	{"couldn't find remote ref", ErrRefNotFound},
+	{"could not find remote ref", ErrRefNotFound},
+	{"authentication failed", ErrAuthRequired},
+	{"could not read username", ErrAuthRequired},
+	{"could not read password", ErrAuthRequired},
+	{"terminal prompts disabled", ErrAuthRequired},
+	{"permission denied (publickey", ErrAuthRequired},
+	{"host key verification failed", ErrAuthRequired},
+	{"returned error: 401", ErrAuthRequired},
+	{"returned error: 403", ErrAuthRequired},
+	{"repository not found", ErrRepoNotFound},
+	{"does not appear to be a git repository", ErrRepoNotFound},
+	{"does not exist", ErrRepoNotFound}, // Local paths: "fatal: repository '/x.git' does not exist"
+	{"returned error: 404", ErrRepoNotFound},
+	{"not found", ErrRepoNotFound}, // "fatal: repository 'https://host/x/y/' not found"
+}
+
+// classifyCloneError maps the error of running git and its stderr to a classified reason, or nil.
+func classifyCloneError(runErr error, stderr string) error {
+	if errors.Is(runErr, exec.ErrNotFound) {
+		return ErrGitNotInstalled
+	}
+	lowerStderr := strings.ToLower(stderr)
+	for _, pattern := range cloneErrorPatterns {
+		if strings.Contains(lowerStderr, pattern.fragment) {
+			return pattern.reason
+		}
+	}
+	return nil
+}
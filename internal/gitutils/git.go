@@ -1,17 +1,25 @@
 package gitutils
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
 // CloneRepo clones a Git repository to a temporary directory.
 // Returns the path to the cloned repo (inside a unique temp dir) and the repo name.
-func CloneRepo(repoURL, ref string) (string, string, error) {
+// If progress is non-nil, git's progress output is streamed to it. Git is killed when ctx is done.
+func CloneRepo(ctx context.Context, repoURL, ref string, progress io.Writer, logger *slog.Logger) (string, string, error) {
 	// Create a unique parent temporary directory first
 	parentTempDir, err := os.MkdirTemp("", "c2c_clone_parent_*")
 	if err != nil {
@@ -24,7 +32,110 @@ func CloneRepo(repoURL, ref string) (string, string, error) {
 	// and ensures the target directory for clone does not exist.
 	clonePath := filepath.Join(parentTempDir, repoName)
 
-	slog.Info("Cloning repository...", "url", repoURL, "ref", ref, "target_path", clonePath)
+	if err := cloneIntoFunc(ctx, repoURL, ref, clonePath, progress, logger); err != nil {
+		os.RemoveAll(parentTempDir) // Clean up on failure
+		return "", "", err
+	}
+
+	logger.Info("Repository cloned successfully", "path", clonePath)
+	// The path to the actual repo content is clonePath.
+	// The parentTempDir is what needs to be cleaned up eventually.
+	// We return clonePath as the basePath for processing, and parentTempDir for cleanup.
+	// Let processor handle cleanup of parentTempDir. For simplicity, we'll make cloneRepo return just the clonePath
+	// and assume the caller of CloneRepo (or its wrapper) will know how to clean it (e.g., if parentTempDir was its idea).
+	// For now, CloneRepo creates parentTempDir AND clonePath, so it should return parentTempDir for cleanup.
+	// The processor will use clonePath (which is parentTempDir/repoName).
+
+	return clonePath, repoName, nil // Caller cleans up parentTempDir which contains clonePath
+}
+
+// CloneRepoCached is like CloneRepo, but keeps the clone in a persistent cache directory
+// (see CloneCacheDir) keyed by repository URL and ref. On a cache hit the existing clone is updated
+// with a shallow fetch and hard reset instead of being cloned again; if that fails, it is re-cloned.
+// The returned path must not be removed by the caller.
+func CloneRepoCached(ctx context.Context, repoURL, ref string, progress io.Writer, logger *slog.Logger) (string, string, error) {
+	cacheRoot, err := CloneCacheDir()
+	if err != nil {
+		return "", "", err
+	}
+
+	repoName := getRepoNameFromURL(repoURL)
+	keyHash := sha256.Sum256([]byte(repoURL + "\x00" + ref))
+	entryDir := filepath.Join(cacheRoot, hex.EncodeToString(keyHash[:8]))
+	clonePath := filepath.Join(entryDir, repoName)
+
+	if _, statErr := os.Stat(filepath.Join(clonePath, ".git")); statErr == nil {
+		logger.Info("Using cached clone", "url", repoURL, "ref", ref, "path", clonePath)
+		updateErr := updateCachedClone(ctx, clonePath, ref, logger)
+		if updateErr == nil {
+			return clonePath, repoName, nil
+		}
+		if ctx.Err() != nil {
+			return "", "", fmt.Errorf("gitutils: failed to update cached clone: %w", ctx.Err())
+		}
+		logger.Warn("Failed to update cached clone, cloning again", "path", clonePath, "error", updateErr)
+	}
+
+	// Cache miss (or unusable entry): start from an empty entry directory
+	if err := os.RemoveAll(entryDir); err != nil {
+		return "", "", fmt.Errorf("gitutils: failed to reset clone cache entry '%s': %w", entryDir, err)
+	}
+	if err := os.MkdirAll(entryDir, 0o755); err != nil {
+		return "", "", fmt.Errorf("gitutils: failed to create clone cache entry '%s': %w", entryDir, err)
+	}
+	if err := cloneIntoFunc(ctx, repoURL, ref, clonePath, progress, logger); err != nil {
+		os.RemoveAll(entryDir) // Don't leave a broken entry behind
+		return "", "", err
+	}
+
+	logger.Info("Repository cloned into cache", "path", clonePath)
+	return clonePath, repoName, nil
+}
+
+// CloneCacheDir returns the directory holding cached clones, e.g. $XDG_CACHE_HOME/code2context/repos.
+func CloneCacheDir() (string, error) {
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("gitutils: failed to determine user cache directory: %w", err)
+	}
+	return filepath.Join(userCacheDir, "code2context", "repos"), nil
+}
+
+// updateCachedClone brings a cached clone up to date with its remote ref (the default branch if ref is empty)
+// and discards any local modifications.
+func updateCachedClone(ctx context.Context, clonePath, ref string, logger *slog.Logger) error {
+	fetchRef := ref
+	if fetchRef == "" {
+		fetchRef = "HEAD"
+	}
+	steps := [][]string{
+		{"fetch", "--no-tags", "--depth", "1", "origin", fetchRef},
+		{"reset", "--hard", "FETCH_HEAD"},
+		{"clean", "-ffdx"},
+	}
+	for _, args := range steps {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = clonePath
+
+		var errBuilder strings.Builder
+		cmd.Stderr = &errBuilder
+
+		logger.Debug("Executing git command", "args", strings.Join(cmd.Args, " "), "dir", clonePath)
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("gitutils: 'git %s' failed in '%s': %w. Stderr: %s", strings.Join(args, " "), clonePath, err, errBuilder.String())
+		}
+	}
+	return nil
+}
+
+// cloneIntoFunc is cloneInto; a variable so tests can observe whether a clone happens.
+var cloneIntoFunc = cloneInto
+
+// cloneInto performs a shallow clone of repoURL (optionally at ref) into clonePath, which must not exist yet.
+// If progress is non-nil, git is asked to report progress and its stderr is streamed there as well.
+func cloneInto(ctx context.Context, repoURL, ref, clonePath string, progress io.Writer, logger *slog.Logger) error {
+	logger.Info("Cloning repository...", "url", repoURL, "ref", ref, "target_path", clonePath)
 
 	cmdArgs := []string{"clone", "--no-tags", "--no-recurse-submodules"} // Start with leaner clone options
 	if ref != "" {
@@ -40,57 +151,57 @@ func CloneRepo(repoURL, ref string) (string, string, error) {
 	} else {
 		cmdArgs = append(cmdArgs, "--depth", "1") // Shallow clone default branch
 	}
+	if progress != nil {
+		cmdArgs = append(cmdArgs, "--progress") // Git only reports progress on a terminal unless asked
+	}
 	cmdArgs = append(cmdArgs, repoURL, clonePath)
 
-	cmd := exec.Command("git", cmdArgs...)
+	cmd := exec.CommandContext(ctx, "git", cmdArgs...)
 
 	// Capture output for better error reporting if verbose is not on
 	var outBuilder, errBuilder strings.Builder
 	cmd.Stdout = &outBuilder
 	cmd.Stderr = &errBuilder
+	if progress != nil {
+		cmd.Stderr = io.MultiWriter(&errBuilder, progress)
+	}
 
-	slog.Debug("Executing git command", "args", strings.Join(cmd.Args, " "))
+	logger.Debug("Executing git command", "args", strings.Join(cmd.Args, " "))
 
 	if err := cmd.Run(); err != nil {
-		os.RemoveAll(parentTempDir) // Clean up on failure
-		slog.Error("Git clone command output", "stdout", outBuilder.String(), "stderr", errBuilder.String())
-		return "", "", fmt.Errorf("gitutils: failed to clone repository '%s' (ref: '%s'): %w. Stderr: %s", repoURL, ref, err, errBuilder.String())
+		logger.Debug("Git clone command output", "stdout", outBuilder.String(), "stderr", errBuilder.String())
+		if ctx.Err() != nil {
+			return fmt.Errorf("gitutils: clone of '%s' was stopped: %w", RedactURLCredentials(repoURL), ctx.Err())
+		}
+		return &CloneError{URL: repoURL, Ref: ref, Reason: classifyCloneError(err, errBuilder.String()), Stderr: errBuilder.String(), Err: err}
 	}
 
-	slog.Info("Repository cloned successfully", "path", clonePath)
-	// The path to the actual repo content is clonePath.
-	// The parentTempDir is what needs to be cleaned up eventually.
-	// We return clonePath as the basePath for processing, and parentTempDir for cleanup.
-	// Let processor handle cleanup of parentTempDir. For simplicity, we'll make cloneRepo return just the clonePath
-	// and assume the caller of CloneRepo (or its wrapper) will know how to clean it (e.g., if parentTempDir was its idea).
-	// For now, CloneRepo creates parentTempDir AND clonePath, so it should return parentTempDir for cleanup.
-	// The processor will use clonePath (which is parentTempDir/repoName).
-
-	return clonePath, repoName, nil // Caller cleans up parentTempDir which contains clonePath
+	return nil
 }
 
+// scpLikeURLRegex matches SCP-like git addresses such as "git@host:group/project.git" or "user@host:project".
+var scpLikeURLRegex = regexp.MustCompile(`^[\w.-]+@[\w.-]+:[^/]`)
+
+// getRepoNameFromURL returns the last path component of a repository URL without a ".git" suffix, e.g.
+// "project" for "https://gitlab.com/group/subgroup/project.git", "ssh://git@host:2222/group/project.git",
+// or "git@bitbucket.org:team/project.git". Ports, user info, query strings, and trailing slashes are ignored.
 func getRepoNameFromURL(repoURL string) string {
-	parsedURL := repoURL
-	// Remove common prefixes
-	if strings.HasPrefix(parsedURL, "https://") {
-		parsedURL = strings.TrimPrefix(parsedURL, "https://")
-	} else if strings.HasPrefix(parsedURL, "http://") {
-		parsedURL = strings.TrimPrefix(parsedURL, "http://")
-	} else if strings.HasPrefix(parsedURL, "git@") {
-		parsedURL = strings.TrimPrefix(parsedURL, "git@")
-		parsedURL = strings.Replace(parsedURL, ":", "/", 1) // Replace git@host:path to git@host/path form
+	repoPath := repoURL
+	if parsedURL, err := url.Parse(repoURL); err == nil && parsedURL.Scheme != "" && parsedURL.Host != "" {
+		repoPath = parsedURL.Path // Drops the scheme, user info, host, port, query, and fragment
+	} else if scpLikeURLRegex.MatchString(repoURL) {
+		repoPath = repoURL[strings.Index(repoURL, ":")+1:] // git@host:group/project.git -> group/project.git
 	}
 
-	// Remove .git suffix
-	parsedURL = strings.TrimSuffix(parsedURL, ".git")
+	// Remove trailing slashes and the .git suffix (also of "project/.git")
+	repoPath = strings.TrimRight(repoPath, "/\\")
+	repoPath = strings.TrimSuffix(repoPath, ".git")
+	repoPath = strings.TrimRight(repoPath, "/\\")
 
 	// Get the last path component
-	parts := strings.Split(parsedURL, "/")
-	if len(parts) > 0 {
-		repoName := parts[len(parts)-1]
-		if repoName != "" {
-			return repoName
-		}
+	repoName := repoPath[strings.LastIndexAny(repoPath, "/\\")+1:]
+	if repoName != "" && repoName != "." && repoName != ".." {
+		return repoName
 	}
 	// Fallback if parsing is difficult
 	return "repository"
@@ -102,5 +213,163 @@ func IsGitURL(path string) bool {
 		strings.HasPrefix(path, "https://") ||
 		strings.HasPrefix(path, "git@") || // Covers git@github.com:user/repo.git
 		strings.HasPrefix(path, "ssh://") ||
+		strings.HasPrefix(path, "git://") ||
+		scpLikeURLRegex.MatchString(path) || // Covers other users, e.g. user@git.example.com:group/repo
 		strings.HasSuffix(path, ".git") // Covers file:///path/to/repo.git or local clones identified by .git
 }
+
+// ChangedFiles lists the files changed between the merge base of base and HEAD (`git diff base...HEAD`)
+// in the repository containing repoRoot. Paths are slash-separated and relative to repoRoot, and
+// unquoted even if they contain non-ASCII characters; changes outside repoRoot and deleted files are omitted.
+func ChangedFiles(repoRoot, base string, logger *slog.Logger) ([]string, error) {
+	cmd := exec.Command("git", "diff", "--name-only", "-z", "--relative", "--diff-filter=d", base+"...HEAD")
+	cmd.Dir = repoRoot
+
+	var outBuilder, errBuilder strings.Builder
+	cmd.Stdout = &outBuilder
+	cmd.Stderr = &errBuilder
+
+	logger.Debug("Executing git command", "args", strings.Join(cmd.Args, " "), "dir", repoRoot)
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("gitutils: failed to list files changed since '%s' in '%s': %w. Stderr: %s", base, repoRoot, err, errBuilder.String())
+	}
+
+	var files []string
+	for _, name := range strings.Split(outBuilder.String(), "\x00") {
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// FindRepoRoot returns the root of the git work tree containing start: the nearest directory at or
+// above start holding a .git directory (or a .git file, for worktrees and submodules).
+// It returns false if there is none up to the filesystem root.
+func FindRepoRoot(start string) (string, bool) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", false
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false // Reached the filesystem root: not inside a work tree
+		}
+		dir = parent
+	}
+}
+
+// TrackedFiles lists the files tracked by git under root (`git ls-files`), which must be inside a work tree.
+// Paths are slash-separated and relative to root.
+func TrackedFiles(root string, logger *slog.Logger) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "-z")
+	cmd.Dir = root
+
+	var outBuilder, errBuilder strings.Builder
+	cmd.Stdout = &outBuilder
+	cmd.Stderr = &errBuilder
+
+	logger.Debug("Executing git command", "args", strings.Join(cmd.Args, " "), "dir", root)
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("gitutils: failed to list tracked files in '%s': %w. Stderr: %s", root, err, errBuilder.String())
+	}
+
+	files := []string{} // Non-nil even if nothing is tracked
+	for _, name := range strings.Split(outBuilder.String(), "\x00") {
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// ExportIgnorePatterns returns the patterns of the .gitattributes file in root that set the
+// export-ignore attribute, which keeps paths out of `git archive`, in file order. Patterns that unset it
+// ("-export-ignore") and quoted patterns are skipped. It returns nil if root has no .gitattributes.
+func ExportIgnorePatterns(root string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(root, ".gitattributes"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gitutils: failed to read .gitattributes in '%s': %w", root, err)
+	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "\"") {
+			continue
+		}
+		for _, attribute := range fields[1:] {
+			if attribute == "export-ignore" {
+				patterns = append(patterns, fields[0])
+				break
+			}
+		}
+	}
+	return patterns, nil
+}
+
+// Info describes the state of a git work tree, e.g. to record which commit a context was generated from.
+type Info struct {
+	RemoteURL string // URL of the "origin" remote without credentials; empty if there is none
+	Commit    string // Full hash of HEAD
+	Ref       string // Current branch, or "HEAD" if detached
+	Dirty     bool   // Tracked files have uncommitted changes
+}
+
+// RepoInfo returns the commit, branch, origin URL, and dirty state of the work tree containing root.
+// Untracked files don't make the work tree dirty (as with `git describe --dirty`).
+func RepoInfo(root string, logger *slog.Logger) (Info, error) {
+	var info Info
+	var err error
+	if info.Commit, err = gitOutput(root, logger, "rev-parse", "HEAD"); err != nil {
+		return Info{}, err
+	}
+	if info.Ref, err = gitOutput(root, logger, "rev-parse", "--abbrev-ref", "HEAD"); err != nil {
+		return Info{}, err
+	}
+	status, err := gitOutput(root, logger, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return Info{}, err
+	}
+	info.Dirty = status != ""
+	if remoteURL, err := gitOutput(root, logger, "remote", "get-url", "origin"); err == nil {
+		info.RemoteURL = RedactURLCredentials(remoteURL)
+	} // No origin remote: leave RemoteURL empty
+	return info, nil
+}
+
+// RedactURLCredentials removes the user info (e.g. an access token) from an URL with a scheme.
+// SCP-like addresses such as "git@host:project" are returned unchanged.
+func RedactURLCredentials(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Scheme == "" || parsedURL.User == nil {
+		return rawURL
+	}
+	parsedURL.User = nil
+	return parsedURL.String()
+}
+
+// gitOutput runs git with args in dir and returns its trimmed standard output.
+func gitOutput(dir string, logger *slog.Logger, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	var outBuilder, errBuilder strings.Builder
+	cmd.Stdout = &outBuilder
+	cmd.Stderr = &errBuilder
+
+	logger.Debug("Executing git command", "args", strings.Join(cmd.Args, " "), "dir", dir)
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("gitutils: 'git %s' failed in '%s': %w. Stderr: %s", strings.Join(args, " "), dir, err, errBuilder.String())
+	}
+	return strings.TrimSpace(outBuilder.String()), nil
+}
@@ -0,0 +1,74 @@
+package processor
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexferrari88/code2context/internal/appconfig"
+	"github.com/alexferrari88/code2context/internal/collector"
+	"github.com/alexferrari88/code2context/internal/filefilter"
+)
+
+// fileAnnotation returns the line written before a file's section with Annotate, describing the file's
+// role for the reader: "// path | go | 142 lines | test | generated". The language is left out if it is
+// unknown, and the line count if the file cannot be read.
+func (p *Processor) fileAnnotation(file includedFile, logger *slog.Logger) string {
+	parts := []string{p.displayPath(file)}
+	if language := collector.LanguageOf(file.relPath); language != "" {
+		parts = append(parts, language)
+	}
+	if lineCount, err := file.lineCount(); err == nil {
+		lineUnit := "lines"
+		if lineCount == 1 {
+			lineUnit = "line"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", lineCount, lineUnit))
+	} else {
+		logger.Warn("Processor: Could not count lines for the annotation", "path", file.relPath, "error", err)
+	}
+	if isTestFile(file.relPath) {
+		parts = append(parts, "test")
+	}
+	if isGeneratedIncludedFile(file) {
+		parts = append(parts, "generated")
+	}
+	annotation := strings.Join(parts, " | ")
+	if p.config.OutputFormat == OutputFormatXML {
+		return "<!-- " + strings.ReplaceAll(xmlEscapeText(annotation), "--", "- -") + " -->\n"
+	}
+	return "// " + annotation + "\n"
+}
+
+// isTestFile reports whether relPath looks like a test by the heuristic of --exclude-tests: its name
+// matches one of the default test file patterns, or it is below one of the default test directories.
+func isTestFile(relPath string) bool {
+	slashPath := filepath.ToSlash(relPath)
+	for _, pattern := range appconfig.GetDefaultTestFilePatterns() {
+		if matched, _ := path.Match(pattern, path.Base(slashPath)); matched {
+			return true
+		}
+	}
+	dirs := strings.Split(path.Dir(slashPath), "/")
+	for _, testDir := range appconfig.GetDefaultTestDirs() {
+		for _, dir := range dirs {
+			if dir == testDir {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isGeneratedIncludedFile reports whether the file's content starts with a generated-code header
+// (see filefilter.IsGeneratedFile); an unreadable file counts as hand-written.
+func isGeneratedIncludedFile(file includedFile) bool {
+	content, err := file.open()
+	if err != nil {
+		return false
+	}
+	defer content.Close()
+	return filefilter.IsGeneratedContent(content)
+}
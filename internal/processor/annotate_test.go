@@ -0,0 +1,48 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnnotate(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"main.go":              "package main\n\nfunc main() {}\n",
+		"main_test.go":         "package main\n",
+		"tests/helper.py":      "import os\n",
+		"gen.pb.go":            "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage gen\n",
+		"zz_generated_test.go": "// Code generated by mockgen. DO NOT EDIT.\npackage main\n",
+		"NOTICE":               "Copyright\n",
+	})
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "main.go", want: "// main.go | go | 3 lines\n"},
+		{path: "main_test.go", want: "// main_test.go | go | 1 line | test\n"},
+		{path: "tests/helper.py", want: "// tests/helper.py | python | 1 line | test\n"},
+		{path: "gen.pb.go", want: "// gen.pb.go | go | 3 lines | generated\n"},
+		{path: "zz_generated_test.go", want: "// zz_generated_test.go | go | 2 lines | test | generated\n"},
+		{path: "NOTICE", want: "// NOTICE | 1 line\n"}, // Unknown language
+	}
+	output := processToString(t, Config{SourcePath: root, Annotate: true})
+	plain := processToString(t, Config{SourcePath: root})
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if !strings.Contains(output, tt.want+"```"+tt.path+"\n") {
+				t.Errorf("output =\n%s\nwant the annotation %q before the section of %s", output, tt.want, tt.path)
+			}
+			if strings.Contains(plain, tt.want) {
+				t.Errorf("output without Annotate =\n%s\nwant no annotation for %s", plain, tt.path)
+			}
+		})
+	}
+
+	t.Run("xml", func(t *testing.T) {
+		output := processToString(t, Config{SourcePath: root, Annotate: true, OutputFormat: OutputFormatXML})
+		want := "<!-- main_test.go | go | 1 line | test -->\n<document index="
+		if !strings.Contains(output, want) {
+			t.Errorf("output =\n%s\nwant the annotation as an XML comment %q", output, want)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package processor
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// reasonTokenBudget is the SkippedByReason key of files left out by MaxTokens.
+const reasonTokenBudget = "token_budget"
+
+// applyTokenBudget returns the leading files whose sections fit in MaxTokens together, counted with the
+// TokenCounter. From the first file that would exceed the budget on, the files are left out. The sections
+// are rendered as they will be written, except that duplicates (see Dedupe) count in full.
+func (p *Processor) applyTokenBudget(files []includedFile) ([]includedFile, error) {
+	discard := slog.New(slog.DiscardHandler) // Problems reading the files are logged when they are written
+	var section strings.Builder
+	total := 0
+	for i, file := range files {
+		section.Reset()
+		if err := p.writeFileSection(&section, file, i+1, p.contentNote(file), nil, discard); err != nil {
+			return nil, err
+		}
+		tokens := p.config.TokenCounter.Count(section.String())
+		if total+tokens > p.config.MaxTokens {
+			p.logger.Warn("Processor: Token budget reached, omitting the remaining files",
+				"max_tokens", p.config.MaxTokens, "included_tokens", total, "omitted_files", len(files)-i, "first_omitted", file.relPath)
+			return files[:i], nil
+		}
+		total += tokens
+	}
+	p.logger.Debug("Processor: All files fit in the token budget", "max_tokens", p.config.MaxTokens, "tokens", total)
+	return files, nil
+}
+
+// tokenBudgetNote returns the note written after the last file when omitted files were left out by MaxTokens.
+func (p *Processor) tokenBudgetNote(omitted int) string {
+	return fmt.Sprintf("Note: the token budget of %d was reached; %d more file(s) were omitted.", p.config.MaxTokens, omitted)
+}
@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixedTokenCounter counts a fixed number of tokens for the section of each file, found by its path.
+type fixedTokenCounter map[string]int
+
+func (c fixedTokenCounter) Name() string { return "fixed" }
+
+func (c fixedTokenCounter) Count(text string) int {
+	for path, tokens := range c {
+		if strings.HasPrefix(text, "```"+path+"\n") {
+			return tokens
+		}
+	}
+	return 0
+}
+
+func TestMaxTokens(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"a.go": "package a\n",
+		"b.go": "package b\n",
+		"c.go": "package c\n",
+		"d.go": "package d\n",
+	})
+	counter := fixedTokenCounter{"a.go": 10, "b.go": 20, "c.go": 5, "d.go": 10}
+	tests := []struct {
+		name      string
+		maxTokens int
+		want      []string
+	}{
+		{name: "unlimited", maxTokens: 0, want: []string{"a.go", "b.go", "c.go", "d.go"}},
+		{name: "all fit", maxTokens: 45, want: []string{"a.go", "b.go", "c.go", "d.go"}},
+		{name: "cut at the first file that exceeds it", maxTokens: 29, want: []string{"a.go"}}, // c.go would still fit after a.go
+		{name: "budget filled exactly", maxTokens: 30, want: []string{"a.go", "b.go"}},
+		{name: "cut before the last file", maxTokens: 39, want: []string{"a.go", "b.go", "c.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(Config{SourcePath: root, IncludeTree: true, MaxTokens: tt.maxTokens, TokenCounter: counter})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			var out bytes.Buffer
+			if err := p.ProcessTo(&out); err != nil {
+				t.Fatalf("ProcessTo() error = %v", err)
+			}
+			output := out.String()
+			if got := sectionPaths(output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+			tree := output[:strings.Index(output, "```")]
+			for _, name := range []string{"a.go", "b.go", "c.go", "d.go"} {
+				if !strings.Contains(tree, name) {
+					t.Errorf("tree =\n%s\nwant it complete, with %s", tree, name)
+				}
+			}
+			omitted := 4 - len(tt.want)
+			note := p.tokenBudgetNote(omitted)
+			if got := strings.Contains(output, note); got != (omitted > 0) {
+				t.Errorf("output contains %q = %v, want %v", note, got, omitted > 0)
+			}
+			if got := p.GetResult().SkippedByReason[reasonTokenBudget]; got != omitted {
+				t.Errorf("SkippedByReason[%q] = %d, want %d", reasonTokenBudget, got, omitted)
+			}
+		})
+	}
+}
+
+func TestMaxTokensLogsFileProblemsOnce(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"bad.txt": "bad \xff byte\n",
+		"good.go": "package good\n",
+	})
+	var logs bytes.Buffer
+	processToString(t, Config{
+		SourcePath: root,
+		MaxTokens:  1000,
+		Logger:     slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelWarn})),
+	})
+	if got := strings.Count(logs.String(), "not valid UTF-8"); got != 1 {
+		t.Errorf("logs = %q, want the invalid UTF-8 warning once, not also when measuring", logs.String())
+	}
+}
@@ -0,0 +1,43 @@
+package processor
+
+import (
+	"io"
+	"maps"
+	"path/filepath"
+)
+
+// CountStats summarizes the files a run would include, computed instead of the output with Config.CountOnly.
+type CountStats struct {
+	Files     int             `json:"files"`
+	Bytes     int64           `json:"bytes"`  // Total size of the files' content
+	Tokens    int             `json:"tokens"` // Tokens of the files' content, by Config.TokenCounter
+	Languages []LanguageStats `json:"languages"`
+	Skipped   map[string]int  `json:"skipped"` // As ProcessResult.SkippedByReason
+}
+
+// countFiles computes the CountStats of files and records their token counts (see GetTokenCounts).
+// Tokens are counted on the raw content, without file headers or any transformation.
+func (p *Processor) countFiles(files []includedFile) CountStats {
+	stats := CountStats{
+		Files:     len(files),
+		Languages: p.languageStats,
+		Skipped:   maps.Clone(p.result.SkippedByReason),
+	}
+	for _, file := range files {
+		stats.Bytes += file.info.Size()
+		content, err := file.open()
+		if err != nil {
+			p.logger.Warn("Processor: Failed to open file for counting (tokens not counted)", "path", file.relPath, "error", err)
+			continue
+		}
+		data, err := io.ReadAll(content)
+		_ = content.Close()
+		if err != nil {
+			p.logger.Warn("Processor: Error reading file content for counting", "path", file.relPath, "error", err)
+		}
+		tokens := p.config.TokenCounter.Count(string(data))
+		p.tokenCounts = append(p.tokenCounts, FileTokenCount{Path: filepath.ToSlash(file.relPath), Tokens: tokens})
+		stats.Tokens += tokens
+	}
+	return stats
+}
@@ -0,0 +1,36 @@
+package processor
+
+import "errors"
+
+// Classified reasons for a failed run. Check for them with errors.Is.
+var (
+	ErrSourceNotFound = errors.New("source path not found")
+	ErrOutputWrite    = errors.New("failed to write output")
+	ErrTimeout        = errors.New("operation timed out")
+)
+
+// ErrNoFiles is returned by a run that included no files: nothing matched the filters.
+// It is a warning rather than a failure: the (empty) output is written unless Config.NoEmptyOutput is set,
+// and the result is available from GetResult.
+var ErrNoFiles = errors.New("no files matched the filters; nothing to include")
+
+// outputWriteError marks an error of writing the output as ErrOutputWrite, keeping its message.
+type outputWriteError struct {
+	err error
+}
+
+func (e *outputWriteError) Error() string {
+	return e.err.Error()
+}
+
+func (e *outputWriteError) Unwrap() []error {
+	return []error{ErrOutputWrite, e.err}
+}
+
+// asOutputWriteError wraps a non-nil err in an outputWriteError.
+func asOutputWriteError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &outputWriteError{err: err}
+}
@@ -0,0 +1,188 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// OutputFormat selects how the tree and file sections are rendered.
+type OutputFormat string
+
+const (
+	OutputFormatText     OutputFormat = "txt"   // Fenced file sections, plain tree (default)
+	OutputFormatMarkdown OutputFormat = "md"    // Fenced file sections, tree inside a fenced block
+	OutputFormatXML      OutputFormat = "xml"   // Anthropic-style <document> elements with escaped contents
+	OutputFormatJSON     OutputFormat = "json"  // Object with the tree and an array of {path, language, size, content} files
+	OutputFormatJSONL    OutputFormat = "jsonl" // One JSON object per line: the tree, then one per file
+)
+
+// ParseOutputFormat validates a --format value. An empty value selects OutputFormatText.
+func ParseOutputFormat(value string) (OutputFormat, error) {
+	switch format := OutputFormat(strings.ToLower(strings.TrimSpace(value))); format {
+	case "":
+		return OutputFormatText, nil
+	case OutputFormatText, OutputFormatMarkdown, OutputFormatXML, OutputFormatJSON, OutputFormatJSONL:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown output format '%s'. Supported: txt, md, xml, json, jsonl", value)
+	}
+}
+
+// FileExtension returns the extension used for default output file names in this format.
+func (f OutputFormat) FileExtension() string {
+	switch f {
+	case OutputFormatMarkdown:
+		return ".md"
+	case OutputFormatXML:
+		return ".xml"
+	case OutputFormatJSON:
+		return ".json"
+	case OutputFormatJSONL:
+		return ".jsonl"
+	default:
+		return ".txt"
+	}
+}
+
+// isJSON reports whether the format encodes file sections as JSON objects.
+func (f OutputFormat) isJSON() bool {
+	return f == OutputFormatJSON || f == OutputFormatJSONL
+}
+
+// isFenced reports whether the format writes file sections as fenced code blocks.
+// The zero value is OutputFormatText, as everywhere else.
+func (f OutputFormat) isFenced() bool {
+	return f == "" || f == OutputFormatText || f == OutputFormatMarkdown
+}
+
+// jsonFile is the JSON representation of one file in OutputFormatJSON and OutputFormatJSONL.
+type jsonFile struct {
+	Type     string `json:"type,omitempty"` // "file" in OutputFormatJSONL, to tell files from other records
+	Path     string `json:"path"`
+	Language string `json:"language"` // Empty if the extension is not recognized
+	Size     int64  `json:"size"`     // Size on disk in bytes
+	Content  string `json:"content"`
+}
+
+// jsonlRecord is a line of OutputFormatJSONL other than a file: the git info, the tree,
+// the table of contents, the prepended or appended text, or a note (e.g. on the token budget).
+type jsonlRecord struct {
+	Type    string `json:"type"` // "git", "tree", "toc", "prepend", "append", or "note" ("meta" is a jsonMeta)
+	Content string `json:"content"`
+}
+
+// SchemaVersion identifies the layout of the JSON and JSON Lines outputs, so parsers can detect it.
+// It is written as the "schema" of the meta members or record (see jsonMeta) and changes whenever
+// the layout changes in a way that could break a parser.
+const SchemaVersion = "code2context/v1"
+
+// jsonMeta describes the output for parsers: its members are the first ones of OutputFormatJSON's
+// top-level object, and it is the first ("meta") record of OutputFormatJSONL.
+type jsonMeta struct {
+	Type        string `json:"type,omitempty"`         // "meta" in OutputFormatJSONL
+	Schema      string `json:"schema"`                 // SchemaVersion
+	GeneratedAt string `json:"generated_at,omitempty"` // RFC 3339 time of the run; omitted with Config.Reproducible
+	Source      string `json:"source"`                 // The repository URL (without credentials), or the name of the local folder
+}
+
+// jsonGitInfo is the "git" member of OutputFormatJSON's top-level object (see Config.WithGitInfo).
+type jsonGitInfo struct {
+	Repository string `json:"repository,omitempty"` // Empty for a local repository without an origin remote
+	Commit     string `json:"commit"`
+	Ref        string `json:"ref"`
+	Dirty      bool   `json:"dirty"`
+}
+
+// encodeJSON encodes v as compact JSON without a trailing newline.
+// HTML characters are not escaped, so code stays readable.
+func encodeJSON(v any) (string, error) {
+	var buf strings.Builder
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// jsonMember renders `"key":<value>` for the JSON document's top-level object.
+func jsonMember(key string, value any) string {
+	encoded, _ := encodeJSON(value) // Strings (and slices of them) always encode; invalid UTF-8 is replaced
+	return fmt.Sprintf("%q:%s", key, encoded)
+}
+
+// jsonlLine renders a jsonlRecord as a line of OutputFormatJSONL.
+func jsonlLine(recordType, content string) string {
+	encoded, _ := encodeJSON(jsonlRecord{Type: recordType, Content: content}) // Strings always encode
+	return encoded + "\n"
+}
+
+// PathStyle selects how file paths are shown in file headers.
+type PathStyle string
+
+const (
+	PathStyleRelative PathStyle = "relative" // Relative to the processed root (default)
+	PathStyleAbsolute PathStyle = "absolute" // Absolute path on disk (of the clone, for remote repositories)
+	PathStyleRepo     PathStyle = "repo"     // Relative path prefixed with the repo/folder name
+)
+
+// ParsePathStyle validates a --path-style value. An empty value selects PathStyleRelative.
+func ParsePathStyle(value string) (PathStyle, error) {
+	switch style := PathStyle(strings.ToLower(strings.TrimSpace(value))); style {
+	case "":
+		return PathStyleRelative, nil
+	case PathStyleRelative, PathStyleAbsolute, PathStyleRepo:
+		return style, nil
+	default:
+		return "", fmt.Errorf("unknown path style '%s'. Supported: relative, absolute, repo", value)
+	}
+}
+
+// FenceLang selects whether the opening fence of a file section names the file's language
+// (e.g. "```go main.go" instead of "```main.go"). It only affects the fenced formats (txt, md).
+type FenceLang string
+
+const (
+	FenceLangNever  FenceLang = "never"  // Only the path (default)
+	FenceLangAuto   FenceLang = "auto"   // The language if it is known from the extension
+	FenceLangAlways FenceLang = "always" // The language of every file; files of unknown language are an error
+)
+
+// ParseFenceLang validates a --fence-lang value. An empty value selects FenceLangNever.
+func ParseFenceLang(value string) (FenceLang, error) {
+	switch mode := FenceLang(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return FenceLangNever, nil
+	case FenceLangNever, FenceLangAuto, FenceLangAlways:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown fence language mode '%s'. Supported: auto, always, never", value)
+	}
+}
+
+var xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// xmlEscapeText escapes s for use as XML element content. Invalid UTF-8 and characters
+// that are not allowed in XML 1.0 documents are replaced with U+FFFD.
+func xmlEscapeText(s string) string {
+	s = xmlTextEscaper.Replace(s)
+	if utf8.ValidString(s) && strings.IndexFunc(s, func(r rune) bool { return !isXMLChar(r) }) < 0 {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if !isXMLChar(r) {
+			return utf8.RuneError
+		}
+		return r
+	}, s)
+}
+
+// isXMLChar reports whether r is in the XML 1.0 Char production.
+func isXMLChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		(r >= 0x20 && r <= 0xD7FF) ||
+		(r >= 0xE000 && r <= 0xFFFD) ||
+		(r >= 0x10000 && r <= 0x10FFFF)
+}
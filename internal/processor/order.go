@@ -0,0 +1,49 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SortOrder controls the order in which file sections are written.
+type SortOrder string
+
+const (
+	SortByPath  SortOrder = "path"  // Lexical walk order (default)
+	SortByExt   SortOrder = "ext"   // Grouped by extension, then by path
+	SortBySize  SortOrder = "size"  // Smallest first, largest last
+	SortByMTime SortOrder = "mtime" // Oldest first, most recently modified last
+)
+
+// ParseSortOrder validates a --sort value. An empty value selects SortByPath.
+func ParseSortOrder(value string) (SortOrder, error) {
+	switch order := SortOrder(strings.ToLower(strings.TrimSpace(value))); order {
+	case "":
+		return SortByPath, nil
+	case SortByPath, SortByExt, SortBySize, SortByMTime:
+		return order, nil
+	default:
+		return "", fmt.Errorf("unknown sort order '%s'. Supported: path, ext, size, mtime", value)
+	}
+}
+
+// sortFiles reorders files (given in walk order) according to order.
+// The sort is stable, so files that compare equal keep their walk order.
+func sortFiles(files []includedFile, order SortOrder) {
+	var less func(a, b includedFile) bool
+	switch order {
+	case SortByExt:
+		less = func(a, b includedFile) bool {
+			return strings.ToLower(filepath.Ext(a.relPath)) < strings.ToLower(filepath.Ext(b.relPath))
+		}
+	case SortBySize:
+		less = func(a, b includedFile) bool { return a.info.Size() < b.info.Size() }
+	case SortByMTime:
+		less = func(a, b includedFile) bool { return a.info.ModTime().Before(b.info.ModTime()) }
+	default:
+		return // SortByPath: the walk order is already lexical
+	}
+	sort.SliceStable(files, func(i, j int) bool { return less(files[i], files[j]) })
+}
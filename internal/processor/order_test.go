@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSortOrder(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    SortOrder
+		wantErr bool
+	}{
+		{value: "", want: SortByPath},
+		{value: "path", want: SortByPath},
+		{value: " EXT ", want: SortByExt},
+		{value: "size", want: SortBySize},
+		{value: "mtime", want: SortByMTime},
+		{value: "name", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSortOrder(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSortOrder(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseSortOrder(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSortOrder(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"b.go":       strings.Repeat("b", 30),
+		"a.md":       strings.Repeat("a", 10),
+		"c.go":       strings.Repeat("c", 20),
+		"sub/d.txt":  strings.Repeat("d", 40),
+		"sub/e.go":   strings.Repeat("e", 10),
+		"sub/f.MD":   strings.Repeat("f", 50),
+		"sub/g.none": strings.Repeat("g", 5),
+	})
+	// Modification times in an order unrelated to the paths and sizes
+	base := time.Now().Add(-time.Hour)
+	for i, path := range []string{"sub/d.txt", "c.go", "sub/g.none", "a.md", "sub/f.MD", "b.go", "sub/e.go"} {
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(path)), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		order SortOrder
+		want  []string
+	}{
+		{order: "", want: []string{"a.md", "b.go", "c.go", "sub/d.txt", "sub/e.go", "sub/f.MD", "sub/g.none"}},
+		{order: SortByPath, want: []string{"a.md", "b.go", "c.go", "sub/d.txt", "sub/e.go", "sub/f.MD", "sub/g.none"}},
+		{order: SortByExt, want: []string{"b.go", "c.go", "sub/e.go", "a.md", "sub/f.MD", "sub/g.none", "sub/d.txt"}},
+		{order: SortBySize, want: []string{"sub/g.none", "a.md", "sub/e.go", "c.go", "b.go", "sub/d.txt", "sub/f.MD"}},
+		{order: SortByMTime, want: []string{"sub/d.txt", "c.go", "sub/g.none", "a.md", "sub/f.MD", "b.go", "sub/e.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.order), func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: root, SortOrder: tt.order})
+			if got := sectionPaths(output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("path is the walk order", func(t *testing.T) {
+		if byPath, unset := processToString(t, Config{SourcePath: root, SortOrder: SortByPath}), processToString(t, Config{SourcePath: root}); byPath != unset {
+			t.Errorf("output with --sort path differs from the default:\n%s\nwant\n%s", byPath, unset)
+		}
+	})
+}
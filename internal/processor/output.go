@@ -0,0 +1,356 @@
+package processor
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pendingOutput is an output file that is written to a temporary file in the same directory first
+// and moved into place by commit, to prevent data loss on error and to handle outputting to the source dir.
+// A streamed output (see newStreamedOutput) has no file and writes through to its writer instead.
+type pendingOutput struct {
+	finalPath string
+	tempFile  *os.File     // Nil for a streamed output
+	gzip      *gzip.Writer // Between writer and tempFile if the output is compressed, nil otherwise
+	writer    *bufio.Writer
+	size      int64 // Bytes written so far (before compression)
+	committed bool
+	logger    *slog.Logger
+
+	holdNewlines bool // Hold back trailing newlines until more content follows, so endWithSingleNewline can drop them
+	heldNewlines int  // Number of newlines held back (already included in size)
+}
+
+// newPendingOutput creates the temporary file for finalPath. If compress is set, the content is
+// written to it gzip-compressed.
+func newPendingOutput(finalPath string, compress bool, logger *slog.Logger) (*pendingOutput, error) {
+	tempFile, err := os.CreateTemp(filepath.Dir(finalPath), "c2c_out_*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("processor: failed to create temporary output file: %w", err)
+	}
+	output := &pendingOutput{finalPath: finalPath, tempFile: tempFile, logger: logger}
+	if compress {
+		output.gzip = gzip.NewWriter(tempFile)
+		output.writer = bufio.NewWriter(output.gzip)
+	} else {
+		output.writer = bufio.NewWriter(tempFile)
+	}
+	return output, nil
+}
+
+// newStreamedOutput returns an output that writes to w as it goes; commit only flushes.
+func newStreamedOutput(w io.Writer, logger *slog.Logger) *pendingOutput {
+	return &pendingOutput{writer: bufio.NewWriter(w), logger: logger}
+}
+
+// WriteString writes s to the temporary file, keeping track of the output size.
+func (o *pendingOutput) WriteString(s string) (int, error) {
+	if !o.holdNewlines {
+		n, err := o.writer.WriteString(s)
+		o.size += int64(n)
+		return n, err
+	}
+	content := strings.TrimRight(s, "\n")
+	if content != "" {
+		if _, err := o.writer.WriteString(strings.Repeat("\n", o.heldNewlines)); err != nil {
+			return 0, err
+		}
+		o.heldNewlines = 0
+		if n, err := o.writer.WriteString(content); err != nil {
+			o.size += int64(n)
+			return n, err
+		}
+	}
+	o.heldNewlines += len(s) - len(content)
+	o.size += int64(len(s))
+	return len(s), nil
+}
+
+// endWithSingleNewline makes the content end in exactly one newline, dropping surplus trailing
+// newlines or adding a missing one. It requires holdNewlines and must be called before commit.
+func (o *pendingOutput) endWithSingleNewline() error {
+	o.size -= int64(o.heldNewlines)
+	o.heldNewlines = 0
+	if _, err := o.writer.WriteString("\n"); err != nil {
+		return fmt.Errorf("processor: failed to end output with a newline: %w", err)
+	}
+	o.size++
+	return nil
+}
+
+// commit flushes the temporary file and moves it to the final path, falling back to a copy
+// if the rename fails (e.g., across different devices/filesystems).
+func (o *pendingOutput) commit() error {
+	if o.tempFile == nil {
+		if flushErr := o.writer.Flush(); flushErr != nil {
+			return fmt.Errorf("processor: failed to flush streamed output: %w", flushErr)
+		}
+		o.committed = true
+		return nil
+	}
+	tempFileName := o.tempFile.Name()
+
+	// All content successfully written to the temporary file's buffer
+	if flushErr := o.writer.Flush(); flushErr != nil {
+		return fmt.Errorf("processor: failed to flush writer for temporary output file: %w", flushErr)
+	}
+	if o.gzip != nil { // Writes the gzip footer, which must be in the file before it is moved into place
+		if closeErr := o.gzip.Close(); closeErr != nil {
+			return fmt.Errorf("processor: failed to finish compressing temporary output file '%s': %w", tempFileName, closeErr)
+		}
+	}
+	if closeErr := o.tempFile.Close(); closeErr != nil { // Ensure temp file is closed before rename
+		return fmt.Errorf("processor: failed to close temporary output file '%s': %w", tempFileName, closeErr)
+	}
+
+	// Rename temporary file to final output file
+	o.logger.Debug("Processor: Attempting to rename temporary output file", "from", tempFileName, "to", o.finalPath)
+	if renameErr := os.Rename(tempFileName, o.finalPath); renameErr != nil {
+		o.logger.Warn("Processor: Rename failed, attempting copy fallback", "from", tempFileName, "to", o.finalPath, "error", renameErr)
+		in, readErr := os.Open(tempFileName)
+		if readErr != nil {
+			// Original temp file might still be there, don't remove if open failed.
+			return fmt.Errorf("processor: failed to open temp file '%s' for copying: %w (original rename error: %v)", tempFileName, readErr, renameErr)
+		}
+
+		out, createErr := os.Create(o.finalPath)
+		if createErr != nil {
+			_ = in.Close()
+			return fmt.Errorf("processor: failed to create final output file '%s' for copying: %w (original rename error: %v)", o.finalPath, createErr, renameErr)
+		}
+
+		_, copyErr := io.Copy(out, in)
+		_ = in.Close()  // Close input file after copy attempt
+		_ = out.Close() // Close output file after copy attempt
+
+		if copyErr != nil {
+			return fmt.Errorf("processor: failed to copy temp file to final output file: %w (original rename error: %v)", copyErr, renameErr)
+		}
+		// If copy succeeds, remove the original temporary file
+		if removeErr := os.Remove(tempFileName); removeErr != nil {
+			o.logger.Warn("Processor: Failed to remove temporary output file after successful copy", "path", tempFileName, "error", removeErr)
+		}
+	}
+	o.committed = true // Mark as successful so discard doesn't remove the (now renamed or copied) temp file.
+	return nil
+}
+
+// discard removes the temporary file unless the output was committed.
+func (o *pendingOutput) discard() {
+	if o.tempFile == nil {
+		return // Nothing to clean up for a streamed output
+	}
+	// The file might have already been closed, but calling Close again on a closed file is safe.
+	_ = o.tempFile.Close()
+	if o.committed {
+		return
+	}
+	tempFileName := o.tempFile.Name()
+	o.logger.Debug("Processor: Cleaning up temporary output file due to error or incomplete processing", "path", tempFileName)
+	if removeErr := os.Remove(tempFileName); removeErr != nil && !os.IsNotExist(removeErr) {
+		o.logger.Warn("Processor: Failed to remove incomplete temporary output file", "path", tempFileName, "error", removeErr)
+	}
+}
+
+// partWriter distributes the output over numbered part files ("name.part1.txt", "name.part2.txt", ...)
+// of at most limit bytes each, splitting only between sections. With a limit of 0 everything is
+// written to finalPath. partOpen and partClose are written at the start and end of every part
+// (e.g. the XML root element) so that each part stands on its own, separator between two sections
+// of the same part, and sectionsClose after the last section of a part, before partClose.
+// If stream is set, the output is written to it instead (no splitting).
+type partWriter struct {
+	finalPath          string
+	stream             io.Writer
+	logger             *slog.Logger
+	limit              int64
+	partOpen           string
+	partClose          string
+	separator          string
+	sectionsClose      string
+	parts              []*pendingOutput
+	partHasData        bool // The current part holds a header or at least one section
+	sections           int  // Number of sections in the current part
+	singleFinalNewline bool // End every part with exactly one newline
+	compress           bool // Gzip-compress every part (not a streamed output)
+	partIsFull         bool // The current part holds an oversized section; the next section starts a new part
+}
+
+func newPartWriter(finalPath string, limit int64, partOpen, partClose string) *partWriter {
+	return &partWriter{finalPath: finalPath, limit: limit, partOpen: partOpen, partClose: partClose}
+}
+
+// partPath returns the path of the n-th (1-based) part, e.g. "out.txt" -> "out.part2.txt".
+func partPath(finalPath string, n int) string {
+	ext := filepath.Ext(finalPath)
+	return fmt.Sprintf("%s.part%d%s", strings.TrimSuffix(finalPath, ext), n, ext)
+}
+
+func (w *partWriter) current() *pendingOutput {
+	return w.parts[len(w.parts)-1]
+}
+
+// startPart closes the current part (if any) and opens the next one.
+func (w *partWriter) startPart() error {
+	if len(w.parts) > 0 {
+		if err := w.closeSections(); err != nil {
+			return err
+		}
+		if _, err := w.current().WriteString(w.partClose); err != nil {
+			return fmt.Errorf("processor: failed to finish output part: %w", err)
+		}
+	}
+	var part *pendingOutput
+	if w.stream != nil {
+		part = newStreamedOutput(w.stream, w.logger)
+	} else {
+		path := w.finalPath
+		if w.limit > 0 {
+			path = partPath(w.finalPath, len(w.parts)+1)
+		}
+		var err error
+		if part, err = newPendingOutput(path, w.compress, w.logger); err != nil {
+			return err
+		}
+	}
+	part.holdNewlines = w.singleFinalNewline
+	w.parts = append(w.parts, part)
+	w.partHasData = false
+	w.partIsFull = false
+	w.sections = 0
+	if _, err := part.WriteString(w.partOpen); err != nil {
+		return fmt.Errorf("processor: failed to start output part: %w", err)
+	}
+	return nil
+}
+
+// writeHeader starts the first part with firstOpen (written instead of partOpen, e.g. to add
+// prepended text before it) followed by header (e.g. the tree).
+func (w *partWriter) writeHeader(firstOpen, header string) error {
+	if len(w.parts) > 0 {
+		return fmt.Errorf("processor: output header must be written before any section")
+	}
+	partOpen := w.partOpen
+	w.partOpen = firstOpen // Only the first part is opened this way
+	err := w.startPart()
+	w.partOpen = partOpen
+	if err != nil {
+		return err
+	}
+	if header == "" {
+		return nil
+	}
+	if _, err := w.current().WriteString(header); err != nil {
+		return fmt.Errorf("processor: failed to write output header: %w", err)
+	}
+	w.partHasData = true
+	return nil
+}
+
+// writeSection writes a complete section (e.g. one file), starting a new part first if it would not fit
+// in the current one. A section that exceeds the limit on its own gets a part of its own, preceded by note.
+func (w *partWriter) writeSection(section, note string) error {
+	if len(w.parts) == 0 {
+		if err := w.startPart(); err != nil {
+			return err
+		}
+	}
+	sectionSize := int64(len(section))
+	separatorSize := int64(0)
+	if w.sections > 0 {
+		separatorSize = int64(len(w.separator))
+	}
+	closeSize := int64(len(w.sectionsClose) + len(w.partClose))
+	if w.limit > 0 && w.partHasData && (w.partIsFull || w.current().size+separatorSize+sectionSize+closeSize > w.limit) {
+		if err := w.startPart(); err != nil {
+			return err
+		}
+	}
+	if w.sections > 0 {
+		if _, err := w.current().WriteString(w.separator); err != nil {
+			return fmt.Errorf("processor: failed to write section separator: %w", err)
+		}
+	}
+	if w.limit > 0 && w.current().size+sectionSize+closeSize > w.limit {
+		if _, err := w.current().WriteString(note); err != nil {
+			return fmt.Errorf("processor: failed to write oversized section note: %w", err)
+		}
+		w.partIsFull = true
+	}
+	if _, err := w.current().WriteString(section); err != nil {
+		return fmt.Errorf("processor: failed to write section to temporary output: %w", err)
+	}
+	w.partHasData = true
+	w.sections++
+	return nil
+}
+
+// finish ends the last part with lastClose (written instead of partClose, e.g. to add appended text after it)
+// and commits all parts.
+func (w *partWriter) finish(lastClose string) error {
+	if len(w.parts) == 0 {
+		if err := w.startPart(); err != nil {
+			return err
+		}
+	}
+	if err := w.closeSections(); err != nil {
+		return err
+	}
+	if _, err := w.current().WriteString(lastClose); err != nil {
+		return fmt.Errorf("processor: failed to finish output: %w", err)
+	}
+	for _, part := range w.parts {
+		if w.singleFinalNewline {
+			if err := part.endWithSingleNewline(); err != nil {
+				return err
+			}
+		}
+		if err := part.commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeSections writes sectionsClose after the sections of the current part, if it has any.
+func (w *partWriter) closeSections() error {
+	if w.sections == 0 {
+		return nil
+	}
+	if _, err := w.current().WriteString(w.sectionsClose); err != nil {
+		return fmt.Errorf("processor: failed to end output sections: %w", err)
+	}
+	return nil
+}
+
+// discard removes the temporary files of all parts that were not committed.
+func (w *partWriter) discard() {
+	for _, part := range w.parts {
+		part.discard()
+	}
+}
+
+// size returns the number of bytes written to all parts so far.
+func (w *partWriter) size() int64 {
+	var total int64
+	for _, part := range w.parts {
+		total += part.size
+	}
+	return total
+}
+
+// paths returns the final paths of the parts written so far.
+func (w *partWriter) paths() []string {
+	paths := make([]string, 0, len(w.parts))
+	for _, part := range w.parts {
+		if part.finalPath != "" { // Streamed outputs have no path
+			paths = append(paths, part.finalPath)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,312 @@
+package processor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitSize(t *testing.T) {
+	content := func(c string, n int) string { return strings.Repeat(c, n-1) + "\n" }
+	root := writeSourceFiles(t, map[string]string{
+		"a.go":   content("a", 40),
+		"b.go":   content("b", 40),
+		"c.go":   content("c", 40),
+		"d.go":   content("d", 300), // Larger than the limit on its own
+		"e.go":   content("e", 40),
+		"f/g.go": content("g", 40),
+	})
+	allFiles := []string{"a.go", "b.go", "c.go", "d.go", "e.go", "f/g.go"}
+	tests := []struct {
+		name        string
+		limit       int64
+		includeTree bool
+		wantParts   [][]string
+	}{
+		{name: "two sections per part", limit: 120, wantParts: [][]string{{"a.go", "b.go"}, {"c.go"}, {"d.go"}, {"e.go", "f/g.go"}}},
+		{name: "tree in the first part", limit: 120, includeTree: true, wantParts: [][]string{nil, {"a.go", "b.go"}, {"c.go"}, {"d.go"}, {"e.go", "f/g.go"}}}, // The tree fills the first part
+		{name: "one section per part", limit: 60, wantParts: [][]string{{"a.go"}, {"b.go"}, {"c.go"}, {"d.go"}, {"e.go"}, {"f/g.go"}}},
+		{name: "everything fits", limit: 10000, wantParts: [][]string{allFiles}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "out.txt")
+			p, err := New(Config{SourcePath: root, OutputFile: outputPath, SplitSize: tt.limit, IncludeTree: tt.includeTree, RootLabel: "proj"})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if err := p.Process(); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			var wantFiles []string
+			for n := range tt.wantParts {
+				wantFiles = append(wantFiles, partPath(outputPath, n+1))
+			}
+			if got := p.GetOutputFiles(); !reflect.DeepEqual(got, wantFiles) {
+				t.Fatalf("GetOutputFiles() = %v, want %v", got, wantFiles)
+			}
+			if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+				t.Errorf("unsplit output %s exists (error %v)", outputPath, err)
+			}
+
+			var written []string
+			for n, path := range wantFiles {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				part := string(data)
+				sections := sectionPaths(part)
+				if !reflect.DeepEqual(sections, tt.wantParts[n]) {
+					t.Errorf("part %d has sections %v, want %v", n+1, sections, tt.wantParts[n])
+				}
+				written = append(written, sections...)
+
+				if oversized := strings.Contains(part, "exceeds the split size"); int64(len(part)) > tt.limit != oversized {
+					t.Errorf("part %d has %d bytes (limit %d) but oversized note = %v", n+1, len(part), tt.limit, oversized)
+				} else if oversized && len(sections) != 1 {
+					t.Errorf("oversized part %d has sections %v, want one", n+1, sections)
+				}
+				if hasTree := strings.HasPrefix(part, "proj\n"); hasTree != (tt.includeTree && n == 0) {
+					t.Errorf("part %d starts with the tree = %v", n+1, hasTree)
+				}
+			}
+			// Each file is written whole, exactly once
+			if !reflect.DeepEqual(written, allFiles) {
+				t.Errorf("sections over all parts = %v, want %v", written, allFiles)
+			}
+		})
+	}
+}
+
+func TestPartPath(t *testing.T) {
+	tests := []struct {
+		path string
+		n    int
+		want string
+	}{
+		{path: "out.txt", n: 1, want: "out.part1.txt"},
+		{path: filepath.Join("dir", "out.xml"), n: 12, want: filepath.Join("dir", "out.part12.xml")},
+		{path: "out", n: 2, want: "out.part2"},
+		{path: "out.tar.gz", n: 3, want: "out.tar.part3.gz"},
+	}
+	for _, tt := range tests {
+		if got := partPath(tt.path, tt.n); got != tt.want {
+			t.Errorf("partPath(%q, %d) = %q, want %q", tt.path, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestOutputDir(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{"main.go": "package main\n"})
+	tests := []struct {
+		name       string
+		outputFile string // Relative to the test's temporary directory
+		outputDir  string // Relative to the test's temporary directory
+		existing   string // Created before the run (a trailing "/" makes a directory)
+		mkdir      bool
+		format     OutputFormat
+		want       string // Relative path of the output file; empty if the run fails with ErrOutputWrite
+	}{
+		{name: "default name in the directory", outputDir: "out", existing: "out/", want: "out/" + filepath.Base(root) + ".txt"},
+		{name: "default extension follows the format", outputDir: "out", existing: "out/", format: OutputFormatMarkdown, want: "out/" + filepath.Base(root) + ".md"},
+		{name: "explicit output file wins", outputFile: "explicit.txt", outputDir: "out", existing: "out/", want: "explicit.txt"},
+		{name: "created with mkdir", outputDir: "new/nested", mkdir: true, want: "new/nested/" + filepath.Base(root) + ".txt"},
+		{name: "missing directory", outputDir: "missing"},
+		{name: "not a directory", outputDir: "file", existing: "file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.existing != "" {
+				existing := filepath.Join(dir, filepath.FromSlash(tt.existing))
+				var err error
+				if strings.HasSuffix(tt.existing, "/") {
+					err = os.MkdirAll(existing, 0o755)
+				} else {
+					err = os.WriteFile(existing, nil, 0o644)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg := Config{SourcePath: root, OutputDir: filepath.Join(dir, tt.outputDir), CreateOutputDir: tt.mkdir, OutputFormat: tt.format}
+			if tt.outputFile != "" {
+				cfg.OutputFile = filepath.Join(dir, tt.outputFile)
+			}
+			p, err := New(cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			err = p.Process()
+			if tt.want == "" {
+				if !errors.Is(err, ErrOutputWrite) {
+					t.Errorf("Process() error = %v, want ErrOutputWrite", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			want := filepath.Join(dir, filepath.FromSlash(tt.want))
+			if got := p.GetFinalOutputFile(); got != want {
+				t.Errorf("GetFinalOutputFile() = %q, want %q", got, want)
+			}
+			if _, err := os.Stat(want); err != nil {
+				t.Errorf("output file not written: %v", err)
+			}
+		})
+	}
+}
+
+func TestOutputWithin(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{"main.go": "package main\n"})
+	base := t.TempDir()
+	for _, dir := range []string{"allowed", "outside"} {
+		if err := os.Mkdir(filepath.Join(base, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{"allowed/escape": "outside", "allowed-link": "allowed"} {
+		if err := os.Symlink(filepath.Join(base, target), filepath.Join(base, link)); err != nil {
+			t.Skipf("symbolic links are not supported: %v", err)
+		}
+	}
+	tests := []struct {
+		name         string
+		within       string // Relative to base
+		outputFile   string // Relative to base
+		outputDir    string // Relative to base; created with CreateOutputDir
+		wantAllowed  bool
+		wantResolved string // Relative to base: where the output ends up, or must not appear
+	}{
+		{name: "inside", within: "allowed", outputFile: "allowed/ctx.txt", wantAllowed: true, wantResolved: "allowed/ctx.txt"},
+		{name: "new directory inside", within: "allowed", outputDir: "allowed/new", wantAllowed: true, wantResolved: "allowed/new/" + filepath.Base(root) + ".txt"},
+		{name: "escape via ..", within: "allowed", outputFile: "allowed/../outside/ctx.txt", wantResolved: "outside/ctx.txt"},
+		{name: "escape via a symlink", within: "allowed", outputFile: "allowed/escape/ctx.txt", wantResolved: "outside/ctx.txt"},
+		{name: "new directory outside", within: "allowed", outputDir: "outside/new", wantResolved: "outside/new"},
+		{name: "allowed directory through a symlink", within: "allowed-link", outputFile: "allowed/ctx.txt", wantAllowed: true, wantResolved: "allowed/ctx.txt"},
+		{name: "the allowed directory itself", within: "allowed", outputFile: "allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{SourcePath: root, OutputWithin: filepath.Join(base, tt.within), CreateOutputDir: true}
+			if tt.outputFile != "" {
+				cfg.OutputFile = filepath.Join(base, tt.outputFile)
+			}
+			if tt.outputDir != "" {
+				cfg.OutputDir = filepath.Join(base, tt.outputDir)
+			}
+			p, err := New(cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			err = p.Process()
+			if tt.wantAllowed {
+				if err != nil {
+					t.Fatalf("Process() error = %v", err)
+				}
+			} else if !errors.Is(err, ErrOutputWrite) || !strings.Contains(err.Error(), "where output is allowed") {
+				t.Fatalf("Process() error = %v, want an output outside the allowed directory error", err)
+			}
+			if tt.wantResolved == "" {
+				return
+			}
+			_, statErr := os.Stat(filepath.Join(base, tt.wantResolved))
+			if written := statErr == nil; written != tt.wantAllowed {
+				t.Errorf("%s exists = %v, want %v", tt.wantResolved, written, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+// readOutputs returns the content of the files in dir, in name order, concatenated; with
+// decompress, every file is gunzipped.
+func readOutputs(t *testing.T, dir string, decompress bool) string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content strings.Builder
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decompress {
+			reader, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("gzip.NewReader(%s) error = %v", entry.Name(), err)
+			}
+			if data, err = io.ReadAll(reader); err != nil {
+				t.Fatalf("reading %s: %v", entry.Name(), err)
+			}
+		}
+		content.Write(data)
+	}
+	return content.String()
+}
+
+func TestGzip(t *testing.T) {
+	files := map[string]string{}
+	for i := range 6 {
+		files[fmt.Sprintf("file%d.go", i)] = "package main\n\n// " + strings.Repeat("filler ", 40) + "\n"
+	}
+	root := writeSourceFiles(t, files)
+	tests := []struct {
+		name       string
+		outputFile string // Empty means the default name in the output directory
+		splitSize  int64
+		wantNames  []string
+	}{
+		{name: "output file", outputFile: "ctx.txt.gz", wantNames: []string{"ctx.txt.gz"}},
+		{name: "default name", wantNames: []string{filepath.Base(root) + ".txt.gz"}},
+		{name: "split parts", outputFile: "ctx.txt.gz", splitSize: 800, wantNames: []string{"ctx.txt.part1.gz", "ctx.txt.part2.gz", "ctx.txt.part3.gz"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := func(gzipped bool) string {
+				dir := t.TempDir()
+				cfg := Config{SourcePath: root, IncludeTree: true, OutputDir: dir, SplitSize: tt.splitSize, Gzip: gzipped}
+				if tt.outputFile != "" {
+					cfg.OutputFile = filepath.Join(dir, strings.TrimSuffix(tt.outputFile, ".gz"))
+					if gzipped {
+						cfg.OutputFile += ".gz"
+					}
+				}
+				p, err := New(cfg)
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				if err := p.Process(); err != nil {
+					t.Fatalf("Process() error = %v", err)
+				}
+				return dir
+			}
+			plainDir, gzipDir := run(false), run(true)
+			entries, err := os.ReadDir(gzipDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("compressed outputs = %v, want %v", names, tt.wantNames)
+			}
+			if got, want := readOutputs(t, gzipDir, true), readOutputs(t, plainDir, false); got != want {
+				t.Errorf("decompressed output =\n%s\nwant the uncompressed output\n%s", got, want)
+			}
+		})
+	}
+}
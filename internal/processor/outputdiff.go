@@ -0,0 +1,218 @@
+package processor
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alexferrari88/code2context/internal/collector"
+)
+
+// OutputDiff compares the files written with those of the previous output at the same path (see Config.ShowDiff).
+// Paths are the ones shown in the file headers, sorted.
+type OutputDiff struct {
+	Added     []string // Only in the new output
+	Removed   []string // Only in the previous output
+	Changed   []string // In both, with different content
+	Unchanged int      // Number of files in both with the same content
+}
+
+// headerStatsRegex matches the " (12 lines, 1.2 KB)" suffix that HeaderStats appends to a fenced header.
+var headerStatsRegex = regexp.MustCompile(` \(\d+ lines?, [^()]*\)$`)
+
+var xmlTextUnescaper = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+
+// readOutputSections reads the file sections of the output at path, or of its numbered parts if path
+// itself does not exist, as written in p's format: displayed path -> content. Gzip-compressed files are
+// recognized by their magic number. It returns nil if there is no such output.
+func (p *Processor) readOutputSections(path string) (map[string]string, error) {
+	paths := []string{path}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		paths = nil
+		for n := 1; ; n++ {
+			if _, err := os.Stat(partPath(path, n)); err != nil {
+				break
+			}
+			paths = append(paths, partPath(path, n))
+		}
+		if len(paths) == 0 {
+			return nil, nil
+		}
+	}
+	sections := make(map[string]string)
+	for _, outputPath := range paths {
+		if err := p.readOutputFileSections(outputPath, sections); err != nil {
+			return nil, fmt.Errorf("processor: failed to read output '%s' for comparison: %w", outputPath, err)
+		}
+	}
+	return sections, nil
+}
+
+func (p *Processor) readOutputFileSections(path string, sections map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	var content io.Reader = reader
+	if magic, _ := reader.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		decompressed, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer decompressed.Close()
+		content = decompressed
+	}
+	return p.parseOutputSections(content, sections)
+}
+
+// parseOutputSections adds the file sections of an output in p's format read from r to sections.
+func (p *Processor) parseOutputSections(r io.Reader, sections map[string]string) error {
+	switch p.config.OutputFormat {
+	case OutputFormatJSON:
+		var output struct {
+			Files []jsonFile `json:"files"`
+		}
+		if err := json.NewDecoder(r).Decode(&output); err != nil {
+			return err
+		}
+		for _, file := range output.Files {
+			sections[file.Path] = file.Content
+		}
+		return nil
+	case OutputFormatJSONL:
+		decoder := json.NewDecoder(r)
+		for decoder.More() {
+			var record jsonFile
+			if err := decoder.Decode(&record); err != nil {
+				return err
+			}
+			if record.Type == "file" {
+				sections[record.Path] = record.Content
+			}
+		}
+		return nil
+	case OutputFormatXML:
+		return parseXMLSections(r, sections)
+	default:
+		treeFenceLang := "" // The tree is only fenced in OutputFormatMarkdown
+		if p.config.OutputFormat == OutputFormatMarkdown {
+			treeFenceLang = p.config.TreeFenceLang
+			if treeFenceLang == "" {
+				treeFenceLang = defaultTreeFenceLang
+			}
+		}
+		return parseFencedSections(r, treeFenceLang, sections)
+	}
+}
+
+// parseFencedSections reads the fenced file sections of the txt and md formats: a "```" line with an
+// info string (see fileHeader) up to the next bare "```" line. The first block tagged treeFenceLang,
+// if set, is the tree and skipped. Files whose content has bare "```" lines of their own are split there,
+// in the previous and the new output alike.
+func parseFencedSections(r io.Reader, treeFenceLang string, sections map[string]string) error {
+	reader := bufio.NewReader(r)
+	inSection, treeSeen := false, treeFenceLang == ""
+	sectionPath := ""
+	var content strings.Builder
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		trimmed := strings.TrimRight(line, "\r\n")
+		switch {
+		case inSection && trimmed == "```":
+			if sectionPath != "" {
+				sections[sectionPath] = content.String()
+			}
+			inSection = false
+		case inSection:
+			content.WriteString(line)
+		case strings.HasPrefix(trimmed, "```") && len(trimmed) > 3:
+			inSection = true
+			content.Reset()
+			sectionPath = fencedHeaderPath(trimmed[3:])
+			if !treeSeen && trimmed[3:] == treeFenceLang {
+				sectionPath, treeSeen = "", true
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+// fencedHeaderPath returns the path in a fenced header's info string, without the language
+// in front (see fenceLanguage) and the header stats after it.
+func fencedHeaderPath(infoString string) string {
+	infoString = headerStatsRegex.ReplaceAllString(infoString, "")
+	if language, path, found := strings.Cut(infoString, " "); found && collector.LanguageOf(path) == language {
+		return path
+	}
+	return infoString
+}
+
+// parseXMLSections reads the <document> sections of the XML format: the path in the <source> line
+// and the escaped content between the <document_contents> lines.
+func parseXMLSections(r io.Reader, sections map[string]string) error {
+	reader := bufio.NewReader(r)
+	inSection := false
+	sectionPath := ""
+	var content strings.Builder
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		trimmed := strings.TrimRight(line, "\r\n")
+		switch {
+		case inSection && trimmed == "</document_contents>":
+			sections[sectionPath] = xmlTextUnescaper.Replace(content.String())
+			inSection = false
+		case inSection:
+			content.WriteString(line)
+		case strings.HasPrefix(trimmed, "<source>") && strings.HasSuffix(trimmed, "</source>"):
+			sectionPath = xmlTextUnescaper.Replace(strings.TrimSuffix(strings.TrimPrefix(trimmed, "<source>"), "</source>"))
+		case trimmed == "<document_contents>" && sectionPath != "":
+			inSection = true
+			content.Reset()
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+// diffOutputSections compares the sections of the previous output with those of the new one.
+func diffOutputSections(previous, current map[string]string) *OutputDiff {
+	diff := &OutputDiff{}
+	for path, content := range current {
+		previousContent, ok := previous[path]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, path)
+		case previousContent != content:
+			diff.Changed = append(diff.Changed, path)
+		default:
+			diff.Unchanged++
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			diff.Removed = append(diff.Removed, path)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
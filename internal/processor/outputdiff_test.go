@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFencedSections(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		treeFenceLang string
+		want          map[string]string
+	}{
+		{
+			name:   "plain tree",
+			output: "proj\n├── a.go\n└── b\n    └── c.go\n\n```a.go\npackage a\n```\n\n```b/c.go\npackage b\n\nfunc C() {}\n```\n",
+			want:   map[string]string{"a.go": "package a\n", "b/c.go": "package b\n\nfunc C() {}\n"},
+		},
+		{
+			name:          "fenced tree",
+			output:        "```text\nproj\n└── a.go\n```\n\n```a.go\npackage a\n```\n",
+			treeFenceLang: "text",
+			want:          map[string]string{"a.go": "package a\n"},
+		},
+		{
+			name:   "language and header stats",
+			output: "```go a.go (1 line, 10 B)\npackage a\n```\n\n```b.go (2 lines, 1.2 KB)\nx\ny\n```\n",
+			want:   map[string]string{"a.go": "package a\n", "b.go": "x\ny\n"},
+		},
+		{
+			name:   "path with a space",
+			output: "```my file.go\nx\n```\n",
+			want:   map[string]string{"my file.go": "x\n"},
+		},
+		{
+			name:   "CRLF line endings",
+			output: "```a.go\r\nx\r\n```\r\n",
+			want:   map[string]string{"a.go": "x\r\n"},
+		},
+		{
+			name:   "no sections",
+			output: "proj\n",
+			want:   map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			if err := parseFencedSections(strings.NewReader(tt.output), tt.treeFenceLang, got); err != nil {
+				t.Fatalf("parseFencedSections() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFencedSections() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffOutputSections(t *testing.T) {
+	tests := []struct {
+		name              string
+		previous, current map[string]string
+		want              *OutputDiff
+	}{
+		{
+			name:     "identical",
+			previous: map[string]string{"a.go": "a", "b.go": "b"},
+			current:  map[string]string{"a.go": "a", "b.go": "b"},
+			want:     &OutputDiff{Unchanged: 2},
+		},
+		{
+			name:     "added, removed, and changed",
+			previous: map[string]string{"keep.go": "k", "old.go": "o", "z.go": "1", "y.go": "1"},
+			current:  map[string]string{"keep.go": "k", "new.go": "n", "a.go": "a", "z.go": "2", "y.go": "2"},
+			want:     &OutputDiff{Added: []string{"a.go", "new.go"}, Removed: []string{"old.go"}, Changed: []string{"y.go", "z.go"}, Unchanged: 1},
+		},
+		{
+			name:     "empty previous output",
+			previous: map[string]string{},
+			current:  map[string]string{"a.go": "a"},
+			want:     &OutputDiff{Added: []string{"a.go"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffOutputSections(tt.previous, tt.current); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffOutputSections() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShowDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "txt"},
+		{name: "md", cfg: Config{OutputFormat: OutputFormatMarkdown}},
+		{name: "md with a custom tree fence", cfg: Config{OutputFormat: OutputFormatMarkdown, TreeFenceLang: "tree"}},
+		{name: "xml", cfg: Config{OutputFormat: OutputFormatXML}},
+		{name: "json", cfg: Config{OutputFormat: OutputFormatJSON}},
+		{name: "jsonl", cfg: Config{OutputFormat: OutputFormatJSONL}},
+		{name: "header stats and languages", cfg: Config{HeaderStats: true, FenceLang: FenceLangAuto}},
+		{name: "gzip", cfg: Config{Gzip: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeSourceFiles(t, map[string]string{
+				"keep.go":   "package main\n",
+				"change.go": "package main\n\nconst v = 1\n",
+				"remove.go": "package main\n",
+			})
+			diffedDir := t.TempDir() // Kept across the runs with ShowDiff
+			run := func(showDiff bool) (string, *OutputDiff) {
+				dir := t.TempDir()
+				if showDiff {
+					dir = diffedDir
+				}
+				cfg := tt.cfg
+				cfg.SourcePath, cfg.IncludeTree, cfg.ShowDiff = root, true, showDiff
+				cfg.OutputFile = filepath.Join(dir, "ctx")
+				p, err := New(cfg)
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				if err := p.Process(); err != nil {
+					t.Fatalf("Process() error = %v", err)
+				}
+				return readOutputs(t, dir, cfg.Gzip), p.GetOutputDiff()
+			}
+
+			if _, diff := run(true); diff != nil {
+				t.Errorf("GetOutputDiff() of the first run = %+v, want nil", diff)
+			}
+			if err := os.WriteFile(filepath.Join(root, "change.go"), []byte("package main\n\nconst v = 2\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Remove(filepath.Join(root, "remove.go")); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(root, "add.go"), []byte("package main\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			output, diff := run(true)
+			want := &OutputDiff{Added: []string{"add.go"}, Removed: []string{"remove.go"}, Changed: []string{"change.go"}, Unchanged: 1}
+			if !reflect.DeepEqual(diff, want) {
+				t.Errorf("GetOutputDiff() = %+v, want %+v", diff, want)
+			}
+			if plain, _ := run(false); output != plain {
+				t.Errorf("output with ShowDiff =\n%s\nwant the output without it\n%s", output, plain)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errSelectionCancelled is returned when the user quits the interactive picker.
+var errSelectionCancelled = errors.New("processor: file selection cancelled")
+
+// filePicker lets the user select some of the candidates, reading from in and writing to out (see pickFiles).
+type filePicker func(candidates []includedFile, in io.Reader, out io.Writer) ([]includedFile, error)
+
+// pickFiles shows a numbered checklist of candidates on out and reads toggle commands from in
+// until the user confirms with an empty line. All candidates start selected.
+// Commands: numbers and ranges to toggle (e.g. "2,5-7"), "a" to select all, "n" to select none, "q" to quit.
+// The returned slice keeps the candidates' order.
+func pickFiles(candidates []includedFile, in io.Reader, out io.Writer) ([]includedFile, error) {
+	selected := make([]bool, len(candidates))
+	for i := range selected {
+		selected[i] = true
+	}
+
+	reader := bufio.NewReader(in)
+	for {
+		printChecklist(out, candidates, selected)
+		fmt.Fprint(out, "Toggle files (e.g. 2,5-7), a = all, n = none, q = quit, Enter = confirm: ")
+
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, fmt.Errorf("processor: failed to read selection: %w", readErr)
+		}
+
+		switch command := strings.ToLower(strings.TrimSpace(line)); command {
+		case "":
+			return selectedFiles(candidates, selected), nil
+		case "q":
+			return nil, errSelectionCancelled
+		case "a", "n":
+			for i := range selected {
+				selected[i] = command == "a"
+			}
+		default:
+			indexes, parseErr := parseSelection(command, len(candidates))
+			if parseErr != nil {
+				fmt.Fprintf(out, "Invalid selection: %v\n", parseErr)
+			}
+			for _, idx := range indexes {
+				selected[idx] = !selected[idx]
+			}
+		}
+
+		if readErr == io.EOF { // Input ended after a command: confirm the current selection
+			fmt.Fprintln(out)
+			return selectedFiles(candidates, selected), nil
+		}
+	}
+}
+
+func printChecklist(out io.Writer, candidates []includedFile, selected []bool) {
+	width := len(strconv.Itoa(len(candidates)))
+	for i, file := range candidates {
+		mark := " "
+		if selected[i] {
+			mark = "x"
+		}
+		fmt.Fprintf(out, "%*d [%s] %s\n", width, i+1, mark, filepath.ToSlash(file.relPath))
+	}
+}
+
+func selectedFiles(candidates []includedFile, selected []bool) []includedFile {
+	var files []includedFile
+	for i, file := range candidates {
+		if selected[i] {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// parseSelection parses 1-based numbers and ranges ("1,3-5") into 0-based indexes below count.
+func parseSelection(selection string, count int) ([]int, error) {
+	var indexes []int
+	for _, part := range strings.Split(selection, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to := part, part
+		if dash := strings.Index(part, "-"); dash >= 0 {
+			from, to = strings.TrimSpace(part[:dash]), strings.TrimSpace(part[dash+1:])
+		}
+		start, startErr := strconv.Atoi(from)
+		end, endErr := strconv.Atoi(to)
+		if startErr != nil || endErr != nil || start < 1 || end > count || start > end {
+			return nil, fmt.Errorf("'%s' is not a number or range between 1 and %d", part, count)
+		}
+		for n := start; n <= end; n++ {
+			indexes = append(indexes, n-1)
+		}
+	}
+	return indexes, nil
+}
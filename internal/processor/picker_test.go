@@ -0,0 +1,155 @@
+package processor
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPickFiles(t *testing.T) {
+	candidates := []includedFile{{relPath: "a.go"}, {relPath: "b.go"}, {relPath: "c.go"}, {relPath: "d.go"}}
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr error
+	}{
+		{name: "confirm keeps all", input: "\n", want: []string{"a.go", "b.go", "c.go", "d.go"}},
+		{name: "toggle one", input: "2\n\n", want: []string{"a.go", "c.go", "d.go"}},
+		{name: "toggle list and range", input: "1,3-4\n\n", want: []string{"b.go"}},
+		{name: "toggle twice", input: "2\n2\n\n", want: []string{"a.go", "b.go", "c.go", "d.go"}},
+		{name: "none then one", input: "n\n3\n\n", want: []string{"c.go"}},
+		{name: "none then all", input: "n\na\n\n", want: []string{"a.go", "b.go", "c.go", "d.go"}},
+		{name: "invalid selection is ignored", input: "9\nx\n\n", want: []string{"a.go", "b.go", "c.go", "d.go"}},
+		{name: "end of input confirms", input: "1", want: []string{"b.go", "c.go", "d.go"}},
+		{name: "quit", input: "q\n", wantErr: errSelectionCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picked, err := pickFiles(candidates, strings.NewReader(tt.input), io.Discard)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("pickFiles() error = %v, want %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, file := range picked {
+				got = append(got, file.relPath)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pickFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInteractiveSelection(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{"a.go": "package a\n", "b.go": "package b\n", "sub/c.go": "package sub\n"})
+	tests := []struct {
+		name       string
+		isTerminal bool
+		input      string
+		want       []string
+		wantTree   []string
+	}{
+		{name: "deselected files are excluded", isTerminal: true, input: "2\n\n", want: []string{"a.go", "sub/c.go"}, wantTree: []string{"a.go", "c.go"}},
+		{name: "deselected directory contents", isTerminal: true, input: "3\n\n", want: []string{"a.go", "b.go"}, wantTree: []string{"a.go", "b.go"}},
+		{name: "not a terminal keeps all", isTerminal: false, input: "2\n\n", want: []string{"a.go", "b.go", "sub/c.go"}, wantTree: []string{"a.go", "b.go", "c.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "out.txt")
+			p, err := New(Config{SourcePath: root, OutputFile: outputPath, Interactive: true, IncludeTree: true, Stdin: strings.NewReader(tt.input)})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			p.isTerminal = func(io.Reader) bool { return tt.isTerminal }
+			picked := false
+			p.pickFiles = func(candidates []includedFile, in io.Reader, out io.Writer) ([]includedFile, error) {
+				picked = true
+				return pickFiles(candidates, in, io.Discard)
+			}
+			if err := p.Process(); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if picked != tt.isTerminal {
+				t.Errorf("picker shown = %v, want %v", picked, tt.isTerminal)
+			}
+			content, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			output := string(content)
+			if got := sectionPaths(output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("file sections = %v, want %v", got, tt.want)
+			}
+			tree := output[:strings.Index(output, "```")]
+			for _, name := range []string{"a.go", "b.go", "c.go"} {
+				if inTree, wantInTree := strings.Contains(tree, name), slices.Contains(tt.wantTree, name); inTree != wantInTree {
+					t.Errorf("tree shows %s = %v, want %v:\n%s", name, inTree, wantInTree, tree)
+				}
+			}
+			if deselected, want := p.GetResult().SkippedByReason["deselected"], 3-len(tt.want); deselected != want {
+				t.Errorf("deselected count = %d, want %d", deselected, want)
+			}
+		})
+	}
+
+	t.Run("quit cancels", func(t *testing.T) {
+		p, err := New(Config{SourcePath: root, OutputFile: filepath.Join(t.TempDir(), "out.txt"), Interactive: true, Stdin: strings.NewReader("q\n")})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		p.isTerminal = func(io.Reader) bool { return true }
+		p.pickFiles = func(candidates []includedFile, in io.Reader, _ io.Writer) ([]includedFile, error) {
+			return pickFiles(candidates, in, io.Discard)
+		}
+		if err := p.Process(); !errors.Is(err, errSelectionCancelled) {
+			t.Errorf("Process() error = %v, want %v", err, errSelectionCancelled)
+		}
+	})
+}
+
+func TestStdinIsTerminal(t *testing.T) {
+	if stdinIsTerminal(strings.NewReader("")) {
+		t.Error("stdinIsTerminal(strings.Reader) = true, want false")
+	}
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if stdinIsTerminal(f) {
+		t.Error("stdinIsTerminal(regular file) = true, want false")
+	}
+}
+
+func TestCollectCandidates(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"b.go":         "package b\n",
+		"a.go":         "package a\n",
+		"z/y.go":       "package z\n",
+		"image.png":    "png",
+		".gitignore":   "ignored/\n",
+		"ignored/x.go": "package ignored\n",
+		"A/upper.go":   "package upper\n",
+	})
+	p := preparedProcessor(t, Config{SourcePath: root, DefaultMediaExts: []string{".png"}})
+	candidates, err := p.collectCandidates(nil)
+	if err != nil {
+		t.Fatalf("collectCandidates() error = %v", err)
+	}
+	var got []string
+	for _, file := range candidates {
+		got = append(got, filepath.ToSlash(file.relPath))
+		if file.absPath != filepath.Join(p.basePath, file.relPath) || file.info == nil {
+			t.Errorf("candidate %s has absPath %q and info %v", file.relPath, file.absPath, file.info)
+		}
+	}
+	if want := []string{".gitignore", "A/upper.go", "a.go", "b.go", "z/y.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collectCandidates() = %v, want %v", got, want)
+	}
+}
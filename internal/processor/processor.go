@@ -1,31 +1,111 @@
 package processor
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/alexferrari88/code2context/internal/archiveutils"
+	"github.com/alexferrari88/code2context/internal/collector"
 	"github.com/alexferrari88/code2context/internal/filefilter"
 	"github.com/alexferrari88/code2context/internal/gitutils"
-	gitignore "github.com/sabhiram/go-gitignore"
+	"github.com/alexferrari88/code2context/internal/utils"
 )
 
+// emptyContentHash is the hex SHA-256 of no content; a file that could not be read hashes to it.
+var emptyContentHash = hex.EncodeToString(sha256.New().Sum(nil))
+
 type Config struct {
 	SourcePath                     string
+	FromStdin                      bool      // Read the files from stdin, framed by "=== path ===" lines, instead of SourcePath
+	Stdin                          io.Reader // Read by FromStdin and FilesFrom "-" (default: os.Stdin)
+	Stderr                         io.Writer // Receives progress and the Interactive checklist (default: os.Stderr)
 	GitRef                         string
+	UseCloneCache                  bool   // Keep clones of remote repositories in a persistent cache between runs
+	DiffBase                       string // If set, only files changed between this ref and HEAD are included
+	FilesFrom                      string // If set, only the files listed in this manifest ("-" for stdin) are included
+	OnlyTracked                    bool   // Only include files tracked by git (instead of applying .gitignore files)
+	IncludeGitignored              bool   // Don't apply .gitignore (or any other ignore) files
+	RepoRoot                       bool   // Process the root of the git repository containing a local SourcePath instead
+	RepoRootFallback               bool   // With RepoRoot, process SourcePath as given if it is not inside a git repository
+	Force                          bool   // Process a local source even if it is the filesystem root or the home directory (see isDangerousRoot)
+	UseAncestorGitignore           bool   // Also apply .gitignore files above a local source, up to its git work tree root
+	RespectExportIgnore            bool   // Exclude paths with the export-ignore attribute in the .gitattributes of the work tree root
 	OutputFile                     string
+	OutputDir                      string // Directory for the default-named output file (ignored if OutputFile is set)
+	CreateOutputDir                bool   // Create OutputDir if it does not exist
+	OutputWithin                   string // If set, the output file must resolve (following symlinks) to a path inside this directory
+	GitignoreOutput                bool   // Add the output file to the nearest .gitignore if it is written inside the source
+	NoEmptyOutput                  bool   // Don't write any output if no files are included (see ErrNoFiles)
+	ShowDiff                       bool   // Compare the files written with those of the output previously at the same path (see GetOutputDiff)
+	Gzip                           bool   // Compress the output file (or each part, but not a stream) with gzip; a default name gets ".gz"
 	IncludeTree                    bool
+	FullTree                       bool // Show excluded entries in the tree too, annotated with " (excluded)"
+	IncludeTOC                     bool // List the included files, numbered in output order, after the tree
+	WithGitInfo                    bool // Write the repository URL, commit, branch, and dirty state before the tree
 	SkipAuxFiles                   bool
+	SkipEmptyFiles                 bool
+	SkipHidden                     bool
+	CaseInsensitive                bool // Match directory names, globs, and file names case-insensitively (see utils.CaseInsensitiveOS)
+	FollowSymlinks                 bool
+	ShowSymlinkDirs                bool     // Show symbolic links to directories in the tree as leaves annotated with " -> target" (they are not followed)
+	DropOutliers                   bool     // Exclude files whose size is an outlier among the candidates (see utils.OutlierThreshold)
+	MaxDepth                       int      // Deepest level of files and dirs included (1 = top level only); 0 means unlimited
+	HeaderStats                    bool     // Append line count and size to each file header
+	Annotate                       bool     // Write a comment line describing each file (language, lines, test, generated) before its section
+	ExtraIgnoreFiles               []string // Additional gitignore-syntax files loaded per directory (e.g. ".dockerignore")
+	ShowProgress                   bool     // Report walk and clone progress on Stderr
+	SortOrder                      SortOrder
+	Prepend                        string // Text (or path to a text file) written before the tree
+	Append                         string // Text (or path to a text file) written after the last file section
+	OutputFormat                   OutputFormat
+	PathStyle                      PathStyle
+	RelativeTo                     string             // If set, paths are shown relative to this directory (which must contain the source) instead of the source
+	FenceLang                      FenceLang          // Whether file fences name the language; empty means FenceLangNever
+	TreeSort                       TreeSort           // Order of the tree's entries at every level; empty means TreeSortDirsFirst
+	RootLabel                      string             // If set, the tree's top line, and the prefix of PathStyleRepo paths, instead of the folder/repo name
+	TreeFenceLang                  string             // Info string of the tree's fence in OutputFormatMarkdown (e.g. "tree"); empty means "text"
+	SectionSeparator               *string            // Written between file sections in the fenced formats (see sectionSeparator); nil means a blank line
+	Interactive                    bool               // Let the user deselect candidate files before writing
+	SplitSize                      int64              // If > 0, split the output into numbered part files of at most this many bytes
+	Reproducible                   bool               // Trim trailing whitespace of content lines and end the output with a single newline
+	ValidUTF8                      bool               // Replace invalid UTF-8 sequences in file contents with U+FFFD
+	CollapseBlankLines             bool               // Write runs of consecutive blank lines as a single blank line
+	StripComments                  bool               // Remove comments from files in supported languages (Go, JS/TS, Python, C/C++)
+	RenderNotebooks                bool               // Write Jupyter notebooks (.ipynb) as their code cells instead of raw JSON
+	NotebookMarkdown               bool               // With RenderNotebooks, also write markdown cells (as comments)
+	Dedupe                         bool               // Replace the content of files identical to an earlier one with a reference to it
+	OmitContentExts                []string           // Files ending in one of these extensions (e.g. ".min.js") are listed without their content
+	CountTokens                    bool               // Count the tokens of each file section; see GetTokenCounts
+	MaxTokens                      int                // If positive, files are included in order until their sections would exceed this many tokens
+	CountOnly                      bool               // Only collect the included files and compute their CountStats (see GetCountStats); nothing is written
+	TokenCounter                   utils.TokenCounter // Used by CountTokens and CountOnly; nil falls back to utils.HeuristicTokenCounter
 	UserExcludeDirs                []string
 	UserExcludeExts                []string
+	UserIncludeExts                []string
 	UserExcludeGlobs               []string
+	UserExcludeRegexes             []string
+	UserExcludeDirRegexes          []string
+	ExcludeContentRegex            string // Exclude files whose first 64 KiB match this regular expression
 	MaxFileSize                    int64
+	MinFileSize                    int64
+	ModifiedSince                  time.Time // If non-zero, files last modified before this time are excluded
+	HeadLines                      int       // If > 0, files above MaxFileSize are included with their first HeadLines lines instead of excluded
+	TailLines                      int       // If > 0, files above MaxFileSize are included with their last TailLines lines (after the head)
+	MaxLineLength                  int       // If > 0, content lines longer than this many characters are cut, noting how many were cut
 	DefaultExcludeDirs             []string
 	DefaultMediaExts               []string
 	DefaultArchiveExts             []string
@@ -34,23 +114,168 @@ type Config struct {
 	DefaultMiscellaneousFileNames  []string
 	DefaultMiscellaneousExtensions []string
 	DefaultAuxExts                 []string
+	ExcludeVendored                bool
+	ExcludeGenerated               bool     // Skip files with a generated-code header in their first 40 lines
+	ExcludeMinified                bool     // Skip minified files: named "*.min.*", or with long lines on average
+	OwnVendorPrefixes              []string // Go module path prefixes whose vendored packages are included (see filefilter.FilterConfig)
+	DefaultVendoredDirs            []string
+	DefaultVendoredFilePatterns    []string
+
+	Watch         bool          // Regenerate the output whenever files under the (local) source change, until interrupted
+	WatchDebounce time.Duration // Quiet period after the last change before regenerating (defaults to defaultWatchDebounce)
+
+	// Timeout, if positive, stops each run with ErrTimeout once it takes longer: cloning, walking, and
+	// writing are canceled, and no output file is left behind (output streamed so far is not taken back).
+	Timeout time.Duration
+
+	// Logger receives the processor's logs (including those of filtering, cloning, and extraction).
+	// If nil, nothing is logged, so embedding the processor neither writes to stderr nor relies on slog.Default.
+	Logger *slog.Logger
+
+	// Transformers rewrite the content of every included file, in order, after notebook rendering and
+	// comment stripping (see Transformer). PrettyJSON is a built-in one.
+	Transformers []Transformer
+
+	// CustomExclude is passed through to the file filter; see filefilter.FilterConfig.CustomExclude.
+	CustomExclude func(absPath string, d fs.DirEntry) (exclude bool, skipDir bool, handled bool)
+
+	// Git operations of the processor, defaulting to the gitutils functions of the same name; set them
+	// to run git differently (e.g. without git, in tests).
+
+	// CloneRepo clones a remote source into a temporary directory.
+	CloneRepo func(ctx context.Context, repoURL, ref string, progress io.Writer, logger *slog.Logger) (string, string, error)
+	// CloneRepoCached clones or updates a remote source in the clone cache (see UseCloneCache).
+	CloneRepoCached func(ctx context.Context, repoURL, ref string, progress io.Writer, logger *slog.Logger) (string, string, error)
+	// ChangedFiles lists the files changed since DiffBase.
+	ChangedFiles func(repoRoot, base string, logger *slog.Logger) ([]string, error)
+	// TrackedFiles lists the files tracked by git (see OnlyTracked).
+	TrackedFiles func(repoRoot string, logger *slog.Logger) ([]string, error)
+	// RepoInfo reads the commit, branch, and dirty state (see WithGitInfo).
+	RepoInfo func(repoRoot string, logger *slog.Logger) (gitutils.Info, error)
 }
 
 type Processor struct {
 	config          Config
-	filter          *filefilter.FileFilter          // To be initialized after output path is known
-	basePath        string                          // Absolute path to the root directory to process
-	repoName        string                          // Name of the repo (from URL or local folder name)
-	isTempRepo      bool                            // True if basePath is a temporary cloned repository
-	tempRepoDir     string                          // The top-level temporary directory created for a clone, to be cleaned up.
-	finalOutputFile string                          // Absolute path of the final output file
-	gitIgnoreCache  map[string]*gitignore.GitIgnore // Cache for compiled .gitignore files
+	filter          *filefilter.FileFilter             // To be initialized after output path is known
+	basePath        string                             // Absolute path to the root directory to process
+	repoName        string                             // Name of the repo (from URL or local folder name)
+	relRoot         string                             // Absolute RelativeTo directory, or empty if paths are relative to basePath
+	relPrefix       string                             // Path of basePath relative to relRoot; empty if they are the same
+	isTempRepo      bool                               // True if basePath is a temporary cloned repository or extracted archive
+	tempRepoDir     string                             // The top-level temporary directory created for a clone or extraction, to be cleaned up.
+	finalOutputFile string                             // Absolute path of the final output file
+	stream          io.Writer                          // If set, the output is written here instead of to a file (see NewReader)
+	ctx             context.Context                    // Of the current run; done when Timeout expires
+	outputFiles     []string                           // Absolute paths of the files actually written (several parts when splitting)
+	gitIgnoreCache  map[string]*filefilter.IgnoreRules // Cache for compiled ignore files, keyed by directory
+	progress        *utils.Progress                    // Nil unless ShowProgress is set
+	logger          *slog.Logger                       // Config.Logger, or a logger discarding all records
+	tokenCounts     []FileTokenCount                   // Per-file token counts, in output order (only with CountTokens)
+	languageStats   []LanguageStats                    // Per-language totals of the written files
+	result          ProcessResult                      // Summary of the last run
+	countStats      CountStats                         // Computed instead of the output with CountOnly
+	ancestorIgnores []*filefilter.IgnoreRules          // Compiled .gitignore files above basePath, from the work tree root down
+	walkedDirs      []string                           // Absolute paths of the directories walked for files (watched in Watch mode)
+	outputDiff      *OutputDiff                        // With ShowDiff, the comparison with the previous output, if there was one
+	isTerminal      func(io.Reader) bool               // Reports whether Stdin is a terminal for Interactive (default: stdinIsTerminal)
+	pickFiles       filePicker                         // Shows the Interactive checklist (default: pickFiles)
+}
+
+// ProcessResult summarizes a Process run: what was written, and why the other walked entries were left out.
+type ProcessResult struct {
+	IncludedFiles int
+	// SkippedByReason counts the excluded entries by filefilter.Reason, plus "deselected" for files
+	// deselected interactively, "outlier" for files dropped by DropOutliers, and "token_budget" for files
+	// left out by MaxTokens. An excluded directory counts once: its contents are not visited.
+	SkippedByReason map[string]int
+	TotalBytes      int64  // Size of the output (of all parts, if split)
+	OutputPath      string // As returned by GetFinalOutputFile
+}
+
+// reasonOutlier is the SkippedByReason key of files dropped by DropOutliers.
+const reasonOutlier = "outlier"
+
+// LanguageStats is the number of files and their total size for one language.
+type LanguageStats struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// computeLanguageStats groups files by the language of their extension, sorted by bytes (descending), then name.
+func computeLanguageStats(files []includedFile) []LanguageStats {
+	byLanguage := make(map[string]*LanguageStats)
+	var stats []*LanguageStats
+	for _, file := range files {
+		language := collector.LanguageOf(file.relPath)
+		if language == "" {
+			language = "other"
+		}
+		entry, ok := byLanguage[language]
+		if !ok {
+			entry = &LanguageStats{Language: language}
+			byLanguage[language] = entry
+			stats = append(stats, entry)
+		}
+		entry.Files++
+		entry.Bytes += file.info.Size()
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Bytes != stats[j].Bytes {
+			return stats[i].Bytes > stats[j].Bytes
+		}
+		return stats[i].Language < stats[j].Language
+	})
+	result := make([]LanguageStats, 0, len(stats))
+	for _, entry := range stats {
+		result = append(result, *entry)
+	}
+	return result
+}
+
+// FileTokenCount is the number of tokens of one file section (header, content, and footer) in the output.
+type FileTokenCount struct {
+	Path   string // Relative, slash-separated path of the file
+	Tokens int
 }
 
 func New(cfg Config) (*Processor, error) {
 	p := &Processor{
 		config:         cfg,
-		gitIgnoreCache: make(map[string]*gitignore.GitIgnore),
+		gitIgnoreCache: make(map[string]*filefilter.IgnoreRules),
+		logger:         cfg.Logger,
+		isTerminal:     stdinIsTerminal,
+		pickFiles:      pickFiles,
+	}
+	if p.logger == nil {
+		p.logger = slog.New(slog.DiscardHandler) // Embedders opt in to logs by passing their logger
+	}
+	if p.config.Stdin == nil {
+		p.config.Stdin = os.Stdin
+	}
+	if p.config.Stderr == nil {
+		p.config.Stderr = os.Stderr
+	}
+	if p.config.CloneRepo == nil {
+		p.config.CloneRepo = gitutils.CloneRepo
+	}
+	if p.config.CloneRepoCached == nil {
+		p.config.CloneRepoCached = gitutils.CloneRepoCached
+	}
+	if p.config.ChangedFiles == nil {
+		p.config.ChangedFiles = gitutils.ChangedFiles
+	}
+	if p.config.TrackedFiles == nil {
+		p.config.TrackedFiles = gitutils.TrackedFiles
+	}
+	if p.config.RepoInfo == nil {
+		p.config.RepoInfo = gitutils.RepoInfo
+	}
+	if (cfg.CountTokens || cfg.CountOnly || cfg.MaxTokens > 0) && cfg.TokenCounter == nil {
+		p.config.TokenCounter = utils.HeuristicTokenCounter{}
+	}
+	if cfg.ShowProgress {
+		p.progress = utils.NewProgress(p.config.Stderr, writerIsTerminal(p.config.Stderr), utils.DefaultProgressInterval)
 	}
 	return p, nil
 }
@@ -59,12 +284,85 @@ func (p *Processor) GetFinalOutputFile() string {
 	return p.finalOutputFile
 }
 
+// GetResult returns the summary of the last Process run.
+func (p *Processor) GetResult() ProcessResult {
+	return p.result
+}
+
+// GetLanguageStats returns the number of files and bytes per language written by the last
+// Process run, sorted by bytes (descending). Files with an unknown extension count as "other".
+func (p *Processor) GetLanguageStats() []LanguageStats {
+	return p.languageStats
+}
+
+// GetTokenCounts returns the per-file token counts of the last Process run, in output order,
+// or nil unless CountTokens is set. The counts sum to the total reported by GetTotalTokens.
+func (p *Processor) GetTokenCounts() []FileTokenCount {
+	return p.tokenCounts
+}
+
+// GetTotalTokens returns the sum of the per-file token counts.
+func (p *Processor) GetTotalTokens() int {
+	total := 0
+	for _, count := range p.tokenCounts {
+		total += count.Tokens
+	}
+	return total
+}
+
+// GetCountStats returns the summary computed by the last Process run with CountOnly set.
+func (p *Processor) GetCountStats() CountStats {
+	return p.countStats
+}
+
+// GetOutputFiles returns the files written by Process: the final output file,
+// or the numbered part files if SplitSize is set.
+func (p *Processor) GetOutputFiles() []string {
+	return p.outputFiles
+}
+
+// GetOutputDiff returns how the files written by the last Process run differ from those of the
+// output previously at the same path, or nil if there was none (or ShowDiff is not set).
+func (p *Processor) GetOutputDiff() *OutputDiff {
+	return p.outputDiff
+}
+
 // setupInitialPaths determines basePath, repoName, and tempRepoDir if applicable.
+// Git URLs are cloned and .zip/.tar/.tar.gz archives are extracted into a temporary directory.
 // It does NOT initialize the file filter.
 func (p *Processor) setupInitialPaths() error {
+	if p.config.FromStdin {
+		// The piped files are placed below the working directory, which is not read.
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("processor: failed to get current working directory: %w", err)
+		}
+		p.basePath = cwd
+		p.repoName = "stdin"
+		p.isTempRepo = false
+		return nil
+	}
 	if gitutils.IsGitURL(p.config.SourcePath) {
-		slog.Info("Input is a Git URL, attempting to clone.", "url", p.config.SourcePath)
-		clonedRepoPath, repoName, err := gitutils.CloneRepo(p.config.SourcePath, p.config.GitRef)
+		p.logger.Info("Input is a Git URL, attempting to clone.", "url", p.config.SourcePath)
+		if err := gitutils.EnsureGitAvailable(); err != nil {
+			return fmt.Errorf("processor: cannot clone repository: %w", err)
+		}
+		var cloneProgress io.Writer
+		if p.config.ShowProgress {
+			cloneProgress = p.config.Stderr
+		}
+		if p.config.UseCloneCache {
+			cachedRepoPath, repoName, err := p.config.CloneRepoCached(p.ctx, p.config.SourcePath, p.config.GitRef, cloneProgress, p.logger)
+			if err != nil {
+				return fmt.Errorf("processor: failed to clone repository into cache: %w", err)
+			}
+			p.basePath = cachedRepoPath
+			p.repoName = repoName
+			p.isTempRepo = false // Cached clones are kept for later runs, never cleaned up
+			p.logger.Info("Repository available from cache", "path", p.basePath)
+			return nil
+		}
+		clonedRepoPath, repoName, err := p.config.CloneRepo(p.ctx, p.config.SourcePath, p.config.GitRef, cloneProgress, p.logger)
 		if err != nil {
 			return fmt.Errorf("processor: failed to clone repository: %w", err)
 		}
@@ -72,32 +370,238 @@ func (p *Processor) setupInitialPaths() error {
 		p.tempRepoDir = filepath.Dir(clonedRepoPath) // This is .../parent_temp_dir
 		p.repoName = repoName
 		p.isTempRepo = true
-		slog.Info("Repository cloned", "path", p.basePath)
+		p.logger.Info("Repository cloned", "path", p.basePath)
+	} else if archiveutils.IsArchive(p.config.SourcePath) {
+		p.logger.Info("Input is an archive, extracting.", "archive", p.config.SourcePath)
+		extractedPath, name, tempDir, err := archiveutils.ExtractArchive(p.config.SourcePath, p.logger)
+		if err != nil {
+			return fmt.Errorf("processor: failed to extract archive: %w", err)
+		}
+		p.basePath = extractedPath
+		p.tempRepoDir = tempDir
+		p.repoName = name
+		p.isTempRepo = true // Extracted like a clone, so it is cleaned up the same way
+		p.logger.Info("Archive extracted", "path", p.basePath)
 	} else {
 		absPath, err := filepath.Abs(p.config.SourcePath)
 		if err != nil {
 			return fmt.Errorf("processor: failed to get absolute path for '%s': %w", p.config.SourcePath, err)
 		}
 		info, err := os.Stat(absPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("processor: %w: '%s'", ErrSourceNotFound, absPath)
+		}
 		if err != nil {
 			return fmt.Errorf("processor: failed to stat source path '%s': %w", absPath, err)
 		}
 		if !info.IsDir() {
 			return fmt.Errorf("processor: source path '%s' is not a directory", absPath)
 		}
+		if p.config.RepoRoot {
+			if repoRoot, found := gitutils.FindRepoRoot(absPath); found {
+				if repoRoot != absPath {
+					p.logger.Info("Processing the enclosing git repository root", "path", repoRoot, "source", absPath)
+				}
+				absPath = repoRoot
+			} else if p.config.RepoRootFallback {
+				p.logger.Warn("Processor: Source path is not inside a git repository, processing it as given", "path", absPath)
+			} else {
+				return fmt.Errorf("processor: source path '%s' is not inside a git repository", absPath)
+			}
+		}
+		if isDangerousRoot(absPath) {
+			if !p.config.Force {
+				return fmt.Errorf("processor: refusing to process '%s': it is the filesystem root or your home directory, an enormous tree to walk (use --force to process it anyway)", absPath)
+			}
+			p.logger.Warn("Processor: Processing the filesystem root or the home directory", "path", absPath)
+		}
 		p.basePath = absPath
 		p.repoName = filepath.Base(absPath)
 		p.isTempRepo = false
-		slog.Info("Processing local path", "path", p.basePath)
+		p.logger.Info("Processing local path", "path", p.basePath)
+		if p.config.UseAncestorGitignore && !p.config.OnlyTracked && !p.config.IncludeGitignored {
+			p.ancestorIgnores = ancestorGitIgnores(p.basePath, p.logger)
+		}
 	}
 	return nil
 }
 
+// isDangerousRoot reports whether the directory at absPath is the filesystem root (or a volume root)
+// or the user's home directory, following symbolic links.
+func isDangerousRoot(absPath string) bool {
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		absPath = resolved
+	}
+	if filepath.Dir(absPath) == absPath {
+		return true
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false
+	}
+	homeInfo, err := os.Stat(home)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(absPath)
+	return err == nil && os.SameFile(info, homeInfo)
+}
+
+// ancestorGitIgnores compiles the .gitignore files of the directories above basePath up to the root of
+// the enclosing git work tree, ordered from the root down. It returns nil if basePath is not below a work tree root.
+func ancestorGitIgnores(basePath string, logger *slog.Logger) []*filefilter.IgnoreRules {
+	repoRoot, ok := gitutils.FindRepoRoot(basePath)
+	if !ok {
+		return nil
+	}
+	var ancestors []string // Directories from the parent of basePath up to the work tree root, nearest first
+	for dir := basePath; dir != repoRoot; {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break // Not below repoRoot (e.g. a differently spelled path); stop at the filesystem root
+		}
+		dir = parent
+		ancestors = append(ancestors, dir)
+	}
+
+	var stack []*filefilter.IgnoreRules
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		ignorePath := filepath.Join(ancestors[i], ".gitignore")
+		if _, err := os.Stat(ignorePath); err != nil {
+			continue
+		}
+		rules, err := filefilter.CompileIgnoreFile(ancestors[i], ignorePath)
+		if err != nil {
+			logger.Warn("Processor: Failed to compile ancestor ignore file, it will be ineffective", "path", ignorePath, "error", err)
+			continue
+		}
+		logger.Debug("Processor: Loaded ancestor ignore file", "path", ignorePath)
+		stack = append(stack, rules)
+	}
+	return stack
+}
+
+// ensureOutputDir checks that OutputDir is a directory, creating it first if it is missing and CreateOutputDir is set.
+func (p *Processor) ensureOutputDir() error {
+	dir := p.config.OutputDir
+	info, err := os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) && p.config.CreateOutputDir {
+		p.logger.Debug("Processor: Creating output directory", "path", dir)
+		if mkdirErr := os.MkdirAll(dir, 0o755); mkdirErr != nil {
+			return fmt.Errorf("processor: %w: failed to create output directory '%s': %v", ErrOutputWrite, dir, mkdirErr)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("processor: %w: output directory '%s' is not accessible (use --mkdir to create it): %v", ErrOutputWrite, dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("processor: %w: output directory '%s' is not a directory", ErrOutputWrite, dir)
+	}
+	return nil
+}
+
+// checkOutputWithin rejects an output path that is not inside OutputWithin, if set. Both are compared with
+// their symbolic links resolved (as far as they exist), so a link inside the directory pointing outside of
+// it does not pass, e.g. "-o link/out.txt" with link -> /etc.
+func (p *Processor) checkOutputWithin(absOutputPath string) error {
+	if p.config.OutputWithin == "" {
+		return nil
+	}
+	absWithin, err := filepath.Abs(p.config.OutputWithin)
+	if err != nil {
+		return fmt.Errorf("processor: failed to get absolute path for '%s': %w", p.config.OutputWithin, err)
+	}
+	resolvedWithin, err := utils.ResolveExistingPath(absWithin)
+	if err != nil {
+		return fmt.Errorf("processor: failed to resolve '%s': %w", absWithin, err)
+	}
+	resolvedOutput, err := utils.ResolveExistingPath(absOutputPath)
+	if err != nil {
+		return fmt.Errorf("processor: failed to resolve output file '%s': %w", absOutputPath, err)
+	}
+	relPath, err := filepath.Rel(resolvedWithin, resolvedOutput)
+	if err != nil || relPath == "." || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("processor: %w: output file '%s' is not inside '%s', where output is allowed", ErrOutputWrite, absOutputPath, absWithin)
+	}
+	return nil
+}
+
+// gitignoreOutput adds the output file (or, when splitting, a pattern matching its parts) to the nearest
+// .gitignore at or above its directory, up to basePath, creating one next to the output if there is none.
+// Nothing is added if the output is outside basePath or the .gitignore already has the entry.
+// Failures only log a warning, as the output has been written.
+func (p *Processor) gitignoreOutput() {
+	outputDir := filepath.Dir(p.finalOutputFile)
+	if rel, err := filepath.Rel(p.basePath, outputDir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		p.logger.Debug("Processor: Output is outside the source, not adding it to a .gitignore", "file", p.finalOutputFile)
+		return
+	}
+
+	ignoreDir := outputDir
+	for dir := outputDir; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".gitignore")); err == nil {
+			ignoreDir = dir
+			break
+		}
+		if dir == p.basePath || filepath.Dir(dir) == dir {
+			break // None found: create one next to the output
+		}
+	}
+
+	relOutput, err := filepath.Rel(ignoreDir, p.finalOutputFile)
+	if err != nil {
+		p.logger.Warn("Processor: Could not add the output to a .gitignore", "file", p.finalOutputFile, "error", err)
+		return
+	}
+	entry := "/" + filepath.ToSlash(relOutput) // Anchored, so a same-named file elsewhere stays visible
+	if p.config.SplitSize > 0 {
+		ext := filepath.Ext(entry)
+		entry = strings.TrimSuffix(entry, ext) + ".part*" + ext
+	}
+	ignorePath := filepath.Join(ignoreDir, ".gitignore")
+	added, err := appendGitignoreEntry(ignorePath, entry)
+	if err != nil {
+		p.logger.Warn("Processor: Could not add the output to a .gitignore", "file", p.finalOutputFile, "error", err)
+	} else if added {
+		p.logger.Info("Added the output to .gitignore", "gitignore", ignorePath, "entry", entry)
+	}
+}
+
+// appendGitignoreEntry appends entry as a line to the .gitignore at ignorePath (creating it if needed),
+// unless the file already has that line. It reports whether the entry was added.
+func appendGitignoreEntry(ignorePath, entry string) (bool, error) {
+	existing, err := os.ReadFile(ignorePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	for _, line := range strings.Split(string(existing), "\n") {
+		if strings.TrimSpace(line) == entry {
+			return false, nil
+		}
+	}
+	addition := entry + "\n"
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		addition = "\n" + addition
+	}
+	f, err := os.OpenFile(ignorePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, err
+	}
+	if _, err := f.WriteString(addition); err != nil {
+		f.Close()
+		return false, err
+	}
+	return true, f.Close()
+}
+
 // determineOutputFileAndInitFilter determines the final output file path and then initializes the file filter,
 // passing the output file path to it for self-exclusion.
 func (p *Processor) determineOutputFileAndInitFilter() error {
 	var determinedPath string
-	if p.config.OutputFile != "" {
+	if p.stream != nil {
+		p.logger.Info("Output will be streamed")
+	} else if p.config.OutputFile != "" {
 		determinedPath = p.config.OutputFile
 	} else {
 		name := p.repoName
@@ -109,23 +613,100 @@ func (p *Processor) determineOutputFileAndInitFilter() error {
 			}
 			name = filepath.Base(cwd)
 		}
-		determinedPath = name + ".txt"
+		determinedPath = name + p.config.OutputFormat.FileExtension()
+		if p.config.Gzip {
+			determinedPath += ".gz"
+		}
+		if p.config.OutputDir != "" {
+			determinedPath = filepath.Join(p.config.OutputDir, determinedPath)
+		}
+	}
+
+	if determinedPath != "" {
+		absOutputFilePath, err := filepath.Abs(determinedPath)
+		if err != nil {
+			return fmt.Errorf("processor: failed to get absolute path for output file '%s': %w", determinedPath, err)
+		}
+		if err := p.checkOutputWithin(absOutputFilePath); err != nil {
+			return err
+		}
+		if p.config.OutputFile == "" && p.config.OutputDir != "" {
+			// Only after the check, so that no directory is created outside OutputWithin
+			if err := p.ensureOutputDir(); err != nil {
+				return err
+			}
+		}
+		p.finalOutputFile = absOutputFilePath // Store the final absolute output path
+		p.logger.Info("Output will be written to", "file", p.finalOutputFile)
+	}
+	if p.config.FromStdin {
+		return nil // The piped files are written as given, so no filter is needed
+	}
+
+	var restrictToPaths []string
+	if p.config.DiffBase != "" {
+		changedFiles, err := p.config.ChangedFiles(p.basePath, p.config.DiffBase, p.logger)
+		if err != nil {
+			return fmt.Errorf("processor: failed to determine changed files: %w", err)
+		}
+		p.logger.Info("Restricting output to changed files", "diff_base", p.config.DiffBase, "count", len(changedFiles))
+		restrictToPaths = append([]string{}, changedFiles...) // Non-nil even if nothing changed
+	}
+	if p.config.OnlyTracked {
+		trackedFiles, err := p.config.TrackedFiles(p.basePath, p.logger)
+		if err != nil {
+			return fmt.Errorf("processor: failed to determine tracked files (--only-tracked requires a git repository): %w", err)
+		}
+		p.logger.Info("Restricting output to files tracked by git", "count", len(trackedFiles))
+		if restrictToPaths != nil {
+			restrictToPaths = intersectPaths(restrictToPaths, trackedFiles)
+		} else {
+			restrictToPaths = trackedFiles
+		}
+	}
+	if p.config.FilesFrom != "" {
+		listedFiles, err := p.readFileList(p.config.FilesFrom)
+		if err != nil {
+			return err
+		}
+		p.logger.Info("Restricting output to listed files", "files_from", p.config.FilesFrom, "count", len(listedFiles))
+		if restrictToPaths != nil {
+			restrictToPaths = intersectPaths(restrictToPaths, listedFiles)
+		} else {
+			restrictToPaths = listedFiles
+		}
 	}
 
-	absOutputFilePath, err := filepath.Abs(determinedPath)
+	includeGlobs, err := readContextInclude(p.basePath, p.logger)
 	if err != nil {
-		return fmt.Errorf("processor: failed to get absolute path for output file '%s': %w", determinedPath, err)
+		return err
+	}
+	var exportIgnore *filefilter.IgnoreRules
+	if p.config.RespectExportIgnore {
+		if exportIgnore, err = p.exportIgnoreRules(); err != nil {
+			return err
+		}
 	}
-	p.finalOutputFile = absOutputFilePath // Store the final absolute output path
-	slog.Info("Output will be written to", "file", p.finalOutputFile)
 
 	// Now initialize FileFilter with the known output file path
 	ffConfig := filefilter.FilterConfig{
 		MaxFileSize:                    p.config.MaxFileSize,
+		KeepLargeFiles:                 p.config.HeadLines > 0 || p.config.TailLines > 0,
+		MinFileSize:                    p.config.MinFileSize,
+		ModifiedSince:                  p.config.ModifiedSince,
 		UserExcludeDirs:                p.config.UserExcludeDirs,
 		UserExcludeExts:                p.config.UserExcludeExts,
+		UserIncludeExts:                p.config.UserIncludeExts,
+		UserIncludeGlobs:               includeGlobs,
 		UserExcludeGlobs:               p.config.UserExcludeGlobs,
+		UserExcludeRegexes:             p.config.UserExcludeRegexes,
+		UserExcludeDirRegexes:          p.config.UserExcludeDirRegexes,
+		ExcludeContentRegex:            p.config.ExcludeContentRegex,
 		SkipAuxFiles:                   p.config.SkipAuxFiles,
+		SkipEmptyFiles:                 p.config.SkipEmptyFiles,
+		SkipHidden:                     p.config.SkipHidden,
+		CaseInsensitive:                p.config.CaseInsensitive,
+		RestrictToPaths:                restrictToPaths,
 		DefaultExcludeDirs:             p.config.DefaultExcludeDirs,
 		DefaultMediaExts:               p.config.DefaultMediaExts,
 		DefaultArchiveExts:             p.config.DefaultArchiveExts,
@@ -134,7 +715,18 @@ func (p *Processor) determineOutputFileAndInitFilter() error {
 		DefaultMiscellaneousFileNames:  p.config.DefaultMiscellaneousFileNames,
 		DefaultMiscellaneousExtensions: p.config.DefaultMiscellaneousExtensions,
 		DefaultAuxExts:                 p.config.DefaultAuxExts,
+		ExcludeVendored:                p.config.ExcludeVendored,
+		ExcludeGenerated:               p.config.ExcludeGenerated,
+		ExcludeMinified:                p.config.ExcludeMinified,
+		OwnVendorPrefixes:              p.config.OwnVendorPrefixes,
+		IgnoreGitignore:                p.config.IncludeGitignored,
+		ExportIgnore:                   exportIgnore,
+		VendoredDirs:                   p.config.DefaultVendoredDirs,
+		VendoredFilePatterns:           p.config.DefaultVendoredFilePatterns,
 		FinalOutputFilePath:            p.finalOutputFile, // Crucial: pass the output file path for self-exclusion
+		ExcludeOutputParts:             p.config.SplitSize > 0,
+		CustomExclude:                  p.config.CustomExclude,
+		Logger:                         p.logger,
 	}
 	p.filter, err = filefilter.NewFileFilter(p.basePath, ffConfig) // Pass basePath for relative path calculations
 	if err != nil {
@@ -143,275 +735,1214 @@ func (p *Processor) determineOutputFileAndInitFilter() error {
 	return nil
 }
 
-// compileAndCacheGitIgnore compiles a .gitignore file if it exists at the given dirPath (absolute)
-// and caches the compiled matcher (or nil if no file/error).
-func (p *Processor) compileAndCacheGitIgnore(dirPath string) (*gitignore.GitIgnore, error) {
-	gitIgnorePath := filepath.Join(dirPath, ".gitignore")
+// exportIgnoreRules compiles the export-ignore patterns of the .gitattributes at the root of the work tree
+// containing basePath (or in basePath itself, outside of one), anchored there. It returns nil if there are none.
+func (p *Processor) exportIgnoreRules() (*filefilter.IgnoreRules, error) {
+	root, ok := gitutils.FindRepoRoot(p.basePath)
+	if !ok {
+		root = p.basePath
+	}
+	patterns, err := gitutils.ExportIgnorePatterns(root)
+	if err != nil {
+		return nil, fmt.Errorf("processor: %w", err)
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	p.logger.Info("Excluding export-ignore paths from .gitattributes", "root", root, "patterns", len(patterns))
+	return filefilter.CompileIgnoreLines(root, patterns...), nil
+}
 
-	// Check cache first
-	if matcher, RIsCached := p.gitIgnoreCache[gitIgnorePath]; RIsCached {
-		return matcher, nil // Return cached matcher (could be nil)
+// readFileList reads newline-separated file paths from the manifest at source ("-" for stdin)
+// and returns them relative to basePath and slash-separated. Blank lines are ignored; paths
+// may be relative to basePath or absolute, but must not point outside of it.
+func (p *Processor) readFileList(source string) ([]string, error) {
+	var data []byte
+	var err error
+	if source == "-" {
+		data, err = io.ReadAll(p.config.Stdin)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("processor: failed to read file list '%s': %w", source, err)
 	}
 
-	// Check if .gitignore file exists
-	if _, statErr := os.Stat(gitIgnorePath); statErr == nil {
-		// File exists, try to compile it
-		matcher, compileErr := gitignore.CompileIgnoreFile(gitIgnorePath)
-		if compileErr != nil {
-			slog.Warn("Processor: Failed to compile .gitignore, it will be ineffective", "path", gitIgnorePath, "error", compileErr)
-			p.gitIgnoreCache[gitIgnorePath] = nil // Cache nil to prevent re-attempts and indicate failure
-			return nil, nil                       // Not a fatal error for the whole process, just this .gitignore is skipped
+	files := []string{} // Non-nil even if the list is empty, so that nothing is included
+	for _, line := range strings.Split(string(data), "\n") {
+		listedPath := strings.TrimSpace(line)
+		if listedPath == "" {
+			continue
+		}
+		absPath := listedPath
+		if !filepath.IsAbs(absPath) {
+			absPath = filepath.Join(p.basePath, filepath.FromSlash(listedPath))
+		}
+		relPath, relErr := filepath.Rel(p.basePath, absPath)
+		if relErr != nil || relPath == "." || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("processor: listed path '%s' is outside of '%s'", listedPath, p.basePath)
 		}
-		slog.Debug("Processor: Loaded and compiled .gitignore", "path", gitIgnorePath)
-		p.gitIgnoreCache[gitIgnorePath] = matcher // Cache the successful matcher
-		return matcher, nil
-	} else if !os.IsNotExist(statErr) {
-		// Some other error stating the file (e.g., permission denied)
-		slog.Warn("Processor: Error trying to stat .gitignore file", "path", gitIgnorePath, "error", statErr)
+		if _, statErr := os.Stat(absPath); statErr != nil {
+			p.logger.Warn("Processor: Listed file not found (skipped)", "path", listedPath, "error", statErr)
+		}
+		files = append(files, filepath.ToSlash(relPath))
 	}
-	// File does not exist or unstat-able for non-existence reasons, cache nil
-	p.gitIgnoreCache[gitIgnorePath] = nil
-	return nil, nil
+	return files, nil
 }
 
-func (p *Processor) Process() error {
-	// Step 1: Setup base paths (local or cloned repo)
-	if err := p.setupInitialPaths(); err != nil {
-		return err // Error already contextualized by setupInitialPaths
+// contextIncludeFileName is the allowlist file at the source root: if it lists glob patterns,
+// only files matching one of them are included.
+const contextIncludeFileName = ".contextinclude"
+
+// readContextInclude returns the patterns of the .contextinclude file in basePath, one per line
+// (blank lines and "#" comments are skipped), or nil if there is none.
+func readContextInclude(basePath string, logger *slog.Logger) ([]string, error) {
+	includePath := filepath.Join(basePath, contextIncludeFileName)
+	data, err := os.ReadFile(includePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("processor: failed to read '%s': %w", includePath, err)
 	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if pattern := strings.TrimSpace(line); pattern != "" && !strings.HasPrefix(pattern, "#") {
+			patterns = append(patterns, filepath.ToSlash(pattern))
+		}
+	}
+	if len(patterns) == 0 {
+		logger.Warn("Processor: Ignoring include file without patterns", "path", includePath)
+		return nil, nil
+	}
+	logger.Info("Restricting output to files matching the include file", "path", includePath, "patterns", len(patterns))
+	return patterns, nil
+}
 
-	// Step 2: Determine the final output file path and initialize the file filter.
-	// The filter needs to know the output file path to exclude it.
-	if err := p.determineOutputFileAndInitFilter(); err != nil {
-		return err // Error already contextualized
+// intersectPaths returns the paths of a that are also in b, in the order of a.
+func intersectPaths(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, path := range b {
+		inB[path] = true
 	}
+	result := []string{}
+	for _, path := range a {
+		if inB[path] {
+			result = append(result, path)
+		}
+	}
+	return result
+}
 
-	// Defer cleanup if a temporary repository was cloned
-	if p.isTempRepo && p.tempRepoDir != "" {
-		defer func() {
-			slog.Info("Cleaning up temporary repository parent directory...", "path", p.tempRepoDir)
-			if err := os.RemoveAll(p.tempRepoDir); err != nil {
-				slog.Error("Processor: Failed to remove temporary directory", "path", p.tempRepoDir, "error", err)
-			} else {
-				slog.Debug("Processor: Temporary repository parent directory removed successfully.")
+// compileAndCacheGitIgnore compiles the ignore files (.gitignore plus any configured ExtraIgnoreFiles)
+// found in the given dirPath (absolute) into a single rule set, in that order, and caches it
+// per directory (nil if no file exists or none could be compiled).
+func (p *Processor) compileAndCacheGitIgnore(dirPath string) (*filefilter.IgnoreRules, error) {
+	// Check cache first
+	if matcher, isCached := p.gitIgnoreCache[dirPath]; isCached {
+		return matcher, nil // Return cached matcher (could be nil)
+	}
+
+	var combined *filefilter.IgnoreRules
+	ignoreFileNames := append([]string{".gitignore"}, p.config.ExtraIgnoreFiles...)
+	if p.config.IncludeGitignored {
+		ignoreFileNames = nil // The filter skips the ignore rules anyway
+	} else if p.config.OnlyTracked {
+		ignoreFileNames = p.config.ExtraIgnoreFiles // Git already decided which files are tracked
+	}
+	for _, name := range ignoreFileNames {
+		ignorePath := filepath.Join(dirPath, name)
+		if _, statErr := os.Stat(ignorePath); statErr != nil {
+			if !os.IsNotExist(statErr) {
+				// Some other error stating the file (e.g., permission denied)
+				p.logger.Warn("Processor: Error trying to stat ignore file", "path", ignorePath, "error", statErr)
 			}
-		}()
+			continue
+		}
+		// File exists, try to compile it
+		matcher, compileErr := filefilter.CompileIgnoreFile(dirPath, ignorePath)
+		if compileErr != nil {
+			// Not a fatal error for the whole process, just this ignore file is skipped
+			p.logger.Warn("Processor: Failed to compile ignore file, it will be ineffective", "path", ignorePath, "error", compileErr)
+			continue
+		}
+		p.logger.Debug("Processor: Loaded and compiled ignore file", "path", ignorePath)
+		if combined == nil {
+			combined = matcher
+		} else {
+			combined.Extend(matcher)
+		}
 	}
 
-	// The explicit error check for "output file path is inside the processed source directory"
-	// is no longer needed here, as the FileFilter will now handle excluding the output file.
+	p.gitIgnoreCache[dirPath] = combined // Cache nil as well to prevent re-attempts
+	return combined, nil
+}
 
-	// Write to a temporary file first to prevent data loss on error and to handle outputting to source dir
-	tempOutFile, err := os.CreateTemp(filepath.Dir(p.finalOutputFile), "c2c_out_*.tmp")
-	if err != nil {
-		return fmt.Errorf("processor: failed to create temporary output file: %w", err)
-	}
-	tempFileName := tempOutFile.Name()
-	successfulWrite := false // Flag to control cleanup of temp file
-
-	defer func() {
-		// tempOutFile.Close() might have already been called, but calling again on a closed file is safe.
-		_ = tempOutFile.Close()
-		if !successfulWrite {
-			slog.Debug("Processor: Cleaning up temporary output file due to error or incomplete processing", "path", tempFileName)
-			if removeErr := os.Remove(tempFileName); removeErr != nil {
-				slog.Warn("Processor: Failed to remove incomplete temporary output file", "path", tempFileName, "error", removeErr)
+// fileHeader builds the opening fence line for a file section, e.g. "```main.go (142 lines, 3.1 KiB)".
+// Stats are only computed when HeaderStats is enabled; failures to compute them just omit the stats.
+func (p *Processor) fileHeader(file includedFile) string {
+	infoString := p.displayPath(file)
+	if p.config.HeaderStats {
+		lineCount, countErr := file.lineCount()
+		if countErr == nil {
+			lineUnit := "lines"
+			if lineCount == 1 {
+				lineUnit = "line"
 			}
+			infoString += fmt.Sprintf(" (%d %s, %s)", lineCount, lineUnit, utils.FormatBytes(uint64(file.info.Size())))
+		} else {
+			p.logger.Warn("Processor: Could not compute header stats", "path", file.relPath, "error", countErr)
 		}
-	}()
+	}
+	if language := p.fenceLanguage(file); language != "" {
+		infoString = language + " " + infoString
+	}
+	return "```" + infoString + "\n"
+}
 
-	writer := bufio.NewWriter(tempOutFile)
+// fenceLanguage returns the language named in the file's opening fence, or "" if none is.
+func (p *Processor) fenceLanguage(file includedFile) string {
+	switch p.config.FenceLang {
+	case FenceLangAuto, FenceLangAlways:
+		return collector.LanguageOf(file.relPath)
+	default:
+		return ""
+	}
+}
 
-	// 1. Generate and write tree if enabled
-	if p.config.IncludeTree {
-		slog.Info("Generating file tree...")
-		// TreeBuilder uses the same filter instance, so it will also exclude the output file.
-		// It also uses the shared gitignore cache and compilation function.
-		treeBuilder := NewTreeBuilder(p.basePath, p.filter, p.gitIgnoreCache, p.compileAndCacheGitIgnore)
-		treeStr, treeErr := treeBuilder.BuildTreeString()
-		if treeErr != nil {
-			slog.Error("Processor: Failed to generate file tree. Skipping tree output.", "error", treeErr)
-			// Continue without tree if it fails
-		} else {
-			if _, writeErr := writer.WriteString(treeStr + "\n\n"); writeErr != nil {
-				return fmt.Errorf("processor: failed to write tree to output: %w", writeErr)
-			}
-			slog.Debug("Processor: File tree written to output.")
+// checkFenceLanguages fails if FenceLangAlways is set and the language of one of the files is unknown.
+func (p *Processor) checkFenceLanguages(files []includedFile) error {
+	if p.config.FenceLang != FenceLangAlways || !p.config.OutputFormat.isFenced() {
+		return nil
+	}
+	for _, file := range files {
+		if collector.LanguageOf(file.relPath) == "" {
+			return fmt.Errorf("processor: unknown language of '%s' for --fence-lang always (use auto to omit it for such files)", filepath.ToSlash(file.relPath))
 		}
 	}
+	return nil
+}
 
-	// 2. Process and write file contents
-	slog.Info("Walking directory and processing files...", "path", p.basePath)
+// displayPath returns the path shown for file in the output, in the configured PathStyle.
+// Paths always use forward slashes.
+func (p *Processor) displayPath(file includedFile) string {
+	relPath := filepath.ToSlash(filepath.Join(p.relPrefix, file.relPath))
+	switch p.config.PathStyle {
+	case PathStyleAbsolute:
+		return filepath.ToSlash(file.absPath)
+	case PathStyleRepo:
+		if p.config.RootLabel != "" {
+			return p.config.RootLabel + "/" + relPath
+		}
+		if p.relRoot != "" {
+			return filepath.Base(p.relRoot) + "/" + relPath
+		}
+		return p.repoName + "/" + relPath
+	default:
+		return relPath
+	}
+}
+
+// resolveRelativeTo sets relRoot and relPrefix from RelativeTo, which must be a directory containing basePath.
+func (p *Processor) resolveRelativeTo() error {
+	p.relRoot, p.relPrefix = "", ""
+	if p.config.RelativeTo == "" {
+		return nil
+	}
+	if p.isTempRepo {
+		return fmt.Errorf("processor: paths relative to '%s' require a local source directory", p.config.RelativeTo)
+	}
+	relRoot, err := filepath.Abs(p.config.RelativeTo)
+	if err != nil {
+		return fmt.Errorf("processor: failed to get absolute path for '%s': %w", p.config.RelativeTo, err)
+	}
+	relPrefix, err := filepath.Rel(relRoot, p.basePath)
+	if err != nil || relPrefix == ".." || strings.HasPrefix(relPrefix, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("processor: source path '%s' is not inside '%s', which paths are relative to", p.basePath, relRoot)
+	}
+	p.relRoot = relRoot
+	if relPrefix != "." {
+		p.relPrefix = relPrefix
+	}
+	return nil
+}
 
-	// activeGitIgnores stores compiled .gitignore objects from root down to current path for the WalkDir callback.
-	// Initialize with the root .gitignore if it exists.
-	var rootGitIgnoreMatchers []*gitignore.GitIgnore
-	if matcher, _ := p.compileAndCacheGitIgnore(p.basePath); matcher != nil {
-		rootGitIgnoreMatchers = append(rootGitIgnoreMatchers, matcher)
+// pathDepth returns how many levels absPath is below basePath (1 for a direct child),
+// measured in path separators of the relative path.
+func pathDepth(basePath, absPath string) int {
+	relPath, err := filepath.Rel(basePath, absPath)
+	if err != nil || relPath == "." {
+		return 0
 	}
+	return strings.Count(relPath, string(filepath.Separator)) + 1
+}
 
-	walkErr := filepath.WalkDir(p.basePath, func(currentPath string, d fs.DirEntry, walkPathErr error) error {
+// includedFile is a file that passed the filter and will be written to the output.
+type includedFile struct {
+	absPath string
+	relPath string      // Relative to basePath, OS-specific separators
+	info    fs.FileInfo // Info of the file (of the link target when following symlinks)
+	content []byte      // Content read from stdin (see FromStdin); nil for a file on disk
+}
+
+// symlinkedDirTarget returns the target of the symbolic link at absPath, as stored in the link,
+// if it points to a directory.
+func symlinkedDirTarget(absPath string) (string, bool) {
+	info, err := os.Stat(absPath)
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+	target, err := os.Readlink(absPath)
+	if err != nil {
+		return "", false
+	}
+	return filepath.ToSlash(target), true
+}
+
+// collectCandidates walks basePath and returns the files that pass the filter, in walk (lexical) order.
+// If tree is not nil, the walked entries are also added to it, so the tree needs no traversal of its own.
+func (p *Processor) collectCandidates(tree *treeBuilder) ([]includedFile, error) {
+	p.logger.Info("Walking directory and processing files...", "path", p.basePath)
+
+	var files []includedFile
+	scannedFiles := 0
+	// Active ignore stacks of the walked directories, built incrementally: a directory's stack is its
+	// parent's plus its own ignore files, so entries don't climb their parents to collect them.
+	ignoreStacks := map[string][]*filefilter.IgnoreRules{p.basePath: p.activeIgnoresFor(p.basePath, true)}
+	walkErr := walk(p.basePath, p.config.FollowSymlinks, p.logger, func(currentPath string, d fs.DirEntry, walkPathErr error) error {
+		if err := p.ctx.Err(); err != nil {
+			return err // Stops the walk
+		}
 		if walkPathErr != nil {
-			slog.Warn("Processor: Error accessing path during walk (entry skipped)", "path", currentPath, "error", walkPathErr)
+			p.logger.Warn("Processor: Error accessing path during walk (entry skipped)", "path", currentPath, "error", walkPathErr)
 			if d != nil && d.IsDir() && errors.Is(walkPathErr, fs.ErrPermission) {
 				return fs.SkipDir // Skip directories we can't read.
 			}
 			return nil // Skip this entry but continue walk for other recoverable errors.
 		}
 
-		absCurrentPath := currentPath // filepath.WalkDir provides absolute paths if the root is absolute.
+		absCurrentPath := currentPath // walk provides absolute paths if the root is absolute.
 		// Ensure basePath was made absolute earlier.
 
-		// Build the stack of active .gitignore matchers for the current path.
-		// The stack goes from root-most .gitignore to the deepest one applicable.
-		var currentActiveIgnores []*gitignore.GitIgnore
-		currentDir := absCurrentPath
 		if !d.IsDir() {
-			currentDir = filepath.Dir(absCurrentPath)
+			scannedFiles++
+			p.progress.Update("Scanned %d files, included %d", scannedFiles, len(files))
 		}
 
-		// Collect matchers from currentDir up to basePath
-		var pathStack []*gitignore.GitIgnore // Deepest first in this temp stack
-		for strings.HasPrefix(currentDir, p.basePath) && currentDir != "" {
-			matcher, _ := p.compileAndCacheGitIgnore(currentDir)
-			if matcher != nil {
-				pathStack = append(pathStack, matcher)
-			}
-			if currentDir == p.basePath {
-				break // Stop once we've processed the basePath's .gitignore
-			}
-			parentDir := filepath.Dir(currentDir)
-			if parentDir == currentDir { // Safety break for filesystem root
-				break
-			}
-			currentDir = parentDir
+		// Now, call the filter with the stack of active ignore files for the current path
+		activeIgnores, known := ignoreStacks[filepath.Dir(absCurrentPath)]
+		if absCurrentPath == p.basePath {
+			activeIgnores = ignoreStacks[p.basePath]
+		} else if !known {
+			activeIgnores = p.activeIgnoresFor(absCurrentPath, d.IsDir()) // Not reached via a walked parent
 		}
-		// Reverse pathStack to get [root, sub, subsub] order
-		for i := len(pathStack) - 1; i >= 0; i-- {
-			currentActiveIgnores = append(currentActiveIgnores, pathStack[i])
+		reason, filterErr := p.filter.ExclusionReason(absCurrentPath, d, activeIgnores)
+		excluded := reason != ""
+		if excluded {
+			p.result.SkippedByReason[string(reason)]++
 		}
-
-		// Now, call the filter
-		excluded, filterErr := p.filter.IsExcluded(absCurrentPath, d, currentActiveIgnores)
 		if filterErr != nil {
 			// Check if it's a SkipDir signal from the filter itself
 			if errors.Is(filterErr, filepath.SkipDir) {
-				slog.Debug("Processor: Directory skipped by filter's SkipDir directive", "path", currentPath)
+				p.logger.Debug("Processor: Directory skipped by filter's SkipDir directive", "path", currentPath)
+				if tree != nil {
+					tree.addEntry(absCurrentPath, d, true)
+				}
 				return filepath.SkipDir
 			}
 			// For other errors from filter (e.g., stat failure for a file), log and skip entry
-			slog.Warn("Processor: Error during filtering process, skipping entry", "path", currentPath, "error", filterErr)
+			p.logger.Warn("Processor: Error during filtering process, skipping entry", "path", currentPath, "error", filterErr)
 			return nil // Skip this entry but continue walk
 		}
+		if tree != nil && reason == filefilter.ReasonSymlink && p.config.ShowSymlinkDirs {
+			if target, ok := symlinkedDirTarget(absCurrentPath); ok {
+				tree.addSymlinkDir(absCurrentPath, target)
+				return nil
+			}
+		}
+		if tree != nil {
+			tree.addEntry(absCurrentPath, d, excluded)
+		}
 
 		if excluded {
 			if d.IsDir() { // If filter excluded a directory (not via SkipDir error but bool return)
-				slog.Debug("Processor: Directory excluded by filter, skipping its contents", "path", currentPath)
+				p.logger.Debug("Processor: Directory excluded by filter, skipping its contents", "path", currentPath)
 				return filepath.SkipDir
 			}
 			// If it's an excluded file, filter might have logged it if verbose.
 			return nil
 		}
 
-		// If it's a directory and not excluded, WalkDir will traverse into it. Nothing to do here for dirs.
+		// If it's a directory and not excluded, WalkDir will traverse into it unless its contents are beyond MaxDepth.
 		if d.IsDir() {
+			if p.config.MaxDepth > 0 && absCurrentPath != p.basePath && pathDepth(p.basePath, absCurrentPath) >= p.config.MaxDepth {
+				p.logger.Debug("Processor: Not descending below maximum depth", "path", currentPath, "max_depth", p.config.MaxDepth)
+				return filepath.SkipDir
+			}
+			if absCurrentPath != p.basePath {
+				ignoreStacks[absCurrentPath] = p.pushIgnores(activeIgnores, absCurrentPath)
+			}
+			p.walkedDirs = append(p.walkedDirs, absCurrentPath)
 			return nil
 		}
 
-		// --- File processing: If we reach here, it's a file to include ---
+		// --- If we reach here, it's a file to include ---
 		relPath, relErr := filepath.Rel(p.basePath, absCurrentPath)
 		if relErr != nil {
-			slog.Warn("Processor: Could not get relative path for included file (skipping)", "path", absCurrentPath, "error", relErr)
+			p.logger.Warn("Processor: Could not get relative path for included file (skipping)", "path", absCurrentPath, "error", relErr)
 			return nil // Skip this file
 		}
-		slog.Info("Processor: Including file", "path", relPath)
+		info, infoErr := d.Info()
+		if infoErr != nil {
+			p.logger.Warn("Processor: Could not get file info for included file (skipping)", "path", relPath, "error", infoErr)
+			return nil
+		}
+		p.logger.Info("Processor: Including file", "path", relPath)
+		files = append(files, includedFile{absPath: absCurrentPath, relPath: relPath, info: info})
+		return nil
+	})
+	p.progress.Done("Scanned %d files, included %d", scannedFiles, len(files))
+
+	if walkErr != nil {
+		// This error is from the WalkDir function itself or propagated from a critical error in the callback.
+		return nil, fmt.Errorf("processor: error during file walk: %w", walkErr)
+	}
+	return files, nil
+}
+
+// dropOutliers returns the files that are not larger than utils.OutlierThreshold of all their sizes,
+// logging each dropped file.
+func dropOutliers(files []includedFile, logger *slog.Logger) []includedFile {
+	sizes := make([]int64, len(files))
+	for i, file := range files {
+		sizes[i] = file.info.Size()
+	}
+	threshold := utils.OutlierThreshold(sizes)
+	kept := files[:0:0]
+	for _, file := range files {
+		if file.info.Size() > threshold {
+			logger.Info("Dropping outlier file", "path", file.relPath, "size", file.info.Size(), "threshold", threshold)
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
+
+// selectInteractively lets the user deselect candidates when Stdin is a terminal; otherwise all are kept.
+// Deselected files are also excluded from the tree.
+func (p *Processor) selectInteractively(candidates []includedFile) ([]includedFile, error) {
+	if !p.isTerminal(p.config.Stdin) {
+		p.logger.Info("Processor: Stdin is not a terminal, including all candidate files without interactive selection")
+		return candidates, nil
+	}
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	selected, err := p.pickFiles(candidates, p.config.Stdin, p.config.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	isSelected := make(map[string]bool, len(selected))
+	for _, file := range selected {
+		isSelected[file.absPath] = true
+	}
+	var deselected []string
+	for _, file := range candidates {
+		if !isSelected[file.absPath] {
+			deselected = append(deselected, file.absPath)
+		}
+	}
+	p.filter.ExcludePaths(deselected...)
+	p.logger.Info("Processor: Interactive selection done", "selected", len(selected), "deselected", len(deselected))
+	return selected, nil
+}
+
+// stdinIsTerminal reports whether r is a file referring to a terminal.
+func stdinIsTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	return ok && utils.IsTerminal(f)
+}
+
+// writerIsTerminal reports whether w is a file referring to a terminal.
+func writerIsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && utils.IsTerminal(f)
+}
+
+// pushIgnores returns the ignore stack for dir: parentIgnores followed by dir's own ignore files, if any.
+// parentIgnores is not modified.
+func (p *Processor) pushIgnores(parentIgnores []*filefilter.IgnoreRules, dir string) []*filefilter.IgnoreRules {
+	rules, _ := p.compileAndCacheGitIgnore(dir)
+	if rules == nil {
+		return parentIgnores // Shared: no ignore file of its own
+	}
+	stack := make([]*filefilter.IgnoreRules, len(parentIgnores), len(parentIgnores)+1)
+	copy(stack, parentIgnores)
+	return append(stack, rules)
+}
+
+// activeIgnoresFor builds the stack of compiled ignore files applicable to absPath,
+// ordered from the root-most .gitignore to the deepest one.
+func (p *Processor) activeIgnoresFor(absPath string, isDir bool) []*filefilter.IgnoreRules {
+	currentDir := absPath
+	if !isDir {
+		currentDir = filepath.Dir(absPath)
+	}
+
+	// Collect matchers from currentDir up to basePath
+	var pathStack []*filefilter.IgnoreRules // Deepest first in this temp stack
+	for strings.HasPrefix(currentDir, p.basePath) && currentDir != "" {
+		matcher, _ := p.compileAndCacheGitIgnore(currentDir)
+		if matcher != nil {
+			pathStack = append(pathStack, matcher)
+		}
+		if currentDir == p.basePath {
+			break // Stop once we've processed the basePath's .gitignore
+		}
+		parentDir := filepath.Dir(currentDir)
+		if parentDir == currentDir { // Safety break for filesystem root
+			break
+		}
+		currentDir = parentDir
+	}
+
+	// Reverse pathStack to get [root, sub, subsub] order, after the ignore files above basePath
+	activeIgnores := make([]*filefilter.IgnoreRules, 0, len(p.ancestorIgnores)+len(pathStack))
+	activeIgnores = append(activeIgnores, p.ancestorIgnores...)
+	for i := len(pathStack) - 1; i >= 0; i-- {
+		activeIgnores = append(activeIgnores, pathStack[i])
+	}
+	return activeIgnores
+}
+
+// wrappingText loads the --prepend/--append text (inline or read from a file), followed by a blank line.
+// It returns an empty string if arg is empty.
+func (p *Processor) wrappingText(arg, kind string) (string, error) {
+	if arg == "" {
+		return "", nil
+	}
+	text, err := utils.TextFromArg(arg)
+	if err != nil {
+		return "", fmt.Errorf("processor: failed to load %s text: %w", kind, err)
+	}
+	return strings.TrimRight(text, "\n") + "\n\n", nil
+}
 
-		// Write file path header (use forward slashes for consistency in output)
-		header := fmt.Sprintf("```%s\n", filepath.ToSlash(relPath))
-		if _, writeErr := writer.WriteString(header); writeErr != nil {
-			// This is a more critical error, likely relates to disk space or permissions for the temp output file.
-			return fmt.Errorf("processor: failed to write file header for '%s' to temporary output: %w", relPath, writeErr)
+// writeFileSection writes one file section (header, content, footer) to writer in the configured format.
+// index is the 1-based position of the file in the output. If note is set, it is written instead of the
+// file's content (e.g. "// content omitted"); otherwise the content read is also written to contentHash, if non-nil.
+// Errors reading the file are noted inside the section and logged to logger; only write errors are returned.
+func (p *Processor) writeFileSection(writer io.StringWriter, file includedFile, index int, note string, contentHash io.Writer, logger *slog.Logger) error {
+	relPath := file.relPath
+
+	if p.config.OutputFormat.isJSON() {
+		// The content is collected first, since it is encoded as a single JSON string.
+		var content strings.Builder
+		if err := p.writeFileContent(&content, file, note, contentHash, func(text string) string { return text }, logger); err != nil {
+			return err
+		}
+		recordType := ""
+		if p.config.OutputFormat == OutputFormatJSONL {
+			recordType = "file"
+		}
+		encoded, encodeErr := encodeJSON(jsonFile{
+			Type:     recordType,
+			Path:     p.displayPath(file),
+			Language: collector.LanguageOf(relPath),
+			Size:     file.info.Size(),
+			Content:  content.String(),
+		})
+		if encodeErr != nil {
+			return fmt.Errorf("processor: failed to encode '%s' as JSON: %w", relPath, encodeErr)
+		}
+		if p.config.OutputFormat == OutputFormatJSONL {
+			encoded += "\n"
 		}
+		if _, writeErr := writer.WriteString(encoded); writeErr != nil {
+			return fmt.Errorf("processor: failed to write file entry for '%s' to temporary output: %w", relPath, writeErr)
+		}
+		return nil
+	}
+
+	// Fenced formats use the path as info string; XML escapes all text written inside elements.
+	header := p.fileHeader(file)
+	footer := "```\n" // The blank line after it is the default section separator (see sectionSeparator)
+	escape := func(text string) string { return text }
+	if p.config.OutputFormat == OutputFormatXML {
+		header = fmt.Sprintf("<document index=\"%d\">\n<source>%s</source>\n<document_contents>\n", index, xmlEscapeText(p.displayPath(file)))
+		footer = "</document_contents>\n</document>\n"
+		escape = xmlEscapeText
+	}
+
+	if p.config.Annotate {
+		header = p.fileAnnotation(file, logger) + header
+	}
 
-		// Write file content
-		file, openErr := os.Open(absCurrentPath)
-		if openErr != nil {
-			slog.Warn("Processor: Failed to open file for reading (content skipped)", "path", relPath, "error", openErr)
-			// Write a note into the output file about the failure
-			if _, noteErr := fmt.Fprintf(writer, "// Error reading file '%s': %v\n", relPath, openErr); noteErr != nil {
-				return fmt.Errorf("processor: failed to write error note for '%s' to temporary output: %w", relPath, noteErr)
+	// Write file path header (use forward slashes for consistency in output)
+	if _, writeErr := writer.WriteString(header); writeErr != nil {
+		// This is a more critical error, likely relates to disk space or permissions for the temp output file.
+		return fmt.Errorf("processor: failed to write file header for '%s' to temporary output: %w", relPath, writeErr)
+	}
+
+	// Write file content
+	if err := p.writeFileContent(writer, file, note, contentHash, escape, logger); err != nil {
+		return err
+	}
+
+	// Write file path footer
+	if _, writeErr := writer.WriteString(footer); writeErr != nil {
+		return fmt.Errorf("processor: failed to write file footer for '%s' to temporary output: %w", relPath, writeErr)
+	}
+	return nil
+}
+
+// writeFileContent writes the (transformed) content of file line by line, or note instead if set,
+// passing every line through escape. See writeFileSection for note and contentHash.
+func (p *Processor) writeFileContent(writer io.StringWriter, file includedFile, note string, contentHash io.Writer, escape func(string) string, logger *slog.Logger) error {
+	relPath := file.relPath
+	if note != "" {
+		if _, noteErr := writer.WriteString(escape(note + "\n")); noteErr != nil {
+			return fmt.Errorf("processor: failed to write content note for '%s' to temporary output: %w", relPath, noteErr)
+		}
+	} else if f, openErr := file.open(); openErr != nil {
+		logger.Warn("Processor: Failed to open file for reading (content skipped)", "path", relPath, "error", openErr)
+		// Write a note into the output file about the failure
+		if _, noteErr := writer.WriteString(escape(fmt.Sprintf("// Error reading file '%s': %v\n", relPath, openErr))); noteErr != nil {
+			return fmt.Errorf("processor: failed to write error note for '%s' to temporary output: %w", relPath, noteErr)
+		}
+	} else {
+		// Using a scanner is good for line-by-line processing.
+		var content io.Reader = f
+		if contentHash != nil {
+			content = io.TeeReader(f, contentHash) // Hash while reading, so duplicates cost no extra read
+		}
+		if p.rendersNotebook(relPath) {
+			data, readErr := io.ReadAll(content)
+			if readErr != nil {
+				logger.Warn("Processor: Error reading file content", "path", relPath, "error", readErr)
 			}
-		} else {
-			// Using a scanner is good for line-by-line processing.
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				if _, writeErr := writer.WriteString(scanner.Text() + "\n"); writeErr != nil {
-					_ = file.Close()
-					return fmt.Errorf("processor: failed to write file content for '%s' to temporary output: %w", relPath, writeErr)
+			render := utils.RenderNotebook
+			if p.config.NotebookMarkdown {
+				render = utils.RenderNotebookWithMarkdown
+			}
+			if rendered, renderErr := render(bytes.NewReader(data)); renderErr != nil {
+				logger.Warn("Processor: Failed to render notebook, writing it unchanged", "path", relPath, "error", renderErr)
+				content = bytes.NewReader(data)
+			} else {
+				content = strings.NewReader(rendered)
+			}
+		}
+		if stripper := p.commentStripperFor(relPath); stripper != nil {
+			// Block comments span lines, so the file is stripped as a whole before it is split into lines.
+			data, readErr := io.ReadAll(content)
+			if readErr != nil {
+				logger.Warn("Processor: Error reading file content", "path", relPath, "error", readErr)
+			}
+			content = strings.NewReader(stripper.StripComments(string(data)))
+		}
+		if len(p.config.Transformers) > 0 {
+			data, readErr := io.ReadAll(content)
+			if readErr != nil {
+				logger.Warn("Processor: Error reading file content", "path", relPath, "error", readErr)
+			}
+			transformed, transformErr := p.transform(relPath, data)
+			if transformErr != nil {
+				_ = f.Close()
+				logger.Warn("Processor: Failed to transform file content (content skipped)", "path", relPath, "error", transformErr)
+				if _, noteErr := writer.WriteString(escape(fmt.Sprintf("// Error transforming file '%s': %v\n", relPath, transformErr))); noteErr != nil {
+					return fmt.Errorf("processor: failed to write transform error note for '%s' to temporary output: %w", relPath, noteErr)
+				}
+				return nil
+			}
+			content = bytes.NewReader(transformed)
+		}
+		var window *lineWindow
+		if p.truncates(file) {
+			logger.Info("Processor: Including only the first and last lines of a large file", "path", relPath, "head", p.config.HeadLines, "tail", p.config.TailLines)
+			window = newLineWindow(p.config.HeadLines, p.config.TailLines)
+		}
+		scanner := utils.NewLineScanner(content) // Unlike bufio.Scanner, not limited to lines of 64 KiB
+		previousBlank := false
+		invalidUTF8 := false
+		for scanner.Scan() {
+			blank := strings.TrimSpace(scanner.Text()) == ""
+			if blank && previousBlank && p.config.CollapseBlankLines {
+				continue // Runs of blank lines become a single blank line
+			}
+			previousBlank = blank
+			line := scanner.Text()
+			if p.config.Reproducible {
+				line = strings.TrimRight(line, " \t\r") // Normalize trailing whitespace (including CRLF endings)
+			}
+			if !utf8.ValidString(line) {
+				invalidUTF8 = true
+				if p.config.ValidUTF8 {
+					line = utils.SanitizeUTF8(line)
 				}
 			}
-			if scanErr := scanner.Err(); scanErr != nil {
-				slog.Warn("Processor: Error scanning file content", "path", relPath, "error", scanErr)
-				if _, noteErr := fmt.Fprintf(writer, "// Error scanning file '%s': %v\n", relPath, scanErr); noteErr != nil {
-					_ = file.Close()
-					return fmt.Errorf("processor: failed to write scan error note for '%s' to temporary output: %w", relPath, noteErr)
+			line = truncateLine(line, p.config.MaxLineLength)
+			if window != nil && !window.inHead(line) {
+				continue // Written after the loop if it is one of the tail lines
+			}
+			if _, writeErr := writer.WriteString(escape(line) + "\n"); writeErr != nil {
+				_ = f.Close()
+				return fmt.Errorf("processor: failed to write file content for '%s' to temporary output: %w", relPath, writeErr)
+			}
+		}
+		if window != nil {
+			tailLines := window.tailLines
+			if marker := window.omittedMarker(); marker != "" {
+				tailLines = append([]string{marker}, tailLines...)
+			}
+			for _, line := range tailLines {
+				if _, writeErr := writer.WriteString(escape(line) + "\n"); writeErr != nil {
+					_ = f.Close()
+					return fmt.Errorf("processor: failed to write file content for '%s' to temporary output: %w", relPath, writeErr)
 				}
 			}
-			_ = file.Close()
 		}
+		if invalidUTF8 {
+			logger.Warn("Processor: File content is not valid UTF-8", "path", relPath, "sanitized", p.config.ValidUTF8)
+		}
+		if scanErr := scanner.Err(); scanErr != nil {
+			logger.Warn("Processor: Error scanning file content", "path", relPath, "error", scanErr)
+			if _, noteErr := writer.WriteString(escape(fmt.Sprintf("// Error scanning file '%s': %v\n", relPath, scanErr))); noteErr != nil {
+				_ = f.Close()
+				return fmt.Errorf("processor: failed to write scan error note for '%s' to temporary output: %w", relPath, noteErr)
+			}
+		}
+		_ = f.Close()
+	}
+	return nil
+}
+
+// oversizedNote returns the note placed before a file section that exceeds the split size on its own
+// (none for JSON and JSON Lines).
+func (p *Processor) oversizedNote(file includedFile) string {
+	note := fmt.Sprintf("Note: '%s' exceeds the split size on its own and was written to a separate part.", p.displayPath(file))
+	switch p.config.OutputFormat {
+	case OutputFormatXML:
+		return "<!-- " + strings.ReplaceAll(xmlEscapeText(note), "--", "- -") + " -->\n"
+	case OutputFormatJSON, OutputFormatJSONL:
+		return "" // JSON has no comments; the file's object stands alone in its part
+	default:
+		return note + "\n\n"
+	}
+}
+
+// defaultSectionSeparator is written between two file sections of a fenced format: a blank line.
+const defaultSectionSeparator = "\n"
+
+// sectionSeparator returns what is written between two file sections of a fenced format:
+// SectionSeparator ending in a newline (added if missing), or a blank line by default.
+func (p *Processor) sectionSeparator() string {
+	if p.config.SectionSeparator == nil {
+		return defaultSectionSeparator
+	}
+	separator := *p.config.SectionSeparator
+	if separator != "" && !strings.HasSuffix(separator, "\n") {
+		separator += "\n"
+	}
+	return separator
+}
+
+// contentNote returns the note written instead of the file's content, or "" if the content is written.
+func (p *Processor) contentNote(file includedFile) string {
+	if p.omitsContent(file.relPath) {
+		return "// content omitted"
+	}
+	return ""
+}
+
+// rendersNotebook reports whether the file is a Jupyter notebook to be rendered as its cells.
+func (p *Processor) rendersNotebook(relPath string) bool {
+	return p.config.RenderNotebooks && strings.EqualFold(filepath.Ext(relPath), ".ipynb")
+}
+
+// commentStripperFor returns the comment stripper for the file's language if StripComments is set,
+// or nil if comments should be kept or the language is not supported.
+func (p *Processor) commentStripperFor(relPath string) utils.CommentStripper {
+	if !p.config.StripComments {
+		return nil
+	}
+	return utils.CommentStripperFor(collector.LanguageOf(relPath))
+}
+
+// omitsContent reports whether the file name ends in one of the OmitContentExts (case-insensitive),
+// which allows multi-part extensions such as ".min.js".
+func (p *Processor) omitsContent(relPath string) bool {
+	baseName := strings.ToLower(filepath.Base(relPath))
+	for _, ext := range p.config.OmitContentExts {
+		if ext != "" && strings.HasSuffix(baseName, strings.ToLower(ext)) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultTreeFenceLang is the info string of the tree's fence in OutputFormatMarkdown, so that
+// Markdown viewers render the tree as preformatted text.
+const defaultTreeFenceLang = "text"
+
+// formatTree renders the tree string for the configured output format: bare in OutputFormatText,
+// in a fenced block in OutputFormatMarkdown.
+func (p *Processor) formatTree(treeStr string) string {
+	switch p.config.OutputFormat {
+	case OutputFormatMarkdown:
+		lang := p.config.TreeFenceLang
+		if lang == "" {
+			lang = defaultTreeFenceLang
+		}
+		return "```" + lang + "\n" + treeStr + "```\n\n"
+	case OutputFormatXML:
+		return "<file_tree>\n" + xmlEscapeText(treeStr) + "</file_tree>\n"
+	case OutputFormatJSON:
+		return treeStr // Encoded as a member of the top-level object
+	case OutputFormatJSONL:
+		return jsonlLine("tree", treeStr)
+	default:
+		return treeStr + "\n\n"
+	}
+}
+
+// gitInfo describes the commit the source was taken from, or returns nil (with a warning)
+// if the source is not a git repository. For a cloned repository, the requested ref is reported.
+func (p *Processor) gitInfo() *jsonGitInfo {
+	info, err := p.config.RepoInfo(p.basePath, p.logger)
+	if err != nil {
+		p.logger.Warn("Processor: Could not read git info, omitting it", "path", p.basePath, "error", err)
+		return nil
+	}
+	if gitutils.IsGitURL(p.config.SourcePath) && p.config.GitRef != "" {
+		info.Ref = p.config.GitRef // A shallow clone of a tag or commit is on a detached HEAD
+	}
+	return &jsonGitInfo{Repository: info.RemoteURL, Commit: info.Commit, Ref: info.Ref, Dirty: info.Dirty}
+}
+
+// metaOpen returns the start of every part of the JSON formats: for OutputFormatJSON, the top-level
+// object opened with the jsonMeta members and a comma; for OutputFormatJSONL, the "meta" record.
+// It returns "" for the other formats.
+func (p *Processor) metaOpen() string {
+	if !p.config.OutputFormat.isJSON() {
+		return ""
+	}
+	meta := jsonMeta{Schema: SchemaVersion, Source: p.repoName}
+	if gitutils.IsGitURL(p.config.SourcePath) {
+		meta.Source = gitutils.RedactURLCredentials(p.config.SourcePath)
+	}
+	if !p.config.Reproducible {
+		meta.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	if p.config.OutputFormat == OutputFormatJSONL {
+		meta.Type = "meta"
+		encoded, _ := encodeJSON(meta) // Strings always encode
+		return encoded + "\n"
+	}
+	encoded, _ := encodeJSON(meta)
+	return strings.TrimSuffix(encoded, "}") + ","
+}
+
+// formatGitInfo renders the git info for the configured output format.
+// For OutputFormatJSON, it is written as the "git" object instead.
+func (p *Processor) formatGitInfo(info *jsonGitInfo) string {
+	if p.config.OutputFormat == OutputFormatJSON {
+		return ""
+	}
+	if p.config.OutputFormat == OutputFormatXML {
+		var elements strings.Builder
+		if info.Repository != "" {
+			elements.WriteString("<repository>" + xmlEscapeText(info.Repository) + "</repository>\n")
+		}
+		fmt.Fprintf(&elements, "<commit>%s</commit>\n<ref>%s</ref>\n<dirty>%t</dirty>\n", info.Commit, xmlEscapeText(info.Ref), info.Dirty)
+		return "<git_info>\n" + elements.String() + "</git_info>\n"
+	}
+	var lines strings.Builder
+	if info.Repository != "" {
+		lines.WriteString("Repository: " + info.Repository + "\n")
+	}
+	fmt.Fprintf(&lines, "Commit: %s\nRef: %s\nDirty: %t\n", info.Commit, info.Ref, info.Dirty)
+	if p.config.OutputFormat == OutputFormatJSONL {
+		return jsonlLine("git", lines.String())
+	}
+	return lines.String() + "\n"
+}
+
+// tocPaths returns the header paths of files, in output order.
+func (p *Processor) tocPaths(files []includedFile) []string {
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = p.displayPath(file)
+	}
+	return paths
+}
+
+// formatTOC renders the table of contents: the files numbered in output order, so that
+// e.g. "file 7" refers to the seventh file section (and to index 7 in OutputFormatXML).
+// For OutputFormatJSON, the paths are written as the "toc" array instead.
+func (p *Processor) formatTOC(files []includedFile) string {
+	switch p.config.OutputFormat {
+	case OutputFormatJSON:
+		return ""
+	case OutputFormatXML:
+		var entries strings.Builder
+		for i, path := range p.tocPaths(files) {
+			fmt.Fprintf(&entries, "<entry index=\"%d\">%s</entry>\n", i+1, xmlEscapeText(path))
+		}
+		return "<table_of_contents>\n" + entries.String() + "</table_of_contents>\n"
+	}
+	var list strings.Builder
+	for i, path := range p.tocPaths(files) {
+		fmt.Fprintf(&list, "%d. %s\n", i+1, path)
+	}
+	if p.config.OutputFormat == OutputFormatJSONL {
+		return jsonlLine("toc", list.String())
+	}
+	return "Table of contents:\n" + list.String() + "\n"
+}
+
+// Process generates the output once or, with Watch set, keeps regenerating it on changes until interrupted.
+// A run that includes no files returns ErrNoFiles (in watch mode, it is only logged).
+func (p *Processor) Process() error {
+	if p.config.Watch {
+		return p.watch()
+	}
+	return p.process()
+}
+
+// process runs once, stopped with ErrTimeout when Timeout is set and expires.
+func (p *Processor) process() error {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if p.config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.config.Timeout)
+	}
+	defer cancel()
+	p.ctx = ctx
+	err := p.run()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("processor: %w after %s", ErrTimeout, p.config.Timeout)
+	}
+	return err
+}
+
+func (p *Processor) run() error {
+	// Reset the state of a previous run (in watch mode)
+	p.gitIgnoreCache = make(map[string]*filefilter.IgnoreRules)
+	p.ancestorIgnores = nil
+	p.tokenCounts = nil
+	p.countStats = CountStats{}
+	p.walkedDirs = nil
+	p.outputDiff = nil
+	p.result = ProcessResult{SkippedByReason: make(map[string]int)}
+
+	// Step 1: Setup base paths (local or cloned repo)
+	if err := p.setupInitialPaths(); err != nil {
+		return err // Error already contextualized by setupInitialPaths
+	}
+
+	// Step 2: Determine the final output file path and initialize the file filter.
+	// The filter needs to know the output file path to exclude it.
+	if err := p.determineOutputFileAndInitFilter(); err != nil {
+		return err // Error already contextualized
+	}
+
+	// Defer cleanup if a temporary repository was cloned
+	if p.isTempRepo && p.tempRepoDir != "" {
+		defer func() {
+			p.logger.Info("Cleaning up temporary repository parent directory...", "path", p.tempRepoDir)
+			if err := os.RemoveAll(p.tempRepoDir); err != nil {
+				p.logger.Error("Processor: Failed to remove temporary directory", "path", p.tempRepoDir, "error", err)
+			} else {
+				p.logger.Debug("Processor: Temporary repository parent directory removed successfully.")
+			}
+		}()
+	}
+
+	if err := p.resolveRelativeTo(); err != nil {
+		return err
+	}
+
+	// The explicit error check for "output file path is inside the processed source directory"
+	// is no longer needed here, as the FileFilter will now handle excluding the output file.
+
+	// Collect the included files and order them; with --interactive the user narrows the selection.
+	// With IncludeTree, the tree is collected by the same walk.
+	var tree *treeBuilder
+	if p.config.IncludeTree && !p.config.CountOnly {
+		tree = newTreeBuilder(p.basePath, p.filter, p.config.FullTree, p.logger)
+		tree.order = p.config.TreeSort
+		if p.relPrefix != "" {
+			tree.root.name = filepath.ToSlash(p.relPrefix) // The root is labeled like the paths in the headers
+		}
+		if p.config.RootLabel != "" {
+			tree.root.name = p.config.RootLabel
+		}
+	}
+	var files []includedFile
+	var err error
+	if p.config.FromStdin {
+		files, err = p.readStdinFiles(tree)
+	} else {
+		files, err = p.collectCandidates(tree)
+	}
+	if err != nil {
+		return err
+	}
+	if p.config.DropOutliers {
+		if kept := dropOutliers(files, p.logger); len(kept) < len(files) {
+			p.result.SkippedByReason[reasonOutlier] += len(files) - len(kept)
+			files = kept
+			if tree != nil {
+				tree.keepFiles(files) // Dropped files are left out of the tree too
+			}
+		}
+	}
+	sortFiles(files, p.config.SortOrder)
+	if p.config.Interactive {
+		candidateCount := len(files)
+		if files, err = p.selectInteractively(files); err != nil {
+			return err
+		}
+		if deselected := candidateCount - len(files); deselected > 0 {
+			p.result.SkippedByReason[string(filefilter.ReasonDeselected)] += deselected
+		}
+		if tree != nil {
+			tree.keepFiles(files) // Deselected files are left out of the tree too
+		}
+	}
 
-		// Write file path footer
-		if _, writeErr := writer.WriteString("```\n\n"); writeErr != nil {
-			return fmt.Errorf("processor: failed to write file footer for '%s' to temporary output: %w", relPath, writeErr)
+	omittedByBudget := 0
+	if p.config.MaxTokens > 0 {
+		kept, err := p.applyTokenBudget(files)
+		if err != nil {
+			return err
 		}
+		omittedByBudget = len(files) - len(kept)
+		p.result.SkippedByReason[reasonTokenBudget] += omittedByBudget
+		files = kept // The tree stays complete
+	}
+
+	if p.config.CountOnly {
+		p.languageStats = computeLanguageStats(files)
+		p.countStats = p.countFiles(files)
+		p.outputFiles = nil
+		p.result.IncludedFiles = len(files)
+		p.logger.Info("Processor: Counted the included files; no output is written", "files", len(files))
 		return nil
-	})
+	}
 
-	if walkErr != nil {
-		// This error is from the WalkDir function itself or propagated from a critical error in the callback.
-		return fmt.Errorf("processor: error during file walk: %w", walkErr)
+	if len(files) == 0 {
+		p.logger.Warn("Processor: No files matched the filters; nothing to include")
+		if p.config.NoEmptyOutput {
+			p.outputFiles = nil
+			p.languageStats = nil
+			p.logger.Info("Processor: Not writing an empty output")
+			return ErrNoFiles
+		}
+	}
+
+	if err := p.checkFenceLanguages(files); err != nil {
+		return err
 	}
+	p.languageStats = computeLanguageStats(files)
 
-	// All content successfully written to tempOutFile's buffer
-	if flushErr := writer.Flush(); flushErr != nil {
-		return fmt.Errorf("processor: failed to flush writer for temporary output file: %w", flushErr)
+	// The previous output is read before it is replaced, and compared with the new one once that is written
+	var previousSections map[string]string
+	if p.config.ShowDiff && p.stream == nil {
+		if previousSections, err = p.readOutputSections(p.finalOutputFile); err != nil {
+			return err
+		}
+		if previousSections == nil {
+			p.logger.Info("Processor: No previous output to compare with", "path", p.finalOutputFile)
+		}
+	}
+
+	// Write to temporary files first to prevent data loss on error and to handle outputting to source dir.
+	// With SplitSize set, the output is distributed over numbered part files at file boundaries.
+	// Every part of the JSON formats starts with the meta members or record, so each can be parsed on its own.
+	metaOpen := p.metaOpen()
+	partOpen, partClose := "", ""
+	switch p.config.OutputFormat {
+	case OutputFormatXML:
+		partOpen, partClose = "<documents>\n", "</documents>\n\n"
+	case OutputFormatJSON:
+		partOpen, partClose = metaOpen+"\"files\":[\n", "\n]}\n"
+	case OutputFormatJSONL:
+		partOpen = metaOpen
 	}
-	if closeErr := tempOutFile.Close(); closeErr != nil { // Ensure temp file is closed before rename
-		return fmt.Errorf("processor: failed to close temporary output file '%s': %w", tempFileName, closeErr)
+	out := newPartWriter(p.finalOutputFile, p.config.SplitSize, partOpen, partClose)
+	switch {
+	case p.config.OutputFormat == OutputFormatJSON:
+		out.separator = ",\n" // One file object per line
+	case p.config.OutputFormat.isFenced():
+		out.separator = p.sectionSeparator()
+		out.sectionsClose = "\n" // A blank line before the appended text or the end of the part
 	}
+	out.singleFinalNewline = p.config.Reproducible
+	out.compress = p.config.Gzip
+	out.stream = p.stream
+	out.logger = p.logger
+	defer out.discard()
 
-	// Rename temporary file to final output file
-	slog.Debug("Processor: Attempting to rename temporary output file", "from", tempFileName, "to", p.finalOutputFile)
-	if renameErr := os.Rename(tempFileName, p.finalOutputFile); renameErr != nil {
-		slog.Warn("Processor: Rename failed, attempting copy fallback", "from", tempFileName, "to", p.finalOutputFile, "error", renameErr)
-		// Fallback to copy if rename fails (e.g., across different devices/filesystems)
-		in, readErr := os.Open(tempFileName)
-		if readErr != nil {
-			// Original temp file might still be there, don't remove if open failed.
-			return fmt.Errorf("processor: failed to open temp file '%s' for copying: %w (original rename error: %v)", tempFileName, readErr, renameErr)
+	// 0. Prepended text, e.g. an instruction header for the prompt
+	prependText, err := p.wrappingText(p.config.Prepend, "prepend")
+	if err != nil {
+		return err
+	}
+
+	// The git info, tree, and table of contents only go into the first part
+	var gitInfo *jsonGitInfo
+	gitInfoText := ""
+	if p.config.WithGitInfo {
+		if gitInfo = p.gitInfo(); gitInfo != nil {
+			gitInfoText = p.formatGitInfo(gitInfo)
 		}
-		// defer in.Close() // Not needed here as 'in' is local to this block
+	}
 
-		out, createErr := os.Create(p.finalOutputFile)
-		if createErr != nil {
-			_ = in.Close()
-			return fmt.Errorf("processor: failed to create final output file '%s' for copying: %w (original rename error: %v)", p.finalOutputFile, createErr, renameErr)
+	// 1. Generate the tree if enabled
+	treeText := ""
+	if tree != nil {
+		p.logger.Info("Generating file tree...")
+		treeText = p.formatTree(tree.String())
+	}
+	tocText := ""
+	if p.config.IncludeTOC {
+		tocText = p.formatTOC(files)
+	}
+	firstOpen, header := prependText+partOpen, gitInfoText+treeText+tocText
+	if p.config.OutputFormat == OutputFormatJSON {
+		// Prepended text, git info, tree, and table of contents become members of the first part's object, before the files array.
+		firstOpen, header = metaOpen, ""
+		if prependText != "" {
+			firstOpen += jsonMember("prepend", strings.TrimRight(prependText, "\n")) + ","
+		}
+		if gitInfo != nil {
+			firstOpen += jsonMember("git", gitInfo) + ","
+		}
+		if treeText != "" {
+			firstOpen += jsonMember("tree", treeText) + ","
 		}
-		// defer out.Close() // Not needed here
+		if p.config.IncludeTOC {
+			firstOpen += jsonMember("toc", p.tocPaths(files)) + ","
+		}
+		firstOpen += "\"files\":[\n"
+	} else if p.config.OutputFormat == OutputFormatJSONL && prependText != "" {
+		firstOpen = partOpen + jsonlLine("prepend", strings.TrimRight(prependText, "\n"))
+	}
+	if err := out.writeHeader(firstOpen, header); err != nil {
+		return asOutputWriteError(err)
+	}
+	if treeText != "" {
+		p.logger.Debug("Processor: File tree written to output.")
+	}
 
-		_, copyErr := io.Copy(out, in)
-		_ = in.Close()  // Close input file after copy attempt
-		_ = out.Close() // Close output file after copy attempt
+	// 2. Write the contents of the collected files, one complete section at a time
+	var section strings.Builder
+	var contentHash hash.Hash
+	firstPathByHash := make(map[string]string) // Content hash -> path of the first file with that content
+	if p.config.Dedupe {
+		contentHash = sha256.New()
+	}
+	for i, file := range files {
+		if err := p.ctx.Err(); err != nil {
+			return err
+		}
+		section.Reset()
+		note := p.contentNote(file)
+		if note != "" {
+			p.logger.Debug("Processor: Omitting file content by extension", "path", file.relPath)
+		}
+		fileHash := contentHash
+		if note != "" || file.info.Size() == 0 {
+			fileHash = nil // Only files with content can be duplicates
+		} else if fileHash != nil {
+			fileHash.Reset()
+		}
+		if err := p.writeFileSection(&section, file, i+1, note, fileHash, p.logger); err != nil {
+			return err
+		}
+		if fileHash != nil {
+			sum := hex.EncodeToString(fileHash.Sum(nil))
+			if firstPath, seen := firstPathByHash[sum]; seen && sum != emptyContentHash {
+				p.logger.Debug("Processor: Replacing duplicate file content with a reference", "path", file.relPath, "duplicate_of", firstPath)
+				section.Reset()
+				if err := p.writeFileSection(&section, file, i+1, "// duplicate of "+firstPath, nil, p.logger); err != nil {
+					return err
+				}
+			} else if !seen {
+				firstPathByHash[sum] = p.displayPath(file)
+			}
+		}
+		if p.config.CountTokens {
+			p.tokenCounts = append(p.tokenCounts, FileTokenCount{
+				Path:   filepath.ToSlash(file.relPath),
+				Tokens: p.config.TokenCounter.Count(section.String()),
+			})
+		}
+		if err := out.writeSection(section.String(), p.oversizedNote(file)); err != nil {
+			return asOutputWriteError(err)
+		}
+	}
 
-		if copyErr != nil {
-			return fmt.Errorf("processor: failed to copy temp file to final output file: %w (original rename error: %v)", copyErr, renameErr)
+	// 3. Appended text, e.g. closing instructions
+	appendText, err := p.wrappingText(p.config.Append, "append")
+	if err != nil {
+		return err
+	}
+	budgetNote := ""
+	if omittedByBudget > 0 {
+		budgetNote = p.tokenBudgetNote(omittedByBudget)
+	}
+	lastClose := partClose + appendText
+	switch {
+	case p.config.OutputFormat == OutputFormatJSON:
+		lastClose = "\n]"
+		if budgetNote != "" {
+			lastClose += "," + jsonMember("note", budgetNote)
+		}
+		if appendText != "" {
+			lastClose += "," + jsonMember("append", strings.TrimRight(appendText, "\n"))
+		}
+		lastClose += "}\n"
+	case p.config.OutputFormat == OutputFormatJSONL:
+		lastClose = ""
+		if budgetNote != "" {
+			lastClose += jsonlLine("note", budgetNote)
 		}
-		// If copy succeeds, remove the original temporary file
-		if removeErr := os.Remove(tempFileName); removeErr != nil {
-			slog.Warn("Processor: Failed to remove temporary output file after successful copy", "path", tempFileName, "error", removeErr)
+		if appendText != "" {
+			lastClose += jsonlLine("append", strings.TrimRight(appendText, "\n"))
 		}
+	case budgetNote != "" && p.config.OutputFormat == OutputFormatXML:
+		lastClose = "<!-- " + strings.ReplaceAll(xmlEscapeText(budgetNote), "--", "- -") + " -->\n" + lastClose
+	case budgetNote != "":
+		lastClose = budgetNote + "\n\n" + lastClose
+	}
+	if err := out.finish(lastClose); err != nil {
+		return asOutputWriteError(err)
+	}
+	p.outputFiles = out.paths()
+	for _, path := range p.outputFiles {
+		p.logger.Info("Successfully wrote output to", "file", path)
+	}
+	p.result.IncludedFiles = len(files)
+	p.result.TotalBytes = out.size()
+	p.result.OutputPath = p.finalOutputFile
+	if previousSections != nil {
+		currentSections := make(map[string]string)
+		for _, path := range p.outputFiles {
+			if err := p.readOutputFileSections(path, currentSections); err != nil {
+				return fmt.Errorf("processor: failed to read output '%s' for comparison: %w", path, err)
+			}
+		}
+		p.outputDiff = diffOutputSections(previousSections, currentSections)
+	}
+	if p.config.GitignoreOutput && p.stream == nil {
+		p.gitignoreOutput()
+	}
+	if len(files) == 0 {
+		return ErrNoFiles
 	}
-	successfulWrite = true // Mark as successful so defer doesn't remove the (now renamed or copied) temp file.
-	slog.Info("Successfully wrote output to", "file", p.finalOutputFile)
 	return nil
 }
@@ -0,0 +1,1906 @@
+package processor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alexferrari88/code2context/internal/filefilter"
+	"github.com/alexferrari88/code2context/internal/gitutils"
+	"github.com/alexferrari88/code2context/internal/utils"
+)
+
+// writeSourceFiles creates the files (slash-separated path -> content) below a new temporary
+// directory and returns its path.
+func writeSourceFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range files {
+		absPath := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(absPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(absPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// processToString runs a processor for cfg and returns its streamed output.
+func processToString(t *testing.T, cfg Config) string {
+	t.Helper()
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := p.ProcessTo(&out); err != nil {
+		t.Fatalf("ProcessTo() error = %v", err)
+	}
+	return out.String()
+}
+
+// sectionPaths returns the paths of the fenced file sections of a txt output, in order.
+func sectionPaths(output string) []string {
+	var paths []string
+	inSection := false
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case inSection && line == "```":
+			inSection = false
+		case !inSection && strings.HasPrefix(line, "```") && len(line) > 3:
+			paths = append(paths, line[3:])
+			inSection = true
+		}
+	}
+	return paths
+}
+
+func TestDiffBaseIncludesOnlyChangedFiles(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"main.go":      "package main\n",
+		"lib/util.go":  "package lib\n",
+		"lib/other.go": "package lib\n",
+		"README.md":    "# Readme\n",
+	})
+	var gotRoot, gotBase string
+	changedFiles := func(repoRoot, base string, logger *slog.Logger) ([]string, error) {
+		gotRoot, gotBase = repoRoot, base
+		return []string{"lib/util.go", "README.md"}, nil
+	}
+
+	output := processToString(t, Config{SourcePath: root, DiffBase: "main", IncludeTree: true, ChangedFiles: changedFiles})
+
+	if gotBase != "main" {
+		t.Errorf("ChangedFiles called with base %q, want %q", gotBase, "main")
+	}
+	if wantRoot, _ := filepath.Abs(root); gotRoot != wantRoot {
+		t.Errorf("ChangedFiles called with root %q, want %q", gotRoot, wantRoot)
+	}
+	if got, want := sectionPaths(output), []string{"README.md", "lib/util.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("file sections = %v, want %v", got, want)
+	}
+	tree := output[:strings.Index(output, "```")]
+	for _, name := range []string{"util.go", "README.md"} {
+		if !strings.Contains(tree, name) {
+			t.Errorf("tree is missing changed file %s:\n%s", name, tree)
+		}
+	}
+	for _, name := range []string{"main.go", "other.go"} {
+		if strings.Contains(tree, name) {
+			t.Errorf("tree contains unchanged file %s:\n%s", name, tree)
+		}
+	}
+}
+
+// stubClones sets the CloneRepo and CloneRepoCached of cfg to functions that "clone" by creating
+// parentDir/repo with a main.go, as the real ones do below their temporary or cache directory.
+func stubClones(t *testing.T, cfg *Config, parentDir string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available") // Checked before cloning
+	}
+	clone := func(ctx context.Context, repoURL, ref string, progress io.Writer, logger *slog.Logger) (string, string, error) {
+		clonePath := filepath.Join(parentDir, "repo")
+		if err := os.MkdirAll(clonePath, 0o755); err != nil {
+			return "", "", err
+		}
+		return clonePath, "repo", os.WriteFile(filepath.Join(clonePath, "main.go"), []byte("package main\n"), 0o644)
+	}
+	cfg.CloneRepo, cfg.CloneRepoCached = clone, clone
+}
+
+func TestCloneCleanup(t *testing.T) {
+	tests := []struct {
+		name          string
+		useCache      bool
+		wantRemaining bool
+	}{
+		{name: "temporary clone is removed", useCache: false, wantRemaining: false},
+		{name: "cached clone is kept", useCache: true, wantRemaining: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parentDir := filepath.Join(t.TempDir(), "clone_parent")
+			cfg := Config{SourcePath: "https://example.com/org/repo.git", UseCloneCache: tt.useCache}
+			stubClones(t, &cfg, parentDir)
+
+			output := processToString(t, cfg)
+
+			if got := sectionPaths(output); !reflect.DeepEqual(got, []string{"main.go"}) {
+				t.Errorf("file sections = %v, want [main.go]", got)
+			}
+			_, err := os.Stat(parentDir)
+			if remaining := err == nil; remaining != tt.wantRemaining {
+				t.Errorf("clone directory remaining after the run = %v, want %v", remaining, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestArchiveSource(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range []struct{ name, content string }{
+		{"project/main.go", "package main\n"},
+		{"project/docs/guide.md", "# Guide\n"},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0o644, Size: int64(len(file.content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archivePath := filepath.Join(t.TempDir(), "project.tar.gz")
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := processToString(t, Config{SourcePath: archivePath, IncludeTree: true})
+
+	if got, want := sectionPaths(output), []string{"docs/guide.md", "main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("file sections = %v, want %v", got, want)
+	}
+	for _, content := range []string{"package main\n", "# Guide\n"} {
+		if !strings.Contains(output, content) {
+			t.Errorf("output is missing the content %q", content)
+		}
+	}
+	if !strings.HasPrefix(output, "project") {
+		t.Errorf("tree root is not named after the archive's top-level directory:\n%s", output)
+	}
+}
+
+func TestTokenCountsSumToTotal(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"a.go":     "package a\n\nfunc hello() string { return \"hello world\" }\n",
+		"b/b.txt":  "hello hello hello\n",
+		"c/c/c.md": "# Title\n",
+	})
+	counter := utils.NewBPETokenCounter("test", map[string]int{"he": 1, "ll": 2, "llo": 3, "hello": 4, "pa": 5, "ck": 6})
+	p, err := New(Config{SourcePath: root, CountTokens: true, TokenCounter: counter})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := p.ProcessTo(&out); err != nil {
+		t.Fatalf("ProcessTo() error = %v", err)
+	}
+
+	counts := p.GetTokenCounts()
+	var paths []string
+	sum := 0
+	for _, count := range counts {
+		if count.Tokens <= 0 {
+			t.Errorf("token count of %s = %d, want > 0", count.Path, count.Tokens)
+		}
+		paths = append(paths, count.Path)
+		sum += count.Tokens
+	}
+	if want := []string{"a.go", "b/b.txt", "c/c/c.md"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("token counts for %v, want %v", paths, want)
+	}
+	if total := p.GetTotalTokens(); total != sum {
+		t.Errorf("GetTotalTokens() = %d, want the sum of the per-file counts %d", total, sum)
+	}
+	if total, whole := p.GetTotalTokens(), counter.Count(out.String()); total > whole {
+		t.Errorf("GetTotalTokens() = %d exceeds the count of the whole output %d", total, whole)
+	}
+}
+
+func TestSectionSeparator(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{"a.go": "package a\n", "b.go": "package b\n"})
+	separator := func(s string) *string { return &s }
+	tests := []struct {
+		name      string
+		format    OutputFormat
+		separator *string
+		want      string
+	}{
+		{name: "default is a blank line", want: "```a.go\npackage a\n```\n\n```b.go\npackage b\n```\n\n"},
+		{name: "default with explicit txt", format: OutputFormatText, want: "```a.go\npackage a\n```\n\n```b.go\npackage b\n```\n\n"},
+		{name: "custom text gets a newline", separator: separator("---"), want: "```a.go\npackage a\n```\n---\n```b.go\npackage b\n```\n\n"},
+		{name: "empty separator", separator: separator(""), want: "```a.go\npackage a\n```\n```b.go\npackage b\n```\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: root, OutputFormat: tt.format, SectionSeparator: tt.separator})
+			if output != tt.want {
+				t.Errorf("output =\n%q\nwant\n%q", output, tt.want)
+			}
+		})
+	}
+}
+
+func TestFollowSymlinks(t *testing.T) {
+	symlink := func(t *testing.T, target, link string) {
+		t.Helper()
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symbolic links are not supported: %v", err)
+		}
+	}
+	tests := []struct {
+		name  string
+		setup func(t *testing.T) string // Returns the source root
+		want  []string
+	}{
+		{
+			name: "link to a file is included",
+			setup: func(t *testing.T) string {
+				root := writeSourceFiles(t, map[string]string{"real.go": "package real\n"})
+				symlink(t, filepath.Join(root, "real.go"), filepath.Join(root, "link.go"))
+				return root
+			},
+			want: []string{"link.go", "real.go"},
+		},
+		{
+			name: "link to a directory inside the root is walked once at its real location",
+			setup: func(t *testing.T) string {
+				root := writeSourceFiles(t, map[string]string{"lib/a.go": "package lib\n"})
+				symlink(t, filepath.Join(root, "lib"), filepath.Join(root, "alias"))
+				return root
+			},
+			want: []string{"lib/a.go"},
+		},
+		{
+			name: "directory outside the root linked twice is walked once",
+			setup: func(t *testing.T) string {
+				external := writeSourceFiles(t, map[string]string{"x.go": "package x\n"})
+				root := writeSourceFiles(t, map[string]string{"main.go": "package main\n"})
+				symlink(t, external, filepath.Join(root, "one"))
+				symlink(t, external, filepath.Join(root, "two"))
+				return root
+			},
+			want: []string{"main.go", "one/x.go"},
+		},
+		{
+			name: "cycle to an ancestor terminates",
+			setup: func(t *testing.T) string {
+				root := writeSourceFiles(t, map[string]string{"d/f.go": "package d\n"})
+				symlink(t, root, filepath.Join(root, "d", "loop"))
+				return root
+			},
+			want: []string{"d/f.go"},
+		},
+		{
+			name: "cycle outside the root terminates",
+			setup: func(t *testing.T) string {
+				external := writeSourceFiles(t, map[string]string{"x.go": "package x\n"})
+				symlink(t, external, filepath.Join(external, "self"))
+				root := t.TempDir()
+				symlink(t, external, filepath.Join(root, "ext"))
+				return root
+			},
+			want: []string{"ext/x.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: tt.setup(t), FollowSymlinks: true})
+			if got := sectionPaths(output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderStats(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"main.go":    "package main\n\nfunc main() {}\n",
+		"one.txt":    "single\n",
+		"partial.md": "# Title\nno newline",
+		"big.go":     strings.Repeat(strings.Repeat("x", 63)+"\n", 48),
+		"empty.txt":  "",
+	})
+	tests := []struct {
+		name      string
+		fenceLang FenceLang
+		want      []string
+	}{
+		{
+			name: "stats",
+			want: []string{"big.go (48 lines, 3.0 KiB)", "empty.txt (0 lines, 0 B)", "main.go (3 lines, 29 B)", "one.txt (1 line, 7 B)", "partial.md (2 lines, 18 B)"},
+		},
+		{
+			name:      "with the language hint",
+			fenceLang: FenceLangAuto,
+			want:      []string{"go big.go (48 lines, 3.0 KiB)", "text empty.txt (0 lines, 0 B)", "go main.go (3 lines, 29 B)", "text one.txt (1 line, 7 B)", "markdown partial.md (2 lines, 18 B)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: root, HeaderStats: true, FenceLang: tt.fenceLang})
+			if got := sectionPaths(output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("headers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtraIgnoreFiles(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		".dockerignore":      "staging/\n",
+		".npmignore":         "fixtures/\n",
+		"main.go":            "package main\n",
+		"staging/deploy.go":  "package staging\n",
+		"fixtures/data.go":   "package fixtures\n",
+		"lib/.dockerignore":  "local.go\n",
+		"lib/local.go":       "package lib\n",
+		"lib/lib.go":         "package lib\n",
+		"other/local.go":     "package other\n",
+		"other/staging/x.go": "package staging\n",
+	})
+	tests := []struct {
+		name        string
+		ignoreFiles []string
+		want        []string
+		prunedDirs  []string // Directories missing from the tree
+	}{
+		{
+			name: "none",
+			want: []string{"fixtures/data.go", "lib/lib.go", "lib/local.go", "main.go", "other/local.go", "other/staging/x.go", "staging/deploy.go"},
+		},
+		{
+			name:        "dockerignore",
+			ignoreFiles: []string{".dockerignore"},
+			want:        []string{"fixtures/data.go", "lib/lib.go", "main.go", "other/local.go"},
+			prunedDirs:  []string{"staging"},
+		},
+		{
+			name:        "dockerignore and npmignore",
+			ignoreFiles: []string{".dockerignore", ".npmignore"},
+			want:        []string{"lib/lib.go", "main.go", "other/local.go"},
+			prunedDirs:  []string{"staging", "fixtures"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: root, IncludeTree: true, SkipHidden: true, ExtraIgnoreFiles: tt.ignoreFiles})
+			if got := sectionPaths(output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("file sections = %v, want %v", got, tt.want)
+			}
+			tree := output[:strings.Index(output, "```")]
+			for _, dir := range tt.prunedDirs {
+				if strings.Contains(tree, dir) {
+					t.Errorf("tree contains ignored directory %s:\n%s", dir, tree)
+				}
+			}
+		})
+	}
+}
+
+func TestSkipEmptyFiles(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{"empty.go": "", "one.go": "x", "sub/empty.txt": ""})
+	tests := []struct {
+		name      string
+		skipEmpty bool
+		want      []string
+	}{
+		{name: "included by default", want: []string{"empty.go", "one.go", "sub/empty.txt"}},
+		{name: "excluded", skipEmpty: true, want: []string{"one.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: root, IncludeTree: true, SkipEmptyFiles: tt.skipEmpty})
+			if got := sectionPaths(output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("file sections = %v, want %v", got, tt.want)
+			}
+			tree := output[:strings.Index(output, "```")]
+			if inTree := strings.Contains(tree, "empty.go"); inTree == tt.skipEmpty {
+				t.Errorf("tree shows empty.go = %v, want %v:\n%s", inTree, !tt.skipEmpty, tree)
+			}
+		})
+	}
+}
+
+func TestPrependAppend(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{"a.go": "package a\n", "b.go": "package b\n"})
+	textDir := t.TempDir()
+	headerFile := filepath.Join(textDir, "header.txt")
+	if err := os.WriteFile(headerFile, []byte("Review this:\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	const (
+		tree  = "proj\n├── a.go\n└── b.go\n\n\n"
+		files = "```a.go\npackage a\n```\n\n```b.go\npackage b\n```\n\n"
+	)
+	tests := []struct {
+		name            string
+		prepend, append string
+		want            string
+	}{
+		{name: "neither", want: tree + files},
+		{name: "inline text", prepend: "Review this:", append: "End.", want: "Review this:\n\n" + tree + files + "End.\n\n"},
+		{name: "trailing newlines are normalized", prepend: "Review this:\n\n\n", append: "End.\n", want: "Review this:\n\n" + tree + files + "End.\n\n"},
+		{name: "text file", prepend: headerFile, want: "Review this:\n\n" + tree + files},
+		{name: "directory is inline text", append: textDir, want: tree + files + textDir + "\n\n"},
+		{name: "only append", append: "End.", want: tree + files + "End.\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: root, IncludeTree: true, RootLabel: "proj", Prepend: tt.prepend, Append: tt.append})
+			if output != tt.want {
+				t.Errorf("output =\n%q\nwant\n%q", output, tt.want)
+			}
+		})
+	}
+}
+
+func TestOmitContentExts(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"app.min.js":    "var a=1;\n",
+		"logo.svg":      "<svg/>\n",
+		"main.go":       "package main\n",
+		"ICON.SVG":      "<svg/>\n",
+		"scripts/ui.js": "let ui;\n",
+	})
+	output := processToString(t, Config{SourcePath: root, IncludeTree: true, OmitContentExts: []string{".min.js", ".svg"}})
+	tree, sections := output[:strings.Index(output, "```")], output[strings.Index(output, "```"):]
+	want := "```ICON.SVG\n// content omitted\n```\n\n" +
+		"```app.min.js\n// content omitted\n```\n\n" +
+		"```logo.svg\n// content omitted\n```\n\n" +
+		"```main.go\npackage main\n```\n\n" +
+		"```scripts/ui.js\nlet ui;\n```\n\n"
+	if sections != want {
+		t.Errorf("sections =\n%q\nwant\n%q", sections, want)
+	}
+	for _, name := range []string{"ICON.SVG", "app.min.js", "logo.svg", "main.go", "ui.js"} {
+		if !strings.Contains(tree, name) {
+			t.Errorf("tree is missing %s:\n%s", name, tree)
+		}
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"a/util.go":   "package util\n",
+		"b/util.go":   "package util\n",
+		"c/util.go":   "package util\n",
+		"d/other.go":  "package other\n",
+		"empty1.txt":  "",
+		"empty2.txt":  "",
+		"omitted.svg": "package util\n",
+	})
+	tests := []struct {
+		name   string
+		dedupe bool
+		want   string
+	}{
+		{
+			name: "off",
+			want: "```a/util.go\npackage util\n```\n\n```b/util.go\npackage util\n```\n\n```c/util.go\npackage util\n```\n\n" +
+				"```d/other.go\npackage other\n```\n\n```empty1.txt\n```\n\n```empty2.txt\n```\n\n```omitted.svg\n// content omitted\n```\n\n",
+		},
+		{
+			name:   "duplicates reference the first file",
+			dedupe: true,
+			want: "```a/util.go\npackage util\n```\n\n```b/util.go\n// duplicate of a/util.go\n```\n\n```c/util.go\n// duplicate of a/util.go\n```\n\n" +
+				"```d/other.go\npackage other\n```\n\n```empty1.txt\n```\n\n```empty2.txt\n```\n\n```omitted.svg\n// content omitted\n```\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: root, IncludeTree: true, Dedupe: tt.dedupe, OmitContentExts: []string{".svg"}})
+			tree, sections := output[:strings.Index(output, "```")], output[strings.Index(output, "```"):]
+			if sections != tt.want {
+				t.Errorf("sections =\n%q\nwant\n%q", sections, tt.want)
+			}
+			if strings.Count(tree, "util.go") != 3 {
+				t.Errorf("tree does not list all three copies of util.go:\n%s", tree)
+			}
+		})
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{"top.go": "1\n", "a/one.go": "2\n", "a/b/two.go": "3\n", "a/b/c/three.go": "4\n"})
+	tests := []struct {
+		depth        int
+		wantTree     string
+		wantSections []string
+	}{
+		{
+			depth:        1,
+			wantTree:     "r\n├── a\n└── top.go\n",
+			wantSections: []string{"top.go"},
+		},
+		{
+			depth:        2,
+			wantTree:     "r\n├── a\n│   ├── b\n│   └── one.go\n└── top.go\n",
+			wantSections: []string{"a/one.go", "top.go"},
+		},
+		{
+			depth:        3,
+			wantTree:     "r\n├── a\n│   ├── b\n│   │   ├── c\n│   │   └── two.go\n│   └── one.go\n└── top.go\n",
+			wantSections: []string{"a/b/two.go", "a/one.go", "top.go"},
+		},
+		{
+			depth:        0, // Unlimited
+			wantTree:     "r\n├── a\n│   ├── b\n│   │   ├── c\n│   │   │   └── three.go\n│   │   └── two.go\n│   └── one.go\n└── top.go\n",
+			wantSections: []string{"a/b/c/three.go", "a/b/two.go", "a/one.go", "top.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("depth %d", tt.depth), func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: root, MaxDepth: tt.depth, IncludeTree: true, RootLabel: "r"})
+			if tree := output[:strings.Index(output, "\n\n")+1]; tree != tt.wantTree {
+				t.Errorf("tree =\n%s\nwant\n%s", tree, tt.wantTree)
+			}
+			if got := sectionPaths(output); !reflect.DeepEqual(got, tt.wantSections) {
+				t.Errorf("file sections = %v, want %v", got, tt.wantSections)
+			}
+		})
+	}
+}
+
+func TestLanguageStats(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"main.go":      strings.Repeat("g", 100),
+		"lib/util.go":  strings.Repeat("g", 50),
+		"app.py":       strings.Repeat("p", 120),
+		"stubs.pyi":    strings.Repeat("p", 10),
+		"data.unknown": strings.Repeat("u", 30),
+		"Makefile":     strings.Repeat("m", 20),
+		"tie.rs":       strings.Repeat("r", 30),
+	})
+	p, err := New(Config{SourcePath: root})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := p.ProcessTo(io.Discard); err != nil {
+		t.Fatalf("ProcessTo() error = %v", err)
+	}
+	want := []LanguageStats{
+		{Language: "go", Files: 2, Bytes: 150},
+		{Language: "python", Files: 2, Bytes: 130},
+		{Language: "other", Files: 2, Bytes: 50},
+		{Language: "rust", Files: 1, Bytes: 30},
+	}
+	if got := p.GetLanguageStats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetLanguageStats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestComputeLanguageStats(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{"a.go": "12345", "b.rs": "12345", "c.txt": "123"})
+	var files []includedFile
+	for _, name := range []string{"a.go", "b.rs", "c.txt"} {
+		info, err := os.Stat(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, includedFile{relPath: name, info: info})
+	}
+	// Equal sizes are ordered by language name
+	want := []LanguageStats{{Language: "go", Files: 1, Bytes: 5}, {Language: "rust", Files: 1, Bytes: 5}, {Language: "text", Files: 1, Bytes: 3}}
+	if got := computeLanguageStats(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("computeLanguageStats() = %+v, want %+v", got, want)
+	}
+	if got := computeLanguageStats(nil); len(got) != 0 {
+		t.Errorf("computeLanguageStats(nil) = %+v, want none", got)
+	}
+}
+
+func TestCollapseBlankLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		collapse bool
+		want     string
+	}{
+		{name: "off keeps the content", content: "a\n\n\n\nb\n", want: "a\n\n\n\nb\n"},
+		{name: "triple blank lines", content: "a\n\n\n\nb\n", collapse: true, want: "a\n\nb\n"},
+		{name: "single blank line is kept", content: "a\n\nb\n", collapse: true, want: "a\n\nb\n"},
+		{name: "several runs", content: "a\n\n\nb\n\n\n\n\nc\n", collapse: true, want: "a\n\nb\n\nc\n"},
+		{name: "whitespace-only lines are blank", content: "a\n  \n\t\n\nb\n", collapse: true, want: "a\n  \nb\n"},
+		{name: "indentation is untouched", content: "func f() {\n\tx := 1\n\n\n\treturn\n}\n", collapse: true, want: "func f() {\n\tx := 1\n\n\treturn\n}\n"},
+		{name: "leading and trailing runs", content: "\n\n\na\n\n\n", collapse: true, want: "\na\n\n"},
+		{name: "no final newline", content: "a\n\n\nb", collapse: true, want: "a\n\nb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeSourceFiles(t, map[string]string{"file.txt": tt.content})
+			output := processToString(t, Config{SourcePath: root, CollapseBlankLines: tt.collapse})
+			if want := "```file.txt\n" + tt.want + "```\n\n"; output != want {
+				t.Errorf("output = %q, want %q", output, want)
+			}
+		})
+	}
+}
+
+func TestFilesFrom(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"a.go":      "package a\n",
+		"b.go":      "package b\n",
+		"c/d.go":    "package d\n",
+		"large.txt": strings.Repeat("x", 100),
+	})
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "two of three", manifest: "a.go\nc/d.go\n", want: []string{"a.go", "c/d.go"}},
+		{name: "blank lines and spaces", manifest: "\n  b.go  \r\n\n", want: []string{"b.go"}},
+		{name: "absolute path inside the source", manifest: filepath.Join(absRoot, "c", "d.go") + "\n", want: []string{"c/d.go"}},
+		{name: "filters still apply", manifest: "a.go\nlarge.txt\n", want: []string{"a.go"}},
+		{name: "missing file is skipped", manifest: "a.go\nmissing.go\n", want: []string{"a.go"}},
+		{name: "empty list includes nothing", manifest: "\n", want: nil},
+		{name: "relative path outside the source", manifest: "a.go\n../outside.go\n", wantErr: true},
+		{name: "absolute path outside the source", manifest: filepath.Join(filepath.Dir(absRoot), "outside.go") + "\n", wantErr: true},
+		{name: "the source itself", manifest: ".\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestPath := filepath.Join(t.TempDir(), "files.txt")
+			if err := os.WriteFile(manifestPath, []byte(tt.manifest), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			p, err := New(Config{SourcePath: root, FilesFrom: manifestPath, IncludeTree: true, MaxFileSize: 50})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			var out bytes.Buffer
+			err = p.ProcessTo(&out)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "outside") {
+					t.Errorf("ProcessTo() error = %v, want a path outside the source error", err)
+				}
+				return
+			}
+			if err != nil && !errors.Is(err, ErrNoFiles) {
+				t.Fatalf("ProcessTo() error = %v", err)
+			}
+			output := out.String()
+			if got := sectionPaths(output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("file sections = %v, want %v", got, tt.want)
+			}
+			if len(tt.want) == 0 {
+				return
+			}
+			tree := output[:strings.Index(output, "```")]
+			for _, name := range []string{"a.go", "b.go", "d.go", "large.txt"} {
+				listed := slices.ContainsFunc(tt.want, func(path string) bool { return filepath.Base(path) == name })
+				if inTree := strings.Contains(tree, name); inTree != listed {
+					t.Errorf("tree shows %s = %v, want %v:\n%s", name, inTree, listed, tree)
+				}
+			}
+		})
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	files := map[string]string{
+		".gitignore":     "*.log\n",
+		"a/.gitignore":   "tmp/\n",
+		"a/x.go":         "package a   \r\nfunc X() {}\t\r\n",
+		"a/tmp/skip.go":  "package tmp\n",
+		"b/y.txt":        "trailing spaces   \n\n\n",
+		"b/c/z.md":       "# Title\nno final newline  ",
+		"debug.log":      "log\n",
+		"main.go":        "package main\n",
+		"zz/deep/w.json": "{\"a\": 1}\n",
+	}
+	root := writeSourceFiles(t, files)
+	cfg := Config{SourcePath: root, IncludeTree: true, IncludeTOC: true, Reproducible: true, Prepend: "Header\n\n", Append: "Footer\n\n\n"}
+
+	first := processToString(t, cfg)
+	for i := 0; i < 5; i++ {
+		if again := processToString(t, cfg); again != first {
+			t.Fatalf("run %d differs from the first run:\n%q\nwant\n%q", i+2, again, first)
+		}
+	}
+
+	if !strings.HasSuffix(first, "Footer\n") || strings.HasSuffix(first, "\n\n") {
+		t.Errorf("output does not end with a single newline: %q", first[len(first)-20:])
+	}
+	for i, line := range strings.Split(first, "\n") {
+		if trimmed := strings.TrimRight(line, " \t\r"); trimmed != line {
+			t.Errorf("line %d has trailing whitespace: %q", i+1, line)
+		}
+	}
+	for _, want := range []string{"```a/x.go\npackage a\nfunc X() {}\n```", "```b/c/z.md\n# Title\nno final newline\n```"} {
+		if !strings.Contains(first, want) {
+			t.Errorf("output is missing the normalized section %q", want)
+		}
+	}
+
+	// Without Reproducible trailing whitespace is kept
+	if plain := processToString(t, Config{SourcePath: root}); !strings.Contains(plain, "package a   \n") {
+		t.Errorf("output without Reproducible lost trailing whitespace:\n%q", plain)
+	}
+
+	// The JSON formats leave out the generation time
+	jsonCfg := Config{SourcePath: root, OutputFormat: OutputFormatJSON, Reproducible: true}
+	jsonOutput := processToString(t, jsonCfg)
+	if strings.Contains(jsonOutput, "generated_at") {
+		t.Errorf("reproducible JSON output contains generated_at:\n%s", jsonOutput)
+	}
+	if again := processToString(t, jsonCfg); again != jsonOutput {
+		t.Errorf("JSON run differs from the first run:\n%s\nwant\n%s", again, jsonOutput)
+	}
+}
+
+func TestGitDirsArePruned(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"main.go":                         "package main\n",
+		"mirror/HEAD":                     "ref: refs/heads/main\n",
+		"mirror/objects/pack/pack-1.pack": "PACK",
+		"mirror/refs/heads/main":          "0000\n",
+		"notes/HEAD":                      "not a git dir\n",
+		"notes/refs/todo.txt":             "todo\n",
+	})
+	output := processToString(t, Config{SourcePath: root, IncludeTree: true})
+	want := []string{"main.go", "notes/HEAD", "notes/refs/todo.txt"}
+	if got := sectionPaths(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("sections = %v, want %v", got, want)
+	}
+	if tree := output[:strings.Index(output, "```")]; strings.Contains(tree, "mirror") {
+		t.Errorf("tree lists the git dir:\n%s", tree)
+	}
+}
+
+func TestValidUTF8(t *testing.T) {
+	const invalid = "ok line\nbad \xed\xa0\x80 surrogate and \xff\xfe bytes\n"
+	root := writeSourceFiles(t, map[string]string{"bad.txt": invalid, "good.txt": "héllo\n"})
+	tests := []struct {
+		name      string
+		validUTF8 bool
+		want      string
+	}{
+		{name: "unchanged by default", want: invalid},
+		{name: "sanitized", validUTF8: true, want: "ok line\nbad � surrogate and � bytes\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			output := processToString(t, Config{
+				SourcePath: root,
+				ValidUTF8:  tt.validUTF8,
+				Logger:     slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelWarn})),
+			})
+			if !strings.Contains(output, "```bad.txt\n"+tt.want+"```") {
+				t.Errorf("output =\n%q\nwant the content %q", output, tt.want)
+			}
+			if got := utf8.ValidString(output); got != tt.validUTF8 {
+				t.Errorf("output is valid UTF-8 = %v, want %v", got, tt.validUTF8)
+			}
+			if !strings.Contains(logs.String(), "not valid UTF-8") || !strings.Contains(logs.String(), "path=bad.txt") {
+				t.Errorf("logs = %q, want a warning for bad.txt", logs.String())
+			}
+			if strings.Contains(logs.String(), "path=good.txt") {
+				t.Errorf("logs = %q, want no warning for good.txt", logs.String())
+			}
+		})
+	}
+}
+
+func TestCloneWithoutGit(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	cloned := false
+	clone := func(ctx context.Context, repoURL, ref string, progress io.Writer, logger *slog.Logger) (string, string, error) {
+		cloned = true
+		return "", "", errors.New("clone must not be attempted")
+	}
+	for _, useCache := range []bool{false, true} {
+		p, err := New(Config{SourcePath: "https://example.com/org/repo.git", UseCloneCache: useCache, CloneRepo: clone, CloneRepoCached: clone})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		err = p.ProcessTo(io.Discard)
+		if !errors.Is(err, gitutils.ErrGitNotInstalled) {
+			t.Errorf("ProcessTo() with UseCloneCache %v error = %v, want ErrGitNotInstalled", useCache, err)
+		}
+	}
+	if cloned {
+		t.Error("clone was attempted without git")
+	}
+}
+
+func TestFullTree(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"main.go":               "package main\n",
+		"debug.log":             "log line\n",
+		"src/app.go":            "package src\n",
+		"src/trace.log":         "log line\n",
+		"node_modules/x/y.js":   "module.exports = {}\n",
+		".git/HEAD":             "ref: refs/heads/main\n",
+		"mirror/HEAD":           "ref: refs/heads/main\n",
+		"mirror/objects/x":      "x",
+		"mirror/refs/heads/foo": "0000\n",
+	})
+	tests := []struct {
+		name     string
+		fullTree bool
+		wantTree string
+	}{
+		{
+			name:     "filtered tree",
+			wantTree: "proj\n├── src\n│   └── app.go\n└── main.go\n",
+		},
+		{
+			name:     "full tree",
+			fullTree: true,
+			wantTree: "proj\n├── node_modules (excluded)\n├── src\n│   ├── app.go\n│   └── trace.log (excluded)\n├── debug.log (excluded)\n└── main.go\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{
+				SourcePath:         root,
+				RootLabel:          "proj",
+				IncludeTree:        true,
+				FullTree:           tt.fullTree,
+				UserExcludeExts:    []string{".log"},
+				DefaultExcludeDirs: []string{".git", "node_modules"},
+			})
+			if tree := output[:strings.Index(output, "\n\n")+1]; tree != tt.wantTree {
+				t.Errorf("tree =\n%s\nwant\n%s", tree, tt.wantTree)
+			}
+			if got, want := sectionPaths(output), []string{"main.go", "src/app.go"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("sections = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestOnlyTracked(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		".gitignore":          "*.tmp\n",
+		"main.go":             "package main\n",
+		"build/out.bin.go":    "package build\n",
+		"lib/deep/nested.go":  "package deep\n",
+		"lib/deep/scratch.go": "package deep\n",
+		"ignored.tmp":         "tracked despite .gitignore\n",
+		"notes/untracked.txt": "untracked\n",
+	})
+	var gotRoot string
+	trackedFiles := func(repoRoot string, logger *slog.Logger) ([]string, error) {
+		gotRoot = repoRoot
+		return []string{".gitignore", "main.go", "lib/deep/nested.go", "ignored.tmp", "deleted.go"}, nil
+	}
+
+	output := processToString(t, Config{SourcePath: root, OnlyTracked: true, IncludeTree: true, TrackedFiles: trackedFiles})
+
+	if wantRoot, _ := filepath.Abs(root); gotRoot != wantRoot {
+		t.Errorf("TrackedFiles called with root %q, want %q", gotRoot, wantRoot)
+	}
+	// .gitignore is not applied: git decided which files are tracked
+	if got, want := sectionPaths(output), []string{".gitignore", "ignored.tmp", "lib/deep/nested.go", "main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("file sections = %v, want %v", got, want)
+	}
+	tree := output[:strings.Index(output, "```")]
+	for _, name := range []string{"build", "scratch.go", "notes", "deleted.go"} {
+		if strings.Contains(tree, name) {
+			t.Errorf("tree contains untracked %s:\n%s", name, tree)
+		}
+	}
+
+	t.Run("with diff base", func(t *testing.T) {
+		changedFiles := func(repoRoot, base string, logger *slog.Logger) ([]string, error) {
+			return []string{"main.go", "lib/deep/scratch.go"}, nil
+		}
+		output := processToString(t, Config{SourcePath: root, OnlyTracked: true, DiffBase: "main", TrackedFiles: trackedFiles, ChangedFiles: changedFiles})
+		if got, want := sectionPaths(output), []string{"main.go"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("file sections = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("not a repository", func(t *testing.T) {
+		notRepository := func(string, *slog.Logger) ([]string, error) {
+			return nil, errors.New("not a git repository")
+		}
+		p, err := New(Config{SourcePath: root, OnlyTracked: true, TrackedFiles: notRepository})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if err := p.ProcessTo(io.Discard); err == nil || !strings.Contains(err.Error(), "--only-tracked requires a git repository") {
+			t.Errorf("ProcessTo() error = %v, want the tracked files error", err)
+		}
+	})
+}
+
+func TestAncestorGitignore(t *testing.T) {
+	repo := writeSourceFiles(t, map[string]string{
+		".git/HEAD":              "ref: refs/heads/main\n",
+		".gitignore":             "*.log\n/top.txt\ngenerated/\n",
+		"pkg/.gitignore":         "*.tmp\n!keep.log\n",
+		"pkg/sub/main.go":        "package sub\n",
+		"pkg/sub/debug.log":      "log\n",
+		"pkg/sub/keep.log":       "kept\n",
+		"pkg/sub/cache.tmp":      "tmp\n",
+		"pkg/sub/top.txt":        "anchored to the repo root, so not ignored\n",
+		"pkg/sub/generated/x.go": "package generated\n",
+	})
+	source := filepath.Join(repo, "pkg", "sub")
+	tests := []struct {
+		name        string
+		useAncestor bool
+		extra       Config
+		want        []string
+	}{
+		{
+			name:        "ancestor ignores apply",
+			useAncestor: true,
+			want:        []string{"keep.log", "main.go", "top.txt"},
+		},
+		{
+			name: "disabled",
+			want: []string{"cache.tmp", "debug.log", "generated/x.go", "keep.log", "main.go", "top.txt"},
+		},
+		{
+			name:        "not with IncludeGitignored",
+			useAncestor: true,
+			extra:       Config{IncludeGitignored: true},
+			want:        []string{"cache.tmp", "debug.log", "generated/x.go", "keep.log", "main.go", "top.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.extra
+			cfg.SourcePath = source
+			cfg.UseAncestorGitignore = tt.useAncestor
+			if got := sectionPaths(processToString(t, cfg)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("file sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("outside a repository", func(t *testing.T) {
+		parent := writeSourceFiles(t, map[string]string{".gitignore": "*.log\n", "src/main.go": "package main\n", "src/debug.log": "log\n"})
+		output := processToString(t, Config{SourcePath: filepath.Join(parent, "src"), UseAncestorGitignore: true})
+		if got, want := sectionPaths(output), []string{"debug.log", "main.go"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("file sections = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestRenderNotebooks(t *testing.T) {
+	const notebook = `{"cells": [` +
+		`{"cell_type": "markdown", "source": ["# Analysis"]},` +
+		`{"cell_type": "code", "source": ["x = 1\n", "print(x)"], "outputs": [{"data": {"image/png": "iVBORw0KGgo="}}]}` +
+		`], "nbformat": 4}`
+	root := writeSourceFiles(t, map[string]string{
+		"analysis.ipynb": notebook,
+		"broken.ipynb":   "{not json\n",
+		"main.py":        "print('hi')\n",
+	})
+	tests := []struct {
+		name         string
+		render       bool
+		markdown     bool
+		wantNotebook string
+	}{
+		{name: "raw by default", wantNotebook: notebook + "\n"},
+		{name: "code cells", render: true, wantNotebook: "# %%\nx = 1\nprint(x)\n"},
+		{name: "with markdown", render: true, markdown: true, wantNotebook: "# %% [markdown]\n# # Analysis\n\n# %%\nx = 1\nprint(x)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: root, RenderNotebooks: tt.render, NotebookMarkdown: tt.markdown})
+			if !strings.Contains(output, "```analysis.ipynb\n"+tt.wantNotebook+"```") {
+				t.Errorf("output =\n%s\nwant the notebook as\n%s", output, tt.wantNotebook)
+			}
+			if !strings.Contains(output, "```broken.ipynb\n{not json\n```") {
+				t.Errorf("output =\n%s\nwant the invalid notebook unchanged", output)
+			}
+			if !strings.Contains(output, "```main.py\nprint('hi')\n```") {
+				t.Errorf("output =\n%s\nwant other files unchanged", output)
+			}
+		})
+	}
+}
+
+func TestRepoRoot(t *testing.T) {
+	parent := writeSourceFiles(t, map[string]string{
+		"project/.git/HEAD":         "ref: refs/heads/main\n",
+		"project/main.go":           "package main\n",
+		"project/pkg/sub/helper.go": "package sub\n",
+		"loose/dir/file.go":         "package dir\n",
+	})
+	tests := []struct {
+		name     string
+		cwd      string
+		source   string
+		fallback bool
+		want     []string
+		wantTree string // First line of the tree
+		wantErr  bool
+	}{
+		{name: "nested cwd", cwd: "project/pkg/sub", source: ".", want: []string{"main.go", "pkg/sub/helper.go"}, wantTree: "project"},
+		{name: "nested source", source: "project/pkg", want: []string{"main.go", "pkg/sub/helper.go"}, wantTree: "project"},
+		{name: "repository root", source: "project", want: []string{"main.go", "pkg/sub/helper.go"}, wantTree: "project"},
+		{name: "outside a repository", source: "loose/dir", wantErr: true},
+		{name: "outside a repository with fallback", source: "loose/dir", fallback: true, want: []string{"file.go"}, wantTree: "dir"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.source
+			if tt.cwd != "" {
+				t.Chdir(filepath.Join(parent, filepath.FromSlash(tt.cwd)))
+			} else {
+				source = filepath.Join(parent, filepath.FromSlash(tt.source))
+			}
+			p, err := New(Config{SourcePath: source, RepoRoot: true, RepoRootFallback: tt.fallback, IncludeTree: true, DefaultExcludeDirs: []string{".git"}})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			var out strings.Builder
+			err = p.ProcessTo(&out)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "not inside a git repository") {
+					t.Errorf("ProcessTo() error = %v, want the not-a-repository error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessTo() error = %v", err)
+			}
+			output := out.String()
+			if got := sectionPaths(output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+			if firstLine, _, _ := strings.Cut(output, "\n"); firstLine != tt.wantTree {
+				t.Errorf("tree root = %q, want %q", firstLine, tt.wantTree)
+			}
+		})
+	}
+}
+
+func TestContextInclude(t *testing.T) {
+	files := map[string]string{
+		"src/app.go":          "package src\n",
+		"src/deep/nested.go":  "package deep\n",
+		"src/debug.log":       "log\n",
+		"README.md":           "# Readme\n",
+		"docs/guide.md":       "# Guide\n",
+		"main.go":             "package main\n",
+		"scripts/build.sh":    "#!/bin/sh\n",
+		"src/generated/x.go":  "package generated\n",
+		".gitignore":          "src/generated/\n",
+		"tools/src/helper.go": "package src\n",
+	}
+	tests := []struct {
+		name    string
+		include string // Content of .contextinclude; none if empty
+		extra   Config
+		want    []string
+	}{
+		{
+			name: "no include file",
+			want: []string{".gitignore", "README.md", "docs/guide.md", "main.go", "scripts/build.sh", "src/app.go", "src/debug.log", "src/deep/nested.go", "tools/src/helper.go"},
+		},
+		{
+			name:    "allowlist",
+			include: "# Code and docs only\nsrc/**\n\n*.md\n",
+			want:    []string{"README.md", "docs/guide.md", "src/app.go", "src/debug.log", "src/deep/nested.go"},
+		},
+		{
+			name:    "deny wins over allow",
+			include: "src/**\n*.md\n",
+			extra:   Config{UserExcludeExts: []string{".log"}, UserExcludeDirs: []string{"docs"}},
+			want:    []string{"README.md", "src/app.go", "src/deep/nested.go"},
+		},
+		{
+			name:    "only comments",
+			include: "# nothing yet\n",
+			want:    []string{".contextinclude", ".gitignore", "README.md", "docs/guide.md", "main.go", "scripts/build.sh", "src/app.go", "src/debug.log", "src/deep/nested.go", "tools/src/helper.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			treeFiles := maps.Clone(files)
+			if tt.include != "" {
+				treeFiles[".contextinclude"] = tt.include
+			}
+			cfg := tt.extra
+			cfg.SourcePath = writeSourceFiles(t, treeFiles)
+			if got := sectionPaths(processToString(t, cfg)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithGitInfo(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{"main.go": "package main\n"})
+	info := gitutils.Info{RemoteURL: "https://example.com/org/repo.git", Commit: "0123456789abcdef0123456789abcdef01234567", Ref: "main", Dirty: true}
+	var infoErr error
+	var gotRoot string
+	repoInfo := func(repoRoot string, logger *slog.Logger) (gitutils.Info, error) {
+		gotRoot = repoRoot
+		return info, infoErr
+	}
+
+	const header = "Repository: https://example.com/org/repo.git\nCommit: 0123456789abcdef0123456789abcdef01234567\nRef: main\nDirty: true\n\n"
+	tests := []struct {
+		name   string
+		cfg    Config
+		prefix string
+	}{
+		{name: "text", cfg: Config{IncludeTree: true}, prefix: header + filepath.Base(root) + "\n"},
+		{name: "no tree", prefix: header + "```main.go\n"},
+		{
+			name:   "xml",
+			cfg:    Config{OutputFormat: OutputFormatXML},
+			prefix: "<git_info>\n<repository>https://example.com/org/repo.git</repository>\n<commit>0123456789abcdef0123456789abcdef01234567</commit>\n<ref>main</ref>\n<dirty>true</dirty>\n</git_info>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.SourcePath, cfg.WithGitInfo, cfg.RepoInfo = root, true, repoInfo
+			output := processToString(t, cfg)
+			if !strings.Contains(output, tt.prefix) {
+				t.Errorf("output =\n%s\nwant it to contain\n%s", output, tt.prefix)
+			}
+		})
+	}
+	if wantRoot, _ := filepath.Abs(root); gotRoot != wantRoot {
+		t.Errorf("RepoInfo called with %q, want %q", gotRoot, wantRoot)
+	}
+
+	t.Run("json", func(t *testing.T) {
+		output := processToString(t, Config{SourcePath: root, WithGitInfo: true, OutputFormat: OutputFormatJSON, RepoInfo: repoInfo})
+		var document struct {
+			Git jsonGitInfo `json:"git"`
+		}
+		if err := json.Unmarshal([]byte(output), &document); err != nil {
+			t.Fatalf("output is not valid JSON: %v", err)
+		}
+		want := jsonGitInfo{Repository: info.RemoteURL, Commit: info.Commit, Ref: info.Ref, Dirty: true}
+		if document.Git != want {
+			t.Errorf("git = %+v, want %+v", document.Git, want)
+		}
+	})
+
+	t.Run("local repository without remote", func(t *testing.T) {
+		info.RemoteURL, info.Dirty = "", false
+		defer func() { info.RemoteURL, info.Dirty = "https://example.com/org/repo.git", true }()
+		output := processToString(t, Config{SourcePath: root, WithGitInfo: true, RepoInfo: repoInfo})
+		if !strings.HasPrefix(output, "Commit: 0123456789abcdef0123456789abcdef01234567\nRef: main\nDirty: false\n\n") {
+			t.Errorf("output =\n%s\nwant a header without repository", output)
+		}
+	})
+
+	t.Run("requested ref of a clone", func(t *testing.T) {
+		cfg := Config{SourcePath: "https://example.com/org/repo.git", GitRef: "v1.2.0", WithGitInfo: true, RepoInfo: repoInfo}
+		stubClones(t, &cfg, filepath.Join(t.TempDir(), "clone_parent"))
+		info.Ref = "HEAD"
+		defer func() { info.Ref = "main" }()
+		output := processToString(t, cfg)
+		if !strings.Contains(output, "Ref: v1.2.0\n") {
+			t.Errorf("output =\n%s\nwant the requested ref", output)
+		}
+	})
+
+	t.Run("not a repository", func(t *testing.T) {
+		infoErr = errors.New("not a git repository")
+		defer func() { infoErr = nil }()
+		output := processToString(t, Config{SourcePath: root, WithGitInfo: true, RepoInfo: repoInfo})
+		if !strings.HasPrefix(output, "```main.go\n") {
+			t.Errorf("output =\n%s\nwant no git header", output)
+		}
+	})
+
+	t.Run("off by default", func(t *testing.T) {
+		if output := processToString(t, Config{SourcePath: root, RepoInfo: repoInfo}); strings.Contains(output, "Commit:") {
+			t.Errorf("output =\n%s\nwant no git header", output)
+		}
+	})
+}
+
+func TestDropOutliers(t *testing.T) {
+	files := map[string]string{"huge.go": strings.Repeat("x", 100_000)}
+	for i := range 6 {
+		files[fmt.Sprintf("small%d.go", i)] = strings.Repeat("y", 100+i*10)
+	}
+	root := writeSourceFiles(t, files)
+	tests := []struct {
+		name         string
+		dropOutliers bool
+		wantHuge     bool
+		wantSkipped  int
+	}{
+		{name: "enabled", dropOutliers: true, wantSkipped: 1},
+		{name: "disabled", wantHuge: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(Config{SourcePath: root, IncludeTree: true, DropOutliers: tt.dropOutliers})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			var out bytes.Buffer
+			if err := p.ProcessTo(&out); err != nil {
+				t.Fatalf("ProcessTo() error = %v", err)
+			}
+			output := out.String()
+			tree := output[:strings.Index(output, "```")]
+			if got := strings.Contains(output, "```huge.go\n"); got != tt.wantHuge {
+				t.Errorf("huge.go included = %t, want %t", got, tt.wantHuge)
+			}
+			if got := strings.Contains(tree, "huge.go"); got != tt.wantHuge {
+				t.Errorf("huge.go in the tree = %t, want %t", got, tt.wantHuge)
+			}
+			for i := range 6 {
+				if name := fmt.Sprintf("small%d.go", i); !strings.Contains(output, "```"+name+"\n") {
+					t.Errorf("%s not included", name)
+				}
+			}
+			if got := p.GetResult().SkippedByReason[reasonOutlier]; got != tt.wantSkipped {
+				t.Errorf("SkippedByReason[%q] = %d, want %d", reasonOutlier, got, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestLogsAndProgressStayWithTheEmbedder(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{"main.go": "package main\n"})
+
+	// Anything written to the process-wide stderr or the default logger is a leak.
+	stderr, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalStderr := os.Stderr
+	os.Stderr = stderr
+	t.Cleanup(func() { os.Stderr = originalStderr; stderr.Close() })
+	var defaultLogs bytes.Buffer
+	originalDefault := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&defaultLogs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(originalDefault) })
+
+	tests := []struct {
+		name         string
+		withLogger   bool
+		wantMessages []string
+	}{
+		{
+			name:       "custom logger",
+			withLogger: true,
+			wantMessages: []string{
+				"Processing local path",                   // From the processor
+				"Filter: Skipping the output file itself", // From the file filter
+			},
+		},
+		{name: "no logger"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs, progress bytes.Buffer
+			cfg := Config{SourcePath: root, OutputFile: filepath.Join(root, "ctx.txt"), ShowProgress: true, Stderr: &progress}
+			if tt.withLogger {
+				cfg.Logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			}
+			for range 2 { // The second run sees its own output file
+				p, err := New(cfg)
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				if err := p.Process(); err != nil {
+					t.Fatalf("Process() error = %v", err)
+				}
+			}
+			for _, message := range tt.wantMessages {
+				if !strings.Contains(logs.String(), message) {
+					t.Errorf("logs = %q, want %q", logs.String(), message)
+				}
+			}
+			if !strings.Contains(progress.String(), "Scanned 2 files, included 1") {
+				t.Errorf("progress = %q, want the walk summary", progress.String())
+			}
+			if defaultLogs.Len() > 0 {
+				t.Errorf("default logger received %q, want nothing", defaultLogs.String())
+			}
+			if leaked, _ := os.ReadFile(stderr.Name()); len(leaked) > 0 {
+				t.Errorf("stderr = %q, want nothing", leaked)
+			}
+		})
+	}
+
+	t.Run("clone progress", func(t *testing.T) {
+		var progress bytes.Buffer
+		var gotProgress io.Writer
+		cfg := Config{SourcePath: "https://example.com/org/repo.git", ShowProgress: true, Stderr: &progress}
+		stubClones(t, &cfg, filepath.Join(t.TempDir(), "clone_parent"))
+		clone := cfg.CloneRepo
+		cfg.CloneRepo = func(ctx context.Context, repoURL, ref string, w io.Writer, logger *slog.Logger) (string, string, error) {
+			gotProgress = w
+			return clone(ctx, repoURL, ref, w, logger)
+		}
+		processToString(t, cfg)
+		if gotProgress != &progress {
+			t.Errorf("CloneRepo progress writer = %v, want Config.Stderr", gotProgress)
+		}
+	})
+}
+
+func TestProcessResult(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		".gitignore":          "*.env\n",
+		"main.go":             "package main\n",
+		"logo.png":            "png",
+		"big.txt":             strings.Repeat("x", 2048),
+		"secret.env":          "TOKEN=1\n",
+		"node_modules/a/a.js": "module.exports = {}\n",
+		"node_modules/b/b.js": "module.exports = {}\n",
+	})
+	outputPath := filepath.Join(t.TempDir(), "ctx.txt")
+	p, err := New(Config{
+		SourcePath:         root,
+		OutputFile:         outputPath,
+		MaxFileSize:        1024,
+		DefaultMediaExts:   []string{".png"},
+		DefaultExcludeDirs: []string{"node_modules"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := p.Process(); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ProcessResult{
+		IncludedFiles: 2, // main.go and .gitignore
+		SkippedByReason: map[string]int{
+			string(filefilter.ReasonMedia):       1,
+			string(filefilter.ReasonTooLarge):    1,
+			string(filefilter.ReasonIgnored):     1,
+			string(filefilter.ReasonExcludedDir): 1, // node_modules counts once: its contents are not visited
+		},
+		TotalBytes: info.Size(),
+		OutputPath: outputPath,
+	}
+	if got := p.GetResult(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetResult() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGitignoreOutput(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         map[string]string
+		output        string // Relative to the source; empty means outside it
+		splitSize     int64
+		gitignorePath string // Relative to the source
+		want          string // Content of the .gitignore after two runs; empty means it does not exist
+	}{
+		{
+			name:          "created next to the output",
+			output:        "ctx.txt",
+			gitignorePath: ".gitignore",
+			want:          "/ctx.txt\n",
+		},
+		{
+			name:          "appended to the nearest one",
+			files:         map[string]string{".gitignore": "*.log", "sub/.keep": ""},
+			output:        "sub/out/ctx.txt",
+			gitignorePath: ".gitignore",
+			want:          "*.log\n/sub/out/ctx.txt\n",
+		},
+		{
+			name:          "already listed",
+			files:         map[string]string{".gitignore": "/ctx.txt\n*.log\n"},
+			output:        "ctx.txt",
+			gitignorePath: ".gitignore",
+			want:          "/ctx.txt\n*.log\n",
+		},
+		{
+			name:          "split parts",
+			output:        "ctx.txt",
+			splitSize:     1 << 20,
+			gitignorePath: ".gitignore",
+			want:          "/ctx.part*.txt\n",
+		},
+		{
+			name:          "outside the source",
+			gitignorePath: ".gitignore",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{"main.go": "package main\n"}
+			maps.Copy(files, tt.files)
+			root := writeSourceFiles(t, files)
+			outputPath := filepath.Join(t.TempDir(), "ctx.txt")
+			if tt.output != "" {
+				outputPath = filepath.Join(root, tt.output)
+				if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for range 2 { // The entry is added once
+				p, err := New(Config{SourcePath: root, OutputFile: outputPath, GitignoreOutput: true, SplitSize: tt.splitSize})
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				if err := p.Process(); err != nil {
+					t.Fatalf("Process() error = %v", err)
+				}
+			}
+			got, err := os.ReadFile(filepath.Join(root, tt.gitignorePath))
+			if tt.want == "" {
+				if !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf(".gitignore = %q, error %v, want no .gitignore", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf(".gitignore = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("streamed", func(t *testing.T) {
+		root := writeSourceFiles(t, map[string]string{"main.go": "package main\n"})
+		processToString(t, Config{SourcePath: root, GitignoreOutput: true})
+		if _, err := os.Stat(filepath.Join(root, ".gitignore")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Stat(.gitignore) error = %v, want no .gitignore for a stream", err)
+		}
+	})
+}
+
+func TestExcludeContentRegex(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"generated.go": "// GENERATED by a tool\npackage main\n",
+		"main.go":      "package main\n",
+		"late.go":      "package main\n" + strings.Repeat("//\n", 64*1024/3) + "// GENERATED past the first 64 KiB\n",
+	})
+	p, err := New(Config{SourcePath: root, IncludeTree: true, ExcludeContentRegex: "GENERATED"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := p.ProcessTo(&out); err != nil {
+		t.Fatalf("ProcessTo() error = %v", err)
+	}
+	output := out.String()
+	if got, want := sectionPaths(output), []string{"late.go", "main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("file sections = %v, want %v", got, want)
+	}
+	if tree := output[:strings.Index(output, "```")]; strings.Contains(tree, "generated.go") {
+		t.Errorf("tree contains the excluded generated.go:\n%s", tree)
+	}
+	if got := p.GetResult().SkippedByReason[string(filefilter.ReasonContent)]; got != 1 {
+		t.Errorf("SkippedByReason[%q] = %d, want 1", filefilter.ReasonContent, got)
+	}
+
+	p, err = New(Config{SourcePath: root, ExcludeContentRegex: "GENERATED("})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := p.ProcessTo(io.Discard); err == nil {
+		t.Error("ProcessTo() with an invalid content regex error = nil, want an error")
+	}
+}
+
+func TestNoFiles(t *testing.T) {
+	filtered := writeSourceFiles(t, map[string]string{"main.go": "package main\n", "lib/util.go": "package lib\n"})
+	tests := []struct {
+		name       string
+		cfg        Config
+		wantErr    error
+		wantOutput bool
+	}{
+		{name: "empty directory", cfg: Config{SourcePath: t.TempDir()}, wantErr: ErrNoFiles, wantOutput: true},
+		{name: "everything excluded", cfg: Config{SourcePath: filtered, UserExcludeExts: []string{".go"}}, wantErr: ErrNoFiles, wantOutput: true},
+		{name: "empty directory without empty output", cfg: Config{SourcePath: t.TempDir(), NoEmptyOutput: true}, wantErr: ErrNoFiles},
+		{name: "everything excluded without empty output", cfg: Config{SourcePath: filtered, UserExcludeExts: []string{".go"}, NoEmptyOutput: true}, wantErr: ErrNoFiles},
+		{name: "files included", cfg: Config{SourcePath: filtered, NoEmptyOutput: true}, wantOutput: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.OutputFile, cfg.IncludeTree = filepath.Join(t.TempDir(), "ctx.txt"), true
+			p, err := New(cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if err := p.Process(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Process() error = %v, want %v", err, tt.wantErr)
+			}
+			_, statErr := os.Stat(cfg.OutputFile)
+			if wrote := statErr == nil; wrote != tt.wantOutput {
+				t.Errorf("output written = %v, want %v", wrote, tt.wantOutput)
+			}
+			if got := p.GetOutputFiles(); tt.wantOutput != (len(got) == 1) {
+				t.Errorf("GetOutputFiles() = %v, want the output only if written", got)
+			}
+		})
+	}
+}
+
+func TestRelativeTo(t *testing.T) {
+	monorepo := writeSourceFiles(t, map[string]string{
+		"services/api/main.go":             "package main\n",
+		"services/api/internal/handler.go": "package internal\n",
+		"services/web/app.js":              "console.log('hi')\n",
+	})
+	api := filepath.Join(monorepo, "services", "api")
+	tests := []struct {
+		name       string
+		relativeTo string
+		wantTree   string
+		wantPaths  []string
+	}{
+		{
+			name:       "parent of the source",
+			relativeTo: monorepo,
+			wantTree:   "services/api\n├── internal\n│   └── handler.go\n└── main.go\n",
+			wantPaths:  []string{"services/api/internal/handler.go", "services/api/main.go"},
+		},
+		{
+			name:       "the source itself",
+			relativeTo: api,
+			wantTree:   "api\n├── internal\n│   └── handler.go\n└── main.go\n",
+			wantPaths:  []string{"internal/handler.go", "main.go"},
+		},
+		{
+			name:      "unset",
+			wantTree:  "api\n├── internal\n│   └── handler.go\n└── main.go\n",
+			wantPaths: []string{"internal/handler.go", "main.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: api, RelativeTo: tt.relativeTo, IncludeTree: true})
+			if tree := output[:strings.Index(output, "\n\n")+1]; tree != tt.wantTree {
+				t.Errorf("tree =\n%s\nwant\n%s", tree, tt.wantTree)
+			}
+			if got := sectionPaths(output); !reflect.DeepEqual(got, tt.wantPaths) {
+				t.Errorf("section paths = %v, want %v", got, tt.wantPaths)
+			}
+		})
+	}
+
+	t.Run("not containing the source", func(t *testing.T) {
+		for _, relativeTo := range []string{filepath.Join(monorepo, "services", "web"), filepath.Join(api, "internal")} {
+			p, err := New(Config{SourcePath: api, RelativeTo: relativeTo})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if err := p.ProcessTo(io.Discard); err == nil || !strings.Contains(err.Error(), "is not inside") {
+				t.Errorf("ProcessTo() with RelativeTo %s error = %v, want a not inside error", relativeTo, err)
+			}
+		}
+	})
+
+	t.Run("cloned source", func(t *testing.T) {
+		cfg := Config{SourcePath: "https://example.com/org/repo.git", RelativeTo: monorepo}
+		stubClones(t, &cfg, filepath.Join(t.TempDir(), "clone_parent"))
+		p, err := New(cfg)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if err := p.ProcessTo(io.Discard); err == nil || !strings.Contains(err.Error(), "require a local source") {
+			t.Errorf("ProcessTo() error = %v, want a local source error", err)
+		}
+	})
+}
+
+func TestCountOnly(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"main.go":     "package main\n",               // 13 bytes, 4 tokens
+		"lib/util.go": "package lib\n\nfunc F() {}\n", // 25 bytes, 7 tokens
+		"app.py":      "print('hi')\n",                // 12 bytes, 3 tokens
+		"logo.png":    "png",
+	})
+	outputPath := filepath.Join(t.TempDir(), "ctx.txt")
+	p, err := New(Config{SourcePath: root, OutputFile: outputPath, IncludeTree: true, CountOnly: true, DefaultMediaExts: []string{".png"}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := p.Process(); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	want := CountStats{
+		Files:  3,
+		Bytes:  50,
+		Tokens: 14,
+		Languages: []LanguageStats{
+			{Language: "go", Files: 2, Bytes: 38},
+			{Language: "python", Files: 1, Bytes: 12},
+		},
+		Skipped: map[string]int{string(filefilter.ReasonMedia): 1},
+	}
+	if got := p.GetCountStats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCountStats() = %+v, want %+v", got, want)
+	}
+	wantTokens := []FileTokenCount{{Path: "app.py", Tokens: 3}, {Path: "lib/util.go", Tokens: 7}, {Path: "main.go", Tokens: 4}}
+	if got := p.GetTokenCounts(); !reflect.DeepEqual(got, wantTokens) {
+		t.Errorf("GetTokenCounts() = %v, want %v", got, wantTokens)
+	}
+	if _, err := os.Stat(outputPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(output) error = %v, want no output file", err)
+	}
+	if got := p.GetOutputFiles(); len(got) != 0 {
+		t.Errorf("GetOutputFiles() = %v, want none", got)
+	}
+}
+
+func TestIsDangerousRoot(t *testing.T) {
+	home := writeSourceFiles(t, map[string]string{"project/main.go": "package main\n"})
+	t.Setenv("HOME", home)
+	homeLink := filepath.Join(t.TempDir(), "home-link")
+	if err := os.Symlink(home, homeLink); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{name: "filesystem root", path: string(filepath.Separator), want: true},
+		{name: "home directory", path: home, want: true},
+		{name: "home directory through a link", path: homeLink, want: true},
+		{name: "project in the home directory", path: filepath.Join(home, "project"), want: false},
+		{name: "parent of the home directory", path: filepath.Dir(home), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDangerousRoot(tt.path); got != tt.want {
+				t.Errorf("isDangerousRoot(%s) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDangerousRootRequiresForce(t *testing.T) {
+	home := writeSourceFiles(t, map[string]string{"main.go": "package main\n"})
+	t.Setenv("HOME", home)
+	tests := []struct {
+		name    string
+		source  string
+		force   bool
+		wantErr bool
+	}{
+		{name: "filesystem root", source: string(filepath.Separator), wantErr: true},
+		{name: "home directory", source: home, wantErr: true},
+		{name: "home directory with force", source: home, force: true},
+		{name: "project directory", source: writeSourceFiles(t, map[string]string{"main.go": "package main\n"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(Config{SourcePath: tt.source, Force: tt.force})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			var out bytes.Buffer
+			err = p.ProcessTo(&out)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "refusing to process") || !strings.Contains(err.Error(), "--force") {
+					t.Errorf("ProcessTo() error = %v, want a refusal mentioning --force", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessTo() error = %v", err)
+			}
+			if got := sectionPaths(out.String()); !reflect.DeepEqual(got, []string{"main.go"}) {
+				t.Errorf("file sections = %v, want [main.go]", got)
+			}
+		})
+	}
+}
+
+func TestFollowSymlinksDedupesWorkspacePackages(t *testing.T) {
+	// A pnpm-like workspace: an external package store linked from two apps, and an internal package
+	// linked from the same apps.
+	store := writeSourceFiles(t, map[string]string{"lodash/index.js": "export const lodash = 1;\n"})
+	root := writeSourceFiles(t, map[string]string{
+		"packages/ui/index.js": "export const ui = 1;\n",
+		"apps/web/main.js":     "import { ui } from 'ui';\n",
+		"apps/admin/main.js":   "import { ui } from 'ui';\n",
+	})
+	for _, app := range []string{"web", "admin"} {
+		for link, target := range map[string]string{"ui": filepath.Join(root, "packages", "ui"), "lodash": filepath.Join(store, "lodash")} {
+			if err := os.MkdirAll(filepath.Join(root, "apps", app, "deps"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(target, filepath.Join(root, "apps", app, "deps", link)); err != nil {
+				t.Skipf("symbolic links are not supported: %v", err)
+			}
+		}
+	}
+
+	output := processToString(t, Config{SourcePath: root, FollowSymlinks: true, IncludeTree: true})
+
+	for _, content := range []string{"export const ui = 1;", "export const lodash = 1;"} {
+		if count := strings.Count(output, content); count != 1 {
+			t.Errorf("%q appears %d times, want once", content, count)
+		}
+	}
+	want := []string{"apps/admin/deps/lodash/index.js", "apps/admin/main.js", "apps/web/main.js", "packages/ui/index.js"}
+	if got := sectionPaths(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("file sections = %v, want %v", got, want)
+	}
+	if tree := output[:strings.Index(output, "```")]; strings.Count(tree, "index.js") != 2 {
+		t.Errorf("tree =\n%s\nwant each package's index.js once", tree)
+	}
+}
+
+func TestOwnVendorPrefixes(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"main.go":                            "package main\n",
+		"vendor/modules.txt":                 "# github.com/me/lib v1.0.0\n",
+		"vendor/github.com/me/lib/lib.go":    "package lib\n",
+		"vendor/github.com/me/lib/sub/x.go":  "package sub\n",
+		"vendor/github.com/other/lib/lib.go": "package lib\n",
+		"vendor/golang.org/x/text/text.go":   "package text\n",
+	})
+	output := processToString(t, Config{
+		SourcePath:         root,
+		RootLabel:          "proj",
+		IncludeTree:        true,
+		DefaultExcludeDirs: []string{"vendor"},
+		OwnVendorPrefixes:  []string{"github.com/me"},
+	})
+	want := []string{"main.go", "vendor/github.com/me/lib/lib.go", "vendor/github.com/me/lib/sub/x.go"}
+	if got := sectionPaths(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("file sections = %v, want %v", got, want)
+	}
+	wantTree := "proj\n├── vendor\n│   └── github.com\n│       └── me\n│           └── lib\n│               ├── sub\n│               │   └── x.go\n│               └── lib.go\n└── main.go\n"
+	if tree := output[:strings.Index(output, "\n\n")+1]; tree != wantTree {
+		t.Errorf("tree =\n%s\nwant\n%s", tree, wantTree)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	files := map[string]string{}
+	for i := range 20 {
+		files[fmt.Sprintf("file%02d.go", i)] = "package main\n"
+	}
+	root := writeSourceFiles(t, files)
+	slowFilter := func(absPath string, d fs.DirEntry) (bool, bool, bool) {
+		time.Sleep(10 * time.Millisecond)
+		return false, false, false
+	}
+	blockingClone := func(ctx context.Context, repoURL, ref string, progress io.Writer, logger *slog.Logger) (string, string, error) {
+		<-ctx.Done()
+		return "", "", ctx.Err()
+	}
+	tests := []struct {
+		name        string
+		cfg         Config
+		wantTimeout bool
+	}{
+		{name: "slow walk", cfg: Config{SourcePath: root, CustomExclude: slowFilter, Timeout: 50 * time.Millisecond}, wantTimeout: true},
+		{name: "slow clone", cfg: Config{SourcePath: "https://example.com/org/repo.git", CloneRepo: blockingClone, Timeout: 50 * time.Millisecond}, wantTimeout: true},
+		{name: "in time", cfg: Config{SourcePath: root, CustomExclude: slowFilter, Timeout: time.Minute}},
+		{name: "no timeout", cfg: Config{SourcePath: root, CustomExclude: slowFilter}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cfg.CloneRepo != nil {
+				if _, err := exec.LookPath("git"); err != nil {
+					t.Skip("git not available") // Checked before cloning
+				}
+			}
+			outputDir := t.TempDir()
+			cfg := tt.cfg
+			cfg.OutputFile = filepath.Join(outputDir, "ctx.txt")
+			p, err := New(cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			err = p.Process()
+			if got := errors.Is(err, ErrTimeout); got != tt.wantTimeout {
+				t.Fatalf("Process() error = %v, want ErrTimeout %v", err, tt.wantTimeout)
+			}
+			if !tt.wantTimeout {
+				if err != nil {
+					t.Fatalf("Process() error = %v", err)
+				}
+				return
+			}
+			entries, err := os.ReadDir(outputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("output directory has %d entries (e.g. %s), want no partial output", len(entries), entries[0].Name())
+			}
+		})
+	}
+}
+
+func TestRespectExportIgnore(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		".gitattributes":    "docs/ export-ignore\n*.snap export-ignore\n/app/fixtures export-ignore\n*.go text eol=lf\n",
+		".git/HEAD":         "ref: refs/heads/main\n",
+		"main.go":           "package main\n",
+		"docs/guide.md":     "# Guide\n",
+		"app/app.go":        "package app\n",
+		"app/app.snap":      "snapshot\n",
+		"app/fixtures/a.go": "package fixtures\n",
+		"app/docs/notes.md": "# Notes\n", // docs/ matches at any depth
+	})
+	tests := []struct {
+		name        string
+		source      string // Relative to root
+		respect     bool
+		want        []string
+		wantSkipped int
+	}{
+		{name: "off", respect: false, want: []string{".gitattributes", "app/app.go", "app/app.snap", "app/docs/notes.md", "app/fixtures/a.go", "docs/guide.md", "main.go"}},
+		{name: "on", respect: true, want: []string{".gitattributes", "app/app.go", "main.go"}, wantSkipped: 4},
+		{name: "subdirectory of the work tree", source: "app", respect: true, want: []string{"app.go"}, wantSkipped: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(Config{SourcePath: filepath.Join(root, tt.source), RespectExportIgnore: tt.respect, DefaultExcludeDirs: []string{".git"}})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			var out bytes.Buffer
+			if err := p.ProcessTo(&out); err != nil {
+				t.Fatalf("ProcessTo() error = %v", err)
+			}
+			if got := sectionPaths(out.String()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+			if got := p.GetResult().SkippedByReason[string(filefilter.ReasonExportIgnore)]; got != tt.wantSkipped {
+				t.Errorf("SkippedByReason[%q] = %d, want %d", filefilter.ReasonExportIgnore, got, tt.wantSkipped)
+			}
+		})
+	}
+}
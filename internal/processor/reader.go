@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// contextReader is the read end of the pipe a background Process run writes the output to.
+type contextReader struct {
+	*io.PipeReader
+	done chan struct{} // Closed when the Process run has returned (and cleaned up)
+}
+
+// Close stops reading; a run still in progress fails on its next write and cleans up
+// (e.g. removes a temporary clone) before Close returns.
+func (r *contextReader) Close() error {
+	err := r.PipeReader.Close()
+	<-r.done
+	return err
+}
+
+// checkStreamable rejects the options that cannot be combined with streaming the output.
+func checkStreamable(cfg Config) error {
+	if cfg.SplitSize > 0 {
+		return errors.New("processor: split output cannot be streamed")
+	}
+	if cfg.Interactive {
+		return errors.New("processor: interactive selection cannot be combined with streaming")
+	}
+	if cfg.Watch {
+		return errors.New("processor: watch mode cannot be combined with streaming")
+	}
+	return nil
+}
+
+// ProcessTo generates the output like Process, but writes it to w instead of a file.
+// Splitting (SplitSize), interactive selection, and watch mode are not supported when streaming.
+func (p *Processor) ProcessTo(w io.Writer) error {
+	if err := checkStreamable(p.config); err != nil {
+		return err
+	}
+	p.stream = w
+	defer func() { p.stream = nil }()
+	return p.process()
+}
+
+// NewReader starts generating the context for cfg in the background and returns a reader
+// for the output, so it can be streamed (e.g. into an HTTP request body) without being buffered
+// or written to a file. Once the output is read completely, Read returns io.EOF if the run
+// succeeded, or the error it failed with. The reader must be closed; closing it early aborts the run.
+// The same options as for ProcessTo are unsupported.
+func NewReader(cfg Config) (io.ReadCloser, error) {
+	if err := checkStreamable(cfg); err != nil {
+		return nil, err
+	}
+	p, err := New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("processor: failed to create processor: %w", err)
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	reader := &contextReader{PipeReader: pipeReader, done: make(chan struct{})}
+	go func() {
+		defer close(reader.done)
+		pipeWriter.CloseWithError(p.ProcessTo(pipeWriter)) // A nil error makes Read return io.EOF
+	}()
+	return reader, nil
+}
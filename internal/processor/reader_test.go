@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// closeWithin closes r, failing the test if Close does not return within the timeout (i.e. deadlocks).
+func closeWithin(t *testing.T, r io.Closer, timeout time.Duration) {
+	t.Helper()
+	closed := make(chan struct{})
+	go func() {
+		r.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(timeout):
+		t.Fatal("Close() did not return")
+	}
+}
+
+// waitForGoroutines fails the test unless the number of goroutines drops back to at most want.
+func waitForGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines = %d, want at most %d (leaked producer?)", runtime.NumGoroutine(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	files := map[string]string{}
+	for i := range 200 {
+		files[fmt.Sprintf("pkg%02d/file%03d.go", i%10, i)] = "package pkg\n\n// " + strings.Repeat("filler ", 100) + "\n"
+	}
+	root := writeSourceFiles(t, files)
+	cfg := Config{SourcePath: root, IncludeTree: true}
+
+	t.Run("read to EOF", func(t *testing.T) {
+		want := processToString(t, cfg)
+		r, err := NewReader(cfg)
+		if err != nil {
+			t.Fatalf("NewReader() error = %v", err)
+		}
+		defer r.Close()
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("ReadAll() error = %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("read %d bytes, want the %d bytes of ProcessTo", len(got), len(want))
+		}
+	})
+
+	t.Run("read to EOF with a file output", func(t *testing.T) {
+		outputPath := filepath.Join(t.TempDir(), "out.txt")
+		p, err := New(Config{SourcePath: root, IncludeTree: true, OutputFile: outputPath})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if err := p.Process(); err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+		want, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewReader(cfg)
+		if err != nil {
+			t.Fatalf("NewReader() error = %v", err)
+		}
+		defer r.Close()
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("ReadAll() error = %v", err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("read %d bytes, want the %d bytes written by Process", len(got), len(want))
+		}
+	})
+
+	t.Run("close early", func(t *testing.T) {
+		baseline := runtime.NumGoroutine()
+		r, err := NewReader(cfg)
+		if err != nil {
+			t.Fatalf("NewReader() error = %v", err)
+		}
+		if _, err := io.ReadFull(r, make([]byte, 100)); err != nil {
+			t.Fatalf("ReadFull() error = %v", err)
+		}
+		closeWithin(t, r, 5*time.Second)
+		waitForGoroutines(t, baseline)
+		if _, err := r.Read(make([]byte, 1)); !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("Read() after Close error = %v, want io.ErrClosedPipe", err)
+		}
+	})
+
+	t.Run("close without reading", func(t *testing.T) {
+		baseline := runtime.NumGoroutine()
+		r, err := NewReader(cfg)
+		if err != nil {
+			t.Fatalf("NewReader() error = %v", err)
+		}
+		closeWithin(t, r, 5*time.Second)
+		waitForGoroutines(t, baseline)
+	})
+
+	t.Run("generation error", func(t *testing.T) {
+		r, err := NewReader(Config{SourcePath: filepath.Join(root, "missing")})
+		if err != nil {
+			t.Fatalf("NewReader() error = %v", err)
+		}
+		defer r.Close()
+		if _, err := io.ReadAll(r); !errors.Is(err, ErrSourceNotFound) {
+			t.Errorf("ReadAll() error = %v, want ErrSourceNotFound", err)
+		}
+	})
+
+	t.Run("unstreamable options", func(t *testing.T) {
+		for _, cfg := range []Config{
+			{SourcePath: root, SplitSize: 1000},
+			{SourcePath: root, Interactive: true},
+			{SourcePath: root, Watch: true},
+		} {
+			if r, err := NewReader(cfg); err == nil {
+				r.Close()
+				t.Errorf("NewReader(%+v) error = nil, want an error", cfg)
+			}
+		}
+	})
+}
+
+func TestNewReaderCleansUpCloneOnEarlyClose(t *testing.T) {
+	parentDir := filepath.Join(t.TempDir(), "clone_parent")
+	cfg := Config{SourcePath: "https://example.com/org/repo.git", IncludeTree: true}
+	stubClones(t, &cfg, parentDir)
+	r, err := NewReader(cfg)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if _, err := io.ReadFull(r, make([]byte, 1)); err != nil {
+		t.Fatalf("ReadFull() error = %v", err)
+	}
+	closeWithin(t, r, 5*time.Second)
+	if _, err := os.Stat(parentDir); !os.IsNotExist(err) {
+		t.Errorf("clone directory after Close: Stat() error = %v, want it removed", err)
+	}
+}
@@ -0,0 +1,126 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alexferrari88/code2context/internal/utils"
+)
+
+// frameHeaderRegex matches the line that starts a file in the framed input read with FromStdin:
+// "=== path ===". The file's content is everything up to the next such line or the end of input.
+var frameHeaderRegex = regexp.MustCompile(`^=== (.+) ===$`)
+
+// framedFile is a file read from the framed input.
+type framedFile struct {
+	path    string // Slash-separated and relative
+	content []byte
+}
+
+// parseFramedFiles reads the files framed by "=== path ===" lines from r. Paths must be relative and
+// stay inside the (virtual) root; blank lines before the first header are ignored, other content is an error.
+func parseFramedFiles(r io.Reader) ([]framedFile, error) {
+	var files []framedFile
+	seen := make(map[string]bool)
+	reader := bufio.NewReader(r)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, fmt.Errorf("processor: failed to read framed input: %w", readErr)
+		}
+		if match := frameHeaderRegex.FindStringSubmatch(strings.TrimRight(line, "\r\n")); match != nil {
+			framedPath, err := cleanFramedPath(match[1])
+			if err != nil {
+				return nil, err
+			}
+			if seen[framedPath] {
+				return nil, fmt.Errorf("processor: file '%s' appears twice in the framed input", framedPath)
+			}
+			seen[framedPath] = true
+			files = append(files, framedFile{path: framedPath, content: []byte{}})
+		} else if len(files) > 0 {
+			current := &files[len(files)-1]
+			current.content = append(current.content, line...)
+		} else if strings.TrimSpace(line) != "" {
+			return nil, errors.New("processor: framed input must start with a '=== path ===' line")
+		}
+		if readErr == io.EOF {
+			return files, nil
+		}
+	}
+}
+
+// cleanFramedPath validates a path declared in the framed input and returns it cleaned.
+func cleanFramedPath(rawPath string) (string, error) {
+	cleaned := path.Clean(strings.TrimSpace(filepath.ToSlash(rawPath)))
+	if path.IsAbs(cleaned) || filepath.IsAbs(rawPath) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("processor: framed input path '%s' must be relative and must not point outside the root", rawPath)
+	}
+	return cleaned, nil
+}
+
+// readStdinFiles reads the files framed on stdin (see parseFramedFiles) as included files below basePath,
+// in input order. If tree is not nil, they are added to it along with their directories.
+func (p *Processor) readStdinFiles(tree *treeBuilder) ([]includedFile, error) {
+	framed, err := parseFramedFiles(p.config.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	p.logger.Info("Read files from stdin", "count", len(framed))
+	files := make([]includedFile, 0, len(framed))
+	for _, frame := range framed {
+		relPath := filepath.FromSlash(frame.path)
+		absPath := filepath.Join(p.basePath, relPath)
+		if tree != nil {
+			tree.addFilePath(absPath)
+		}
+		files = append(files, includedFile{
+			absPath: absPath,
+			relPath: relPath,
+			info:    framedFileInfo{name: path.Base(frame.path), size: int64(len(frame.content))},
+			content: frame.content,
+		})
+	}
+	return files, nil
+}
+
+// framedFileInfo describes a file read from the framed input: a regular file without a modification time.
+type framedFileInfo struct {
+	name string
+	size int64
+}
+
+func (fi framedFileInfo) Name() string       { return fi.name }
+func (fi framedFileInfo) Size() int64        { return fi.size }
+func (fi framedFileInfo) Mode() fs.FileMode  { return 0o644 }
+func (fi framedFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi framedFileInfo) IsDir() bool        { return false }
+func (fi framedFileInfo) Sys() any           { return nil }
+
+// open returns the file's content: the framed content if it was read from stdin, or the file on disk.
+func (file includedFile) open() (io.ReadCloser, error) {
+	if file.content != nil {
+		return io.NopCloser(bytes.NewReader(file.content)), nil
+	}
+	return os.Open(file.absPath)
+}
+
+// lineCount returns the number of lines of the file's content (see utils.CountLines).
+func (file includedFile) lineCount() (int, error) {
+	content, err := file.open()
+	if err != nil {
+		return 0, err
+	}
+	defer content.Close()
+	return utils.CountLinesFrom(content)
+}
@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseFramedFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantPaths []string
+		wantErr   string
+	}{
+		{name: "two files", input: "=== a.go ===\npackage a\n=== b/c.py ===\nprint(1)\n", wantPaths: []string{"a.go", "b/c.py"}},
+		{name: "leading blank lines", input: "\n\n=== a.go ===\n", wantPaths: []string{"a.go"}},
+		{name: "path is cleaned", input: "=== ./x/../y.txt ===\n", wantPaths: []string{"y.txt"}},
+		{name: "content before the first header", input: "stray\n=== a.go ===\n", wantErr: "must start with"},
+		{name: "duplicate path", input: "=== a.go ===\n=== ./a.go ===\n", wantErr: "appears twice"},
+		{name: "path outside the root", input: "=== ../etc/passwd ===\n", wantErr: "must be relative"},
+		{name: "absolute path", input: "=== /etc/passwd ===\n", wantErr: "must be relative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := parseFramedFiles(strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseFramedFiles() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFramedFiles() error = %v", err)
+			}
+			var paths []string
+			for _, file := range files {
+				paths = append(paths, file.path)
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.wantPaths, ",") {
+				t.Errorf("parseFramedFiles() paths = %v, want %v", paths, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestFromStdinWritesFencedSections(t *testing.T) {
+	input := "=== main.go ===\npackage main\n\nfunc main() {}\n=== scripts/build.py ===\nprint(\"build\")\n"
+	tests := []struct {
+		name      string
+		fenceLang FenceLang
+		want      string
+	}{
+		{
+			name: "plain headers",
+			want: "```main.go\npackage main\n\nfunc main() {}\n```\n\n```scripts/build.py\nprint(\"build\")\n```\n\n",
+		},
+		{
+			name:      "languages from the declared paths",
+			fenceLang: FenceLangAuto,
+			want:      "```go main.go\npackage main\n\nfunc main() {}\n```\n\n```python scripts/build.py\nprint(\"build\")\n```\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{FromStdin: true, IncludeTree: true, Stdin: bytes.NewBufferString(input), FenceLang: tt.fenceLang})
+
+			if !strings.HasSuffix(output, tt.want) {
+				t.Errorf("output does not end with the file sections\n%q\ngot\n%q", tt.want, output)
+			}
+			tree := strings.TrimSuffix(output, tt.want)
+			for _, name := range []string{"main.go", "scripts", "build.py"} {
+				if !strings.Contains(tree, name) {
+					t.Errorf("tree is missing %s:\n%s", name, tree)
+				}
+			}
+			if strings.Contains(tree, "stdin_test.go") {
+				t.Errorf("tree lists the working directory, which must not be read:\n%s", tree)
+			}
+		})
+	}
+}
+
+func TestFilesFromStdinReader(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{"a.go": "package a\n", "b.go": "package b\n", "c/d.go": "package d\n"})
+
+	output := processToString(t, Config{SourcePath: root, FilesFrom: "-", Stdin: strings.NewReader("c/d.go\n\nb.go\n")})
+
+	if got, want := sectionPaths(output), []string{"b.go", "c/d.go"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("file sections = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Transformer rewrites the content of a file before it is written. path is the file's slash-separated
+// relative path, so a transformer can pick the files it applies to and return the others unchanged.
+// An error skips the file's content: a note with the error is written instead.
+type Transformer func(path string, content []byte) ([]byte, error)
+
+// PrettyJSON is a Transformer that indents the content of .json files by two spaces.
+// Other files, and .json files that are not valid JSON, are returned unchanged.
+func PrettyJSON(filePath string, content []byte) ([]byte, error) {
+	if !strings.EqualFold(path.Ext(filePath), ".json") {
+		return content, nil
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, bytes.TrimSpace(content), "", "  "); err != nil {
+		return content, nil
+	}
+	indented.WriteByte('\n')
+	return indented.Bytes(), nil
+}
+
+// transform passes the content of the file at relPath through the configured transformers, in order.
+func (p *Processor) transform(relPath string, content []byte) ([]byte, error) {
+	slashPath := filepath.ToSlash(relPath)
+	for _, transformer := range p.config.Transformers {
+		var err error
+		if content, err = transformer(slashPath, content); err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPrettyJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		want    string
+	}{
+		{name: "minified object", path: "data.json", content: `{"a":1,"b":[true,null]}`, want: "{\n  \"a\": 1,\n  \"b\": [\n    true,\n    null\n  ]\n}\n"},
+		{name: "surrounding whitespace", path: "dir/X.JSON", content: "\n [1,2] \n", want: "[\n  1,\n  2\n]\n"},
+		{name: "invalid json is unchanged", path: "bad.json", content: `{"a":`, want: `{"a":`},
+		{name: "other extensions are unchanged", path: "data.txt", content: `{"a":1}`, want: `{"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PrettyJSON(tt.path, []byte(tt.content))
+			if err != nil {
+				t.Fatalf("PrettyJSON() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("PrettyJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformers(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"a.txt":      "a\n",
+		"sub/b.txt":  "b\n",
+		"data.json":  `{"k":"v"}`,
+		"secret.txt": "secret\n",
+	})
+	var seenPaths []string
+	recordPath := func(path string, content []byte) ([]byte, error) {
+		seenPaths = append(seenPaths, path)
+		return content, nil
+	}
+	appendText := func(text string) Transformer {
+		return func(path string, content []byte) ([]byte, error) {
+			return append(content, text...), nil
+		}
+	}
+	failOnSecret := func(path string, content []byte) ([]byte, error) {
+		if path == "secret.txt" {
+			return nil, errors.New("refusing secrets")
+		}
+		return content, nil
+	}
+
+	output := processToString(t, Config{
+		SourcePath:   root,
+		Transformers: []Transformer{recordPath, failOnSecret, PrettyJSON, appendText("first\n"), appendText("second\n")},
+	})
+
+	for _, want := range []string{
+		"```a.txt\na\nfirst\nsecond\n```",                        // Applied in order
+		"```sub/b.txt\nb\nfirst\nsecond\n```",                    // Slash-separated paths
+		"```data.json\n{\n  \"k\": \"v\"\n}\nfirst\nsecond\n```", // Built-in before the others
+		"```secret.txt\n// Error transforming file 'secret.txt': refusing secrets\n```",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain\n%s\ngot\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "secret\n") {
+		t.Errorf("content of the file that failed to transform was written:\n%s", output)
+	}
+	if got := strings.Join(seenPaths, ","); got != "a.txt,data.json,secret.txt,sub/b.txt" {
+		t.Errorf("transformed paths = %s, want every included file once", got)
+	}
+}
@@ -1,16 +1,14 @@
 package processor
 
 import (
-	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/alexferrari88/code2context/internal/filefilter"
-	gitignore "github.com/sabhiram/go-gitignore"
 )
 
 const (
@@ -20,134 +18,173 @@ const (
 	treePrefixEmpty    = "    "
 )
 
-type TreeBuilder struct {
-	basePath             string
-	filter               *filefilter.FileFilter
-	gitIgnoreCache       map[string]*gitignore.GitIgnore                    // Shared cache from Processor
-	compileGitIgnoreFunc func(dirPath string) (*gitignore.GitIgnore, error) // Function to compile/get from cache
-}
+// TreeSort selects the order of the entries at every level of the tree.
+type TreeSort string
 
-func NewTreeBuilder(
-	basePath string,
-	filter *filefilter.FileFilter,
-	cache map[string]*gitignore.GitIgnore,
-	compileFunc func(dirPath string) (*gitignore.GitIgnore, error),
-) *TreeBuilder {
-	return &TreeBuilder{
-		basePath:             basePath,
-		filter:               filter,
-		gitIgnoreCache:       cache, // Use the shared cache
-		compileGitIgnoreFunc: compileFunc,
+const (
+	TreeSortDirsFirst  TreeSort = "dirs-first"  // Directories, then files, each alphanumerically (default)
+	TreeSortFilesFirst TreeSort = "files-first" // Files, then directories, each alphanumerically
+	TreeSortAlpha      TreeSort = "alpha"       // Directories and files mixed, alphanumerically
+)
+
+// ParseTreeSort validates a --tree-sort value. An empty value selects TreeSortDirsFirst.
+func ParseTreeSort(value string) (TreeSort, error) {
+	switch order := TreeSort(strings.ToLower(strings.TrimSpace(value))); order {
+	case "":
+		return TreeSortDirsFirst, nil
+	case TreeSortDirsFirst, TreeSortFilesFirst, TreeSortAlpha:
+		return order, nil
+	default:
+		return "", fmt.Errorf("unknown tree sort order '%s'. Supported: dirs-first, files-first, alpha", value)
 	}
 }
 
+// excludedSuffix marks entries shown in a full tree that are not included in the output.
+const excludedSuffix = " (excluded)"
+
+// treeBuilder collects the tree from the entries of the walk that also collects the files, so the
+// source is traversed and filtered only once. Entries are added in walk order and sorted when rendered.
+type treeBuilder struct {
+	root     *treeNode
+	dirNodes map[string]*treeNode // Included directories by absolute path, to attach their entries
+	filter   *filefilter.FileFilter
+	fullTree bool     // Also show excluded entries, annotated with excludedSuffix
+	order    TreeSort // Order of the entries at every level; empty means TreeSortDirsFirst
+	logger   *slog.Logger
+}
+
 type treeNode struct {
-	name     string
-	isDir    bool
-	children []*treeNode
+	name       string
+	absPath    string
+	isDir      bool
+	excluded   bool   // Only shown in a full tree
+	linkTarget string // For a symbolic link to a directory shown as a leaf (see addSymlinkDir)
+	children   []*treeNode
 }
 
-func (tb *TreeBuilder) BuildTreeString() (string, error) {
-	absBasePath, err := filepath.Abs(tb.basePath)
-	if err != nil {
-		return "", fmt.Errorf("treebuilder: failed to get absolute base path: %w", err)
+func newTreeBuilder(basePath string, filter *filefilter.FileFilter, fullTree bool, logger *slog.Logger) *treeBuilder {
+	root := &treeNode{name: filepath.Base(basePath), absPath: basePath, isDir: true}
+	return &treeBuilder{
+		root:     root,
+		dirNodes: map[string]*treeNode{basePath: root},
+		filter:   filter,
+		fullTree: fullTree,
+		logger:   logger,
 	}
+}
 
-	rootNodeName := filepath.Base(absBasePath)
-	rootNode := &treeNode{name: rootNodeName, isDir: true}
-
-	var initialGitIgnores []*gitignore.GitIgnore
-	rootGitIgnore, _ := tb.compileGitIgnoreFunc(absBasePath) // Use the passed function
-	if rootGitIgnore != nil {
-		initialGitIgnores = append(initialGitIgnores, rootGitIgnore)
+// addEntry records a walked entry below the root with the filter's decision. Excluded entries are
+// only kept in a full tree, except for git internals and the output itself.
+func (tb *treeBuilder) addEntry(absPath string, d fs.DirEntry, excluded bool) {
+	if absPath == tb.root.absPath {
+		return
 	}
-
-	err = tb.buildNodeRecursive(absBasePath, rootNode, initialGitIgnores)
-	if err != nil {
-		return "", err // Error already contextualized
+	parent, ok := tb.dirNodes[filepath.Dir(absPath)]
+	if !ok {
+		tb.logger.Debug("TreeBuilder: Parent of walked entry is not in the tree (entry skipped)", "path", absPath)
+		return
+	}
+	if excluded && (!tb.fullTree || tb.isHiddenFromFullTree(absPath, d)) {
+		return
+	}
+	// The name is taken from the path: for a followed symlink, d describes the target.
+	node := &treeNode{name: filepath.Base(absPath), absPath: absPath, isDir: d.IsDir(), excluded: excluded}
+	parent.children = append(parent.children, node)
+	if node.isDir && !excluded {
+		tb.dirNodes[absPath] = node
 	}
-
-	var builder strings.Builder
-	builder.WriteString(rootNode.name + "\n")
-	tb.writeNodeRecursive(&builder, rootNode.children, "") // Start with children of root
-	return builder.String(), nil
 }
 
-func (tb *TreeBuilder) buildNodeRecursive(currentDirPath string, parentNode *treeNode, parentActiveIgnores []*gitignore.GitIgnore) error {
-	entries, err := os.ReadDir(currentDirPath)
-	if err != nil {
-		// Don't fail the whole tree for one unreadable dir, just log and skip its children.
-		slog.Warn("TreeBuilder: Failed to read directory (skipping its children in tree)", "path", currentDirPath, "error", err)
-		return nil // Continue building other parts of the tree
+// addSymlinkDir records a symbolic link to a directory as a leaf annotated with the link's target,
+// so the structure is visible although the link is not followed.
+func (tb *treeBuilder) addSymlinkDir(absPath, target string) {
+	parent, ok := tb.dirNodes[filepath.Dir(absPath)]
+	if !ok {
+		tb.logger.Debug("TreeBuilder: Parent of symlinked directory is not in the tree (entry skipped)", "path", absPath)
+		return
 	}
+	parent.children = append(parent.children, &treeNode{name: filepath.Base(absPath), absPath: absPath, isDir: true, linkTarget: target})
+}
 
-	sort.Slice(entries, func(i, j int) bool {
-		if entries[i].IsDir() != entries[j].IsDir() {
-			return entries[i].IsDir() // Dirs first
-		}
-		return strings.ToLower(entries[i].Name()) < strings.ToLower(entries[j].Name()) // Then alphanumeric
-	})
+// addFilePath records an included file that was not walked (e.g. read from stdin), along with
+// the directories above it that are not in the tree yet.
+func (tb *treeBuilder) addFilePath(absPath string) {
+	parent := tb.dirNode(filepath.Dir(absPath))
+	parent.children = append(parent.children, &treeNode{name: filepath.Base(absPath), absPath: absPath})
+}
 
-	currentDirGitIgnore, _ := tb.compileGitIgnoreFunc(currentDirPath)
-	currentActiveIgnores := parentActiveIgnores
-	if currentDirGitIgnore != nil {
-		// Avoid appending duplicate if currentDirPath's ignore is already the last one in parentActiveIgnores
-		// (e.g. if parentActiveIgnores was passed down from this same level due to root call)
-		isNewMatcher := true
-		if len(parentActiveIgnores) > 0 && parentActiveIgnores[len(parentActiveIgnores)-1] == currentDirGitIgnore {
-			isNewMatcher = false
-		}
-		if isNewMatcher {
-			// Create a new slice to avoid modifying the parent's slice
-			currentActiveIgnores = make([]*gitignore.GitIgnore, len(parentActiveIgnores))
-			copy(currentActiveIgnores, parentActiveIgnores)
-			currentActiveIgnores = append(currentActiveIgnores, currentDirGitIgnore)
-		}
+// dirNode returns the node of the directory at absPath below the root, creating it and its parents if needed.
+func (tb *treeBuilder) dirNode(absPath string) *treeNode {
+	if node, ok := tb.dirNodes[absPath]; ok {
+		return node
 	}
+	parentPath := filepath.Dir(absPath)
+	if parentPath == absPath {
+		return tb.root // Not below the root; cannot happen for paths joined to it
+	}
+	node := &treeNode{name: filepath.Base(absPath), absPath: absPath, isDir: true}
+	parent := tb.dirNode(parentPath)
+	parent.children = append(parent.children, node)
+	tb.dirNodes[absPath] = node
+	return node
+}
 
-	for _, entry := range entries {
-		entryAbsPath := filepath.Join(currentDirPath, entry.Name())
-
-		// Use the filter to decide if this entry (file or dir) should be in the tree
-		// The filter itself will log why something is skipped if verbose.
-		excluded, filterErr := tb.filter.IsExcluded(entryAbsPath, entry, currentActiveIgnores)
-
-		if filterErr != nil {
-			if errors.Is(filterErr, filepath.SkipDir) {
-				// Filter explicitly said to skip this directory and its contents
-				slog.Debug("TreeBuilder: Directory skipped by filter decision in tree", "path", entryAbsPath)
-				continue
+// keepFiles drops the included files that are not in keep (e.g. deselected interactively);
+// in a full tree they are marked excluded instead.
+func (tb *treeBuilder) keepFiles(keep []includedFile) {
+	kept := make(map[string]bool, len(keep))
+	for _, file := range keep {
+		kept[file.absPath] = true
+	}
+	for _, dir := range tb.dirNodes {
+		children := dir.children[:0]
+		for _, child := range dir.children {
+			if !child.isDir && !child.excluded && !kept[child.absPath] {
+				if !tb.fullTree {
+					continue
+				}
+				child.excluded = true
 			}
-			// Some other error during filtering this entry
-			slog.Warn("TreeBuilder: Error filtering entry for tree (entry skipped)", "path", entryAbsPath, "error", filterErr)
-			continue
+			children = append(children, child)
 		}
+		dir.children = children
+	}
+}
 
-		if excluded {
-			// If it's a directory and filter said "excluded=true" (but not SkipDir error),
-			// it means the directory itself matches an exclusion, so don't recurse.
-			// If it's a file and excluded, just don't add it.
-			// Logging is handled by the filter.
-			continue
-		}
+// isHiddenFromFullTree reports whether an excluded entry is left out even from a full tree:
+// git directories and the output file (or its parts).
+func (tb *treeBuilder) isHiddenFromFullTree(absPath string, entry fs.DirEntry) bool {
+	if entry.IsDir() {
+		return entry.Name() == ".git" || filefilter.IsGitDir(absPath)
+	}
+	return tb.filter.IsOutput(absPath)
+}
 
-		node := &treeNode{name: entry.Name(), isDir: entry.IsDir()}
-		parentNode.children = append(parentNode.children, node)
+// String renders the tree, sorted by tb.order (case-insensitive) at every level.
+func (tb *treeBuilder) String() string {
+	var builder strings.Builder
+	builder.WriteString(tb.root.name + "\n")
+	writeNodeRecursive(&builder, tb.root.children, "", tb.order) // Start with children of root
+	return builder.String()
+}
 
-		if entry.IsDir() {
-			// Recursively build for subdirectories
-			// Pass down the currentActiveIgnores, which now includes this directory's .gitignore if present
-			err := tb.buildNodeRecursive(entryAbsPath, node, currentActiveIgnores)
-			if err != nil {
-				// Log or handle, but typically continue building other branches
-				slog.Debug("TreeBuilder: Error processing sub-directory for tree", "path", entryAbsPath, "error", err)
-			}
+// sortTreeNodes orders nodes for display: directories first (or last, or mixed in, depending on order),
+// then alphanumerically.
+func sortTreeNodes(nodes []*treeNode, order TreeSort) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].isDir != nodes[j].isDir && order != TreeSortAlpha {
+			return nodes[i].isDir == (order != TreeSortFilesFirst) // Dirs first, unless files first
 		}
-	}
-	return nil
+		lowerI, lowerJ := strings.ToLower(nodes[i].name), strings.ToLower(nodes[j].name)
+		if lowerI != lowerJ {
+			return lowerI < lowerJ // Then alphanumeric
+		}
+		return nodes[i].name < nodes[j].name // Names differing only in case keep a fixed order
+	})
 }
 
-func (tb *TreeBuilder) writeNodeRecursive(builder *strings.Builder, children []*treeNode, prefix string) {
+func writeNodeRecursive(builder *strings.Builder, children []*treeNode, prefix string, order TreeSort) {
+	sortTreeNodes(children, order)
 	for i, child := range children {
 		connector := treePrefixEntry
 		nextPrefixElement := treePrefixContinue
@@ -159,10 +196,16 @@ func (tb *TreeBuilder) writeNodeRecursive(builder *strings.Builder, children []*
 		builder.WriteString(prefix)
 		builder.WriteString(connector)
 		builder.WriteString(child.name)
+		if child.linkTarget != "" {
+			builder.WriteString(" -> " + child.linkTarget)
+		}
+		if child.excluded {
+			builder.WriteString(excludedSuffix)
+		}
 		builder.WriteString("\n")
 
 		if child.isDir && len(child.children) > 0 {
-			tb.writeNodeRecursive(builder, child.children, prefix+nextPrefixElement)
+			writeNodeRecursive(builder, child.children, prefix+nextPrefixElement, order)
 		}
 	}
 }
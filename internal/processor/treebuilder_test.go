@@ -0,0 +1,145 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTreeSort(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    TreeSort
+		wantErr bool
+	}{
+		{value: "", want: TreeSortDirsFirst},
+		{value: "dirs-first", want: TreeSortDirsFirst},
+		{value: " Files-First ", want: TreeSortFilesFirst},
+		{value: "ALPHA", want: TreeSortAlpha},
+		{value: "size", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTreeSort(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTreeSort(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseTreeSort(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestTreeSort(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"a/x.go":   "package a\n",
+		"b.go":     "package main\n",
+		"c/z.go":   "package c\n",
+		"c/d/w.go": "package d\n",
+		"Zeta.md":  "# Zeta\n",
+	})
+	tests := []struct {
+		order TreeSort
+		want  string
+	}{
+		{
+			order: "", // TreeSortDirsFirst
+			want:  "proj\n├── a\n│   └── x.go\n├── c\n│   ├── d\n│   │   └── w.go\n│   └── z.go\n├── b.go\n└── Zeta.md\n",
+		},
+		{
+			order: TreeSortDirsFirst,
+			want:  "proj\n├── a\n│   └── x.go\n├── c\n│   ├── d\n│   │   └── w.go\n│   └── z.go\n├── b.go\n└── Zeta.md\n",
+		},
+		{
+			order: TreeSortFilesFirst,
+			want:  "proj\n├── b.go\n├── Zeta.md\n├── a\n│   └── x.go\n└── c\n    ├── z.go\n    └── d\n        └── w.go\n",
+		},
+		{
+			order: TreeSortAlpha,
+			want:  "proj\n├── a\n│   └── x.go\n├── b.go\n├── c\n│   ├── d\n│   │   └── w.go\n│   └── z.go\n└── Zeta.md\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.order), func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: root, RootLabel: "proj", IncludeTree: true, TreeSort: tt.order})
+			if tree := output[:strings.Index(output, "\n\n")+1]; tree != tt.want {
+				t.Errorf("tree =\n%s\nwant\n%s", tree, tt.want)
+			}
+		})
+	}
+}
+
+func TestShowSymlinkDirs(t *testing.T) {
+	external := t.TempDir()
+	root := writeSourceFiles(t, map[string]string{"main.go": "package main\n", "lib/a.go": "package lib\n"})
+	for target, link := range map[string]string{
+		"lib":                          "alias",   // Relative link to a directory inside the source
+		external:                       "ext",     // Absolute link to a directory outside it
+		"main.go":                      "link.go", // Link to a file: still excluded
+		filepath.Join(root, "missing"): "broken",  // Broken link: still excluded
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skipf("symbolic links are not supported: %v", err)
+		}
+	}
+	tests := []struct {
+		name            string
+		showSymlinkDirs bool
+		fullTree        bool
+		wantTree        string
+	}{
+		{
+			name:     "hidden by default",
+			wantTree: "proj\n├── lib\n│   └── a.go\n└── main.go\n",
+		},
+		{
+			name:            "directory links as leaves",
+			showSymlinkDirs: true,
+			wantTree:        "proj\n├── alias -> lib\n├── ext -> " + filepath.ToSlash(external) + "\n├── lib\n│   └── a.go\n└── main.go\n",
+		},
+		{
+			name:            "with a full tree",
+			showSymlinkDirs: true,
+			fullTree:        true,
+			wantTree:        "proj\n├── alias -> lib\n├── ext -> " + filepath.ToSlash(external) + "\n├── lib\n│   └── a.go\n├── broken (excluded)\n├── link.go (excluded)\n└── main.go\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: root, RootLabel: "proj", IncludeTree: true, ShowSymlinkDirs: tt.showSymlinkDirs, FullTree: tt.fullTree})
+			if tree := output[:strings.Index(output, "\n\n")+1]; tree != tt.wantTree {
+				t.Errorf("tree =\n%s\nwant\n%s", tree, tt.wantTree)
+			}
+			// The links are not followed for the content
+			if got, want := sectionPaths(output), []string{"lib/a.go", "main.go"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("file sections = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRootLabel(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{"main.go": "package main\n", "lib/a.go": "package lib\n"})
+	children := "├── lib\n│   └── a.go\n└── main.go\n"
+	tests := []struct {
+		name      string
+		rootLabel string
+		want      string
+	}{
+		{name: "folder name", want: filepath.Base(root) + "\n" + children},
+		{name: "custom label", rootLabel: "my-service", want: "my-service\n" + children},
+		{name: "label with spaces", rootLabel: "my service (v2)", want: "my service (v2)\n" + children},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := processToString(t, Config{SourcePath: root, RootLabel: tt.rootLabel, IncludeTree: true})
+			if tree := output[:strings.Index(output, "\n\n")+1]; tree != tt.want {
+				t.Errorf("tree =\n%s\nwant\n%s", tree, tt.want)
+			}
+			if got, want := sectionPaths(output), []string{"lib/a.go", "main.go"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("sections = %v, want the paths unchanged %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// lineWindow selects the lines written for a file truncated to its first head and last tail lines
+// (see Config.HeadLines and Config.TailLines).
+type lineWindow struct {
+	head, tail int
+	headLines  int      // Head lines seen so far
+	tailLines  []string // The last (up to) tail lines after the head
+	omitted    int      // Lines between the head and the tail
+}
+
+func newLineWindow(head, tail int) *lineWindow {
+	return &lineWindow{head: head, tail: tail}
+}
+
+// inHead reports whether line is one of the head lines, which are written right away.
+// Other lines are held back for the tail, or counted as omitted once the tail is full.
+func (w *lineWindow) inHead(line string) bool {
+	if w.headLines < w.head {
+		w.headLines++
+		return true
+	}
+	w.tailLines = append(w.tailLines, line)
+	if len(w.tailLines) > w.tail {
+		w.tailLines = w.tailLines[1:]
+		w.omitted++
+	}
+	return false
+}
+
+// omittedMarker returns the line written in place of the omitted lines, or "" if none were omitted.
+func (w *lineWindow) omittedMarker() string {
+	if w.omitted == 0 {
+		return ""
+	}
+	unit := "lines"
+	if w.omitted == 1 {
+		unit = "line"
+	}
+	return fmt.Sprintf("// ... (%d %s omitted) ...", w.omitted, unit)
+}
+
+// truncates reports whether only the head and tail of file are written: with HeadLines or TailLines
+// set, for files larger than MaxFileSize (which are then not excluded by the filter).
+func (p *Processor) truncates(file includedFile) bool {
+	return (p.config.HeadLines > 0 || p.config.TailLines > 0) && p.config.MaxFileSize > 0 && file.info.Size() > p.config.MaxFileSize
+}
+
+// truncateLine cuts line after maxChars characters (runes), noting how many were cut. Lines that fit,
+// and all lines if maxChars is not positive, are returned unchanged.
+func truncateLine(line string, maxChars int) string {
+	if maxChars <= 0 || len(line) <= maxChars {
+		return line // A line of at most maxChars bytes has at most maxChars characters
+	}
+	chars := utf8.RuneCountInString(line)
+	if chars <= maxChars {
+		return line
+	}
+	cut := 0
+	for i := 0; i < maxChars; i++ {
+		_, size := utf8.DecodeRuneInString(line[cut:])
+		cut += size
+	}
+	return fmt.Sprintf("%s… (truncated %d chars)", line[:cut], chars-maxChars)
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns the lines "line 1" to "line n", each ending in a newline.
+func numberedLines(from, to int) string {
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+func TestLineWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      int
+		head, tail int
+		want       string // Written lines, with the marker
+	}{
+		{name: "head and tail", lines: 100, head: 5, tail: 5, want: numberedLines(1, 5) + "// ... (90 lines omitted) ...\n" + numberedLines(96, 100)},
+		{name: "head only", lines: 10, head: 3, want: numberedLines(1, 3) + "// ... (7 lines omitted) ...\n"},
+		{name: "tail only", lines: 10, tail: 2, want: "// ... (8 lines omitted) ...\n" + numberedLines(9, 10)},
+		{name: "one line omitted", lines: 5, head: 2, tail: 2, want: numberedLines(1, 2) + "// ... (1 line omitted) ...\n" + numberedLines(4, 5)},
+		{name: "short file is complete", lines: 8, head: 5, tail: 5, want: numberedLines(1, 8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window := newLineWindow(tt.head, tt.tail)
+			var got strings.Builder
+			for _, line := range strings.SplitAfter(numberedLines(1, tt.lines), "\n") {
+				if line != "" && window.inHead(strings.TrimSuffix(line, "\n")) {
+					got.WriteString(line)
+				}
+			}
+			if marker := window.omittedMarker(); marker != "" {
+				got.WriteString(marker + "\n")
+			}
+			for _, line := range window.tailLines {
+				got.WriteString(line + "\n")
+			}
+			if got.String() != tt.want {
+				t.Errorf("written lines =\n%s\nwant\n%s", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadAndTailOfLargeFiles(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		"large.txt": numberedLines(1, 100),
+		"small.txt": "small\n",
+	})
+
+	output := processToString(t, Config{SourcePath: root, MaxFileSize: 100, HeadLines: 5, TailLines: 5})
+
+	want := "```large.txt\n" + numberedLines(1, 5) + "// ... (90 lines omitted) ...\n" + numberedLines(96, 100) + "```\n"
+	if !strings.Contains(output, want) {
+		t.Errorf("output does not contain the head and tail of large.txt\n%s\ngot\n%s", want, output)
+	}
+	if !strings.Contains(output, "```small.txt\nsmall\n```\n") {
+		t.Errorf("small file is not complete:\n%s", output)
+	}
+}
+
+func TestTruncateLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		maxChars int
+		want     string
+	}{
+		{name: "unlimited", line: "abcdef", maxChars: 0, want: "abcdef"},
+		{name: "fits", line: "abcdef", maxChars: 6, want: "abcdef"},
+		{name: "too long", line: "abcdefgh", maxChars: 3, want: "abc… (truncated 5 chars)"},
+		{name: "multi-byte characters fit", line: "héllo", maxChars: 5, want: "héllo"},
+		{name: "multi-byte characters are not split", line: "日本語のテキスト", maxChars: 2, want: "日本… (truncated 6 chars)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateLine(tt.line, tt.maxChars); got != tt.want {
+				t.Errorf("truncateLine(%q, %d) = %q, want %q", tt.line, tt.maxChars, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxLineLength(t *testing.T) {
+	longLine := strings.Repeat("x", 100_000)   // Longer than bufio.Scanner's 64 KiB limit
+	singleLine := strings.Repeat("y", 200_000) // A whole file on one line, without a trailing newline
+	tests := []struct {
+		name          string
+		content       string
+		maxLineLength int
+		want          string
+	}{
+		{name: "long line is truncated", content: "short\n" + longLine + "\nend\n", maxLineLength: 10, want: "```app.min.js\nshort\nxxxxxxxxxx… (truncated 99990 chars)\nend\n```\n"},
+		{name: "long line is kept without a limit", content: "short\n" + longLine + "\nend\n", want: "```app.min.js\nshort\n" + longLine + "\nend\n```\n"},
+		{name: "single line is truncated", content: singleLine, maxLineLength: 10, want: "```app.min.js\nyyyyyyyyyy… (truncated 199990 chars)\n```\n"},
+		{name: "single line is kept without a limit", content: singleLine, want: "```app.min.js\n" + singleLine + "\n```\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeSourceFiles(t, map[string]string{"app.min.js": tt.content})
+			output := processToString(t, Config{SourcePath: root, MaxLineLength: tt.maxLineLength})
+			if strings.Contains(output, "Error scanning") {
+				t.Fatalf("scanning the long line failed:\n%.200s", output)
+			}
+			if !strings.Contains(output, tt.want) {
+				t.Errorf("output does not contain\n%.200s\ngot\n%.200s", tt.want, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// preparedProcessor returns a processor for cfg that is ready to collect candidates, as in a run.
+func preparedProcessor(tb testing.TB, cfg Config) *Processor {
+	tb.Helper()
+	p, err := New(cfg)
+	if err != nil {
+		tb.Fatalf("New() error = %v", err)
+	}
+	if err := p.setupInitialPaths(); err != nil {
+		tb.Fatalf("setupInitialPaths() error = %v", err)
+	}
+	if err := p.determineOutputFileAndInitFilter(); err != nil {
+		tb.Fatalf("determineOutputFileAndInitFilter() error = %v", err)
+	}
+	p.ctx = context.Background()
+	p.result = ProcessResult{SkippedByReason: make(map[string]int)}
+	return p
+}
+
+// singlePass collects the tree and the included files in one walk, as a run does.
+func singlePass(tb testing.TB, p *Processor) (string, []string) {
+	tb.Helper()
+	tree := newTreeBuilder(p.basePath, p.filter, false, p.logger)
+	files, err := p.collectCandidates(tree)
+	if err != nil {
+		tb.Fatalf("collectCandidates() error = %v", err)
+	}
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = filepath.ToSlash(file.relPath)
+	}
+	return tree.String(), paths
+}
+
+// twoPass collects the tree and the included files the way they were before the walks were merged:
+// the tree by its own recursive reading of the directories, and the files by a second walk, both
+// collecting the ignore files of every entry by climbing its parents.
+func twoPass(tb testing.TB, p *Processor) (string, []string) {
+	tb.Helper()
+	var readTree func(dir string) []*treeNode
+	readTree = func(dir string) []*treeNode {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			tb.Fatalf("ReadDir() error = %v", err)
+		}
+		var nodes []*treeNode
+		for _, entry := range entries {
+			absPath := filepath.Join(dir, entry.Name())
+			if reason, _ := p.filter.ExclusionReason(absPath, entry, p.activeIgnoresFor(absPath, entry.IsDir())); reason != "" {
+				continue
+			}
+			node := &treeNode{name: entry.Name(), absPath: absPath, isDir: entry.IsDir()}
+			if node.isDir {
+				node.children = readTree(absPath)
+			}
+			nodes = append(nodes, node)
+		}
+		return nodes
+	}
+	var tree strings.Builder
+	tree.WriteString(filepath.Base(p.basePath) + "\n")
+	writeNodeRecursive(&tree, readTree(p.basePath), "", "")
+	return tree.String(), climbingWalk(tb, p)
+}
+
+// climbingWalk collects the included files in a walk that collects the ignore files of every
+// entry by climbing its parents, instead of carrying the stack of its directory.
+func climbingWalk(tb testing.TB, p *Processor) []string {
+	tb.Helper()
+	var paths []string
+	err := filepath.WalkDir(p.basePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == p.basePath {
+			return err
+		}
+		reason, filterErr := p.filter.ExclusionReason(path, d, p.activeIgnoresFor(path, d.IsDir()))
+		if errors.Is(filterErr, filepath.SkipDir) || (reason != "" && d.IsDir()) {
+			return filepath.SkipDir
+		}
+		if reason == "" && !d.IsDir() {
+			relPath, _ := filepath.Rel(p.basePath, path)
+			paths = append(paths, filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+	if err != nil {
+		tb.Fatalf("WalkDir() error = %v", err)
+	}
+	return paths
+}
+
+func TestSinglePassMatchesTwoPasses(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		".gitignore":                "*.log\nbuild/\n/secret.txt\n",
+		"main.go":                   "package main\n",
+		"app.log":                   "log\n",
+		"secret.txt":                "anchored\n",
+		"build/out.go":              "package build\n",
+		"docs/secret.txt":           "not anchored here\n",
+		"docs/guide.md":             "# Guide\n",
+		"docs/.gitignore":           "!important.log\ndraft*\n",
+		"docs/important.log":        "re-included\n",
+		"docs/draft-1.md":           "draft\n",
+		"src/lib/util.go":           "package lib\n",
+		"src/lib/util_test.go":      "package lib\n",
+		"src/lib/.dockerignore":     "*.go\n",
+		"src/vendor/dep/dep.go":     "package dep\n",
+		"src/node_modules/x/y.js":   "module.exports = {}\n",
+		"src/assets/logo.png":       "png",
+		".hidden/config.go":         "package hidden\n",
+		"deeply/nested/dirs/a/b.go": "package a\n",
+	})
+	if err := os.Mkdir(filepath.Join(root, "src", "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "defaults"},
+		{name: "default exclusions", cfg: Config{DefaultExcludeDirs: []string{"node_modules", "vendor"}, DefaultMediaExts: []string{".png"}}},
+		{name: "user exclusions", cfg: Config{UserExcludeDirs: []string{"docs"}, UserExcludeExts: []string{".md"}, UserExcludeGlobs: []string{"*_test.go"}}},
+		{name: "extra ignore files", cfg: Config{ExtraIgnoreFiles: []string{".dockerignore"}}},
+		{name: "skip hidden", cfg: Config{SkipHidden: true}},
+		{name: "gitignore disabled", cfg: Config{IncludeGitignored: true}},
+		{name: "included extensions", cfg: Config{UserIncludeExts: []string{".go"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.SourcePath = root
+			wantTree, wantFiles := twoPass(t, preparedProcessor(t, cfg))
+			if len(wantFiles) == 0 {
+				t.Fatal("no files included")
+			}
+			gotTree, gotFiles := singlePass(t, preparedProcessor(t, cfg))
+			if gotTree != wantTree {
+				t.Errorf("single-pass tree =\n%s\nwant the two-pass tree\n%s", gotTree, wantTree)
+			}
+			if !reflect.DeepEqual(gotFiles, wantFiles) {
+				t.Errorf("single-pass files = %v, want the two-pass files %v", gotFiles, wantFiles)
+			}
+		})
+	}
+}
+
+// writeWideTree creates a tree of dirs directories with filesPerDir files each, and a .gitignore
+// in every directory, for the benchmarks.
+func writeWideTree(tb testing.TB, dirs, filesPerDir int) string {
+	tb.Helper()
+	root := tb.TempDir()
+	for d := range dirs {
+		dir := filepath.Join(root, fmt.Sprintf("pkg%02d", d%10), fmt.Sprintf("sub%03d", d))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.tmp\n"), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+		for f := range filesPerDir {
+			name := fmt.Sprintf("file%03d.go", f)
+			if f%5 == 0 {
+				name = fmt.Sprintf("file%03d.tmp", f)
+			}
+			if err := os.WriteFile(filepath.Join(dir, name), []byte("package x\n"), 0o644); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+func BenchmarkCollectTreeAndFiles(b *testing.B) {
+	root := writeWideTree(b, 100, 50)
+	b.Run("single pass", func(b *testing.B) {
+		for b.Loop() {
+			singlePass(b, preparedProcessor(b, Config{SourcePath: root}))
+		}
+	})
+	b.Run("two passes", func(b *testing.B) {
+		for b.Loop() {
+			twoPass(b, preparedProcessor(b, Config{SourcePath: root}))
+		}
+	})
+}
+
+func TestNestedNegations(t *testing.T) {
+	root := writeSourceFiles(t, map[string]string{
+		".gitignore":       "*.log\ntmp/\n",
+		"main.go":          "package main\n",
+		"top.log":          "ignored by the root\n",
+		"tmp/y.go":         "package tmp\n",
+		"a/.gitignore":     "!*.log\nsecret.log\n!tmp/\n",
+		"a/a.log":          "re-included by a\n",
+		"a/secret.log":     "ignored again by a's later rule\n",
+		"a/tmp/x.go":       "package tmp\n",
+		"a/b/.gitignore":   "*.log\n!b-keep.log\n",
+		"a/b/b.log":        "ignored again by b\n",
+		"a/b/b-keep.log":   "re-included by b\n",
+		"a/b/c/.gitignore": "!c.log\n",
+		"a/b/c/c.log":      "re-included by c\n",
+		"a/b/c/other.log":  "still ignored by b\n",
+		"a/b/c/d/deep.log": "ignored by b, two levels up\n",
+	})
+	want := []string{
+		".gitignore", "a/.gitignore", "a/a.log", "a/b/.gitignore", "a/b/b-keep.log",
+		"a/b/c/.gitignore", "a/b/c/c.log", "a/tmp/x.go", "main.go",
+	}
+	_, got := singlePass(t, preparedProcessor(t, Config{SourcePath: root}))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("included files = %v, want %v", got, want)
+	}
+	if climbed := climbingWalk(t, preparedProcessor(t, Config{SourcePath: root})); !reflect.DeepEqual(got, climbed) {
+		t.Errorf("included files = %v, want those of a walk climbing the parents %v", got, climbed)
+	}
+}
+
+// writeDeepTree creates a chain of depth nested directories, each with a .gitignore and filesPerDir files.
+func writeDeepTree(tb testing.TB, depth, filesPerDir int) string {
+	tb.Helper()
+	root := tb.TempDir()
+	dir := root
+	for d := range depth {
+		dir = filepath.Join(dir, fmt.Sprintf("level%02d", d))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(fmt.Sprintf("*.tmp\n!keep%02d.tmp\n", d)), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+		for f := range filesPerDir {
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%03d.go", f)), []byte("package x\n"), 0o644); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+func BenchmarkWalkDeepTree(b *testing.B) {
+	root := writeDeepTree(b, 40, 50)
+	b.Run("carried stack", func(b *testing.B) {
+		for b.Loop() {
+			p := preparedProcessor(b, Config{SourcePath: root})
+			if _, err := p.collectCandidates(nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("climbing parents", func(b *testing.B) {
+		for b.Loop() {
+			climbingWalk(b, preparedProcessor(b, Config{SourcePath: root}))
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walk traverses root like filepath.WalkDir. If followSymlinks is set, symbolic links are resolved:
+// links to files are reported with their target's info and links to directories are descended into,
+// with every path reported under the link's location rather than the target's.
+// Every real directory is walked once: a link to a directory inside root is not descended into, as its
+// target is walked at its real location, and a link to a directory outside root is only descended into if
+// that directory was not walked yet through another link. This also guarantees that cyclic links terminate.
+func walk(root string, followSymlinks bool, logger *slog.Logger, fn fs.WalkDirFunc) error {
+	if !followSymlinks {
+		return filepath.WalkDir(root, fn)
+	}
+	realBase := root
+	if realRoot, err := filepath.EvalSymlinks(root); err == nil {
+		realBase = realRoot
+	}
+	w := &linkWalk{realBase: realBase, visited: map[string]bool{realBase: true}, fn: fn, logger: logger}
+	return w.walk(root, root)
+}
+
+// linkWalk is the state of a walk that follows symbolic links (see walk).
+type linkWalk struct {
+	realBase string          // Real path of the walked root
+	visited  map[string]bool // Real paths of the directories walked so far, as roots or link targets
+	fn       fs.WalkDirFunc
+	logger   *slog.Logger
+}
+
+// walk walks realRoot, reporting paths as if realRoot were located at displayRoot.
+func (w *linkWalk) walk(realRoot, displayRoot string) error {
+	return filepath.WalkDir(realRoot, func(path string, d fs.DirEntry, err error) error {
+		displayPath := displayRoot
+		if rel, relErr := filepath.Rel(realRoot, path); relErr == nil && rel != "." {
+			displayPath = filepath.Join(displayRoot, rel)
+		}
+
+		if err != nil || d.Type()&fs.ModeSymlink == 0 {
+			if err == nil && d.IsDir() && path != realRoot && w.visited[path] {
+				// E.g. the root again, below a link to one of its ancestors
+				w.logger.Debug("Walker: Skipping already walked directory", "path", displayPath)
+				return filepath.SkipDir
+			}
+			return w.fn(displayPath, d, err)
+		}
+
+		resolved, realTarget, resolveErr := resolveSymlink(path)
+		if resolveErr != nil {
+			// Broken link or unreadable target: hand the raw link to the callback so the filter can skip it.
+			w.logger.Debug("Walker: Could not resolve symbolic link", "path", displayPath, "error", resolveErr)
+			return w.fn(displayPath, d, nil)
+		}
+		if !resolved.IsDir() {
+			return w.fn(displayPath, resolved, nil)
+		}
+		if isWithinDir(w.realBase, realTarget) {
+			w.logger.Debug("Walker: Skipping symlink to a directory inside the root (walked at its real location)", "path", displayPath, "target", realTarget)
+			return nil
+		}
+		for visitedDir := range w.visited {
+			if isWithinDir(visitedDir, realTarget) {
+				w.logger.Debug("Walker: Skipping already visited symlink target", "path", displayPath, "target", realTarget)
+				return nil
+			}
+		}
+		w.visited[realTarget] = true
+		return w.walk(realTarget, displayPath)
+	})
+}
+
+// resolveSymlink resolves the symbolic link at path and returns a DirEntry describing its target.
+// For directory targets the real path is returned as well.
+func resolveSymlink(path string) (fs.DirEntry, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("walker: failed to stat symlink target of '%s': %w", path, err)
+	}
+	if !info.IsDir() {
+		return fs.FileInfoToDirEntry(info), "", nil
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("walker: failed to resolve symlink '%s': %w", path, err)
+	}
+	return fs.FileInfoToDirEntry(info), realPath, nil
+}
+
+// isWithinDir reports whether path is dir or below it. Both must be clean absolute paths.
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/alexferrari88/code2context/internal/archiveutils"
+	"github.com/alexferrari88/code2context/internal/gitutils"
+	"github.com/fsnotify/fsnotify"
+)
+
+// defaultWatchDebounce is the quiet period after the last change before the output is regenerated.
+const defaultWatchDebounce = 300 * time.Millisecond
+
+// watch generates the output, then regenerates it whenever a file in one of the walked directories
+// changes, until interrupted (Ctrl-C or SIGTERM). Changes are debounced, so a burst of writes
+// (e.g. a branch switch) triggers a single run. Errors of later runs are logged and watching continues.
+func (p *Processor) watch() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return p.watchUntil(ctx)
+}
+
+func (p *Processor) watchUntil(ctx context.Context) error {
+	if err := checkWatchable(p.config); err != nil {
+		return err
+	}
+	if err := p.process(); err != nil && !errors.Is(err, ErrNoFiles) {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("processor: failed to create file watcher: %w", err)
+	}
+	defer watcher.Close()
+	p.syncWatches(watcher)
+
+	debounce := p.config.WatchDebounce
+	if debounce <= 0 {
+		debounce = defaultWatchDebounce
+	}
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	p.logger.Info("Watching for changes (press Ctrl-C to stop)...", "path", p.basePath, "directories", len(watcher.WatchList()))
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info("Stopped watching for changes")
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if p.isRelevantChange(event.Name) {
+				p.logger.Debug("Processor: Change detected", "path", event.Name, "op", event.Op.String())
+				timer.Reset(debounce)
+			}
+		case watchErr, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			p.logger.Warn("Processor: File watcher error", "error", watchErr)
+		case <-timer.C:
+			p.logger.Info("Changes detected, regenerating output...")
+			if err := p.process(); err != nil && !errors.Is(err, ErrNoFiles) {
+				p.logger.Error("Processor: Failed to regenerate output", "error", err)
+			}
+			p.syncWatches(watcher)
+		}
+	}
+}
+
+// checkWatchable rejects the sources that cannot be watched, before anything is cloned, extracted, or written:
+// only a local directory is watched, not a clone or extraction of the source.
+func checkWatchable(cfg Config) error {
+	switch {
+	case cfg.FromStdin:
+		return errors.New("processor: watch mode cannot be combined with reading files from stdin")
+	case gitutils.IsGitURL(cfg.SourcePath), archiveutils.IsArchive(cfg.SourcePath):
+		return fmt.Errorf("processor: watch mode requires a local source directory, not '%s'", gitutils.RedactURLCredentials(cfg.SourcePath))
+	}
+	return nil
+}
+
+// syncWatches makes the watcher watch exactly the directories walked by the last run,
+// so that new directories are picked up and removed or newly excluded ones are dropped.
+func (p *Processor) syncWatches(watcher *fsnotify.Watcher) {
+	wanted := make(map[string]bool, len(p.walkedDirs))
+	for _, dir := range p.walkedDirs {
+		wanted[dir] = true
+	}
+	for _, dir := range watcher.WatchList() {
+		if !wanted[dir] {
+			_ = watcher.Remove(dir) // Fails if the directory is gone, in which case the watch is already removed
+		}
+		delete(wanted, dir)
+	}
+	for dir := range wanted {
+		if err := watcher.Add(dir); err != nil {
+			p.logger.Warn("Processor: Failed to watch directory", "path", dir, "error", err)
+		}
+	}
+}
+
+// isRelevantChange reports whether a change to absPath can affect the output. Changes to the output
+// itself (including its temporary files) and to excluded paths are ignored; changes to ignore files
+// and removals always count.
+func (p *Processor) isRelevantChange(absPath string) bool {
+	baseName := filepath.Base(absPath)
+	if matched, _ := filepath.Match("c2c_out_*.tmp", baseName); matched {
+		return false // Temporary output file of a run in progress
+	}
+	if baseName == ".gitignore" || absPath == filepath.Join(p.basePath, contextIncludeFileName) {
+		return true
+	}
+	for _, name := range p.config.ExtraIgnoreFiles {
+		if baseName == name {
+			return true
+		}
+	}
+	info, err := os.Lstat(absPath)
+	if err != nil {
+		return true // Removed (or renamed away); the output may have included it
+	}
+	excluded, _ := p.filter.IsExcluded(absPath, fs.FileInfoToDirEntry(info), p.activeIgnoresFor(absPath, info.IsDir()))
+	return !excluded
+}
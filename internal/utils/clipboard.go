@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// SetClipboardFunc copies text to the system clipboard. It is a variable so it can be replaced,
+// e.g. with a stub in tests.
+var SetClipboardFunc = setClipboard
+
+// clipboardCommands lists the commands that read new clipboard content from stdin, by preference.
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip.exe"}}
+	default:
+		return [][]string{
+			{"wl-copy"},
+			{"xclip", "-selection", "clipboard"},
+			{"xsel", "--clipboard", "--input"},
+			{"termux-clipboard-set"},
+		}
+	}
+}
+
+// setClipboard pipes text into the first clipboard command that is installed.
+func setClipboard(text string) error {
+	for _, command := range clipboardCommands() {
+		path, err := exec.LookPath(command[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s failed: %w (%s)", command[0], err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	return errors.New("no clipboard tool found (install wl-clipboard, xclip, or xsel; a display server is required)")
+}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSetClipboardWithoutTools(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the clipboard tool is part of the system")
+	}
+	t.Setenv("PATH", t.TempDir())
+	err := setClipboard("text")
+	if err == nil || !strings.Contains(err.Error(), "no clipboard tool found") {
+		t.Errorf("setClipboard() error = %v, want the missing tool error", err)
+	}
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"strings"
+)
+
+// CommentStripper removes comments from source code while leaving string literals intact.
+type CommentStripper interface {
+	StripComments(content string) string
+}
+
+// CommentStripperFor returns the comment stripper for a language name as used by the
+// extension mapping (e.g. "go", "python"), or nil if comments of that language are not supported.
+func CommentStripperFor(language string) CommentStripper {
+	switch language {
+	case "go":
+		return cStyleStripper{rawBacktick: true, keepPrefixes: []string{"//go:", "// +build"}}
+	case "javascript", "typescript":
+		return cStyleStripper{}
+	case "c", "cpp":
+		return cStyleStripper{}
+	case "python":
+		return hashStripper{tripleQuotes: true, keepShebang: true}
+	default:
+		return nil
+	}
+}
+
+// cStyleStripper removes "//" line comments and "/* */" block comments.
+// Strings quoted with ", ', or ` are copied verbatim, honoring backslash escapes
+// (except inside Go raw strings when rawBacktick is set).
+type cStyleStripper struct {
+	rawBacktick  bool     // Backtick strings are raw (Go) rather than escapable templates (JS)
+	keepPrefixes []string // Line comments starting with one of these are kept (e.g. build directives)
+}
+
+func (s cStyleStripper) StripComments(content string) string {
+	var out strings.Builder
+	out.Grow(len(content))
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			end := endOfQuoted(content, i+1, c, !(c == '`' && s.rawBacktick))
+			out.WriteString(content[i:end])
+			i = end
+		case strings.HasPrefix(content[i:], "//"):
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				end = len(content)
+			} else {
+				end += i
+			}
+			if s.keeps(content[i:end]) {
+				out.WriteString(content[i:end])
+			}
+			i = end // The newline itself is kept
+		case strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				end = len(content)
+			} else {
+				end += i + 4
+			}
+			// Keep the line structure so that dropping comment-only lines works line by line.
+			out.WriteString(strings.Repeat("\n", strings.Count(content[i:end], "\n")))
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return dropEmptiedLines(content, out.String())
+}
+
+func (s cStyleStripper) keeps(comment string) bool {
+	for _, prefix := range s.keepPrefixes {
+		if strings.HasPrefix(comment, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hashStripper removes "#" line comments, copying quoted strings verbatim: single- and double-quoted
+// ones and, with tripleQuotes, those delimited by three single or three double quotes.
+type hashStripper struct {
+	tripleQuotes bool
+	keepShebang  bool // Keep a "#!" interpreter line at the very start
+}
+
+func (s hashStripper) StripComments(content string) string {
+	var out strings.Builder
+	out.Grow(len(content))
+	i := 0
+	if s.keepShebang && strings.HasPrefix(content, "#!") {
+		if end := strings.IndexByte(content, '\n'); end >= 0 {
+			i = end
+		} else {
+			i = len(content)
+		}
+		out.WriteString(content[:i])
+	}
+	for i < len(content) {
+		c := content[i]
+		switch {
+		case s.tripleQuotes && (strings.HasPrefix(content[i:], `"""`) || strings.HasPrefix(content[i:], `'''`)):
+			end := endOfTripleQuoted(content, i+3, content[i:i+3])
+			out.WriteString(content[i:end])
+			i = end
+		case c == '"' || c == '\'':
+			end := endOfQuoted(content, i+1, c, true)
+			out.WriteString(content[i:end])
+			i = end
+		case c == '#':
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				i = len(content)
+			} else {
+				i += end
+			}
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return dropEmptiedLines(content, out.String())
+}
+
+// endOfQuoted returns the index just past the closing quote of a string starting at start
+// (after the opening quote). Single-line strings end at an unescaped newline if unterminated.
+func endOfQuoted(content string, start int, quote byte, escapes bool) int {
+	for i := start; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			if escapes {
+				i++ // Skip the escaped character
+			}
+		case quote:
+			return i + 1
+		case '\n':
+			if quote != '`' {
+				return i // Unterminated: stop at the end of the line rather than swallowing the file
+			}
+		}
+	}
+	return len(content)
+}
+
+// endOfTripleQuoted returns the index just past the closing delimiter (three quotes) of a string
+// starting at start (after the opening delimiter), honoring backslash escapes.
+func endOfTripleQuoted(content string, start int, delimiter string) int {
+	for i := start; i < len(content); i++ {
+		if content[i] == '\\' {
+			i++ // Skip the escaped character
+			continue
+		}
+		if strings.HasPrefix(content[i:], delimiter) {
+			return i + len(delimiter)
+		}
+	}
+	return len(content)
+}
+
+// dropEmptiedLines compares stripped with original line by line: lines that only held a comment
+// are removed and whitespace left before a removed trailing comment is trimmed.
+// Lines that were blank originally are kept. Both texts must have the same number of lines.
+func dropEmptiedLines(original, stripped string) string {
+	originalLines := strings.Split(original, "\n")
+	strippedLines := strings.Split(stripped, "\n")
+	if len(originalLines) != len(strippedLines) {
+		return stripped
+	}
+	kept := strippedLines[:0]
+	for i, line := range strippedLines {
+		if line == originalLines[i] {
+			kept = append(kept, line)
+			continue
+		}
+		trimmed := strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(trimmed) == "" && strings.TrimSpace(originalLines[i]) != "" {
+			continue // The line only held a comment
+		}
+		if strings.HasSuffix(originalLines[i], "\r") {
+			trimmed += "\r" // Keep CRLF line endings
+		}
+		kept = append(kept, trimmed)
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,80 @@
+package utils
+
+import "testing"
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		input    string
+		want     string
+	}{
+		{
+			name:     "go line comments",
+			language: "go",
+			input:    "package main\n\n// Doc comment\nfunc f() {} // trailing\n",
+			want:     "package main\n\nfunc f() {}\n",
+		},
+		{
+			name:     "go block comments",
+			language: "go",
+			input:    "/*\nLicense\n*/\npackage main\n\nvar x = 1 /* inline */ + 2\n",
+			want:     "package main\n\nvar x = 1  + 2\n",
+		},
+		{
+			name:     "go build directives are kept",
+			language: "go",
+			input:    "//go:build linux\n\npackage main\n\n//go:generate stringer\n",
+			want:     "//go:build linux\n\npackage main\n\n//go:generate stringer\n",
+		},
+		{
+			name:     "go strings with comment-like sequences",
+			language: "go",
+			input:    "var a = \"http://example.com\" // url\nvar b = `/* raw */`\nvar c = '/'\nvar d = \"\\\"// still a string\"\n",
+			want:     "var a = \"http://example.com\"\nvar b = `/* raw */`\nvar c = '/'\nvar d = \"\\\"// still a string\"\n",
+		},
+		{
+			name:     "javascript template literal",
+			language: "javascript",
+			input:    "const s = `a ${b} // not a comment`; // comment\n",
+			want:     "const s = `a ${b} // not a comment`;\n",
+		},
+		{
+			name:     "python hash comments",
+			language: "python",
+			input:    "#!/usr/bin/env python3\n# Module comment\nx = 1  # trailing\n\ny = 2\n",
+			want:     "#!/usr/bin/env python3\nx = 1\n\ny = 2\n",
+		},
+		{
+			name:     "python strings with hashes",
+			language: "python",
+			input:    "a = \"# not a comment\"\nb = '#nor this' # but this\nc = \"\"\"\n# inside a docstring\n\"\"\"\nd = '''# quoted'''\n",
+			want:     "a = \"# not a comment\"\nb = '#nor this'\nc = \"\"\"\n# inside a docstring\n\"\"\"\nd = '''# quoted'''\n",
+		},
+		{
+			name:     "crlf line endings are kept",
+			language: "c",
+			input:    "int x; // comment\r\nint y;\r\n",
+			want:     "int x;\r\nint y;\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripper := CommentStripperFor(tt.language)
+			if stripper == nil {
+				t.Fatalf("CommentStripperFor(%q) = nil", tt.language)
+			}
+			if got := stripper.StripComments(tt.input); got != tt.want {
+				t.Errorf("StripComments() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentStripperForUnsupportedLanguage(t *testing.T) {
+	for _, language := range []string{"", "ruby", "markdown"} {
+		if stripper := CommentStripperFor(language); stripper != nil {
+			t.Errorf("CommentStripperFor(%q) = %T, want nil", language, stripper)
+		}
+	}
+}
@@ -1,18 +1,28 @@
 package utils
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 var (
-	// fileSizeRegex matches numbers followed by optional K, M, G, T and optional B. Case-insensitive.
-	fileSizeRegex = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*([KMGT])?B?$`)
+	// fileSizeRegex matches numbers followed by an optional K, M, G, T prefix, an optional binary 'i'
+	// (as in "MiB"), and an optional B. Case-insensitive.
+	fileSizeRegex = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*(?:([KMGT])(I)?)?B?$`)
+	// dayDurationRegex matches an age in days or weeks (e.g. "7d", "2w"), which time.ParseDuration lacks.
+	dayDurationRegex = regexp.MustCompile(`(?i)^(\d+)\s*([dw])$`)
 	// fileSizeRegexOnlyDigits matches if the string is only digits (for plain bytes).
 	fileSizeRegexOnlyDigits = regexp.MustCompile(`^(\d+)$`)
 )
@@ -25,10 +35,25 @@ const (
 	TB
 )
 
+// decimalMultipliers are the 1000-based values of the unit prefixes, used by ParseFileSizeDecimal.
+var decimalMultipliers = map[string]int64{"K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12}
+
 // ParseFileSize converts a human-readable file size string (e.g., "1MB", "500KB", "1024") to bytes.
-// Supports K, M, G, T units (case-insensitive), with an optional 'B'.
-// Also supports plain numbers for bytes. Allows fractional inputs like "0.5MB".
+// Supports K, M, G, T units (case-insensitive), with an optional 'B', and the binary spellings
+// KiB, MiB, GiB, TiB. Also supports plain numbers for bytes. Allows fractional inputs like "0.5MB".
+// For backward compatibility, KB, MB, GB, and TB are binary (1024-based) like KiB, MiB, GiB, and TiB;
+// see ParseFileSizeDecimal for 1000-based units.
 func ParseFileSize(sizeStr string) (int64, error) {
+	return parseFileSize(sizeStr, false)
+}
+
+// ParseFileSizeDecimal is like ParseFileSize, but KB (or kB), MB, GB, and TB are decimal (1000-based) units.
+// The binary spellings KiB, MiB, GiB, and TiB remain 1024-based.
+func ParseFileSizeDecimal(sizeStr string) (int64, error) {
+	return parseFileSize(sizeStr, true)
+}
+
+func parseFileSize(sizeStr string, decimal bool) (int64, error) {
 	sizeStr = strings.TrimSpace(sizeStr)
 	if sizeStr == "" {
 		return 0, errors.New("file size string is empty")
@@ -36,9 +61,10 @@ func ParseFileSize(sizeStr string) (int64, error) {
 
 	// Try matching with units first
 	matches := fileSizeRegex.FindStringSubmatch(sizeStr)
-	if len(matches) == 3 { // matches[0] is full string, [1] is value, [2] is unit (K,M,G,T) or empty
+	if len(matches) == 4 { // matches[0] is full string, [1] is value, [2] is unit (K,M,G,T) or empty, [3] is "i" for binary units
 		valueStr := matches[1]
 		unit := strings.ToUpper(matches[2])
+		binary := matches[3] != "" || !decimal
 
 		value, err := strconv.ParseFloat(valueStr, 64)
 		if err != nil {
@@ -65,6 +91,9 @@ func ParseFileSize(sizeStr string) (int64, error) {
 			// Should not happen if regex is correct, as ([KMGT])? captures only those or empty.
 			return 0, fmt.Errorf("unknown size unit prefix '%s' (from '%s'). Supported: K, M, G, T", unit, sizeStr)
 		}
+		if !binary && unit != "" {
+			multiplier = decimalMultipliers[unit]
+		}
 		// Convert float value * multiplier to int64.
 		// Be careful with float precision for very large numbers, but for typical file sizes it's fine.
 		return int64(value * float64(multiplier)), nil
@@ -79,7 +108,46 @@ func ParseFileSize(sizeStr string) (int64, error) {
 		}
 	}
 
-	return 0, fmt.Errorf("invalid file size format: '%s'. Expected format like '1024', '500KB', '0.5MB', '1GB'", sizeStr)
+	return 0, fmt.Errorf("invalid file size format: '%s'. Expected format like '1024', '500KB', '0.5MB', '1GiB'", sizeStr)
+}
+
+// ParseModifiedSince converts a --modified-since value into the cutoff time: an age before now
+// (a Go duration such as "24h" or "90m", or days and weeks such as "7d" or "2w"), an RFC 3339
+// timestamp (e.g. "2024-05-01T12:00:00Z"), or a date (e.g. "2024-05-01", midnight local time).
+func ParseModifiedSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, errors.New("modification time is empty")
+	}
+	if matches := dayDurationRegex.FindStringSubmatch(value); matches != nil {
+		count, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid number of days '%s': %w", matches[1], err)
+		}
+		if strings.EqualFold(matches[2], "w") {
+			count *= 7
+		}
+		return now.AddDate(0, 0, -count), nil
+	}
+	if age, err := time.ParseDuration(value); err == nil {
+		if age < 0 {
+			return time.Time{}, fmt.Errorf("age '%s' must not be negative", value)
+		}
+		return now.Add(-age), nil
+	}
+	if cutoff, err := time.Parse(time.RFC3339, value); err == nil {
+		return cutoff, nil
+	}
+	if cutoff, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return cutoff, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid modification time '%s'. Use an age (e.g., 24h, 7d, 2w), an RFC 3339 timestamp, or a date (YYYY-MM-DD)", value)
+}
+
+// CaseInsensitiveOS reports whether file names are usually case-insensitive on this OS:
+// on Windows and macOS (whose default file systems are case-insensitive).
+func CaseInsensitiveOS() bool {
+	return runtime.GOOS == "windows" || runtime.GOOS == "darwin"
 }
 
 // FormatBytes converts bytes to a human-readable string (e.g., 1.5 MiB).
@@ -97,6 +165,126 @@ func FormatBytes(b uint64) string {
 	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
 }
 
+// CountLines returns the number of lines in the file at path.
+// A final line without a trailing newline is counted as well.
+func CountLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return CountLinesFrom(f)
+}
+
+// CountLinesFrom is CountLines for the content read from r.
+func CountLinesFrom(r io.Reader) (int, error) {
+	buf := make([]byte, 32*1024)
+	count := 0
+	var lastByte byte = '\n'
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			count += bytes.Count(buf[:n], []byte{'\n'})
+			lastByte = buf[n-1]
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return 0, readErr
+		}
+	}
+	if lastByte != '\n' {
+		count++
+	}
+	return count, nil
+}
+
+// LineScanner reads lines like a bufio.Scanner splitting with bufio.ScanLines (without the
+// newline and a carriage return before it), but has no limit on the length of a line.
+type LineScanner struct {
+	reader *bufio.Reader
+	line   string
+	done   bool
+	err    error
+}
+
+func NewLineScanner(r io.Reader) *LineScanner {
+	return &LineScanner{reader: bufio.NewReader(r)}
+}
+
+// Scan advances to the next line, which is then available from Text. It returns false at the
+// end of the input or on a read error (see Err).
+func (s *LineScanner) Scan() bool {
+	if s.done {
+		return false
+	}
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		s.done = true
+		if err != io.EOF {
+			s.err = err
+		}
+		if line == "" {
+			return false // No final line without a trailing newline
+		}
+	}
+	line = strings.TrimSuffix(line, "\n")
+	s.line = strings.TrimSuffix(line, "\r")
+	return true
+}
+
+// Text returns the line read by the last call to Scan.
+func (s *LineScanner) Text() string {
+	return s.line
+}
+
+// Err returns the first read error, or nil if the input was read to its end.
+func (s *LineScanner) Err() error {
+	return s.err
+}
+
+// TextFromArg returns the contents of the file named by arg if it is an existing regular file,
+// and arg itself otherwise. This lets flags accept either inline text or a path to a text file.
+func TextFromArg(arg string) (string, error) {
+	info, err := os.Stat(arg)
+	if err != nil || !info.Mode().IsRegular() {
+		return arg, nil
+	}
+	data, err := os.ReadFile(arg)
+	if err != nil {
+		return "", fmt.Errorf("failed to read text file '%s': %w", arg, err)
+	}
+	return string(data), nil
+}
+
+// ResolveExistingPath resolves the symbolic links in the longest existing prefix of the absolute path
+// absPath and appends the rest of it unchanged, so a path to a file that is yet to be created is
+// resolved through its existing parent directories.
+func ResolveExistingPath(absPath string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(absPath)
+	if err == nil {
+		return resolved, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	parent := filepath.Dir(absPath)
+	if parent == absPath {
+		return absPath, nil
+	}
+	resolvedParent, err := ResolveExistingPath(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolvedParent, filepath.Base(absPath)), nil
+}
+
+// SanitizeUTF8 replaces each run of invalid UTF-8 bytes in s with the Unicode replacement character (U+FFFD).
+func SanitizeUTF8(s string) string {
+	return strings.ToValidUTF8(s, string(utf8.RuneError))
+}
+
 // DummyDirEntry is a helper for creating fs.DirEntry for testing or specific scenarios
 type DummyDirEntry struct {
 	name  string
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestParseFileSize(t *testing.T) {
+	tests := []struct {
+		input       string
+		want        int64
+		wantDecimal int64
+		wantErr     bool
+	}{
+		{input: "1024", want: 1024, wantDecimal: 1024},
+		{input: "10B", want: 10, wantDecimal: 10},
+		{input: "1KiB", want: 1024, wantDecimal: 1024},
+		{input: "1MiB", want: 1048576, wantDecimal: 1048576},
+		{input: "2GiB", want: 2 << 30, wantDecimal: 2 << 30},
+		{input: "1TiB", want: 1 << 40, wantDecimal: 1 << 40},
+		{input: "1KB", want: 1024, wantDecimal: 1000}, // KB stays 1024 unless decimal sizes are requested
+		{input: "1kB", want: 1024, wantDecimal: 1000},
+		{input: "1MB", want: 1048576, wantDecimal: 1000000},
+		{input: "1mb", want: 1048576, wantDecimal: 1000000},
+		{input: "0.5MB", want: 524288, wantDecimal: 500000},
+		{input: "1G", want: 1 << 30, wantDecimal: 1e9},
+		{input: " 500 KB ", want: 512000, wantDecimal: 500000},
+		{input: "", wantErr: true},
+		{input: "MB", wantErr: true},
+		{input: "1XB", wantErr: true},
+		{input: "-1KB", wantErr: true},
+		{input: "1PiB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseFileSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFileSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFileSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+			gotDecimal, err := ParseFileSizeDecimal(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFileSizeDecimal(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if gotDecimal != tt.wantDecimal {
+				t.Errorf("ParseFileSizeDecimal(%q) = %d, want %d", tt.input, gotDecimal, tt.wantDecimal)
+			}
+		})
+	}
+}
+
+func TestLineScanner(t *testing.T) {
+	longLine := strings.Repeat("a", 200_000)
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "empty", input: "", want: nil},
+		{name: "trailing newline", input: "a\nb\n", want: []string{"a", "b"}},
+		{name: "no trailing newline", input: "a\nb", want: []string{"a", "b"}},
+		{name: "crlf", input: "a\r\nb\r\n", want: []string{"a", "b"}},
+		{name: "blank lines", input: "\n\na\n", want: []string{"", "", "a"}},
+		{name: "line longer than 64 KiB", input: "x\n" + longLine + "\ny\n", want: []string{"x", longLine, "y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := NewLineScanner(strings.NewReader(tt.input))
+			var got []string
+			for scanner.Scan() {
+				got = append(got, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatalf("Err() = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %.80q, want %.80q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseModifiedSince(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "7d", want: now.AddDate(0, 0, -7)},
+		{value: "2w", want: now.AddDate(0, 0, -14)},
+		{value: "24h", want: now.Add(-24 * time.Hour)},
+		{value: "90m", want: now.Add(-90 * time.Minute)},
+		{value: "2024-05-01T08:30:00Z", want: time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)},
+		{value: "2024-05-01", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)},
+		{value: "", wantErr: true},
+		{value: "-24h", wantErr: true},
+		{value: "7x", wantErr: true},
+		{value: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseModifiedSince(tt.value, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseModifiedSince(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseModifiedSince(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeUTF8(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "valid", input: "héllo ✓", want: "héllo ✓"},
+		{name: "empty", input: "", want: ""},
+		{name: "invalid byte", input: "a\xffb", want: "a�b"},
+		{name: "run of invalid bytes", input: "a\xff\xfe\xfdb", want: "a�b"},
+		{name: "lone surrogate", input: "x\xed\xa0\x80y", want: "x�y"},
+		{name: "truncated sequence", input: "end\xe2\x9c", want: "end�"},
+		{name: "replacement character is kept", input: "�", want: "�"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeUTF8(tt.input)
+			if got != tt.want {
+				t.Errorf("SanitizeUTF8(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("SanitizeUTF8(%q) = %q, not valid UTF-8", tt.input, got)
+			}
+		})
+	}
+}
+
+func TestResolveExistingPath(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir()) // The temporary directory may itself be behind a link
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(base, "real", "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "real"), filepath.Join(base, "link")); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+	tests := []struct {
+		name string
+		path string // Relative to base
+		want string // Relative to base
+	}{
+		{name: "existing directory", path: "real/sub", want: "real/sub"},
+		{name: "through a link", path: "link/sub", want: "real/sub"},
+		{name: "missing file through a link", path: "link/sub/out.txt", want: "real/sub/out.txt"},
+		{name: "missing directories through a link", path: "link/new/dir/out.txt", want: "real/new/dir/out.txt"},
+		{name: "missing below the base", path: "missing/out.txt", want: "missing/out.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveExistingPath(filepath.Join(base, tt.path))
+			if err != nil {
+				t.Fatalf("ResolveExistingPath() error = %v", err)
+			}
+			if want := filepath.Join(base, tt.want); got != want {
+				t.Errorf("ResolveExistingPath(%s) = %q, want %q", tt.path, got, want)
+			}
+		})
+	}
+}
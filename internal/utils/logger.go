@@ -1,23 +1,44 @@
 package utils
 
 import (
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"strings"
 )
 
 var globalLogger *slog.Logger
 
-// InitLogger initializes or re-initializes the global slog logger.
-func InitLogger(verbose bool) {
-	var level slog.LevelVar // Use LevelVar for dynamic level setting if needed later
-	if verbose {
-		level.Set(slog.LevelDebug)
-	} else {
-		level.Set(slog.LevelInfo)
+// ParseLogLevel validates a --log-level value: debug, info, warn (or warning), or error (case-insensitive).
+func ParseLogLevel(value string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level '%s'. Supported: debug, info, warn, error", value)
 	}
+}
 
+// InitLogger initializes or re-initializes the global slog logger, writing records of at least level to stderr.
+func InitLogger(level slog.Level) {
+	// Using os.Stderr for all logs is common for CLI tools.
+	// Info and Debug could go to Stdout, Warn/Error to Stderr if desired,
+	// but that requires a more complex handler setup.
+	globalLogger = newLogger(os.Stderr, level)
+	slog.SetDefault(globalLogger)
+}
+
+// newLogger returns the CLI's logger, writing records of at least level to w as text without timestamps.
+func newLogger(w io.Writer, level slog.Level) *slog.Logger {
 	opts := &slog.HandlerOptions{
-		Level: level.Level(), // Get current level from LevelVar
+		Level: level,
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
 			if a.Key == slog.TimeKey {
 				// More concise time format or remove if too noisy for CLI
@@ -33,15 +54,10 @@ func InitLogger(verbose bool) {
 			// }
 			return a
 		},
-		// AddSource: verbose, // Optionally add source file and line number if verbose
+		// AddSource: level == slog.LevelDebug, // Optionally add source file and line number if verbose
 	}
 
-	// Using os.Stderr for all logs is common for CLI tools.
-	// Info and Debug could go to Stdout, Warn/Error to Stderr if desired,
-	// but that requires a more complex handler setup.
-	handler := slog.NewTextHandler(os.Stderr, opts)
-	globalLogger = slog.New(handler)
-	slog.SetDefault(globalLogger)
+	return slog.New(slog.NewTextHandler(w, opts))
 }
 
 // GetLogger returns the configured global logger.
@@ -50,7 +66,7 @@ func GetLogger() *slog.Logger {
 	if globalLogger == nil {
 		// Fallback if InitLogger was somehow not called.
 		// This ensures slog.Default() is always set, but Init should be preferred.
-		InitLogger(false) // Default to non-verbose
+		InitLogger(slog.LevelInfo) // Default to non-verbose
 	}
 	return globalLogger
 }
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{input: "debug", want: slog.LevelDebug},
+		{input: "INFO", want: slog.LevelInfo},
+		{input: " warn ", want: slog.LevelWarn},
+		{input: "warning", want: slog.LevelWarn},
+		{input: "Error", want: slog.LevelError},
+		{input: "", wantErr: true},
+		{input: "trace", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseLogLevel(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLogLevel(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseLogLevel(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerLevels(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  []string
+	}{
+		{level: slog.LevelDebug, want: []string{"debug message", "info message", "warn message", "error message"}},
+		{level: slog.LevelInfo, want: []string{"info message", "warn message", "error message"}},
+		{level: slog.LevelWarn, want: []string{"warn message", "error message"}},
+		{level: slog.LevelError, want: []string{"error message"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			var out strings.Builder
+			logger := newLogger(&out, tt.level)
+			logger.Debug("debug message")
+			logger.Info("info message")
+			logger.Warn("warn message")
+			logger.Error("error message")
+
+			var got []string
+			for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+				if strings.Contains(line, "time=") {
+					t.Errorf("record %q has a timestamp", line)
+				}
+				if _, message, ok := strings.Cut(line, "msg=\""); ok {
+					got = append(got, strings.TrimSuffix(message, "\""))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("logged %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
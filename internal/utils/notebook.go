@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// notebookSource is a cell's source, which nbformat stores as a string or as a list of lines.
+type notebookSource string
+
+func (s *notebookSource) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*s = notebookSource(strings.Join(lines, "")) // The lines keep their own newlines
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("cell source is neither a string nor a list of strings: %w", err)
+	}
+	*s = notebookSource(text)
+	return nil
+}
+
+// notebook holds the parts of an nbformat 4 notebook that are rendered; outputs and metadata are ignored.
+type notebook struct {
+	Cells *[]struct {
+		CellType string         `json:"cell_type"`
+		Source   notebookSource `json:"source"`
+	} `json:"cells"`
+}
+
+// RenderNotebook renders the code cells of a Jupyter notebook (nbformat 4) as a script in the
+// "percent" format, each cell starting with a "# %%" line. Outputs (including base64 images),
+// execution counts, and metadata are dropped.
+func RenderNotebook(r io.Reader) (string, error) {
+	return renderNotebook(r, false)
+}
+
+// RenderNotebookWithMarkdown is like RenderNotebook, but also renders the markdown cells,
+// as "# %% [markdown]" cells whose lines are commented out with "# ".
+func RenderNotebookWithMarkdown(r io.Reader) (string, error) {
+	return renderNotebook(r, true)
+}
+
+func renderNotebook(r io.Reader, markdown bool) (string, error) {
+	var nb notebook
+	if err := json.NewDecoder(r).Decode(&nb); err != nil {
+		return "", fmt.Errorf("utils: failed to parse notebook: %w", err)
+	}
+	if nb.Cells == nil {
+		return "", fmt.Errorf("utils: notebook has no cells list (only nbformat 4 is supported)")
+	}
+
+	var out strings.Builder
+	for _, cell := range *nb.Cells {
+		source := strings.TrimRight(string(cell.Source), "\n")
+		switch {
+		case cell.CellType == "code":
+			if out.Len() > 0 {
+				out.WriteString("\n")
+			}
+			out.WriteString("# %%\n")
+			if source != "" {
+				out.WriteString(source + "\n")
+			}
+		case cell.CellType == "markdown" && markdown:
+			if out.Len() > 0 {
+				out.WriteString("\n")
+			}
+			out.WriteString("# %% [markdown]\n")
+			if source == "" {
+				continue
+			}
+			for _, line := range strings.Split(source, "\n") {
+				out.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+			}
+		}
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+const testNotebook = `{
+ "cells": [
+  {"cell_type": "markdown", "metadata": {}, "source": ["# Title\n", "\n", "Some *text*"]},
+  {"cell_type": "code", "execution_count": 1, "metadata": {"scrolled": true}, "source": ["import numpy as np\n", "x = np.arange(3)"],
+   "outputs": [{"output_type": "display_data", "data": {"image/png": "iVBORw0KGgoAAAANSUhEUgAAAAE=", "text/plain": ["<Figure>"]}}]},
+  {"cell_type": "raw", "metadata": {}, "source": "raw cell"},
+  {"cell_type": "code", "execution_count": null, "metadata": {}, "source": "", "outputs": []},
+  {"cell_type": "code", "execution_count": 2, "metadata": {}, "source": "print(x)\n",
+   "outputs": [{"output_type": "stream", "name": "stdout", "text": ["[0 1 2]\n"]}]}
+ ],
+ "metadata": {"kernelspec": {"name": "python3"}},
+ "nbformat": 4,
+ "nbformat_minor": 5
+}`
+
+func TestRenderNotebook(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		markdown bool
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:  "code cells",
+			input: testNotebook,
+			want:  "# %%\nimport numpy as np\nx = np.arange(3)\n\n# %%\n\n# %%\nprint(x)\n",
+		},
+		{
+			name:     "with markdown",
+			input:    testNotebook,
+			markdown: true,
+			want:     "# %% [markdown]\n# # Title\n#\n# Some *text*\n\n# %%\nimport numpy as np\nx = np.arange(3)\n\n# %%\n\n# %%\nprint(x)\n",
+		},
+		{name: "no cells", input: `{"cells": [], "nbformat": 4}`, want: ""},
+		{name: "not JSON", input: "print('hi')", wantErr: true},
+		{name: "nbformat 3", input: `{"worksheets": [], "nbformat": 3}`, wantErr: true},
+		{name: "invalid source", input: `{"cells": [{"cell_type": "code", "source": 42}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			render := RenderNotebook
+			if tt.markdown {
+				render = RenderNotebookWithMarkdown
+			}
+			got, err := render(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("render error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("render =\n%q\nwant\n%q", got, tt.want)
+			}
+			for _, dropped := range []string{"iVBORw0KGgo", "[0 1 2]", "execution_count", "kernelspec", "raw cell"} {
+				if strings.Contains(got, dropped) {
+					t.Errorf("render output contains %q", dropped)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"math"
+	"slices"
+)
+
+// outlierFenceFactor is k in the fence Q3 + k*IQR; 3 is Tukey's fence for "far out" values,
+// so only sizes that stand out clearly from the distribution are outliers.
+const outlierFenceFactor = 3
+
+// minOutlierSamples is the fewest sizes quartiles are computed from.
+const minOutlierSamples = 4
+
+// OutlierThreshold returns the size above which a file is an outlier among sizes: the upper
+// quartile plus 3 times the interquartile range. With fewer than 4 sizes, there is no meaningful
+// spread and math.MaxInt64 is returned, so nothing is an outlier.
+func OutlierThreshold(sizes []int64) int64 {
+	if len(sizes) < minOutlierSamples {
+		return math.MaxInt64
+	}
+	sorted := slices.Clone(sizes)
+	slices.Sort(sorted)
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	fence := q3 + outlierFenceFactor*(q3-q1)
+	if fence >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(math.Floor(fence))
+}
+
+// quantile returns the q-quantile of the sorted values, interpolating linearly between ranks.
+func quantile(sorted []int64, q float64) float64 {
+	rank := q * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	if lower+1 >= len(sorted) {
+		return float64(sorted[lower])
+	}
+	fraction := rank - float64(lower)
+	return float64(sorted[lower]) + fraction*float64(sorted[lower+1]-sorted[lower])
+}
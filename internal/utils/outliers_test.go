@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestOutlierThreshold(t *testing.T) {
+	tests := []struct {
+		name  string
+		sizes []int64
+		want  int64
+	}{
+		{name: "no sizes", sizes: nil, want: math.MaxInt64},
+		{name: "too few sizes", sizes: []int64{1, 2, 1_000_000}, want: math.MaxInt64},
+		{name: "interpolated quartiles", sizes: []int64{1, 2, 3, 4}, want: 7},                          // Q1 1.75, Q3 3.25: 3.25 + 3*1.5
+		{name: "unsorted", sizes: []int64{4, 1, 3, 2}, want: 7},                                        // Same sizes in another order
+		{name: "no spread", sizes: []int64{10, 10, 10, 10}, want: 10},                                  // Anything bigger is an outlier
+		{name: "exact quartiles", sizes: []int64{0, 10, 20, 30, 40}, want: 90},                         // Q1 10, Q3 30: 30 + 3*20
+		{name: "one huge file", sizes: []int64{100, 110, 90, 100, 105, 95, 100, 1_000_000}, want: 128}, // Q1 98.75, Q3 106.25: floor(106.25 + 3*7.5)
+		{name: "overflowing fence", sizes: []int64{0, 0, math.MaxInt64 / 2, math.MaxInt64}, want: math.MaxInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := slices.Clone(tt.sizes)
+			if got := OutlierThreshold(tt.sizes); got != tt.want {
+				t.Errorf("OutlierThreshold(%v) = %d, want %d", tt.sizes, got, tt.want)
+			}
+			if !slices.Equal(tt.sizes, original) {
+				t.Errorf("OutlierThreshold() reordered its argument to %v", tt.sizes)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProgressInterval is the minimum time between two progress updates.
+const DefaultProgressInterval = 250 * time.Millisecond
+
+// Progress writes throttled, single-line status updates (e.g. "Scanned 1200 files...").
+// On a terminal each update overwrites the previous one using a carriage return;
+// otherwise every emitted update is written on its own line.
+// All methods are safe to call on a nil *Progress, which disables output.
+type Progress struct {
+	mu       sync.Mutex
+	w        io.Writer
+	isTTY    bool
+	interval time.Duration
+	now      func() time.Time // Clock, replaceable for deterministic throttling
+	last     time.Time        // Time of the last emitted update
+	lastLen  int              // Length of the last line written on a terminal, for clearing
+}
+
+// NewProgress creates a Progress writing to w at most once per interval.
+func NewProgress(w io.Writer, isTTY bool, interval time.Duration) *Progress {
+	return &Progress{
+		w:        w,
+		isTTY:    isTTY,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Update emits a status line unless the previous one was written less than the interval ago.
+// It reports whether the line was emitted.
+func (p *Progress) Update(format string, args ...any) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if !p.last.IsZero() && now.Sub(p.last) < p.interval {
+		return false
+	}
+	p.last = now
+	p.writeLine(fmt.Sprintf(format, args...))
+	return true
+}
+
+// Done writes a final status line regardless of throttling and terminates it with a newline.
+func (p *Progress) Done(format string, args ...any) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.writeLine(fmt.Sprintf(format, args...))
+	if p.isTTY {
+		fmt.Fprintln(p.w)
+		p.lastLen = 0
+	}
+}
+
+// writeLine must be called with p.mu held.
+func (p *Progress) writeLine(line string) {
+	if !p.isTTY {
+		fmt.Fprintln(p.w, line)
+		return
+	}
+	padding := ""
+	if len(line) < p.lastLen {
+		padding = strings.Repeat(" ", p.lastLen-len(line)) // Clear leftovers of a longer previous line
+	}
+	fmt.Fprintf(p.w, "\r%s%s", line, padding)
+	p.lastLen = len(line)
+}
+
+// IsTerminal reports whether f refers to a character device such as an interactive terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
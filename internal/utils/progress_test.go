@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestProgressThrottling(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		offsets []time.Duration // Times of the updates, relative to start
+		want    []bool
+	}{
+		{name: "first update is emitted", offsets: []time.Duration{0}, want: []bool{true}},
+		{name: "updates within the interval are dropped", offsets: []time.Duration{0, 100 * time.Millisecond, 249 * time.Millisecond}, want: []bool{true, false, false}},
+		{name: "update at the interval is emitted", offsets: []time.Duration{0, 250 * time.Millisecond}, want: []bool{true, true}},
+		{name: "interval counts from the last emitted update", offsets: []time.Duration{0, 200 * time.Millisecond, 300 * time.Millisecond, 450 * time.Millisecond, 500 * time.Millisecond}, want: []bool{true, false, true, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := NewProgress(&out, false, DefaultProgressInterval)
+			for i, offset := range tt.offsets {
+				p.now = func() time.Time { return start.Add(offset) }
+				if got := p.Update("update %d", i); got != tt.want[i] {
+					t.Errorf("Update() at %v = %v, want %v", offset, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestProgressOutput(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		isTTY bool
+		want  string
+	}{
+		{name: "terminal", isTTY: true, want: "\rScanned 1000 files\rScanned 2 files   \rDone: 3 files  \n"},
+		{name: "not a terminal", isTTY: false, want: "Scanned 1000 files\nScanned 2 files\nDone: 3 files\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := NewProgress(&out, tt.isTTY, time.Second)
+			p.now = func() time.Time { return clock }
+			p.Update("Scanned %d files", 1000)
+			clock = clock.Add(time.Second)
+			p.Update("Scanned %d files", 2)
+			p.Update("dropped") // Throttled
+			p.Done("Done: %d files", 3)
+			if got := out.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilProgress(t *testing.T) {
+	var p *Progress
+	if p.Update("x") {
+		t.Error("Update() on a nil Progress = true, want false")
+	}
+	p.Done("x") // Must not panic
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenCounter counts the tokens a language model would see for a piece of text.
+type TokenCounter interface {
+	Count(text string) int
+	Name() string // Short description for summaries, e.g. "cl100k_base"
+}
+
+// HeuristicTokenCounter estimates one token per four bytes of text (rounded up).
+// It is used when no tokenizer is selected.
+type HeuristicTokenCounter struct{}
+
+func (HeuristicTokenCounter) Count(text string) int { return (len(text) + 3) / 4 }
+
+func (HeuristicTokenCounter) Name() string { return "estimate (bytes/4)" }
+
+// bpePreTokenRegex splits text into the pieces that are encoded independently, following the
+// cl100k_base pattern. Go's regexp has no lookahead, so the "\s+(?!\S)" alternative is emulated
+// in BPETokenCounter.Count by giving back the last whitespace character before a non-space.
+var bpePreTokenRegex = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// BPETokenCounter counts tokens with byte-pair encoding using the merge ranks of a tiktoken
+// vocabulary such as cl100k_base.
+type BPETokenCounter struct {
+	name  string
+	ranks map[string]int // Token bytes -> merge rank (lower merges first)
+}
+
+// NewBPETokenCounter creates a counter from token ranks, keyed by the token's bytes.
+func NewBPETokenCounter(name string, ranks map[string]int) *BPETokenCounter {
+	return &BPETokenCounter{name: name, ranks: ranks}
+}
+
+// LoadBPETokenCounter reads a tiktoken ranks file ("<base64 token> <rank>" per line),
+// e.g. cl100k_base.tiktoken. The counter is named after the file.
+func LoadBPETokenCounter(path string) (*BPETokenCounter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tokenizer file '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid tokenizer file '%s': line %d: expected \"<base64 token> <rank>\"", path, lineNum)
+		}
+		token, decodeErr := base64.StdEncoding.DecodeString(fields[0])
+		if decodeErr != nil {
+			return nil, fmt.Errorf("invalid tokenizer file '%s': line %d: %w", path, lineNum, decodeErr)
+		}
+		rank, rankErr := strconv.Atoi(fields[1])
+		if rankErr != nil {
+			return nil, fmt.Errorf("invalid tokenizer file '%s': line %d: %w", path, lineNum, rankErr)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tokenizer file '%s': %w", path, err)
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("invalid tokenizer file '%s': no tokens found", path)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return NewBPETokenCounter(name, ranks), nil
+}
+
+func (c *BPETokenCounter) Name() string { return c.name }
+
+// Count splits text into pre-tokens and sums the number of BPE tokens of each.
+func (c *BPETokenCounter) Count(text string) int {
+	count := 0
+	for len(text) > 0 {
+		loc := bpePreTokenRegex.FindStringIndex(text)
+		if loc == nil {
+			break
+		}
+		end := loc[1]
+		piece := text[loc[0]:end]
+		// Emulate "\s+(?!\S)": a whitespace run directly followed by a non-space gives up its last
+		// character, which is then matched as part of the next piece (e.g. " word").
+		if end < len(text) && isAllSpace(piece) && utf8.RuneCountInString(piece) > 1 {
+			if next, _ := utf8.DecodeRuneInString(text[end:]); !unicode.IsSpace(next) {
+				_, lastSize := utf8.DecodeLastRuneInString(piece)
+				end -= lastSize
+				piece = text[loc[0]:end]
+			}
+		}
+		count += c.countPiece(piece)
+		text = text[end:]
+	}
+	return count
+}
+
+// countPiece merges the bytes of piece pairwise, lowest rank first, until no known pair remains.
+func (c *BPETokenCounter) countPiece(piece string) int {
+	if _, ok := c.ranks[piece]; ok {
+		return 1
+	}
+	// bounds[i] is the start offset of the i-th part; the last entry is len(piece).
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		bestRank, bestIdx := -1, -1
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := c.ranks[piece[bounds[i]:bounds[i+2]]]; ok && (bestRank < 0 || rank < bestRank) {
+				bestRank, bestIdx = rank, i
+			}
+		}
+		if bestIdx < 0 {
+			break
+		}
+		bounds = append(bounds[:bestIdx+1], bounds[bestIdx+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+func isAllSpace(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewTokenCounter returns the heuristic counter for an empty spec, and otherwise
+// a BPE counter loaded from the tiktoken ranks file at spec.
+func NewTokenCounter(spec string) (TokenCounter, error) {
+	if spec == "" {
+		return HeuristicTokenCounter{}, nil
+	}
+	return LoadBPETokenCounter(spec)
+}
@@ -0,0 +1,46 @@
+package utils
+
+import "testing"
+
+// testRanks is a tiny BPE vocabulary: every piece not covered by it counts one token per byte.
+var testRanks = map[string]int{"he": 1, "ll": 2, "llo": 3, "hello": 4, " w": 5, "or": 6, " wor": 7, "ld": 8}
+
+func TestBPETokenCounterCount(t *testing.T) {
+	counter := NewBPETokenCounter("test", testRanks)
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "hello", want: 1},       // A known token as a whole
+		{text: "hi", want: 2},          // No merges: one token per byte
+		{text: "hello world", want: 3}, // "hello" + " wor" + "ld"
+		{text: "  hello", want: 3},     // The space run gives its last space to the word: " " + " " + "hello"
+		{text: "hello\nhello", want: 3},
+	}
+	for _, tt := range tests {
+		if got := counter.Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+	if got := counter.Name(); got != "test" {
+		t.Errorf("Name() = %q, want %q", got, "test")
+	}
+}
+
+func TestHeuristicTokenCounterCount(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "abcd", want: 1},
+		{text: "abcde", want: 2},
+		{text: "hello world!", want: 3},
+	}
+	for _, tt := range tests {
+		if got := (HeuristicTokenCounter{}).Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"log/slog"
+
 	"github.com/alexferrari88/code2context/cmd"
 	"github.com/alexferrari88/code2context/internal/utils"
 )
@@ -12,5 +14,5 @@ func main() {
 // Initialize global logger
 func init() {
 	// Default to non-verbose. Cobra PersistentPreRun will set it based on flag.
-	utils.InitLogger(false)
+	utils.InitLogger(slog.LevelInfo)
 }